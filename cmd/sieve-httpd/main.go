@@ -0,0 +1,45 @@
+// Command sieve-httpd serves a sharded SIEVE cache over a minimal HTTP API,
+// for quick deployments and debugging with curl.
+//
+//	PUT    /keys/{key}       stores the request body as the value of key
+//	GET    /keys/{key}       returns the value of key
+//	DELETE /keys/{key}       removes key
+//	GET    /keys?prefix=...  lists the keys starting with a prefix, as JSON
+//	GET    /stats            returns cache statistics, as JSON
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/jedisct1/go-sieve-cache/pkg/sievecache"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:8080", "address to listen on")
+	capacity := flag.Int("capacity", 100000, "maximum number of entries")
+	shards := flag.Int("shards", 0, "number of shards (0 to derive it from GOMAXPROCS)")
+	maxValueSize := flag.Int64("max-value-size", 1<<20, "maximum size of a value, in bytes")
+	flag.Parse()
+
+	var cache *sievecache.ShardedSieveCache[string, []byte]
+	var err error
+	if *shards > 0 {
+		cache, err = sievecache.NewShardedWithShards[string, []byte](*capacity, *shards)
+	} else {
+		cache, err = sievecache.NewSharded[string, []byte](*capacity)
+	}
+	if err != nil {
+		log.Fatalf("Error creating cache: %v", err)
+	}
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           newServer(cache, *maxValueSize),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	log.Printf("Serving a cache of %d entries on %s", cache.Capacity(), *addr)
+	log.Fatal(server.ListenAndServe())
+}
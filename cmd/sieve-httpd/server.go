@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/jedisct1/go-sieve-cache/pkg/sievecache"
+)
+
+// server implements the HTTP API on top of a sharded cache.
+type server struct {
+	cache        *sievecache.ShardedSieveCache[string, []byte]
+	maxValueSize int64
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// stats is the response of /stats.
+type stats struct {
+	Capacity int    `json:"capacity"`
+	Len      int    `json:"len"`
+	Shards   int    `json:"shards"`
+	Hits     uint64 `json:"hits"`
+	Misses   uint64 `json:"misses"`
+}
+
+// newServer returns the HTTP handler serving cache.
+func newServer(cache *sievecache.ShardedSieveCache[string, []byte], maxValueSize int64) http.Handler {
+	s := &server{cache: cache, maxValueSize: maxValueSize}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/keys", s.handleList)
+	mux.HandleFunc("/keys/", s.handleKey)
+	mux.HandleFunc("/stats", s.handleStats)
+	return mux
+}
+
+// handleKey serves GET, PUT and DELETE /keys/{key}.
+func (s *server) handleKey(w http.ResponseWriter, r *http.Request) {
+	key, err := url.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), "/keys/"))
+	if err != nil || key == "" {
+		http.Error(w, "invalid key", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		value, found := s.cache.Get(key)
+		if !found {
+			s.misses.Add(1)
+			http.NotFound(w, r)
+			return
+		}
+		s.hits.Add(1)
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(value)
+
+	case http.MethodPut:
+		value, err := io.ReadAll(http.MaxBytesReader(w, r.Body, s.maxValueSize))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, "value too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "error reading value", http.StatusBadRequest)
+			return
+		}
+		if s.cache.Insert(key, value) {
+			w.WriteHeader(http.StatusCreated)
+		} else {
+			w.WriteHeader(http.StatusNoContent)
+		}
+
+	case http.MethodDelete:
+		if _, found := s.cache.Remove(key); !found {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleList serves GET /keys?prefix=..., returning the sorted matching keys.
+func (s *server) handleList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	prefix := r.URL.Query().Get("prefix")
+	keys := []string{}
+	for _, key := range s.cache.Keys() {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	writeJSON(w, keys)
+}
+
+// handleStats serves GET /stats.
+func (s *server) handleStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, stats{
+		Capacity: s.cache.Capacity(),
+		Len:      s.cache.Len(),
+		Shards:   s.cache.NumShards(),
+		Hits:     s.hits.Load(),
+		Misses:   s.misses.Load(),
+	})
+}
+
+// writeJSON writes v as a JSON response.
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jedisct1/go-sieve-cache/pkg/sievecache"
+)
+
+func TestServer(t *testing.T) {
+	cache, _ := sievecache.NewShardedWithShards[string, []byte](100, 4)
+	ts := httptest.NewServer(newServer(cache, 16))
+	defer ts.Close()
+
+	do := func(method, path, body string) (int, string) {
+		req, _ := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(data)
+	}
+
+	if status, _ := do("PUT", "/keys/user%2F1", "alice"); status != http.StatusCreated {
+		t.Errorf("Expected 201 for a new key, got %d", status)
+	}
+	if status, _ := do("PUT", "/keys/user%2F1", "alice2"); status != http.StatusNoContent {
+		t.Errorf("Expected 204 for an update, got %d", status)
+	}
+	do("PUT", "/keys/user%2F2", "bob")
+	do("PUT", "/keys/other", "x")
+	if status, body := do("GET", "/keys/user%2F1", ""); status != http.StatusOK || body != "alice2" {
+		t.Errorf("Expected alice2, got %d %q", status, body)
+	}
+	if status, _ := do("PUT", "/keys/big", strings.Repeat("x", 17)); status != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 for a large value, got %d", status)
+	}
+
+	_, body := do("GET", "/keys?prefix=user/", "")
+	var keys []string
+	if err := json.Unmarshal([]byte(body), &keys); err != nil || len(keys) != 2 || keys[0] != "user/1" || keys[1] != "user/2" {
+		t.Errorf("Unexpected key list %q", body)
+	}
+
+	if status, _ := do("DELETE", "/keys/user%2F2", ""); status != http.StatusNoContent {
+		t.Errorf("Expected 204 for a deletion, got %d", status)
+	}
+	if status, _ := do("DELETE", "/keys/user%2F2", ""); status != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing key, got %d", status)
+	}
+	if status, _ := do("GET", "/keys/user%2F2", ""); status != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing key, got %d", status)
+	}
+	if status, _ := do("POST", "/keys/x", ""); status != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405, got %d", status)
+	}
+
+	_, body = do("GET", "/stats", "")
+	var s stats
+	if err := json.Unmarshal([]byte(body), &s); err != nil {
+		t.Fatalf("Invalid stats %q: %v", body, err)
+	}
+	if s.Len != 2 || s.Capacity != 100 || s.Shards != 4 || s.Hits != 1 || s.Misses != 1 {
+		t.Errorf("Unexpected stats %+v", s)
+	}
+}
@@ -0,0 +1,367 @@
+package bitset
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/bits"
+	"sync/atomic"
+	"unsafe"
+)
+
+// BitSet provides a memory-efficient way to store boolean values
+// using 1 bit per value instead of 1 byte per value.
+type BitSet struct {
+	bits []uint64
+	size int
+}
+
+// New creates a new bit set of the given size, with all bits unset.
+func New(size int) *BitSet {
+	// Calculate how many uint64s we need to store size bits
+	numWords := (size + 63) / 64
+	return &BitSet{
+		bits: make([]uint64, numWords),
+		size: size,
+	}
+}
+
+// WithCapacity creates an empty bit set with storage preallocated for
+// capacity bits.
+func WithCapacity(capacity int) *BitSet {
+	return &BitSet{
+		bits: make([]uint64, 0, (capacity+63)>>6),
+	}
+}
+
+// Set sets the bit at the given index to the specified value.
+func (b *BitSet) Set(index int, value bool) {
+	if index >= b.size {
+		b.resize(index + 1)
+	}
+
+	wordIndex := index >> 6  // Equivalent to index / 64
+	bitIndex := index & 0x3F // Equivalent to index % 64
+
+	if value {
+		b.bits[wordIndex] |= 1 << bitIndex
+	} else {
+		b.bits[wordIndex] &= ^(1 << bitIndex)
+	}
+}
+
+// Get returns the value of the bit at the given index.
+func (b *BitSet) Get(index int) bool {
+	if index >= b.size {
+		return false
+	}
+
+	wordIndex := index >> 6  // Equivalent to index / 64
+	bitIndex := index & 0x3F // Equivalent to index % 64
+
+	return (b.bits[wordIndex] & (1 << bitIndex)) != 0
+}
+
+// resize increases the capacity of the bit set to at least the specified size.
+func (b *BitSet) resize(newSize int) {
+	if newSize <= b.size {
+		return
+	}
+
+	// Calculate new number of words needed using bit shifting
+	numWords := (newSize + 63) >> 6 // Equivalent to (newSize + 63) / 64
+
+	// If we need more words, extend the slice
+	if numWords > cap(b.bits) {
+		// Apply capacity growth strategy similar to Go slices
+		newCap := len(b.bits)
+		if newCap < 4 {
+			newCap = 4
+		}
+		for newCap < numWords {
+			newCap += newCap >> 1 // Grow by 50%
+		}
+
+		newBits := make([]uint64, numWords, newCap)
+		copy(newBits, b.bits)
+		b.bits = newBits
+	} else if numWords > len(b.bits) {
+		// Reuse the spare capacity, clearing words left over by Truncate
+		oldWords := len(b.bits)
+		b.bits = b.bits[:numWords]
+		clear(b.bits[oldWords:])
+	}
+
+	b.size = newSize
+}
+
+// Append adds a new bit to the end of the set.
+func (b *BitSet) Append(value bool) {
+	b.Set(b.size, value)
+}
+
+// Truncate reduces the size of the bit set to the specified size.
+func (b *BitSet) Truncate(newSize int) {
+	if newSize >= b.size {
+		return
+	}
+
+	// Calculate new number of words needed using bit shifting
+	numWords := (newSize + 63) >> 6 // Equivalent to (newSize + 63) / 64
+
+	// Clear any bits in the last word that are beyond the new size
+	if numWords > 0 {
+		lastWordBits := newSize & 0x3F // Equivalent to newSize % 64
+		if lastWordBits > 0 {
+			// Create a mask for the bits we want to keep
+			mask := (uint64(1) << lastWordBits) - 1
+			// Apply the mask to the last word
+			b.bits[numWords-1] &= mask
+		}
+	}
+
+	// If we need fewer words, truncate the slice
+	if numWords < len(b.bits) {
+		b.bits = b.bits[:numWords]
+	}
+
+	b.size = newSize
+}
+
+// SetAll sets all bits in the set to true.
+func (b *BitSet) SetAll() {
+	b.SetRange(0, b.size, true)
+}
+
+// ClearAll sets all bits in the set to false.
+func (b *BitSet) ClearAll() {
+	clear(b.bits)
+}
+
+// SetRange sets the bits in the range [from, to) to the specified value,
+// operating on whole words where possible.
+// The set grows if to is larger than its size.
+func (b *BitSet) SetRange(from, to int, value bool) {
+	if from < 0 {
+		from = 0
+	}
+	if from >= to {
+		return
+	}
+	if to > b.size {
+		b.resize(to)
+	}
+
+	firstWord := from >> 6
+	lastWord := (to - 1) >> 6
+	for w := firstWord; w <= lastWord; w++ {
+		// Mask of the bits of this word that are within the range
+		mask := ^uint64(0)
+		if w == firstWord {
+			mask &= ^uint64(0) << (from & 0x3F)
+		}
+		if w == lastWord {
+			mask &= ^uint64(0) >> (63 - ((to - 1) & 0x3F))
+		}
+
+		if value {
+			b.bits[w] |= mask
+		} else {
+			b.bits[w] &= ^mask
+		}
+	}
+}
+
+// NextSet returns the index of the first bit set to true at or after from,
+// or -1 if there is none. Whole words of unset bits are skipped at once.
+func (b *BitSet) NextSet(from int) int {
+	if from < 0 {
+		from = 0
+	}
+	if from >= b.size {
+		return -1
+	}
+
+	wordIndex := from >> 6
+	// Ignore the bits before from in the first word
+	word := b.bits[wordIndex] & (^uint64(0) << (from & 0x3F))
+	for {
+		if word != 0 {
+			index := wordIndex<<6 + bits.TrailingZeros64(word)
+			if index >= b.size {
+				return -1
+			}
+			return index
+		}
+		wordIndex++
+		if wordIndex >= len(b.bits) {
+			return -1
+		}
+		word = b.bits[wordIndex]
+	}
+}
+
+// ClearDown scans the bits from index from down to index stop (inclusive),
+// clearing set bits until an unset bit is found, and returns its index.
+// If every bit in the range was set, they are all cleared and -1 is returned.
+// Whole words are processed at once. This is how the SIEVE hand looks for
+// an entry to evict, clearing the visited flags it passes.
+func (b *BitSet) ClearDown(from, stop int) int {
+	for from >= stop {
+		wordIndex := from >> 6
+		// Mask of the bits of this word that are within [stop, from]
+		mask := ^uint64(0) >> (63 - (from & 0x3F))
+		if wordIndex == stop>>6 {
+			mask &= ^uint64(0) << (stop & 0x3F)
+		}
+
+		if unset := ^b.bits[wordIndex] & mask; unset != 0 {
+			index := 63 - bits.LeadingZeros64(unset)
+			// Only clear the bits that were scanned before the unset one
+			b.bits[wordIndex] &= ^(mask & (^uint64(0) << (index + 1)))
+			return wordIndex<<6 + index
+		}
+		b.bits[wordIndex] &= ^mask
+		from = wordIndex<<6 - 1
+	}
+	return -1
+}
+
+// SetAtomic sets the bit at the given index, which must be lower than the
+// size, with an atomic operation. It can be called concurrently with other
+// calls to SetAtomic and with LoadWord, Clone, CountSetBits and
+// MarshalBinary, but not with any other method. The word isn't written if the bit is already set, so that
+// concurrent readers of a hot entry don't contend on its cache line.
+func (b *BitSet) SetAtomic(index int) {
+	word := &b.bits[index>>6]
+	mask := uint64(1) << (index & 0x3F)
+	for {
+		old := atomic.LoadUint64(word)
+		if old&mask != 0 || atomic.CompareAndSwapUint64(word, old, old|mask) {
+			return
+		}
+	}
+}
+
+// LoadWord atomically loads the word holding bits 64*wordIndex to
+// 64*wordIndex+63, so that it can be read concurrently with SetAtomic.
+// The word index must be lower than (Size()+63)/64.
+func (b *BitSet) LoadWord(wordIndex int) uint64 {
+	return atomic.LoadUint64(&b.bits[wordIndex])
+}
+
+// Clone returns an independent copy of the bit set.
+// It can be called concurrently with SetAtomic.
+func (b *BitSet) Clone() *BitSet {
+	bits := make([]uint64, len(b.bits), cap(b.bits))
+	for i := range b.bits {
+		bits[i] = b.LoadWord(i)
+	}
+	return &BitSet{
+		bits: bits,
+		size: b.size,
+	}
+}
+
+// Size returns the number of bits in the set.
+func (b *BitSet) Size() int {
+	return b.size
+}
+
+// CountSetBits returns the number of bits that are set to true.
+// It can be called concurrently with SetAtomic.
+func (b *BitSet) CountSetBits() int {
+	var count int
+	for i := range b.bits {
+		count += bits.OnesCount64(b.LoadWord(i))
+	}
+	return count
+}
+
+// Clip releases the storage reserved beyond the current size.
+func (b *BitSet) Clip() {
+	b.bits = append([]uint64(nil), b.bits...)
+}
+
+// EstimatedMemory returns the number of heap bytes used by the bit set,
+// including the storage reserved beyond its size.
+func (b *BitSet) EstimatedMemory() int64 {
+	return int64(unsafe.Sizeof(*b)) + int64(cap(b.bits))*8
+}
+
+// ForEachSet calls f with the index of every bit set to true, in increasing
+// order, until f returns false. Whole words of unset bits are skipped at once.
+func (b *BitSet) ForEachSet(f func(index int) bool) {
+	for w, word := range b.bits {
+		for word != 0 {
+			if !f(w<<6 + bits.TrailingZeros64(word)) {
+				return
+			}
+			word &= word - 1
+		}
+	}
+}
+
+// And clears the bits that are not set in other. Bits beyond the size of
+// other are cleared, and the size is unchanged.
+func (b *BitSet) And(other *BitSet) {
+	n := min(len(b.bits), len(other.bits))
+	for i := 0; i < n; i++ {
+		b.bits[i] &= other.bits[i]
+	}
+	clear(b.bits[n:])
+}
+
+// Or sets the bits that are set in other, growing the set to the size of
+// other if it is larger.
+func (b *BitSet) Or(other *BitSet) {
+	b.resize(other.size)
+	for i, word := range other.bits {
+		b.bits[i] |= word
+	}
+}
+
+// AndNot clears the bits that are set in other. The size is unchanged.
+func (b *BitSet) AndNot(other *BitSet) {
+	n := min(len(b.bits), len(other.bits))
+	for i := 0; i < n; i++ {
+		b.bits[i] &^= other.bits[i]
+	}
+}
+
+// MarshalBinary encodes the bit set as its size, as a little-endian 64-bit
+// integer, followed by its words, least significant bits first, as
+// little-endian 64-bit integers. It can be called concurrently with
+// SetAtomic.
+func (b *BitSet) MarshalBinary() ([]byte, error) {
+	data := make([]byte, 8+8*len(b.bits))
+	binary.LittleEndian.PutUint64(data, uint64(b.size))
+	for i := range b.bits {
+		binary.LittleEndian.PutUint64(data[8+8*i:], b.LoadWord(i))
+	}
+	return data, nil
+}
+
+// UnmarshalBinary replaces the bit set with one encoded by MarshalBinary.
+// Returns an error, leaving the bit set unchanged, if data isn't a valid
+// encoding.
+func (b *BitSet) UnmarshalBinary(data []byte) error {
+	if len(data) < 8 {
+		return errors.New("bitset: truncated data")
+	}
+	size := binary.LittleEndian.Uint64(data)
+	numWords := (size + 63) >> 6
+	if size > math.MaxInt || uint64(len(data)-8)/8 != numWords || len(data)%8 != 0 {
+		return errors.New("bitset: invalid data length")
+	}
+	words := make([]uint64, numWords)
+	for i := range words {
+		words[i] = binary.LittleEndian.Uint64(data[8+8*i:])
+	}
+	if size&0x3F != 0 && words[numWords-1]>>(size&0x3F) != 0 {
+		return errors.New("bitset: bits set beyond the size")
+	}
+	b.bits = words
+	b.size = int(size)
+	return nil
+}
@@ -0,0 +1,232 @@
+package bitset
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestBitSetBasics(t *testing.T) {
+	b := New(0)
+	for i := 0; i < 200; i++ {
+		b.Append(i%3 == 0)
+	}
+	if b.Size() != 200 {
+		t.Errorf("Expected size 200, got %d", b.Size())
+	}
+	if b.CountSetBits() != 67 {
+		t.Errorf("Expected 67 set bits, got %d", b.CountSetBits())
+	}
+
+	b.Truncate(100)
+	if b.Size() != 100 || b.CountSetBits() != 34 {
+		t.Errorf("Expected size 100 with 34 set bits, got %d and %d", b.Size(), b.CountSetBits())
+	}
+	if b.Get(150) {
+		t.Error("Expected bits beyond the size to be unset")
+	}
+}
+
+func TestBitSetBulkOperations(t *testing.T) {
+	b := New(130)
+
+	b.SetAll()
+	if b.CountSetBits() != 130 {
+		t.Errorf("Expected 130 set bits, got %d", b.CountSetBits())
+	}
+	b.ClearAll()
+	if b.CountSetBits() != 0 {
+		t.Errorf("Expected 0 set bits, got %d", b.CountSetBits())
+	}
+
+	// Ranges within a word, across words, and at word boundaries
+	ranges := [][2]int{{3, 9}, {60, 70}, {64, 128}, {0, 130}, {129, 130}, {5, 5}}
+	for _, r := range ranges {
+		b.ClearAll()
+		b.SetRange(r[0], r[1], true)
+		for i := 0; i < 130; i++ {
+			want := i >= r[0] && i < r[1]
+			if b.Get(i) != want {
+				t.Fatalf("Range %v: bit %d is %v", r, i, b.Get(i))
+			}
+		}
+
+		b.SetAll()
+		b.SetRange(r[0], r[1], false)
+		for i := 0; i < 130; i++ {
+			want := i < r[0] || i >= r[1]
+			if b.Get(i) != want {
+				t.Fatalf("Cleared range %v: bit %d is %v", r, i, b.Get(i))
+			}
+		}
+	}
+
+	// SetRange grows the set if needed
+	b.SetRange(200, 210, true)
+	if b.Size() != 210 || !b.Get(209) {
+		t.Errorf("Expected set to grow to 210 bits, got %d", b.Size())
+	}
+}
+
+func TestBitSetNextSet(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	b := New(0)
+	for i := 0; i < 1000; i++ {
+		b.Append(rng.Intn(20) == 0)
+	}
+
+	// Walking with NextSet finds exactly the set bits
+	var found []int
+	for i := b.NextSet(0); i >= 0; i = b.NextSet(i + 1) {
+		found = append(found, i)
+	}
+	var expected []int
+	for i := 0; i < 1000; i++ {
+		if b.Get(i) {
+			expected = append(expected, i)
+		}
+	}
+	if len(found) != len(expected) {
+		t.Fatalf("Expected %d set bits, found %d", len(expected), len(found))
+	}
+	for i := range found {
+		if found[i] != expected[i] {
+			t.Fatalf("Expected set bit %d, found %d", expected[i], found[i])
+		}
+	}
+
+	if New(100).NextSet(0) != -1 {
+		t.Error("Expected -1 for an empty set")
+	}
+	if b.NextSet(1000) != -1 || b.NextSet(5000) != -1 {
+		t.Error("Expected -1 when starting beyond the size")
+	}
+}
+
+func TestBitSetClearDown(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for iter := 0; iter < 1000; iter++ {
+		size := 1 + rng.Intn(300)
+		b := New(0)
+		expected := make([]bool, size)
+		for i := 0; i < size; i++ {
+			v := rng.Intn(10) != 0
+			b.Append(v)
+			expected[i] = v
+		}
+		from := rng.Intn(size)
+		stop := rng.Intn(from + 1)
+
+		// Reference: clear set bits one at a time until an unset one is found
+		want := -1
+		for i := from; i >= stop; i-- {
+			if !expected[i] {
+				want = i
+				break
+			}
+			expected[i] = false
+		}
+
+		if got := b.ClearDown(from, stop); got != want {
+			t.Fatalf("ClearDown(%d, %d): expected %d, got %d", from, stop, want, got)
+		}
+		for i := 0; i < size; i++ {
+			if b.Get(i) != expected[i] {
+				t.Fatalf("ClearDown(%d, %d): bit %d is %v", from, stop, i, b.Get(i))
+			}
+		}
+	}
+}
+
+func TestBitSetCombine(t *testing.T) {
+	a, b := New(0), New(0)
+	for i := 0; i < 200; i++ {
+		a.Append(i%2 == 0)
+	}
+	for i := 0; i < 100; i++ {
+		b.Append(i%3 == 0)
+	}
+
+	and := a.Clone()
+	and.And(b)
+	or := a.Clone()
+	or.Or(b)
+	andNot := a.Clone()
+	andNot.AndNot(b)
+	for i := 0; i < 200; i++ {
+		inA, inB := i%2 == 0, i < 100 && i%3 == 0
+		if and.Get(i) != (inA && inB) || or.Get(i) != (inA || inB) || andNot.Get(i) != (inA && !inB) {
+			t.Fatalf("Unexpected bit %d: and=%v or=%v andNot=%v", i, and.Get(i), or.Get(i), andNot.Get(i))
+		}
+	}
+	if and.Size() != 200 || andNot.Size() != 200 {
+		t.Error("Expected And and AndNot to keep the size")
+	}
+
+	// Or grows the set to the size of the other one
+	b.Or(a)
+	if b.Size() != 200 || !b.Get(198) {
+		t.Errorf("Expected Or to grow the set to 200 bits, got %d", b.Size())
+	}
+}
+
+func TestBitSetForEachSet(t *testing.T) {
+	b := New(300)
+	for _, i := range []int{0, 63, 64, 150, 299} {
+		b.Set(i, true)
+	}
+	var found []int
+	b.ForEachSet(func(index int) bool {
+		found = append(found, index)
+		return true
+	})
+	if len(found) != 5 || found[0] != 0 || found[1] != 63 || found[2] != 64 || found[4] != 299 {
+		t.Errorf("Unexpected set bits %v", found)
+	}
+
+	// Iteration stops when f returns false
+	count := 0
+	b.ForEachSet(func(int) bool {
+		count++
+		return count < 2
+	})
+	if count != 2 {
+		t.Errorf("Expected iteration to stop after 2 bits, got %d", count)
+	}
+}
+
+func TestBitSetMarshalBinary(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, size := range []int{0, 1, 63, 64, 65, 1000} {
+		b := New(0)
+		for i := 0; i < size; i++ {
+			b.Append(rng.Intn(2) == 0)
+		}
+		data, err := b.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var restored BitSet
+		if err := restored.UnmarshalBinary(data); err != nil {
+			t.Fatalf("Size %d: %v", size, err)
+		}
+		if restored.Size() != size || restored.CountSetBits() != b.CountSetBits() {
+			t.Fatalf("Size %d: restored %d bits with %d set", size, restored.Size(), restored.CountSetBits())
+		}
+		for i := 0; i < size; i++ {
+			if restored.Get(i) != b.Get(i) {
+				t.Fatalf("Size %d: bit %d differs", size, i)
+			}
+		}
+	}
+
+	data, _ := New(10).MarshalBinary()
+	var b BitSet
+	if b.UnmarshalBinary(data[:4]) == nil || b.UnmarshalBinary(append(data, 0)) == nil {
+		t.Error("Expected an error for an invalid length")
+	}
+	data[8] = 0xff
+	data[9] = 0xff
+	if b.UnmarshalBinary(data) == nil {
+		t.Error("Expected an error for bits set beyond the size")
+	}
+}
@@ -0,0 +1,14 @@
+/*
+Package bitset provides the compact bit set that sievecache uses to store the
+visited flags of its entries, one bit per entry.
+
+A BitSet grows when bits are appended or set beyond its size, and supports
+bulk operations on whole 64-bit words: setting ranges, finding the next set
+bit, iterating over set bits, and combining sets with And, Or and AndNot. It
+can be serialized with MarshalBinary and UnmarshalBinary.
+
+A BitSet is not safe for concurrent use, except that SetAtomic can be called
+concurrently with readers that only use the methods documented as safe,
+which lets a cache mark entries as visited while only holding a read lock.
+*/
+package bitset
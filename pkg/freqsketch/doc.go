@@ -0,0 +1,27 @@
+/*
+Package freqsketch provides the frequency sketch that the PolicyWTinyLFU policy
+of sievecache uses to estimate how often keys were accessed recently, for
+applications implementing their own admission or eviction logic.
+
+# Usage
+
+A Sketch is a count-min sketch with 4-bit counters, sized for the number of
+keys it should track, typically the capacity of a cache. Keys are identified
+by a 64-bit hash that the caller computes, for example with hash/maphash:
+
+	seed := maphash.MakeSeed()
+	sketch := freqsketch.New(10000)
+
+	// On every access
+	sketch.Add(maphash.String(seed, key))
+
+	// Only admit a candidate if it is more popular than the victim
+	if sketch.Estimate(maphash.String(seed, candidate)) > sketch.Estimate(maphash.String(seed, victim)) {
+		// ...
+	}
+
+Estimates never undercount recent accesses, but collisions can make them
+overcount. Counters saturate at MaxFrequency, and are halved periodically, so
+that old accesses fade away and the sketch adapts to changing workloads.
+*/
+package freqsketch
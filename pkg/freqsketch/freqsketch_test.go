@@ -0,0 +1,70 @@
+package freqsketch
+
+import (
+	"hash/maphash"
+	"strconv"
+	"testing"
+)
+
+var seed = maphash.MakeSeed()
+
+func hashInt(i int) uint64 {
+	return maphash.String(seed, strconv.Itoa(i))
+}
+
+func TestSketch(t *testing.T) {
+	s := New(100)
+	for i := 0; i < 20; i++ {
+		s.Add(hashInt(1))
+	}
+	for i := 0; i < 3; i++ {
+		s.Add(hashInt(2))
+	}
+	if f := s.Estimate(hashInt(1)); f != MaxFrequency {
+		t.Errorf("Expected a saturated frequency of 15, got %d", f)
+	}
+	if f := s.Estimate(hashInt(2)); f < 3 {
+		t.Errorf("Expected a frequency of at least 3, got %d", f)
+	}
+	if f := s.Estimate(hashInt(3)); f > 1 {
+		t.Errorf("Expected a frequency of about 0, got %d", f)
+	}
+
+	s.age()
+	if f := s.Estimate(hashInt(1)); f != 7 {
+		t.Errorf("Expected the frequency to be halved to 7, got %d", f)
+	}
+
+	// Old accesses fade away
+	for i := 0; i < 100000; i++ {
+		s.Add(hashInt(1000 + i))
+	}
+	if f := s.Estimate(hashInt(1)); f > 2 {
+		t.Errorf("Expected the frequency to have faded, got %d", f)
+	}
+}
+
+func TestSketchResetAndClone(t *testing.T) {
+	s := New(100)
+	for i := 0; i < 5; i++ {
+		s.Add(hashInt(1))
+	}
+	clone := s.Clone()
+	s.Reset()
+	if f := s.Estimate(hashInt(1)); f != 0 {
+		t.Errorf("Expected Reset to forget accesses, got %d", f)
+	}
+	if f := clone.Estimate(hashInt(1)); f != 5 {
+		t.Errorf("Expected the clone to keep its accesses, got %d", f)
+	}
+
+	// Growing forgets the accesses, but shrinking is a no-op
+	clone.EnsureCapacity(10)
+	if f := clone.Estimate(hashInt(1)); f != 5 {
+		t.Errorf("Expected accesses to be kept, got %d", f)
+	}
+	clone.EnsureCapacity(1000)
+	if f := clone.Estimate(hashInt(1)); f != 0 || clone.EstimatedMemory() != 1024*8 {
+		t.Errorf("Expected a grown, empty sketch, got %d and %d bytes", f, clone.EstimatedMemory())
+	}
+}
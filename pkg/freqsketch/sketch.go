@@ -0,0 +1,134 @@
+package freqsketch
+
+import (
+	"math/bits"
+	"sync/atomic"
+)
+
+// MaxFrequency is the largest frequency a Sketch can estimate.
+const MaxFrequency = 15
+
+// seeds are the multipliers used to derive the positions of a key's counters
+// in each row of a Sketch.
+var seeds = [4]uint64{0xc3a5c85c97cb3127, 0xb492b66fbe98f273, 0x9ae16a3b2f90404f, 0xcbf29ce484222325}
+
+// Sketch is a count-min sketch with 4-bit counters, estimating how often keys
+// were accessed recently. Every key maps to 4 counters, and its frequency is
+// the smallest of them, so that collisions only cause overestimates.
+// Counters saturate at MaxFrequency, and are halved once the number of
+// recorded accesses reaches 10 times the number of tracked keys, so that old
+// accesses fade away.
+// Add, Estimate and Reset are safe for concurrent use: counters are updated
+// atomically, without locks.
+type Sketch struct {
+	// Each word holds 16 counters
+	table      []atomic.Uint64
+	mask       uint64
+	additions  atomic.Int64
+	sampleSize int64
+}
+
+// New creates a sketch sized for capacity keys. It uses about 8 bytes per
+// key, rounded up to a power of two, and at least 128 bytes.
+func New(capacity int) *Sketch {
+	s := &Sketch{}
+	s.EnsureCapacity(capacity)
+	return s
+}
+
+// EnsureCapacity grows the sketch to track capacity keys, forgetting the
+// recorded accesses if it has to be resized. It must not be called
+// concurrently with other methods.
+func (s *Sketch) EnsureCapacity(capacity int) {
+	capacity = min(max(capacity, 16), 1<<30)
+	size := 1 << bits.Len(uint(capacity-1))
+	if len(s.table) >= size {
+		return
+	}
+	s.table = make([]atomic.Uint64, size)
+	s.mask = uint64(size - 1)
+	s.sampleSize = 10 * int64(capacity)
+	s.additions.Store(0)
+}
+
+// position returns the word and the bit offset of the counter of row i for a key hash.
+func (s *Sketch) position(hash uint64, i int) (int, uint) {
+	h := (hash + seeds[i]) * seeds[i]
+	h ^= h >> 32
+	// Each row uses a different quarter of the counters of a word
+	offset := uint((h>>60)&3 | uint64(i)<<2)
+	return int(h & s.mask), offset << 2
+}
+
+// Add records an access to the key with the given hash.
+func (s *Sketch) Add(hash uint64) {
+	added := false
+	for i := 0; i < 4; i++ {
+		idx, offset := s.position(hash, i)
+		word := &s.table[idx]
+		for {
+			old := word.Load()
+			if (old>>offset)&0xF == MaxFrequency {
+				break
+			}
+			if word.CompareAndSwap(old, old+1<<offset) {
+				added = true
+				break
+			}
+		}
+	}
+	if added && s.additions.Add(1) == s.sampleSize {
+		s.age()
+	}
+}
+
+// Estimate returns the estimated number of recent accesses to the key with
+// the given hash, up to MaxFrequency.
+func (s *Sketch) Estimate(hash uint64) int {
+	frequency := MaxFrequency
+	for i := 0; i < 4; i++ {
+		idx, offset := s.position(hash, i)
+		frequency = min(frequency, int((s.table[idx].Load()>>offset)&0xF))
+	}
+	return frequency
+}
+
+// age halves every counter.
+func (s *Sketch) age() {
+	for i := range s.table {
+		for {
+			old := s.table[i].Load()
+			if s.table[i].CompareAndSwap(old, (old>>1)&0x7777777777777777) {
+				break
+			}
+		}
+	}
+	s.additions.Add(-s.sampleSize / 2)
+}
+
+// Reset forgets all the recorded accesses.
+func (s *Sketch) Reset() {
+	for i := range s.table {
+		s.table[i].Store(0)
+	}
+	s.additions.Store(0)
+}
+
+// Clone returns an independent copy of the sketch.
+func (s *Sketch) Clone() *Sketch {
+	clone := &Sketch{
+		table:      make([]atomic.Uint64, len(s.table)),
+		mask:       s.mask,
+		sampleSize: s.sampleSize,
+	}
+	for i := range s.table {
+		clone.table[i].Store(s.table[i].Load())
+	}
+	clone.additions.Store(s.additions.Load())
+	return clone
+}
+
+// EstimatedMemory returns the number of heap bytes used by the counters.
+func (s *Sketch) EstimatedMemory() int64 {
+	return int64(len(s.table)) * 8
+}
@@ -0,0 +1,92 @@
+package sievebus
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+)
+
+// Message is an invalidation broadcast to other instances.
+type Message struct {
+	// Origin identifies the bus that published the message
+	Origin string `json:"origin"`
+	// Cache is the name of the cache the keys belong to
+	Cache string `json:"cache"`
+	// Keys to remove
+	Keys []string `json:"keys"`
+}
+
+// Transport delivers messages between the instances of a bus.
+type Transport interface {
+	// Publish sends msg to the other instances.
+	Publish(ctx context.Context, msg Message) error
+	// Subscribe sets the function called with every message received from
+	// other instances. It is called once, by NewBus.
+	Subscribe(receive func(Message))
+}
+
+// Bus removes keys from local caches and broadcasts the invalidations to the
+// other instances. It is safe for concurrent use.
+type Bus struct {
+	id        string
+	transport Transport
+
+	mutex    sync.RWMutex
+	handlers map[string]func(key string)
+}
+
+// NewBus creates a bus that publishes and receives invalidations with transport.
+func NewBus(transport Transport) *Bus {
+	var id [16]byte
+	rand.Read(id[:])
+
+	b := &Bus{
+		id:        hex.EncodeToString(id[:]),
+		transport: transport,
+		handlers:  make(map[string]func(key string)),
+	}
+	transport.Subscribe(b.receive)
+	return b
+}
+
+// Handle registers the function that removes a key from the named cache.
+// It replaces any function previously registered for that cache.
+func (b *Bus) Handle(cache string, remove func(key string)) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.handlers[cache] = remove
+}
+
+// Invalidate removes keys from the named local cache, and publishes the
+// invalidation to the other instances. Keys are removed locally even if
+// publishing fails.
+func (b *Bus) Invalidate(ctx context.Context, cache string, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	b.apply(cache, keys)
+	return b.transport.Publish(ctx, Message{Origin: b.id, Cache: cache, Keys: keys})
+}
+
+// receive applies an invalidation published by another instance.
+func (b *Bus) receive(msg Message) {
+	// Broker-based transports may deliver our own messages back
+	if msg.Origin == b.id {
+		return
+	}
+	b.apply(msg.Cache, msg.Keys)
+}
+
+// apply removes keys from the named cache, if it is registered.
+func (b *Bus) apply(cache string, keys []string) {
+	b.mutex.RLock()
+	remove := b.handlers[cache]
+	b.mutex.RUnlock()
+	if remove == nil {
+		return
+	}
+	for _, key := range keys {
+		remove(key)
+	}
+}
@@ -0,0 +1,31 @@
+/*
+Package sievebus propagates cache invalidations between instances of a service.
+
+When several replicas cache the same data, removing a stale entry from one of
+them isn't enough: the others keep serving it. A Bus removes keys from the
+local caches and broadcasts the invalidation to the other instances through a
+pluggable Transport, which applies it to their caches.
+
+# Usage
+
+Each instance registers the caches that can be invalidated, and mounts the
+transport so that it receives invalidations from its siblings:
+
+	transport := sievebus.NewHTTPTransport(sievebus.HTTPTransportOptions{})
+	transport.SetPeers("http://10.0.0.2:8080", "http://10.0.0.3:8080")
+	http.Handle(sievebus.DefaultHTTPPath, transport)
+
+	bus := sievebus.NewBus(transport)
+	bus.Handle("users", func(key string) { users.Remove(key) })
+
+	// After updating a user in the database
+	err := bus.Invalidate(ctx, "users", userID)
+
+Delivery is best effort: an instance that is down or unreachable when an
+invalidation is published misses it, so caches should still bound how long
+entries can stay stale.
+
+Transports for message brokers such as Redis pub/sub or NATS can be written by
+implementing Transport; this module deliberately doesn't depend on their clients.
+*/
+package sievebus
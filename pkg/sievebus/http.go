@@ -0,0 +1,133 @@
+package sievebus
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// DefaultHTTPPath is the path under which an HTTPTransport receives messages by default.
+const DefaultHTTPPath = "/_sievebus"
+
+// maxMessageSize is the maximum size of a message accepted by an HTTPTransport.
+const maxMessageSize = 1 << 20
+
+// HTTPTransportOptions configures an HTTPTransport.
+// Zero values are replaced with sensible defaults.
+type HTTPTransportOptions struct {
+	// Path at which the other instances serve the transport. Defaults to DefaultHTTPPath.
+	Path string
+	// Client is used to send messages. Defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// HTTPTransport delivers messages by posting them to every other instance,
+// and receives messages as an http.Handler.
+type HTTPTransport struct {
+	opts HTTPTransportOptions
+
+	mutex   sync.RWMutex
+	peers   []string
+	receive func(Message)
+}
+
+// NewHTTPTransport creates a transport with no peers.
+func NewHTTPTransport(opts HTTPTransportOptions) *HTTPTransport {
+	if opts.Path == "" {
+		opts.Path = DefaultHTTPPath
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	return &HTTPTransport{opts: opts}
+}
+
+// SetPeers replaces the base URLs of the other instances
+// (for example "http://10.0.0.2:8080"). The local instance must not be included.
+func (t *HTTPTransport) SetPeers(peers ...string) {
+	urls := make([]string, len(peers))
+	for i, peer := range peers {
+		urls[i] = strings.TrimSuffix(peer, "/") + t.opts.Path
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.peers = urls
+}
+
+// Publish posts msg to all peers concurrently, and returns the errors of the
+// peers that couldn't be reached, joined.
+func (t *HTTPTransport) Publish(ctx context.Context, msg Message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	t.mutex.RLock()
+	peers := t.peers
+	t.mutex.RUnlock()
+
+	errs := make([]error, len(peers))
+	var wg sync.WaitGroup
+	for i, peer := range peers {
+		wg.Add(1)
+		go func(i int, peer string) {
+			defer wg.Done()
+			errs[i] = t.post(ctx, peer, body)
+		}(i, peer)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// post sends a message to a single peer.
+func (t *HTTPTransport) post(ctx context.Context, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := t.opts.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sievebus: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 512))
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("sievebus: %s returned %s", url, resp.Status)
+	}
+	return nil
+}
+
+// Subscribe sets the function called with every received message.
+func (t *HTTPTransport) Subscribe(receive func(Message)) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.receive = receive
+}
+
+// ServeHTTP receives a message posted by another instance.
+func (t *HTTPTransport) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var msg Message
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxMessageSize)).Decode(&msg); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	t.mutex.RLock()
+	receive := t.receive
+	t.mutex.RUnlock()
+	if receive != nil {
+		receive(msg)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,116 @@
+package sievebus
+
+import (
+	"context"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/jedisct1/go-sieve-cache/pkg/sievecache"
+)
+
+// testInstance is a service replica with a cache and a bus.
+type testInstance struct {
+	server *httptest.Server
+	cache  *sievecache.SyncSieveCache[string, string]
+	bus    *Bus
+}
+
+func newTestInstances(t *testing.T, n int) []*testInstance {
+	instances := make([]*testInstance, n)
+	transports := make([]*HTTPTransport, n)
+	for i := range instances {
+		transports[i] = NewHTTPTransport(HTTPTransportOptions{})
+		server := httptest.NewServer(transports[i])
+		t.Cleanup(server.Close)
+		cache, _ := sievecache.NewSync[string, string](100)
+		bus := NewBus(transports[i])
+		bus.Handle("users", func(key string) { cache.Remove(key) })
+		instances[i] = &testInstance{server: server, cache: cache, bus: bus}
+	}
+	for i, transport := range transports {
+		var peers []string
+		for j, instance := range instances {
+			if j != i {
+				peers = append(peers, instance.server.URL)
+			}
+		}
+		transport.SetPeers(peers...)
+	}
+	return instances
+}
+
+func TestHTTPInvalidation(t *testing.T) {
+	instances := newTestInstances(t, 3)
+	for _, instance := range instances {
+		instance.cache.Insert("alice", "v1")
+		instance.cache.Insert("bob", "v1")
+		instance.cache.Insert("carol", "v1")
+	}
+
+	if err := instances[1].bus.Invalidate(context.Background(), "users", "alice", "bob"); err != nil {
+		t.Fatalf("Invalidate failed: %v", err)
+	}
+	for i, instance := range instances {
+		if instance.cache.ContainsKey("alice") || instance.cache.ContainsKey("bob") {
+			t.Errorf("Instance %d still has invalidated keys", i)
+		}
+		if !instance.cache.ContainsKey("carol") {
+			t.Errorf("Instance %d lost a key that wasn't invalidated", i)
+		}
+	}
+
+	// Unknown caches are ignored
+	if err := instances[0].bus.Invalidate(context.Background(), "unknown", "carol"); err != nil {
+		t.Fatalf("Invalidate failed: %v", err)
+	}
+
+	// Keys are removed locally even if a peer is unreachable
+	instances[2].server.Close()
+	if err := instances[0].bus.Invalidate(context.Background(), "users", "carol"); err == nil {
+		t.Error("Expected an error for an unreachable peer")
+	}
+	if instances[0].cache.ContainsKey("carol") || instances[1].cache.ContainsKey("carol") {
+		t.Error("Expected the reachable instances to be invalidated")
+	}
+}
+
+// loopbackTransport delivers messages to every subscriber, including the publisher,
+// like a broker-based transport.
+type loopbackTransport struct {
+	mutex       sync.Mutex
+	subscribers []func(Message)
+}
+
+func (l *loopbackTransport) Publish(ctx context.Context, msg Message) error {
+	l.mutex.Lock()
+	subscribers := l.subscribers
+	l.mutex.Unlock()
+	for _, receive := range subscribers {
+		receive(msg)
+	}
+	return nil
+}
+
+func (l *loopbackTransport) Subscribe(receive func(Message)) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.subscribers = append(l.subscribers, receive)
+}
+
+func TestOwnMessagesAreIgnored(t *testing.T) {
+	transport := &loopbackTransport{}
+	a := NewBus(transport)
+	b := NewBus(transport)
+
+	removedA, removedB := 0, 0
+	a.Handle("cache", func(key string) { removedA++ })
+	b.Handle("cache", func(key string) { removedB++ })
+
+	if err := a.Invalidate(context.Background(), "cache", "k1", "k2"); err != nil {
+		t.Fatalf("Invalidate failed: %v", err)
+	}
+	if removedA != 2 || removedB != 2 {
+		t.Errorf("Expected each bus to remove 2 keys once, got %d and %d", removedA, removedB)
+	}
+}
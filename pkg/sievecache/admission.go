@@ -0,0 +1,77 @@
+package sievecache
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// ErrNotAdmitted is returned by TryInsert when a new entry would displace
+// existing entries, but is turned away by the doorkeeper or would exceed
+// AdmissionRate.
+var ErrNotAdmitted = errors.New("SieveCache: entry not admitted")
+
+// tokenBucket limits the rate of admissions of new entries that displace
+// existing ones. It is not safe for concurrent use.
+type tokenBucket struct {
+	clock  Clock
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket creates a full bucket allowing rate events per second, and
+// bursts of burst events, or of the rate if burst is not positive.
+func newTokenBucket(rate float64, burst int, clock Clock) *tokenBucket {
+	if burst <= 0 {
+		burst = max(1, int(math.Ceil(rate)))
+	}
+	clock = clockOrDefault(clock)
+	return &tokenBucket{
+		clock:  clock,
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   clock.Now(),
+	}
+}
+
+// allow takes a token from the bucket, if there is one.
+func (b *tokenBucket) allow() bool {
+	now := b.clock.Now()
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = min(b.burst, b.tokens+elapsed.Seconds()*b.rate)
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// clone returns an independent copy of the bucket.
+func (b *tokenBucket) clone() *tokenBucket {
+	clone := *b
+	return &clone
+}
+
+// admits returns nil if a new entry of the given cost may be stored: either
+// it fits without evicting anything, or it passes the doorkeeper and the
+// admission rate. Otherwise, it returns ErrNotAdmitted.
+func (c *SieveCache[K, V]) admits(key K, cost int64) error {
+	if c.admission == nil && c.doorkeeper == nil {
+		return nil
+	}
+	if len(c.nodes) < c.capacity && (c.maxCost == 0 || c.cost+cost <= c.maxCost) {
+		return nil
+	}
+	if c.doorkeeper != nil && !c.doorkeeper.admit(hashKey(key)) {
+		return ErrNotAdmitted
+	}
+	if c.admission != nil && !c.admission.allow() {
+		return ErrNotAdmitted
+	}
+	return nil
+}
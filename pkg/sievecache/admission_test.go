@@ -0,0 +1,142 @@
+package sievecache
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAdmissionRate(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	cache, err := NewWithOptions(Options[int, int]{Capacity: 10, AdmissionRate: 2, AdmissionBurst: 3, Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Filling free space is never limited
+	for i := 0; i < 10; i++ {
+		if !cache.Insert(i, i) {
+			t.Fatalf("Expected key %d to be inserted", i)
+		}
+	}
+
+	// The burst is admitted, then new keys are rejected
+	for i := 10; i < 13; i++ {
+		if !cache.Insert(i, i) {
+			t.Fatalf("Expected key %d to be admitted", i)
+		}
+	}
+	if inserted, err := cache.TryInsert(13, 13); inserted || !errors.Is(err, ErrNotAdmitted) {
+		t.Fatalf("Expected ErrNotAdmitted, got %v, %v", inserted, err)
+	}
+	if cache.ContainsKey(13) || cache.Rejections() != 1 || cache.Len() != 10 {
+		t.Errorf("Expected the rejected key to be counted and not stored")
+	}
+
+	// Updates are not limited
+	if _, err := cache.TryInsert(12, 120); err != nil {
+		t.Errorf("Expected an update to succeed, got %v", err)
+	}
+
+	// Tokens are refilled at the configured rate
+	clock.Advance(500 * time.Millisecond)
+	if !cache.Insert(13, 13) {
+		t.Error("Expected a key to be admitted after a refill")
+	}
+	if cache.Insert(14, 14) {
+		t.Error("Expected a single token to be refilled")
+	}
+	clock.Advance(time.Hour)
+	for i := 20; i < 23; i++ {
+		if !cache.Insert(i, i) {
+			t.Errorf("Expected key %d to be admitted", i)
+		}
+	}
+	if cache.Insert(23, 23) {
+		t.Error("Expected the refill to be capped by the burst")
+	}
+
+	if _, err := NewWithOptions(Options[int, int]{Capacity: 10, AdmissionRate: -1}); err == nil {
+		t.Error("Expected an error for a negative admission rate")
+	}
+}
+
+func TestShardedAdmissionRate(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	cache, err := NewShardedWithOptions(Options[int, int]{Capacity: 40, AdmissionRate: 8, Clock: clock}, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1000; i++ {
+		cache.Insert(i, i)
+	}
+	// Every shard admits its share of the burst once it is full
+	if admitted := 1000 - int(cache.Rejections()); admitted < 40 || admitted > 40+8 {
+		t.Errorf("Expected 40 entries plus a burst of 8 to be admitted, got %d", admitted)
+	}
+}
+
+func TestDoorkeeper(t *testing.T) {
+	cache, err := NewWithOptions(Options[int, int]{Capacity: 10, DoorkeeperSize: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if !cache.Insert(i, i) {
+			t.Fatalf("Expected key %d to be stored in free space", i)
+		}
+	}
+
+	// Once full, new keys are only stored on their second insertion
+	if inserted, err := cache.TryInsert(100, 100); inserted || !errors.Is(err, ErrNotAdmitted) {
+		t.Fatalf("Expected ErrNotAdmitted, got %v, %v", inserted, err)
+	}
+	if !cache.Insert(100, 100) {
+		t.Error("Expected a key seen twice to be stored")
+	}
+	if cache.Len() != 10 || cache.Rejections() != 1 {
+		t.Errorf("Unexpected length %d and rejections %d", cache.Len(), cache.Rejections())
+	}
+
+	// A scan of one-hit keys doesn't displace the working set
+	rejected := 0
+	for i := 1000; i < 2000; i++ {
+		if !cache.Insert(i, i) {
+			rejected++
+		}
+	}
+	if rejected < 990 {
+		t.Errorf("Expected nearly all one-hit keys to be rejected, got %d", rejected)
+	}
+}
+
+func TestDoorkeeperRotation(t *testing.T) {
+	d := newDoorkeeper(1000)
+	for i := uint64(0); i < 1000; i++ {
+		d.admit(hashKey(i))
+	}
+	// Keys are still remembered after a rotation
+	remembered := 0
+	for i := uint64(0); i < 1000; i++ {
+		if d.admit(hashKey(i)) {
+			remembered++
+		}
+	}
+	if remembered != 1000 {
+		t.Errorf("Expected all keys to be remembered, got %d", remembered)
+	}
+
+	// Two rotations later, keys are forgotten
+	for i := uint64(10000); i < 13000; i++ {
+		d.admit(hashKey(i))
+	}
+	falsePositives := 0
+	for i := uint64(0); i < 1000; i++ {
+		if d.admit(hashKey(i)) {
+			falsePositives++
+		}
+	}
+	if falsePositives > 20 {
+		t.Errorf("Expected old keys to be forgotten, got %d false positives", falsePositives)
+	}
+}
@@ -0,0 +1,198 @@
+package sievecache
+
+import (
+	"math"
+	"runtime"
+	"time"
+)
+
+// CapacityMetrics are the observations a CapacityAdvisor bases its
+// recommendation on. For a sharded cache, they describe a single shard.
+type CapacityMetrics struct {
+	// Capacity is the current capacity, which may be Unbounded
+	Capacity int
+	// Len is the number of entries
+	Len int
+	// FillRatio is the number of entries divided by the capacity
+	FillRatio float64
+	// VisitedRatio is the fraction of the entries that were accessed since
+	// the hand last passed them
+	VisitedRatio float64
+	// HitRatio is the hit ratio over the last 5 minutes, or 0 unless the
+	// cache was created with RecordStats set
+	HitRatio float64
+	// GhostHits is the number of lookups of recently evicted keys, which
+	// would have been hits with a larger capacity. The cache doesn't keep
+	// track of evicted keys yet, so this is always 0.
+	GhostHits uint64
+}
+
+// CapacityAdvisor is a capacity sizing policy.
+type CapacityAdvisor interface {
+	// AdviseCapacity returns the recommended capacity of a cache, given its
+	// current metrics. It must not access the cache.
+	AdviseCapacity(m CapacityMetrics) int
+}
+
+// ThresholdAdvisor is the default CapacityAdvisor, used by RecommendedCapacity.
+// It scales the capacity according to the fraction of visited entries, and
+// shrinks caches that are less than 10% full regardless of it.
+type ThresholdAdvisor struct {
+	// MinFactor is the minimum scaling factor (e.g., 0.5 means recommend at
+	// least 50% of current capacity)
+	MinFactor float64
+	// MaxFactor is the maximum scaling factor (e.g., 2.0 means recommend at
+	// most 200% of current capacity)
+	MaxFactor float64
+	// LowThreshold is the utilization below which capacity is reduced
+	LowThreshold float64
+	// HighThreshold is the utilization above which capacity is increased
+	HighThreshold float64
+}
+
+// AdviseCapacity implements CapacityAdvisor.
+func (a ThresholdAdvisor) AdviseCapacity(m CapacityMetrics) int {
+	// If the cache is empty or unbounded, return the current capacity
+	if m.Len == 0 || m.Capacity == Unbounded {
+		return m.Capacity
+	}
+
+	utilizationRatio := m.VisitedRatio
+	fillRatio := m.FillRatio
+
+	// Low fill ratio threshold (consider the cache underfilled below this)
+	lowFillThreshold := 0.1 // 10% filled
+
+	// Fill ratio takes precedence over utilization:
+	// If the cache is severely underfilled, we should decrease capacity
+	// regardless of utilization
+	if fillRatio < lowFillThreshold {
+		// Calculate how much to decrease based on how empty the cache is
+		fillBelowThreshold := 0.0
+		if fillRatio > 0.0 {
+			fillBelowThreshold = (lowFillThreshold - fillRatio) / lowFillThreshold
+		} else {
+			fillBelowThreshold = 1.0
+		}
+		// Apply the minFactor as a floor
+		scalingFactor := 1.0 - (1.0-a.MinFactor)*fillBelowThreshold
+
+		// Apply the scaling factor to current capacity and ensure it's at least 1
+		return max(1, int(math.Round(float64(m.Capacity)*scalingFactor)))
+	}
+
+	// For normal fill levels, use the original logic based on utilization
+	var scalingFactor float64
+	if utilizationRatio >= a.HighThreshold {
+		// High utilization - recommend increasing the capacity
+		// Scale between 1.0 and maxFactor based on utilization above the high threshold
+		utilizationAboveThreshold := (utilizationRatio - a.HighThreshold) / (1.0 - a.HighThreshold)
+		scalingFactor = 1.0 + (a.MaxFactor-1.0)*utilizationAboveThreshold
+	} else if utilizationRatio <= a.LowThreshold {
+		// Low utilization - recommend decreasing capacity
+		// Scale between minFactor and 1.0 based on how far below the low threshold
+		utilizationBelowThreshold := (a.LowThreshold - utilizationRatio) / a.LowThreshold
+		scalingFactor = 1.0 - (1.0-a.MinFactor)*utilizationBelowThreshold
+	} else {
+		// Normal utilization - keep current capacity
+		scalingFactor = 1.0
+	}
+
+	// Apply the scaling factor to current capacity and ensure it's at least 1
+	return max(1, int(math.Round(float64(m.Capacity)*scalingFactor)))
+}
+
+// capacityMetrics returns the current metrics of the cache.
+// It only requires shared access to the cache.
+func (c *SieveCache[K, V]) capacityMetrics() CapacityMetrics {
+	m := CapacityMetrics{
+		Capacity:     c.capacity,
+		Len:          len(c.nodes),
+		FillRatio:    c.FillRatio(),
+		VisitedRatio: c.VisitedRatio(),
+	}
+	if c.stats != nil {
+		m.HitRatio = c.stats.window(c.stats.clock.Now(), 5).HitRatio()
+	}
+	return m
+}
+
+// AdviseCapacity returns the capacity recommended by advisor for the cache.
+func (c *SieveCache[K, V]) AdviseCapacity(advisor CapacityAdvisor) int {
+	return advisor.AdviseCapacity(c.capacityMetrics())
+}
+
+// AdviseCapacity returns the capacity recommended by advisor for the cache.
+func (c *SyncSieveCache[K, V]) AdviseCapacity(advisor CapacityAdvisor) int {
+	c.rlock()
+	m := c.cache.capacityMetrics()
+	c.runlock()
+	// The advisor is called without holding the lock
+	return advisor.AdviseCapacity(m)
+}
+
+// AdviseCapacity returns the total capacity recommended by advisor for the
+// cache, as the sum of the capacities it recommends for each shard, so that
+// shards with different access patterns are sized independently.
+func (c *ShardedSieveCache[K, V]) AdviseCapacity(advisor CapacityAdvisor) int {
+	// The capacity of an unbounded cache isn't adjusted, and adding up the
+	// capacities of its shards would overflow
+	if c.Capacity() == Unbounded {
+		return Unbounded
+	}
+
+	// For each shard, calculate the recommended capacity
+	totalRecommended := 0
+
+	shards := c.table.Load().shards
+	for _, shard := range shards {
+		totalRecommended += shard.AdviseCapacity(advisor)
+	}
+
+	// Ensure we return at least the original capacity for an empty cache
+	// and at least the number of shards otherwise
+	if c.IsEmpty() {
+		return c.Capacity()
+	}
+
+	return max(len(shards), totalRecommended)
+}
+
+// Average number of goroutines waiting for shard locks above which
+// RecommendedShards recommends more shards, and below which it recommends
+// fewer shards if there are more than the default number.
+const (
+	highLockWaiters = 0.05
+	lowLockWaiters  = 0.005
+)
+
+// RecommendedShards recommends a number of shards from the lock contention
+// observed since the since snapshot of Stats, taken elapsed ago.
+// The time spent waiting for shard locks divided by elapsed is the average
+// number of goroutines waiting. When it is significant, twice the current
+// number of shards is recommended, up to 16 × GOMAXPROCS. When it is
+// negligible and there are more shards than the default for the capacity,
+// half the current number is recommended, as larger shards make better
+// eviction decisions. It is meant to be called periodically, and its
+// recommendation applied with Reshard.
+func (c *ShardedSieveCache[K, V]) RecommendedShards(since Stats, elapsed time.Duration) int {
+	n := c.NumShards()
+	if elapsed <= 0 {
+		return n
+	}
+	capacity := c.Capacity()
+	waiters := float64(c.Stats().Delta(since).LockWaitTime) / float64(elapsed)
+
+	switch {
+	case waiters > highLockWaiters:
+		maxShards := max(n, 16*runtime.GOMAXPROCS(0))
+		if capacity != Unbounded {
+			// Every shard must be able to hold at least one entry
+			maxShards = max(n, min(maxShards, capacity))
+		}
+		return min(2*n, maxShards)
+	case waiters < lowLockWaiters && n > defaultShardCount(capacity):
+		return max(n/2, defaultShardCount(capacity))
+	}
+	return n
+}
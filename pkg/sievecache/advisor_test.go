@@ -0,0 +1,75 @@
+package sievecache
+
+import (
+	"testing"
+	"time"
+)
+
+// fixedAdvisor records the metrics it is given and recommends a fixed capacity.
+type fixedAdvisor struct {
+	capacity int
+	metrics  []CapacityMetrics
+}
+
+func (a *fixedAdvisor) AdviseCapacity(m CapacityMetrics) int {
+	a.metrics = append(a.metrics, m)
+	return a.capacity
+}
+
+func TestAdviseCapacity(t *testing.T) {
+	cache, _ := NewWithOptions(Options[int, int]{Capacity: 10, RecordStats: true})
+	for i := 0; i < 5; i++ {
+		cache.Insert(i, i)
+	}
+	cache.Get(0)
+	cache.Get(10)
+
+	advisor := &fixedAdvisor{capacity: 42}
+	if recommended := cache.AdviseCapacity(advisor); recommended != 42 {
+		t.Errorf("Expected 42, got %d", recommended)
+	}
+	m := advisor.metrics[0]
+	if m.Capacity != 10 || m.Len != 5 || m.FillRatio != 0.5 || m.VisitedRatio != 0.2 || m.HitRatio != 0.5 {
+		t.Errorf("Unexpected metrics: %+v", m)
+	}
+
+	// The default advisor matches RecommendedCapacity
+	threshold := ThresholdAdvisor{MinFactor: 0.5, MaxFactor: 2, LowThreshold: 0.3, HighThreshold: 0.7}
+	if cache.AdviseCapacity(threshold) != cache.RecommendedCapacity(0.5, 2, 0.3, 0.7) {
+		t.Error("Expected ThresholdAdvisor to match RecommendedCapacity")
+	}
+}
+
+func TestShardedAdviseCapacity(t *testing.T) {
+	cache, _ := NewShardedWithShards[int, int](100, 4)
+	for i := 0; i < 50; i++ {
+		cache.Insert(i, i)
+	}
+	advisor := &fixedAdvisor{capacity: 30}
+	if recommended := cache.AdviseCapacity(advisor); recommended != 120 {
+		t.Errorf("Expected 4 shards of 30, got %d", recommended)
+	}
+	if len(advisor.metrics) != 4 {
+		t.Errorf("Expected the advisor to be called for every shard, got %d calls", len(advisor.metrics))
+	}
+}
+
+func TestRecommendedShards(t *testing.T) {
+	cache, _ := NewShardedWithShards[int, int](10000, 2)
+	since := cache.Stats()
+	if n := cache.RecommendedShards(since, time.Second); n != 2 {
+		t.Errorf("Expected 2 shards without contention, got %d", n)
+	}
+
+	// Simulate 100ms of waiting over a second
+	cache.GetShardByIndex(0).lockWaitTime.Add(int64(100 * time.Millisecond))
+	if n := cache.RecommendedShards(since, time.Second); n != 4 {
+		t.Errorf("Expected 4 shards with contention, got %d", n)
+	}
+
+	// Too many shards without contention
+	many, _ := NewShardedWithShards[int, int](10000, 4*defaultShardCount(10000))
+	if n := many.RecommendedShards(many.Stats(), time.Second); n != 2*defaultShardCount(10000) {
+		t.Errorf("Expected %d shards, got %d", 2*defaultShardCount(10000), n)
+	}
+}
@@ -0,0 +1,93 @@
+package sievecache
+
+import "errors"
+
+// ErrWrongType is returned by an AnyCache when a key or a value doesn't have
+// the type of the underlying cache.
+var ErrWrongType = errors.New("SieveCache: wrong key or value type")
+
+// AnyCache is a type-erased Cache, for frameworks that store heterogeneous
+// values or can only use a cache through reflection.
+// Keys of the wrong type are never found, and insertions of keys or values of
+// the wrong type fail with ErrWrongType.
+type AnyCache interface {
+	Get(key any) (any, bool)
+	Insert(key, value any) bool
+	TryInsert(key, value any) (bool, error)
+	Remove(key any) (any, bool)
+	ContainsKey(key any) bool
+	Len() int
+	Capacity() int
+	Clear()
+}
+
+// anyCache adapts a Cache to the AnyCache interface.
+type anyCache[K comparable, V any] struct {
+	cache Cache[K, V]
+}
+
+// NewAnyCache returns a type-erased view of cache. Use a cache with values of
+// type any to store heterogeneous values.
+// It is as safe for concurrent use as the cache it wraps.
+func NewAnyCache[K comparable, V any](cache Cache[K, V]) AnyCache {
+	return anyCache[K, V]{cache: cache}
+}
+
+// convert returns x as a T. A nil x is accepted if T is an interface type.
+func convert[T any](x any) (T, bool) {
+	if t, ok := x.(T); ok {
+		return t, true
+	}
+	var zero T
+	return zero, x == nil && any(zero) == nil
+}
+
+func (c anyCache[K, V]) Get(key any) (any, bool) {
+	k, ok := convert[K](key)
+	if !ok {
+		return nil, false
+	}
+	return c.cache.Get(k)
+}
+
+func (c anyCache[K, V]) Insert(key, value any) bool {
+	inserted, _ := c.TryInsert(key, value)
+	return inserted
+}
+
+func (c anyCache[K, V]) TryInsert(key, value any) (bool, error) {
+	k, ok := convert[K](key)
+	if !ok {
+		return false, ErrWrongType
+	}
+	v, ok := convert[V](value)
+	if !ok {
+		return false, ErrWrongType
+	}
+	return c.cache.TryInsert(k, v)
+}
+
+func (c anyCache[K, V]) Remove(key any) (any, bool) {
+	k, ok := convert[K](key)
+	if !ok {
+		return nil, false
+	}
+	return c.cache.Remove(k)
+}
+
+func (c anyCache[K, V]) ContainsKey(key any) bool {
+	k, ok := convert[K](key)
+	return ok && c.cache.ContainsKey(k)
+}
+
+func (c anyCache[K, V]) Len() int {
+	return c.cache.Len()
+}
+
+func (c anyCache[K, V]) Capacity() int {
+	return c.cache.Capacity()
+}
+
+func (c anyCache[K, V]) Clear() {
+	c.cache.Clear()
+}
@@ -0,0 +1,42 @@
+package sievecache
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAnyCache(t *testing.T) {
+	cache, _ := NewSync[string, any](10)
+	c := NewAnyCache[string, any](cache)
+
+	c.Insert("int", 1)
+	c.Insert("string", "one")
+	if !c.Insert("nil", nil) {
+		t.Error("Expected nil to be accepted as a value of type any")
+	}
+	if value, found := c.Get("string"); !found || value != "one" {
+		t.Errorf("Expected \"one\", got %v (found: %v)", value, found)
+	}
+	if value, found := cache.Get("int"); !found || value != 1 {
+		t.Errorf("Expected the typed cache to hold 1, got %v (found: %v)", value, found)
+	}
+
+	if _, err := c.TryInsert(42, "wrong key"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+	if _, found := c.Get(42); found || c.ContainsKey(nil) {
+		t.Error("Expected keys of the wrong type not to be found")
+	}
+	if value, found := c.Remove("int"); !found || value != 1 || c.Len() != 2 {
+		t.Errorf("Expected to remove 1, got %v (found: %v)", value, found)
+	}
+
+	intCache, _ := New[string, int](10)
+	typed := NewAnyCache[string, int](intCache)
+	if _, err := typed.TryInsert("a", "not an int"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+	if _, err := typed.TryInsert("a", nil); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected nil to be rejected as an int, got %v", err)
+	}
+}
@@ -42,6 +42,7 @@ func zipfDistribution(keyCount, sampleCount int, rng *rand.Rand) []string {
 // benchmarkInsert benchmarks the insertion of keys into a cache
 func benchmarkInsert(b *testing.B, c Cache[string, int]) {
 	keys := generateKeys(benchKeySize)
+	b.ReportAllocs()
 	b.ResetTimer()
 
 	for i := 0; i < b.N; i++ {
@@ -64,6 +65,7 @@ func benchmarkGet(b *testing.B, c Cache[string, int]) {
 	// Generate access patterns following a Zipf distribution
 	accessPatterns := zipfDistribution(benchKeySize, b.N, rng)
 
+	b.ReportAllocs()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		c.Get(accessPatterns[i])
@@ -83,6 +85,7 @@ func benchmarkMixed(b *testing.B, c Cache[string, int]) {
 		c.Insert(keys[i%benchKeySize], i)
 	}
 
+	b.ReportAllocs()
 	b.ResetTimer()
 
 	// Mixed workload with 80% reads and 20% writes
@@ -99,12 +102,6 @@ func benchmarkMixed(b *testing.B, c Cache[string, int]) {
 	}
 }
 
-// Define Cache interface for benchmarking
-type Cache[K comparable, V any] interface {
-	Insert(key K, value V) bool
-	Get(key K) (V, bool)
-}
-
 // Benchmark the base SieveCache implementation
 func BenchmarkSieveCache_Insert(b *testing.B) {
 	cache, _ := New[string, int](benchCacheSize)
@@ -175,6 +172,7 @@ func BenchmarkParallelAccess(b *testing.B) {
 		cache.Insert(keys[i%benchKeySize], i)
 	}
 
+	b.ReportAllocs()
 	b.ResetTimer()
 	b.RunParallel(func(pb *testing.PB) {
 		// Each goroutine has its own RNG
@@ -195,3 +193,269 @@ func BenchmarkParallelAccess(b *testing.B) {
 		}
 	})
 }
+
+// Benchmark the worst-case insert, where every entry has been visited and the
+// hand has to clear all the visited flags, with and without a scan limit
+func BenchmarkEvictAllVisited(b *testing.B) {
+	for _, maxScan := range []int{0, 64} {
+		b.Run("MaxEvictionScan="+strconv.Itoa(maxScan), func(b *testing.B) {
+			cache, _ := NewWithOptions(Options[int, int]{Capacity: benchCacheSize, MaxEvictionScan: maxScan})
+			for i := 0; i < benchCacheSize; i++ {
+				cache.Insert(i, i)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				cache.visited.SetRange(0, cache.Len(), true)
+				b.StartTimer()
+				cache.Insert(benchCacheSize+i, i)
+			}
+		})
+	}
+}
+
+// Benchmark the policies on a Zipf workload, reporting their hit ratio
+func BenchmarkPolicies(b *testing.B) {
+	policies := []struct {
+		name   string
+		policy Policy
+	}{
+		{"SIEVE", PolicySIEVE},
+		{"WTinyLFU", PolicyWTinyLFU},
+		{"SLRU", PolicySLRU},
+		{"Counters", PolicyCounters},
+	}
+	for _, p := range policies {
+		b.Run(p.name, func(b *testing.B) {
+			cache, _ := NewWithOptions(Options[string, int]{Capacity: benchCacheSize, Policy: p.policy})
+			rng := rand.New(rand.NewSource(benchRandSeed))
+			accessPatterns := zipfDistribution(benchKeySize, b.N, rng)
+
+			hits := 0
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, ok := cache.Get(accessPatterns[i]); ok {
+					hits++
+				} else {
+					cache.Insert(accessPatterns[i], i)
+				}
+			}
+			b.ReportMetric(float64(hits)/float64(b.N), "hits/op")
+		})
+	}
+}
+
+// Benchmark parallel lookups on SyncSieveCache, which only take a read lock
+func BenchmarkSyncSieveCache_ParallelGet(b *testing.B) {
+	cache, _ := NewSync[string, int](benchCacheSize)
+	keys := generateKeys(benchWorkingSet)
+	for i, key := range keys {
+		cache.Insert(key, i)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		rng := rand.New(rand.NewSource(benchRandSeed))
+		for pb.Next() {
+			cache.Get(keys[rng.Intn(benchWorkingSet)])
+		}
+	})
+}
+
+// Benchmark inserting a batch of entries into ShardedSieveCache at once,
+// compared with inserting them one by one
+func BenchmarkShardedSieveCache_InsertBatch(b *testing.B) {
+	keys := generateKeys(1000)
+	items := make(map[string]int, len(keys))
+	for i, key := range keys {
+		items[key] = i
+	}
+
+	b.Run("Batch", func(b *testing.B) {
+		cache, _ := NewShardedWithShards[string, int](benchCacheSize, benchShardCount)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			cache.InsertBatch(items)
+		}
+	})
+	b.Run("OneByOne", func(b *testing.B) {
+		cache, _ := NewShardedWithShards[string, int](benchCacheSize, benchShardCount)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for key, value := range items {
+				cache.Insert(key, value)
+			}
+		}
+	})
+}
+
+// Benchmark looking up a few hundred keys of ShardedSieveCache at once,
+// compared with looking them up one by one
+func BenchmarkShardedSieveCache_GetMany(b *testing.B) {
+	keys := generateKeys(500)
+	cache, _ := NewShardedWithShards[string, int](benchCacheSize, benchShardCount)
+	for i, key := range keys {
+		cache.Insert(key, i)
+	}
+
+	b.Run("GetMany", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			cache.GetMany(keys)
+		}
+	})
+	b.Run("OneByOne", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			result := make(map[string]int, len(keys))
+			for _, key := range keys {
+				if value, found := cache.Get(key); found {
+					result[key] = value
+				}
+			}
+		}
+	})
+}
+
+// Benchmark repeated lookups of a few keys of ShardedSieveCache through a
+// LocalCache, compared with looking them up in the shards
+func BenchmarkShardedSieveCache_Local(b *testing.B) {
+	keys := generateKeys(4)
+	cache, _ := NewShardedWithShards[string, int](benchCacheSize, benchShardCount)
+	for i, key := range keys {
+		cache.Insert(key, i)
+	}
+
+	b.Run("Local", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			local := cache.Local()
+			for i := 0; pb.Next(); i++ {
+				local.Get(keys[i%len(keys)])
+			}
+		})
+	})
+	b.Run("Shards", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for i := 0; pb.Next(); i++ {
+				cache.Get(keys[i%len(keys)])
+			}
+		})
+	})
+}
+
+// Benchmark lookups and insertions with each index, reporting the memory
+// used per entry
+func BenchmarkIndexes(b *testing.B) {
+	indexes := []struct {
+		name  string
+		index Index
+	}{
+		{"Map", IndexMap},
+		{"OpenAddressing", IndexOpenAddressing},
+		{"Swiss", IndexSwiss},
+	}
+	keys := generateKeys(benchKeySize)
+	for _, x := range indexes {
+		b.Run(x.name+"/Get", func(b *testing.B) {
+			cache, _ := NewWithOptions(Options[string, int]{Capacity: benchCacheSize, Index: x.index})
+			for i, key := range keys {
+				cache.Insert(key, i)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				cache.Get(keys[i%len(keys)])
+			}
+			b.ReportMetric(float64(cache.EstimatedMemory())/float64(cache.Len()), "bytes/entry")
+		})
+		// Every insertion evicts an entry, leaving tombstones in Swiss tables
+		b.Run(x.name+"/Churn", func(b *testing.B) {
+			cache, _ := NewWithOptions(Options[string, int]{Capacity: benchCacheSize, Index: x.index})
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				cache.Insert(keys[i%len(keys)], i)
+			}
+		})
+	}
+}
+
+// BenchmarkEntryLayout compares storing entries as a slice of Node, as the
+// cache does, with parallel slices of keys, values and versions. It runs the
+// work that depends on the layout when every insertion evicts: comparing the
+// key of a slot in place, as IndexOpenAddressing does, reading the key of the
+// evicted entry, moving the last entry into its slot and appending the new
+// one. The eviction scan only reads the visited bitset, whatever the layout.
+func BenchmarkEntryLayout(b *testing.B) {
+	b.Run("SmallValue", func(b *testing.B) { benchmarkEntryLayout(b, 0) })
+	b.Run("LargeValue", func(b *testing.B) { benchmarkEntryLayout(b, [8]uint64{}) })
+}
+
+// layoutSink keeps the key reads of BenchmarkEntryLayout from being optimized away.
+var layoutSink int
+
+func benchmarkEntryLayout[V any](b *testing.B, value V) {
+	keys := generateKeys(benchKeySize)
+	rng := rand.New(rand.NewSource(benchRandSeed))
+	slots := make([]int, 1<<16)
+	for i := range slots {
+		slots[i] = rng.Intn(benchCacheSize)
+	}
+
+	b.Run("Structs", func(b *testing.B) {
+		nodes := make([]Node[string, V], 0, benchCacheSize)
+		for _, key := range keys[:benchCacheSize] {
+			nodes = append(nodes, NewNode(key, value))
+		}
+		evicted := 0
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			key := keys[i%len(keys)]
+			idx := slots[i%len(slots)]
+			if nodes[idx].Key == key {
+				value = nodes[idx].Value
+			}
+			evicted += len(nodes[idx].Key)
+			last := len(nodes) - 1
+			nodes[idx] = nodes[last]
+			nodes[last] = Node[string, V]{}
+			nodes = append(nodes[:last], Node[string, V]{Key: key, Value: value, version: uint64(i)})
+		}
+		layoutSink = evicted
+	})
+
+	b.Run("Parallel", func(b *testing.B) {
+		entryKeys := make([]string, 0, benchCacheSize)
+		values := make([]V, 0, benchCacheSize)
+		versions := make([]uint64, 0, benchCacheSize)
+		for _, key := range keys[:benchCacheSize] {
+			entryKeys = append(entryKeys, key)
+			values = append(values, value)
+			versions = append(versions, 0)
+		}
+		var zero V
+		evicted := 0
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			key := keys[i%len(keys)]
+			idx := slots[i%len(slots)]
+			if entryKeys[idx] == key {
+				value = values[idx]
+			}
+			evicted += len(entryKeys[idx])
+			last := len(entryKeys) - 1
+			entryKeys[idx], values[idx], versions[idx] = entryKeys[last], values[last], versions[last]
+			entryKeys[last], values[last] = "", zero
+			entryKeys = append(entryKeys[:last], key)
+			values = append(values[:last], value)
+			versions = append(versions[:last], uint64(i))
+		}
+		layoutSink = evicted
+	})
+}
@@ -0,0 +1,311 @@
+package sievecache
+
+import (
+	"bytes"
+	"errors"
+	"hash/maphash"
+	"math/bits"
+)
+
+const (
+	// byteCacheMinChunkShift is log2 of the smallest chunk size (64 bytes).
+	byteCacheMinChunkShift = 6
+	// byteCacheSlabShift is log2 of the slab size (1 MiB).
+	byteCacheSlabShift = 20
+	// ByteCacheMaxValueSize is the largest value that can be stored in a SieveByteCache.
+	ByteCacheMaxValueSize = 1 << byteCacheSlabShift
+)
+
+// ErrValueTooLarge is returned when a value exceeds the maximum size a cache accepts.
+var ErrValueTooLarge = errors.New("SieveCache: value is too large")
+
+// byteRef locates a value stored in a byteArena.
+type byteRef struct {
+	class  uint8
+	chunk  uint32
+	length uint32
+}
+
+// byteSlabClass manages the chunks of a single size.
+type byteSlabClass struct {
+	chunkShift uint
+	slabs      [][]byte
+	free       []uint32
+	next       uint32
+}
+
+// byteArena stores byte slices in large slabs, split into power-of-two chunks.
+// Slabs are never returned to the runtime, but freed chunks are reused.
+type byteArena struct {
+	classes [byteCacheSlabShift - byteCacheMinChunkShift + 1]byteSlabClass
+}
+
+// newByteArena creates an empty arena.
+func newByteArena() *byteArena {
+	a := &byteArena{}
+	for i := range a.classes {
+		a.classes[i].chunkShift = uint(byteCacheMinChunkShift + i)
+	}
+	return a
+}
+
+// sizeClass returns the index of the smallest class whose chunks can hold n bytes.
+func (a *byteArena) sizeClass(n int) int {
+	if n <= 1<<byteCacheMinChunkShift {
+		return 0
+	}
+	return bits.Len(uint(n-1)) - byteCacheMinChunkShift
+}
+
+// alloc copies value into a free chunk and returns its reference.
+// The value must not exceed ByteCacheMaxValueSize.
+func (a *byteArena) alloc(value []byte) byteRef {
+	classIdx := a.sizeClass(len(value))
+	class := &a.classes[classIdx]
+
+	var chunk uint32
+	if n := len(class.free); n > 0 {
+		chunk = class.free[n-1]
+		class.free = class.free[:n-1]
+	} else {
+		chunksPerSlab := uint32(1) << (byteCacheSlabShift - class.chunkShift)
+		if int(class.next/chunksPerSlab) == len(class.slabs) {
+			class.slabs = append(class.slabs, make([]byte, 1<<byteCacheSlabShift))
+		}
+		chunk = class.next
+		class.next++
+	}
+
+	ref := byteRef{class: uint8(classIdx), chunk: chunk, length: uint32(len(value))}
+	copy(a.bytes(ref), value)
+	return ref
+}
+
+// bytes returns the arena memory holding the value referenced by ref.
+func (a *byteArena) bytes(ref byteRef) []byte {
+	class := &a.classes[ref.class]
+	chunksPerSlab := uint32(1) << (byteCacheSlabShift - class.chunkShift)
+	slab := class.slabs[ref.chunk/chunksPerSlab]
+	offset := int(ref.chunk%chunksPerSlab) << class.chunkShift
+	return slab[offset : offset+int(ref.length) : offset+int(ref.length)]
+}
+
+// free makes the chunk referenced by ref available for reuse.
+func (a *byteArena) free(ref byteRef) {
+	class := &a.classes[ref.class]
+	class.free = append(class.free, ref.chunk)
+}
+
+// size returns the total number of bytes allocated for slabs.
+func (a *byteArena) size() int64 {
+	var total int64
+	for i := range a.classes {
+		total += int64(len(a.classes[i].slabs)) << byteCacheSlabShift
+	}
+	return total
+}
+
+// ByteCacheOptions configures a SieveByteCache.
+type ByteCacheOptions struct {
+	// Dedup stores a single copy of identical values, shared among all the
+	// keys mapping to them. This saves memory for workloads where many keys
+	// map to the same bytes, at the cost of hashing every inserted value.
+	Dedup bool
+}
+
+// dedupIndex tracks shared values when deduplication is enabled.
+type dedupIndex struct {
+	seed maphash.Seed
+	// Values with a given hash; collisions are resolved by comparing contents
+	byHash map[uint64][]byteRef
+	// Number of entries sharing each stored value
+	refCounts map[byteRef]int
+}
+
+// SieveByteCache is a SIEVE cache specialized for []byte values.
+// Values are copied into large slab allocations on insert and copied out on
+// retrieval, so the cache doesn't keep millions of small heap objects alive,
+// and callers can't modify cached data through the slices they pass or receive.
+// Slabs don't contain pointers, so the garbage collector doesn't scan them.
+// This implementation is not thread-safe.
+type SieveByteCache[K comparable] struct {
+	cache *SieveCache[K, byteRef]
+	arena *byteArena
+	dedup *dedupIndex
+}
+
+// NewByteCache creates a new byte cache with the given capacity.
+// Returns an error if capacity is negative.
+func NewByteCache[K comparable](capacity int) (*SieveByteCache[K], error) {
+	return NewByteCacheWithOptions[K](capacity, ByteCacheOptions{})
+}
+
+// NewByteCacheWithOptions creates a new byte cache with the given capacity and options.
+// Returns an error if capacity is negative.
+func NewByteCacheWithOptions[K comparable](capacity int, opts ByteCacheOptions) (*SieveByteCache[K], error) {
+	cache, err := New[K, byteRef](capacity)
+	if err != nil {
+		return nil, err
+	}
+	c := &SieveByteCache[K]{
+		cache: cache,
+		arena: newByteArena(),
+	}
+	if opts.Dedup {
+		c.dedup = newDedupIndex()
+	}
+	return c, nil
+}
+
+// newDedupIndex creates an empty deduplication index.
+func newDedupIndex() *dedupIndex {
+	return &dedupIndex{
+		seed:      maphash.MakeSeed(),
+		byHash:    make(map[uint64][]byteRef),
+		refCounts: make(map[byteRef]int),
+	}
+}
+
+// acquire stores value, or shares an identical stored value when deduplication is enabled.
+func (c *SieveByteCache[K]) acquire(value []byte) byteRef {
+	if c.dedup == nil {
+		return c.arena.alloc(value)
+	}
+
+	h := maphash.Bytes(c.dedup.seed, value)
+	for _, ref := range c.dedup.byHash[h] {
+		if bytes.Equal(c.arena.bytes(ref), value) {
+			c.dedup.refCounts[ref]++
+			return ref
+		}
+	}
+
+	ref := c.arena.alloc(value)
+	c.dedup.byHash[h] = append(c.dedup.byHash[h], ref)
+	c.dedup.refCounts[ref] = 1
+	return ref
+}
+
+// release drops a reference to a stored value, freeing it once it is no longer used.
+func (c *SieveByteCache[K]) release(ref byteRef) {
+	if c.dedup == nil {
+		c.arena.free(ref)
+		return
+	}
+
+	c.dedup.refCounts[ref]--
+	if c.dedup.refCounts[ref] > 0 {
+		return
+	}
+	delete(c.dedup.refCounts, ref)
+
+	h := maphash.Bytes(c.dedup.seed, c.arena.bytes(ref))
+	refs := c.dedup.byHash[h]
+	for i, r := range refs {
+		if r == ref {
+			refs[i] = refs[len(refs)-1]
+			refs = refs[:len(refs)-1]
+			break
+		}
+	}
+	if len(refs) == 0 {
+		delete(c.dedup.byHash, h)
+	} else {
+		c.dedup.byHash[h] = refs
+	}
+	c.arena.free(ref)
+}
+
+// UniqueValues returns the number of distinct values stored in the arena.
+// Without deduplication, this is the number of entries.
+func (c *SieveByteCache[K]) UniqueValues() int {
+	if c.dedup == nil {
+		return c.cache.Len()
+	}
+	return len(c.dedup.refCounts)
+}
+
+// Capacity returns the maximum number of entries the cache can hold.
+func (c *SieveByteCache[K]) Capacity() int {
+	return c.cache.Capacity()
+}
+
+// Len returns the number of cached values.
+func (c *SieveByteCache[K]) Len() int {
+	return c.cache.Len()
+}
+
+// ContainsKey returns true if there is a value in the cache mapped to by key.
+func (c *SieveByteCache[K]) ContainsKey(key K) bool {
+	return c.cache.ContainsKey(key)
+}
+
+// Get returns a copy of the value mapped to by key.
+// This operation marks the entry as "visited" in the SIEVE algorithm.
+func (c *SieveByteCache[K]) Get(key K) ([]byte, bool) {
+	return c.AppendValue(nil, key)
+}
+
+// AppendValue appends the value mapped to by key to dst and returns the extended slice.
+// It avoids allocations when dst has enough spare capacity.
+// This operation marks the entry as "visited" in the SIEVE algorithm.
+func (c *SieveByteCache[K]) AppendValue(dst []byte, key K) ([]byte, bool) {
+	ref, ok := c.cache.Get(key)
+	if !ok {
+		return dst, false
+	}
+	return append(dst, c.arena.bytes(ref)...), true
+}
+
+// Insert copies value into the cache and maps key to it, possibly evicting old entries.
+// Returns true when this is a new entry, and false if an existing entry was updated.
+// Returns ErrValueTooLarge if value is larger than ByteCacheMaxValueSize.
+func (c *SieveByteCache[K]) Insert(key K, value []byte) (bool, error) {
+	if len(value) > ByteCacheMaxValueSize {
+		return false, ErrValueTooLarge
+	}
+
+	if ptr := c.cache.GetPointer(key); ptr != nil {
+		old := *ptr
+		*ptr = c.acquire(value)
+		c.release(old)
+		return false, nil
+	}
+
+	// A null cache doesn't store anything
+	if c.cache.dropsInserts() {
+		return false, nil
+	}
+
+	// Evict here rather than in Insert, so that evicted chunks are released
+	for c.cache.Len() >= c.cache.Capacity() {
+		if ref, ok := c.cache.Evict(); ok {
+			c.release(ref)
+		}
+	}
+	return c.cache.Insert(key, c.acquire(value)), nil
+}
+
+// Remove removes the cache entry mapped to by key.
+// Returns true if the key was present.
+func (c *SieveByteCache[K]) Remove(key K) bool {
+	ref, ok := c.cache.Remove(key)
+	if ok {
+		c.release(ref)
+	}
+	return ok
+}
+
+// Clear removes all entries from the cache and releases the slabs.
+func (c *SieveByteCache[K]) Clear() {
+	c.cache.Clear()
+	c.arena = newByteArena()
+	if c.dedup != nil {
+		c.dedup = newDedupIndex()
+	}
+}
+
+// ArenaBytes returns the number of bytes allocated for slabs.
+func (c *SieveByteCache[K]) ArenaBytes() int64 {
+	return c.arena.size()
+}
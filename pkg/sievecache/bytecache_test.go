@@ -0,0 +1,139 @@
+package sievecache
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestByteCache(t *testing.T) {
+	cache, err := NewByteCache[string](100)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+
+	sizes := []int{0, 1, 63, 64, 65, 1000, 4096, 100000, ByteCacheMaxValueSize}
+	for _, size := range sizes {
+		value := bytes.Repeat([]byte{byte(size)}, size)
+		if _, err := cache.Insert(fmt.Sprintf("key%d", size), value); err != nil {
+			t.Fatalf("Insert of %d bytes failed: %v", size, err)
+		}
+	}
+	for _, size := range sizes {
+		got, ok := cache.Get(fmt.Sprintf("key%d", size))
+		if !ok || !bytes.Equal(got, bytes.Repeat([]byte{byte(size)}, size)) {
+			t.Errorf("Unexpected value for size %d", size)
+		}
+	}
+
+	if _, err := cache.Insert("huge", make([]byte, ByteCacheMaxValueSize+1)); err != ErrValueTooLarge {
+		t.Errorf("Expected ErrValueTooLarge, got %v", err)
+	}
+}
+
+func TestByteCacheCopySemantics(t *testing.T) {
+	cache, _ := NewByteCache[int](10)
+
+	value := []byte("hello")
+	cache.Insert(1, value)
+	value[0] = 'X'
+
+	got, _ := cache.Get(1)
+	if string(got) != "hello" {
+		t.Errorf("Cached value was modified through the inserted slice: %q", got)
+	}
+	got[0] = 'Y'
+	got2, _ := cache.Get(1)
+	if string(got2) != "hello" {
+		t.Errorf("Cached value was modified through the returned slice: %q", got2)
+	}
+
+	// AppendValue reuses the destination buffer
+	buf := make([]byte, 0, 64)
+	buf, ok := cache.AppendValue(buf[:0], 1)
+	if !ok || string(buf) != "hello" {
+		t.Errorf("Expected hello, got %q", buf)
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		buf, _ = cache.AppendValue(buf[:0], 1)
+	})
+	if allocs != 0 {
+		t.Errorf("Expected no allocations, got %f", allocs)
+	}
+}
+
+func TestByteCacheChunkReuse(t *testing.T) {
+	cache, _ := NewByteCache[int](100)
+	value := make([]byte, 1000)
+
+	// Churn through many more entries than the capacity
+	for i := 0; i < 100000; i++ {
+		if _, err := cache.Insert(i, value); err != nil {
+			t.Fatal(err)
+		}
+		if i%3 == 0 {
+			cache.Remove(i)
+		}
+		if i%5 == 0 {
+			cache.Insert(i-1, value[:10])
+		}
+	}
+
+	if cache.Len() > 100 {
+		t.Errorf("Expected at most 100 entries, got %d", cache.Len())
+	}
+	// Freed chunks are reused, so only a couple of slabs are needed
+	if cache.ArenaBytes() > 2*ByteCacheMaxValueSize {
+		t.Errorf("Expected freed chunks to be reused, arena uses %d bytes", cache.ArenaBytes())
+	}
+
+	cache.Clear()
+	if cache.Len() != 0 || cache.ArenaBytes() != 0 {
+		t.Errorf("Expected empty cache and arena after clear")
+	}
+}
+
+func TestByteCacheDedup(t *testing.T) {
+	cache, _ := NewByteCacheWithOptions[int](1000, ByteCacheOptions{Dedup: true})
+	fragment := bytes.Repeat([]byte("<div>shared</div>"), 100)
+
+	for i := 0; i < 500; i++ {
+		cache.Insert(i, fragment)
+	}
+	cache.Insert(1000, []byte("unique"))
+	if cache.UniqueValues() != 2 {
+		t.Errorf("Expected 2 unique values, got %d", cache.UniqueValues())
+	}
+
+	// Removing some sharers keeps the value for the others
+	for i := 0; i < 499; i++ {
+		cache.Remove(i)
+	}
+	if got, ok := cache.Get(499); !ok || !bytes.Equal(got, fragment) {
+		t.Error("Shared value was lost")
+	}
+
+	// Updating the last sharer releases the shared value
+	cache.Insert(499, []byte("changed"))
+	if cache.UniqueValues() != 2 {
+		t.Errorf("Expected 2 unique values, got %d", cache.UniqueValues())
+	}
+
+	// Identical updates are shared again
+	cache.Insert(1000, []byte("changed"))
+	if cache.UniqueValues() != 1 {
+		t.Errorf("Expected 1 unique value, got %d", cache.UniqueValues())
+	}
+	got, _ := cache.Get(1000)
+	if string(got) != "changed" {
+		t.Errorf("Expected changed, got %q", got)
+	}
+
+	// Evictions release references too
+	for i := 2000; i < 4000; i++ {
+		cache.Insert(i, []byte(fmt.Sprintf("v%d", i%10)))
+	}
+	if cache.UniqueValues() > 11 {
+		t.Errorf("Expected at most 11 unique values, got %d", cache.UniqueValues())
+	}
+}
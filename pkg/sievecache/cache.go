@@ -0,0 +1,21 @@
+package sievecache
+
+// Cache is the set of operations shared by SieveCache, SyncSieveCache and
+// ShardedSieveCache. Code written against it works with any of them, and
+// with the decorators that wrap them.
+type Cache[K comparable, V any] interface {
+	Get(key K) (V, bool)
+	Insert(key K, value V) bool
+	TryInsert(key K, value V) (bool, error)
+	Remove(key K) (V, bool)
+	ContainsKey(key K) bool
+	Len() int
+	Capacity() int
+	Clear()
+}
+
+var (
+	_ Cache[string, int] = (*SieveCache[string, int])(nil)
+	_ Cache[string, int] = (*SyncSieveCache[string, int])(nil)
+	_ Cache[string, int] = (*ShardedSieveCache[string, int])(nil)
+)
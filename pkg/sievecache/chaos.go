@@ -0,0 +1,128 @@
+package sievecache
+
+import (
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// ErrInjected is the error returned by a ChaosCache when it injects a failure.
+var ErrInjected = errors.New("SieveCache: injected failure")
+
+// ChaosOptions configures the faults injected by a ChaosCache.
+// Zero values inject nothing.
+type ChaosOptions struct {
+	// Latency is added to every operation
+	Latency time.Duration
+	// Jitter is the maximum random latency added on top of Latency
+	Jitter time.Duration
+	// MissRate is the fraction of lookups reported as misses, even if the key
+	// is present. Get, ContainsKey and Remove are affected.
+	MissRate float64
+	// ErrorRate is the fraction of insertions that fail without storing the
+	// value. TryInsert returns Err, and Insert returns false.
+	ErrorRate float64
+	// Err is the error returned by failed insertions. Defaults to ErrInjected.
+	Err error
+	// Sleep waits for the injected latency. Defaults to time.Sleep.
+	Sleep func(time.Duration)
+}
+
+// ChaosCache wraps a Cache and injects latency, misses and failures, to test
+// how an application behaves with a slow, cold or failing cache.
+// It is as safe for concurrent use as the cache it wraps.
+type ChaosCache[K comparable, V any] struct {
+	cache Cache[K, V]
+	opts  ChaosOptions
+}
+
+// NewChaosCache wraps cache with the faults described by opts.
+func NewChaosCache[K comparable, V any](cache Cache[K, V], opts ChaosOptions) *ChaosCache[K, V] {
+	if opts.Err == nil {
+		opts.Err = ErrInjected
+	}
+	if opts.Sleep == nil {
+		opts.Sleep = time.Sleep
+	}
+	return &ChaosCache[K, V]{cache: cache, opts: opts}
+}
+
+// Unwrap returns the wrapped cache.
+func (c *ChaosCache[K, V]) Unwrap() Cache[K, V] {
+	return c.cache
+}
+
+// delay waits for the configured latency.
+func (c *ChaosCache[K, V]) delay() {
+	d := c.opts.Latency
+	if c.opts.Jitter > 0 {
+		d += time.Duration(rand.Int63n(int64(c.opts.Jitter) + 1))
+	}
+	if d > 0 {
+		c.opts.Sleep(d)
+	}
+}
+
+// inject returns true with the given probability.
+func inject(rate float64) bool {
+	return rate > 0 && rand.Float64() < rate
+}
+
+// Get looks up a key, unless a miss is injected.
+func (c *ChaosCache[K, V]) Get(key K) (V, bool) {
+	c.delay()
+	if inject(c.opts.MissRate) {
+		var zero V
+		return zero, false
+	}
+	return c.cache.Get(key)
+}
+
+// Insert inserts a key-value pair, unless a failure is injected.
+func (c *ChaosCache[K, V]) Insert(key K, value V) bool {
+	inserted, _ := c.TryInsert(key, value)
+	return inserted
+}
+
+// TryInsert inserts a key-value pair, unless a failure is injected.
+func (c *ChaosCache[K, V]) TryInsert(key K, value V) (bool, error) {
+	c.delay()
+	if inject(c.opts.ErrorRate) {
+		return false, c.opts.Err
+	}
+	return c.cache.TryInsert(key, value)
+}
+
+// Remove removes a key. When a miss is injected, the key is still removed,
+// but reported as absent.
+func (c *ChaosCache[K, V]) Remove(key K) (V, bool) {
+	c.delay()
+	value, found := c.cache.Remove(key)
+	if inject(c.opts.MissRate) {
+		var zero V
+		return zero, false
+	}
+	return value, found
+}
+
+// ContainsKey checks if a key is present, unless a miss is injected.
+func (c *ChaosCache[K, V]) ContainsKey(key K) bool {
+	c.delay()
+	return !inject(c.opts.MissRate) && c.cache.ContainsKey(key)
+}
+
+// Len returns the number of entries in the wrapped cache.
+func (c *ChaosCache[K, V]) Len() int {
+	return c.cache.Len()
+}
+
+// Capacity returns the capacity of the wrapped cache.
+func (c *ChaosCache[K, V]) Capacity() int {
+	return c.cache.Capacity()
+}
+
+// Clear removes all entries from the wrapped cache.
+func (c *ChaosCache[K, V]) Clear() {
+	c.delay()
+	c.cache.Clear()
+}
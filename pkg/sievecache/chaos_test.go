@@ -0,0 +1,43 @@
+package sievecache
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestChaosCache(t *testing.T) {
+	cache, _ := NewSync[string, int](10)
+	var slept time.Duration
+	chaos := NewChaosCache[string, int](cache, ChaosOptions{
+		Latency: time.Millisecond,
+		Sleep:   func(d time.Duration) { slept += d },
+	})
+
+	if !chaos.Insert("a", 1) {
+		t.Fatal("Expected insert to succeed without injected failures")
+	}
+	if value, found := chaos.Get("a"); !found || value != 1 {
+		t.Errorf("Expected 1, got %v (found: %v)", value, found)
+	}
+	if slept != 2*time.Millisecond {
+		t.Errorf("Expected 2ms of injected latency, got %v", slept)
+	}
+
+	chaos = NewChaosCache[string, int](cache, ChaosOptions{MissRate: 1, ErrorRate: 1})
+	if _, found := chaos.Get("a"); found {
+		t.Error("Expected an injected miss")
+	}
+	if chaos.ContainsKey("a") {
+		t.Error("Expected an injected miss")
+	}
+	if _, err := chaos.TryInsert("b", 2); !errors.Is(err, ErrInjected) {
+		t.Errorf("Expected ErrInjected, got %v", err)
+	}
+	if chaos.Insert("b", 2) || cache.ContainsKey("b") {
+		t.Error("Expected the failed insert not to store the value")
+	}
+	if !cache.ContainsKey("a") || chaos.Unwrap() != Cache[string, int](cache) {
+		t.Error("Expected the wrapped cache to be left untouched")
+	}
+}
@@ -0,0 +1,63 @@
+package sievecache
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock abstracts the source of the current time.
+// Time-based features accept a Clock so that their behavior can be tested
+// deterministically with a FakeClock instead of sleeping in tests.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+}
+
+// SystemClock is a Clock backed by time.Now.
+type SystemClock struct{}
+
+// Now returns the current wall-clock time.
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// FakeClock is a Clock whose time only changes when explicitly advanced.
+// It is safe for concurrent use.
+type FakeClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+// NewFakeClock creates a new fake clock set to the given time.
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start}
+}
+
+// Now returns the current fake time.
+func (c *FakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+// Advance moves the fake time forward by d.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Set sets the fake time to t.
+func (c *FakeClock) Set(t time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = t
+}
+
+// clockOrDefault returns clock, or the system clock if clock is nil.
+func clockOrDefault(clock Clock) Clock {
+	if clock == nil {
+		return SystemClock{}
+	}
+	return clock
+}
@@ -0,0 +1,34 @@
+package sievecache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+
+	if !clock.Now().Equal(start) {
+		t.Errorf("Expected %v, got %v", start, clock.Now())
+	}
+
+	clock.Advance(5 * time.Second)
+	if got := clock.Now().Sub(start); got != 5*time.Second {
+		t.Errorf("Expected 5s elapsed, got %v", got)
+	}
+
+	later := start.Add(time.Hour)
+	clock.Set(later)
+	if !clock.Now().Equal(later) {
+		t.Errorf("Expected %v, got %v", later, clock.Now())
+	}
+
+	// A nil clock falls back to the system clock
+	if _, ok := clockOrDefault(nil).(SystemClock); !ok {
+		t.Error("Expected clockOrDefault(nil) to return SystemClock")
+	}
+	if clockOrDefault(clock) != Clock(clock) {
+		t.Error("Expected clockOrDefault to return the provided clock")
+	}
+}
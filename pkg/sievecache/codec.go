@@ -0,0 +1,62 @@
+package sievecache
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"io"
+)
+
+// Encoder writes a stream of encoded values.
+type Encoder interface {
+	Encode(v any) error
+}
+
+// Decoder reads a stream of encoded values.
+type Decoder interface {
+	Decode(v any) error
+}
+
+// Codec defines how keys and values are serialized in cache snapshots.
+// Users can provide their own implementation (e.g. msgpack or protobuf)
+// to persist types that the built-in codecs cannot handle.
+type Codec interface {
+	// NewEncoder returns an encoder writing to w.
+	NewEncoder(w io.Writer) Encoder
+	// NewDecoder returns a decoder reading from r.
+	NewDecoder(r io.Reader) Decoder
+}
+
+// GobCodec serializes keys and values using encoding/gob.
+// This is the default codec.
+type GobCodec struct{}
+
+// NewEncoder returns a gob encoder writing to w.
+func (GobCodec) NewEncoder(w io.Writer) Encoder {
+	return gob.NewEncoder(w)
+}
+
+// NewDecoder returns a gob decoder reading from r.
+func (GobCodec) NewDecoder(r io.Reader) Decoder {
+	return gob.NewDecoder(r)
+}
+
+// JSONCodec serializes keys and values using encoding/json.
+type JSONCodec struct{}
+
+// NewEncoder returns a JSON encoder writing to w.
+func (JSONCodec) NewEncoder(w io.Writer) Encoder {
+	return json.NewEncoder(w)
+}
+
+// NewDecoder returns a JSON decoder reading from r.
+func (JSONCodec) NewDecoder(r io.Reader) Decoder {
+	return json.NewDecoder(r)
+}
+
+// codecOrDefault returns codec, or GobCodec if codec is nil.
+func codecOrDefault(codec Codec) Codec {
+	if codec == nil {
+		return GobCodec{}
+	}
+	return codec
+}
@@ -0,0 +1,95 @@
+package sievecache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Digest is the SHA-256 hash of a value, used as its key by ContentCache.
+type Digest [sha256.Size]byte
+
+// DigestOf returns the digest of value.
+func DigestOf(value []byte) Digest {
+	return sha256.Sum256(value)
+}
+
+// String returns the hexadecimal representation of the digest.
+func (d Digest) String() string {
+	return hex.EncodeToString(d[:])
+}
+
+// ContentCache is a content-addressable SIEVE cache for blobs: the key of a
+// value is its SHA-256 digest, so callers address content by hash.
+// Values are stored like in SieveByteCache, and are verified against their
+// digest whenever they are retrieved.
+// This implementation is not thread-safe.
+type ContentCache struct {
+	cache *SieveByteCache[Digest]
+}
+
+// NewContentCache creates a new content-addressable cache with the given capacity.
+// Returns an error if capacity is negative.
+func NewContentCache(capacity int) (*ContentCache, error) {
+	cache, err := NewByteCache[Digest](capacity)
+	if err != nil {
+		return nil, err
+	}
+	return &ContentCache{cache: cache}, nil
+}
+
+// Capacity returns the maximum number of entries the cache can hold.
+func (c *ContentCache) Capacity() int {
+	return c.cache.Capacity()
+}
+
+// Len returns the number of cached values.
+func (c *ContentCache) Len() int {
+	return c.cache.Len()
+}
+
+// ContainsKey returns true if there is a value with the given digest in the cache.
+func (c *ContentCache) ContainsKey(key Digest) bool {
+	return c.cache.ContainsKey(key)
+}
+
+// InsertCAS copies value into the cache, possibly evicting old entries, and
+// returns its digest, which is the key to retrieve it with.
+// Inserting a value that is already cached only marks it as visited.
+// Returns ErrValueTooLarge if value is larger than ByteCacheMaxValueSize.
+func (c *ContentCache) InsertCAS(value []byte) (Digest, error) {
+	key := DigestOf(value)
+	if _, found := c.cache.cache.Get(key); found {
+		return key, nil
+	}
+	if _, err := c.cache.Insert(key, value); err != nil {
+		return Digest{}, err
+	}
+	return key, nil
+}
+
+// Get returns a copy of the value with the given digest.
+// The value is hashed and compared to the digest; if they don't match, the
+// entry is removed and reported as missing.
+// This operation marks the entry as "visited" in the SIEVE algorithm.
+func (c *ContentCache) Get(key Digest) ([]byte, bool) {
+	value, ok := c.cache.Get(key)
+	if !ok {
+		return nil, false
+	}
+	if DigestOf(value) != key {
+		c.cache.Remove(key)
+		return nil, false
+	}
+	return value, true
+}
+
+// Remove removes the value with the given digest.
+// Returns true if it was present.
+func (c *ContentCache) Remove(key Digest) bool {
+	return c.cache.Remove(key)
+}
+
+// Clear removes all entries from the cache and releases the slabs.
+func (c *ContentCache) Clear() {
+	c.cache.Clear()
+}
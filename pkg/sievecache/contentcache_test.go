@@ -0,0 +1,62 @@
+package sievecache
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestContentCache(t *testing.T) {
+	cache, err := NewContentCache(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blob := []byte("hello, world")
+	key, err := cache.InsertCAS(blob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != DigestOf(blob) {
+		t.Errorf("Expected the key to be the digest of the value")
+	}
+	if key.String() != "09ca7e4eaa6e8ae9c7d261167129184883644d07dfba7cbfbc4c8a2e08360d5b" {
+		t.Errorf("Unexpected digest: %s", key)
+	}
+
+	// Inserting the same content again returns the same key
+	if again, _ := cache.InsertCAS(blob); again != key || cache.Len() != 1 {
+		t.Errorf("Expected identical content to be stored once")
+	}
+
+	value, found := cache.Get(key)
+	if !found || !bytes.Equal(value, blob) {
+		t.Fatalf("Expected %q, got %q", blob, value)
+	}
+	value[0] = 'X'
+	if value, _ := cache.Get(key); !bytes.Equal(value, blob) {
+		t.Error("Modifying a returned value changed the cached value")
+	}
+
+	if _, found := cache.Get(DigestOf([]byte("missing"))); found {
+		t.Error("Expected a miss for unknown content")
+	}
+
+	// Corrupted values are detected and dropped
+	ref, _ := cache.cache.cache.Get(key)
+	cache.cache.arena.bytes(ref)[0] ^= 1
+	if _, found := cache.Get(key); found {
+		t.Error("Expected a corrupted value not to be returned")
+	}
+	if cache.ContainsKey(key) {
+		t.Error("Expected a corrupted value to be removed")
+	}
+
+	// Eviction follows the SIEVE policy
+	a, _ := cache.InsertCAS([]byte("a"))
+	b, _ := cache.InsertCAS([]byte("b"))
+	cache.Get(a)
+	c, _ := cache.InsertCAS([]byte("c"))
+	if !cache.ContainsKey(a) || cache.ContainsKey(b) || !cache.ContainsKey(c) {
+		t.Error("Expected the unvisited value to be evicted")
+	}
+}
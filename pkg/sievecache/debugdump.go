@@ -0,0 +1,186 @@
+package sievecache
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// DebugFormat is the output format of DebugDumpWithOptions.
+type DebugFormat int
+
+const (
+	// DebugFormatJSON describes the cache as a JSON document.
+	DebugFormatJSON DebugFormat = iota
+	// DebugFormatDOT describes the cache as a Graphviz graph.
+	DebugFormatDOT
+)
+
+// DebugDumpOptions configures DebugDumpWithOptions.
+type DebugDumpOptions struct {
+	// Format is the output format, JSON by default.
+	Format DebugFormat
+	// HashKeys replaces keys with a prefix of the SHA-256 hash of their
+	// string representation, so that dumps don't reveal them. Hashes are
+	// stable, so entries can be followed across dumps, but low-entropy keys
+	// can still be recovered by brute force.
+	HashKeys bool
+	// RedactKey, if set, replaces keys instead, for example with
+	// RedactHMAC, which resists brute force.
+	RedactKey KeyRedactor
+}
+
+// DebugEntry describes an entry in a debug dump.
+type DebugEntry struct {
+	Key     string `json:"key"`
+	Visited bool   `json:"visited"`
+}
+
+// DebugState describes the internal state of a cache in a debug dump.
+// Entries are listed in slot order; the hand moves from its position towards
+// slot 0, then wraps around to the last slot.
+type DebugState struct {
+	Capacity int `json:"capacity"`
+	// Hand is the slot of the next eviction candidate, or -1 if the hand
+	// hasn't moved yet, in which case evictions start from the last slot
+	Hand    int          `json:"hand"`
+	Entries []DebugEntry `json:"entries"`
+}
+
+// debugState captures the state of the cache.
+// It can be called concurrently with lookups under a read lock.
+func (c *SieveCache[K, V]) debugState(opts DebugDumpOptions) DebugState {
+	state := DebugState{
+		Capacity: c.capacity,
+		Hand:     -1,
+		Entries:  make([]DebugEntry, len(c.nodes)),
+	}
+	if c.handInitialized {
+		state.Hand = c.hand
+	}
+	redact := opts.RedactKey
+	if redact == nil && opts.HashKeys {
+		redact = RedactSHA256
+	}
+	for i := range c.nodes {
+		key := ToString(c.nodes[i].Key)
+		if redact != nil {
+			key = redact(key)
+		}
+		state.Entries[i] = DebugEntry{
+			Key:     key,
+			Visited: c.visited.LoadWord(i>>6)&(1<<(i&0x3F)) != 0,
+		}
+	}
+	return state
+}
+
+// DebugDump writes a JSON description of the slot order, visited flags and
+// hand position of the cache to w, to visualize how SIEVE makes its decisions.
+func (c *SieveCache[K, V]) DebugDump(w io.Writer) error {
+	return c.DebugDumpWithOptions(w, DebugDumpOptions{})
+}
+
+// DebugDumpWithOptions writes a description of the internal state of the
+// cache to w, in the format and with the key hashing set by opts.
+func (c *SieveCache[K, V]) DebugDumpWithOptions(w io.Writer, opts DebugDumpOptions) error {
+	return writeDebugStates(w, []DebugState{c.debugState(opts)}, opts.Format, false)
+}
+
+// DebugDump writes a JSON description of the internal state of the cache to w.
+// See SieveCache.DebugDump for details.
+func (c *SyncSieveCache[K, V]) DebugDump(w io.Writer) error {
+	return c.DebugDumpWithOptions(w, DebugDumpOptions{})
+}
+
+// DebugDumpWithOptions writes a description of the internal state of the
+// cache to w. See SieveCache.DebugDumpWithOptions for details.
+func (c *SyncSieveCache[K, V]) DebugDumpWithOptions(w io.Writer, opts DebugDumpOptions) error {
+	c.rlock()
+	state := c.cache.debugState(opts)
+	c.runlock()
+	return writeDebugStates(w, []DebugState{state}, opts.Format, false)
+}
+
+// DebugDump writes a JSON array describing the internal state of every shard to w.
+// See SieveCache.DebugDump for details.
+func (c *ShardedSieveCache[K, V]) DebugDump(w io.Writer) error {
+	return c.DebugDumpWithOptions(w, DebugDumpOptions{})
+}
+
+// DebugDumpWithOptions writes a description of the internal state of every
+// shard to w. Shards are captured one at a time, so the dump isn't an atomic
+// snapshot of the whole cache. See SieveCache.DebugDumpWithOptions for details.
+func (c *ShardedSieveCache[K, V]) DebugDumpWithOptions(w io.Writer, opts DebugDumpOptions) error {
+	shards := c.table.Load().all()
+	states := make([]DebugState, len(shards))
+	for i, shard := range shards {
+		shard.rlock()
+		states[i] = shard.cache.debugState(opts)
+		shard.runlock()
+	}
+	return writeDebugStates(w, states, opts.Format, true)
+}
+
+// writeDebugStates renders states in the given format. Sharded caches are
+// rendered as a JSON array or as one DOT cluster per shard.
+func writeDebugStates(w io.Writer, states []DebugState, format DebugFormat, sharded bool) error {
+	switch format {
+	case DebugFormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if sharded {
+			return enc.Encode(states)
+		}
+		return enc.Encode(states[0])
+	case DebugFormatDOT:
+		bw := bufio.NewWriter(w)
+		fmt.Fprintln(bw, "digraph sieve {")
+		fmt.Fprintln(bw, "  rankdir=RL;")
+		fmt.Fprintln(bw, "  node [shape=box, style=filled, fillcolor=white];")
+		for i, state := range states {
+			indent := "  "
+			if sharded {
+				fmt.Fprintf(bw, "  subgraph cluster_%d {\n    label=\"shard %d\";\n", i, i)
+				indent = "    "
+			}
+			writeDebugGraph(bw, indent, fmt.Sprintf("s%d_", i), state)
+			if sharded {
+				fmt.Fprintln(bw, "  }")
+			}
+		}
+		fmt.Fprintln(bw, "}")
+		return bw.Flush()
+	default:
+		return fmt.Errorf("SieveCache: unknown debug format %d", format)
+	}
+}
+
+// writeDebugGraph writes the nodes and edges describing a single cache.
+// Visited entries are filled in gray, and edges follow the hand's direction.
+func writeDebugGraph(w io.Writer, indent, prefix string, state DebugState) {
+	n := len(state.Entries)
+	for i, entry := range state.Entries {
+		fill := ""
+		if entry.Visited {
+			fill = ", fillcolor=gray"
+		}
+		fmt.Fprintf(w, "%s%s%d [label=%s%s];\n", indent, prefix, i, strconv.Quote(entry.Key), fill)
+	}
+	for i := n - 1; i > 0; i-- {
+		fmt.Fprintf(w, "%s%s%d -> %s%d;\n", indent, prefix, i, prefix, i-1)
+	}
+	if n > 1 {
+		fmt.Fprintf(w, "%s%s0 -> %s%d [style=dashed];\n", indent, prefix, prefix, n-1)
+	}
+	if n > 0 {
+		hand := state.Hand
+		if hand < 0 {
+			hand = n - 1
+		}
+		fmt.Fprintf(w, "%s%shand [label=\"hand\", shape=plaintext, style=\"\"];\n", indent, prefix)
+		fmt.Fprintf(w, "%s%shand -> %s%d;\n", indent, prefix, prefix, hand)
+	}
+}
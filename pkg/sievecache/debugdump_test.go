@@ -0,0 +1,110 @@
+package sievecache
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestDebugDump(t *testing.T) {
+	cache, _ := New[string, int](3)
+	cache.Insert("a", 1)
+	cache.Insert("b", 2)
+	cache.Insert("c", 3)
+	cache.Get("a")
+
+	var buf bytes.Buffer
+	if err := cache.DebugDump(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var state DebugState
+	if err := json.Unmarshal(buf.Bytes(), &state); err != nil {
+		t.Fatal(err)
+	}
+	expected := []DebugEntry{{"a", true}, {"b", false}, {"c", false}}
+	if state.Capacity != 3 || state.Hand != -1 || len(state.Entries) != 3 {
+		t.Fatalf("Unexpected state: %+v", state)
+	}
+	for i, entry := range expected {
+		if state.Entries[i] != entry {
+			t.Errorf("Expected entry %d to be %+v, got %+v", i, entry, state.Entries[i])
+		}
+	}
+
+	// Evicting c moves the hand to b
+	cache.Insert("d", 4)
+	buf.Reset()
+	cache.DebugDump(&buf)
+	json.Unmarshal(buf.Bytes(), &state)
+	if state.Hand != 1 {
+		t.Errorf("Expected the hand at slot 1, got %d", state.Hand)
+	}
+
+	buf.Reset()
+	if err := cache.DebugDumpWithOptions(&buf, DebugDumpOptions{HashKeys: true}); err != nil {
+		t.Fatal(err)
+	}
+	json.Unmarshal(buf.Bytes(), &state)
+	// First 8 bytes of SHA-256("a")
+	if state.Entries[0].Key != "ca978112ca1bbdca" {
+		t.Errorf("Expected a hashed key, got %q", state.Entries[0].Key)
+	}
+
+	buf.Reset()
+	redact := RedactHMAC([]byte("secret"))
+	if err := cache.DebugDumpWithOptions(&buf, DebugDumpOptions{HashKeys: true, RedactKey: redact}); err != nil {
+		t.Fatal(err)
+	}
+	json.Unmarshal(buf.Bytes(), &state)
+	if key := state.Entries[0].Key; key != redact("a") || key == "ca978112ca1bbdca" || len(key) != 16 {
+		t.Errorf("Expected an HMAC of the key, got %q", key)
+	}
+	if redact("a") == RedactHMAC([]byte("other"))("a") {
+		t.Error("Expected redacted keys to depend on the secret")
+	}
+
+	buf.Reset()
+	if err := cache.DebugDumpWithOptions(&buf, DebugDumpOptions{Format: DebugFormatDOT}); err != nil {
+		t.Fatal(err)
+	}
+	dot := buf.String()
+	for _, s := range []string{"digraph sieve {", `s0_0 [label="a", fillcolor=gray];`, "s0_2 -> s0_1;", "s0_hand -> s0_1;"} {
+		if !strings.Contains(dot, s) {
+			t.Errorf("Expected the DOT output to contain %q:\n%s", s, dot)
+		}
+	}
+
+	if err := cache.DebugDumpWithOptions(&buf, DebugDumpOptions{Format: 42}); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
+
+func TestShardedDebugDump(t *testing.T) {
+	cache, _ := NewShardedWithShards[int, int](100, 4)
+	for i := 0; i < 20; i++ {
+		cache.Insert(i, i)
+	}
+
+	var buf bytes.Buffer
+	if err := cache.DebugDump(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var states []DebugState
+	if err := json.Unmarshal(buf.Bytes(), &states); err != nil {
+		t.Fatal(err)
+	}
+	total := 0
+	for _, state := range states {
+		total += len(state.Entries)
+	}
+	if len(states) != 4 || total != 20 {
+		t.Errorf("Expected 4 shards with 20 entries, got %d shards with %d entries", len(states), total)
+	}
+
+	buf.Reset()
+	cache.DebugDumpWithOptions(&buf, DebugDumpOptions{Format: DebugFormatDOT})
+	if strings.Count(buf.String(), "subgraph cluster_") != 4 {
+		t.Errorf("Expected one cluster per shard:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,84 @@
+package sievecache
+
+import "math/bits"
+
+// doorkeeperBitsPerKey is the number of bits of a doorkeeper generation per
+// key it remembers, which gives a false positive rate below 1% with 4 hash
+// functions.
+const doorkeeperBitsPerKey = 12
+
+// doorkeeper is a rotating Bloom filter remembering the keys of new entries
+// that were turned away, so that they are only stored when seen a second
+// time. Keys are added to the current generation, and looked up in both the
+// current and the previous ones. Once the current generation holds as many
+// keys as it was sized for, it replaces the previous one, so that keys are
+// remembered for between 1 and 2 generations, and the false positive rate
+// stays bounded. It is not safe for concurrent use.
+type doorkeeper struct {
+	current  []uint64
+	previous []uint64
+	mask     uint64
+	count    int
+	size     int
+}
+
+// newDoorkeeper creates a doorkeeper remembering size keys per generation.
+func newDoorkeeper(size int) *doorkeeper {
+	size = max(size, 1)
+	words := max(1, min(size, 1<<30)*doorkeeperBitsPerKey/64)
+	words = 1 << bits.Len(uint(words-1))
+	return &doorkeeper{
+		current:  make([]uint64, words),
+		previous: make([]uint64, words),
+		mask:     uint64(words*64 - 1),
+		size:     size,
+	}
+}
+
+// bitPositions returns the positions of the 4 bits of a key hash, derived by
+// double hashing.
+func (d *doorkeeper) bitPositions(hash uint64) [4]uint64 {
+	h2 := bits.RotateLeft64(hash, 32) | 1
+	var pos [4]uint64
+	for i := range pos {
+		pos[i] = (hash + uint64(i)*h2) & d.mask
+	}
+	return pos
+}
+
+// filterContains returns true if all the bits of pos are set in filter.
+func filterContains(filter []uint64, pos [4]uint64) bool {
+	for _, p := range pos {
+		if filter[p>>6]&(1<<(p&63)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// admit returns true if the key with the given hash was seen recently, and
+// otherwise remembers it and returns false.
+func (d *doorkeeper) admit(hash uint64) bool {
+	pos := d.bitPositions(hash)
+	if filterContains(d.current, pos) || filterContains(d.previous, pos) {
+		return true
+	}
+	for _, p := range pos {
+		d.current[p>>6] |= 1 << (p & 63)
+	}
+	d.count++
+	if d.count >= d.size {
+		d.previous, d.current = d.current, d.previous
+		clear(d.current)
+		d.count = 0
+	}
+	return false
+}
+
+// clone returns an independent copy of the doorkeeper.
+func (d *doorkeeper) clone() *doorkeeper {
+	clone := *d
+	clone.current = append([]uint64(nil), d.current...)
+	clone.previous = append([]uint64(nil), d.previous...)
+	return &clone
+}
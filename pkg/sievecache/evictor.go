@@ -0,0 +1,95 @@
+package sievecache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// evictAheadBatchSize is the maximum number of entries a SyncSieveCache
+// evicts ahead of demand while holding its lock.
+const evictAheadBatchSize = 64
+
+// AheadEvictor is implemented by caches that can evict entries ahead of demand.
+type AheadEvictor interface {
+	Capacity() int
+	EvictAhead(headroom int) int
+}
+
+// BackgroundEvictorOptions configures a BackgroundEvictor.
+// Zero values are replaced with sensible defaults.
+type BackgroundEvictorOptions struct {
+	// Interval between two eviction passes. Defaults to 10 milliseconds.
+	Interval time.Duration
+	// Headroom is the number of free slots to maintain.
+	// Defaults to 1% of the capacity, with a minimum of 1.
+	Headroom int
+}
+
+// BackgroundEvictor keeps a few slots of a cache free by evicting entries
+// from a background goroutine, so that inserts don't pay the eviction cost
+// inline. Inserts still evict if a burst fills the headroom between two passes.
+type BackgroundEvictor struct {
+	cache AheadEvictor
+	opts  BackgroundEvictorOptions
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewBackgroundEvictor starts evicting entries of cache ahead of demand.
+// The cache must be safe for concurrent use.
+func NewBackgroundEvictor(cache AheadEvictor, opts BackgroundEvictorOptions) *BackgroundEvictor {
+	if opts.Interval <= 0 {
+		opts.Interval = 10 * time.Millisecond
+	}
+
+	e := &BackgroundEvictor{
+		cache: cache,
+		opts:  opts,
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+// run evicts entries periodically until the evictor is closed.
+func (e *BackgroundEvictor) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(e.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			e.evict()
+		case <-e.stop:
+			return
+		}
+	}
+}
+
+// evict makes room for the configured headroom.
+func (e *BackgroundEvictor) evict() int {
+	headroom := e.opts.Headroom
+	if headroom <= 0 {
+		// The capacity may have changed since the evictor was started
+		headroom = max(1, e.cache.Capacity()/100)
+	}
+	return e.cache.EvictAhead(headroom)
+}
+
+// Close stops the evictor.
+func (e *BackgroundEvictor) Close() {
+	e.Shutdown(context.Background())
+}
+
+// Shutdown stops the evictor like Close, but stops waiting for its goroutine
+// to exit when ctx is done, and returns the error of ctx.
+func (e *BackgroundEvictor) Shutdown(ctx context.Context) error {
+	e.closeOnce.Do(func() {
+		close(e.stop)
+	})
+	return waitDone(ctx, e.done)
+}
@@ -0,0 +1,72 @@
+package sievecache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEvictAhead(t *testing.T) {
+	cache, _ := New[int, int](100)
+	for i := 0; i < 100; i++ {
+		cache.Insert(i, i)
+	}
+	if n := cache.EvictAhead(10); n != 10 || cache.Len() != 90 {
+		t.Errorf("Expected 10 evictions leaving 90 entries, got %d and %d", n, cache.Len())
+	}
+	if n := cache.EvictAhead(10); n != 0 {
+		t.Errorf("Expected no eviction with enough headroom, got %d", n)
+	}
+	if n := cache.EvictAhead(1000); n != 90 || !cache.IsEmpty() {
+		t.Errorf("Expected the headroom to be capped to the capacity, got %d evictions", n)
+	}
+
+	syncCache, _ := NewSync[int, int](1000)
+	for i := 0; i < 1000; i++ {
+		syncCache.Insert(i, i)
+	}
+	// More than one batch
+	if n := syncCache.EvictAhead(200); n != 200 || syncCache.Len() != 800 {
+		t.Errorf("Expected 200 evictions leaving 800 entries, got %d and %d", n, syncCache.Len())
+	}
+
+	sharded, _ := NewShardedWithShards[int, int](100, 4)
+	for i := 0; i < 1000; i++ {
+		sharded.Insert(i, i)
+	}
+	sharded.EvictAhead(8)
+	for i := 0; i < sharded.NumShards(); i++ {
+		shard := sharded.GetShardByIndex(i)
+		if free := shard.Capacity() - shard.Len(); free < 2 {
+			t.Errorf("Expected at least 2 free slots in shard %d, got %d", i, free)
+		}
+	}
+}
+
+func TestBackgroundEvictor(t *testing.T) {
+	cache, _ := NewSync[int, int](1000)
+	e := NewBackgroundEvictor(cache, BackgroundEvictorOptions{Interval: time.Millisecond})
+	defer e.Close()
+
+	for i := 0; i < 1000; i++ {
+		cache.Insert(i, i)
+	}
+
+	// The default headroom is 1% of the capacity
+	deadline := time.Now().Add(5 * time.Second)
+	for cache.Len() > 990 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the evictor to free 10 slots, got %d entries", cache.Len())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	e.Close()
+	e.Close()
+	for i := 1000; i < 2000; i++ {
+		cache.Insert(i, i)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if cache.Len() != 1000 {
+		t.Errorf("Expected no eviction after Close, got %d entries", cache.Len())
+	}
+}
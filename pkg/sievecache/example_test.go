@@ -123,3 +123,17 @@ func ExampleSieveCache_RecommendedCapacity() {
 
 	// Note: Exact output may vary slightly, so we're not including the Output comment
 }
+
+// ExampleShardedSieveCache_RecommendedCapacity shows how the utilization of a
+// sharded cache is aggregated across shards
+func ExampleShardedSieveCache_RecommendedCapacity() {
+	cache, _ := sievecache.NewShardedWithShards[string, int](100, 4)
+	for i := 0; i < 80; i++ {
+		cache.Insert(fmt.Sprintf("key%d", i), i)
+	}
+
+	fmt.Printf("Fill ratio: %.2f, visited ratio: %.2f\n", cache.FillRatio(), cache.VisitedRatio())
+	fmt.Printf("Recommended capacity: %d\n", cache.RecommendedCapacity(0.5, 2.0, 0.3, 0.7))
+
+	// Keys are spread across shards with a random seed, so the output varies
+}
@@ -0,0 +1,100 @@
+package sievecache
+
+// FrozenCache is an immutable copy of a cache, returned by Freeze.
+// It has no methods to modify it, and lookups don't update any state, so it
+// can be shared between goroutines without locking. It is meant for serving
+// prebuilt lookup tables, where a write would indicate a bug.
+type FrozenCache[K comparable, V any] struct {
+	indices slotIndex[K, V]
+	nodes   []Node[K, V]
+}
+
+// Freeze returns an immutable copy of the entries of the cache.
+// Later changes to the cache don't affect the copy.
+func (c *SieveCache[K, V]) Freeze() *FrozenCache[K, V] {
+	nodes := make([]Node[K, V], len(c.nodes))
+	copy(nodes, c.nodes)
+	return &FrozenCache[K, V]{
+		indices: c.indices.clone(),
+		nodes:   nodes,
+	}
+}
+
+// Freeze returns an immutable copy of the entries of the cache.
+// See SieveCache.Freeze for details.
+func (c *SyncSieveCache[K, V]) Freeze() *FrozenCache[K, V] {
+	c.rlock()
+	defer c.runlock()
+	return c.cache.Freeze()
+}
+
+// Freeze returns an immutable copy of the entries of every shard.
+// Shards are copied one at a time, so the copy isn't an atomic snapshot of
+// the whole cache. See SieveCache.Freeze for details.
+func (c *ShardedSieveCache[K, V]) Freeze() *FrozenCache[K, V] {
+	shards := c.table.Load().all()
+	indices, _ := newSlotIndex[K, V](shards[0].cache.indices.kind(), c.Len())
+	frozen := &FrozenCache[K, V]{indices: indices}
+	for _, shard := range shards {
+		shard.rlock()
+		for _, node := range shard.cache.nodes {
+			// During a reshard, an entry may briefly exist in two shards;
+			// the current shards are visited last and win
+			if idx, exists := frozen.indices.get(node.Key, frozen.nodes); exists {
+				frozen.nodes[idx] = node
+				continue
+			}
+			frozen.indices.add(node.Key, len(frozen.nodes))
+			frozen.nodes = append(frozen.nodes, node)
+		}
+		shard.runlock()
+	}
+	return frozen
+}
+
+// Len returns the number of entries.
+func (c *FrozenCache[K, V]) Len() int {
+	return len(c.nodes)
+}
+
+// ContainsKey returns true if there is a value mapped to by key.
+func (c *FrozenCache[K, V]) ContainsKey(key K) bool {
+	_, exists := c.indices.get(key, c.nodes)
+	return exists
+}
+
+// Get returns the value mapped to by key.
+func (c *FrozenCache[K, V]) Get(key K) (V, bool) {
+	idx, exists := c.indices.get(key, c.nodes)
+	if !exists {
+		var zero V
+		return zero, false
+	}
+	return c.nodes[idx].Value, true
+}
+
+// Keys returns a slice of all keys.
+func (c *FrozenCache[K, V]) Keys() []K {
+	keys := make([]K, len(c.nodes))
+	for i := range c.nodes {
+		keys[i] = c.nodes[i].Key
+	}
+	return keys
+}
+
+// Items returns a slice of all key-value pairs.
+func (c *FrozenCache[K, V]) Items() []Item[K, V] {
+	items := make([]Item[K, V], len(c.nodes))
+	for i := range c.nodes {
+		items[i] = Item[K, V]{Key: c.nodes[i].Key, Value: c.nodes[i].Value}
+	}
+	return items
+}
+
+// ForEach calls f for every key-value pair.
+// The iteration order is not specified and should not be relied upon.
+func (c *FrozenCache[K, V]) ForEach(f func(k K, v V)) {
+	for i := range c.nodes {
+		f(c.nodes[i].Key, c.nodes[i].Value)
+	}
+}
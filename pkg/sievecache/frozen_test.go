@@ -0,0 +1,66 @@
+package sievecache
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestFreeze(t *testing.T) {
+	cache, _ := New[string, int](10)
+	cache.Insert("a", 1)
+	cache.Insert("b", 2)
+
+	frozen := cache.Freeze()
+	cache.Insert("a", 10)
+	cache.Insert("c", 3)
+	cache.Remove("b")
+
+	if frozen.Len() != 2 {
+		t.Errorf("Expected 2 entries, got %d", frozen.Len())
+	}
+	if value, found := frozen.Get("a"); !found || value != 1 {
+		t.Errorf("Expected a to map to 1, got %d, %v", value, found)
+	}
+	if !frozen.ContainsKey("b") || frozen.ContainsKey("c") {
+		t.Error("Expected the frozen copy not to see later changes")
+	}
+	if len(frozen.Keys()) != 2 || len(frozen.Items()) != 2 {
+		t.Error("Expected 2 keys and items")
+	}
+	sum := 0
+	frozen.ForEach(func(_ string, v int) { sum += v })
+	if sum != 3 {
+		t.Errorf("Expected a sum of 3, got %d", sum)
+	}
+
+	// Concurrent lookups need no locking
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				frozen.Get("a")
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestShardedFreeze(t *testing.T) {
+	cache, _ := NewShardedWithShards[int, int](1000, 8)
+	for i := 0; i < 500; i++ {
+		cache.Insert(i, i)
+	}
+	frozen := cache.Freeze()
+	cache.Clear()
+
+	if frozen.Len() != 500 {
+		t.Fatalf("Expected 500 entries, got %d", frozen.Len())
+	}
+	for i := 0; i < 500; i++ {
+		if value, found := frozen.Get(i); !found || value != i {
+			t.Fatalf("Expected %d to map to itself, got %d, %v", i, value, found)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package sievecache
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"runtime/metrics"
+	"sync"
+	"time"
+)
+
+// Resizable is implemented by caches whose capacity can be changed at runtime.
+type Resizable interface {
+	Capacity() int
+	SetCapacity(capacity int) error
+}
+
+// MemoryGovernorOptions configures a MemoryGovernor.
+// Zero values are replaced with sensible defaults.
+type MemoryGovernorOptions struct {
+	// Interval between two memory pressure checks. Defaults to 1 second.
+	Interval time.Duration
+	// HighWatermark is the pressure above which the capacity is reduced. Defaults to 0.9.
+	HighWatermark float64
+	// LowWatermark is the pressure below which the capacity grows back. Defaults to 0.7.
+	LowWatermark float64
+	// ShrinkFactor is applied to the capacity on every check above the high
+	// watermark, and its inverse on every check below the low watermark. Defaults to 0.75.
+	ShrinkFactor float64
+	// MinCapacity is the capacity below which the cache is never shrunk. Defaults to 1.
+	MinCapacity int
+	// Pressure returns the current memory pressure, as the fraction of the
+	// memory limit in use. Defaults to a function that compares the memory
+	// used by the Go runtime with GOMEMLIMIT, and returns 0 if no limit is set.
+	Pressure func() float64
+	// HistorySize is the number of recent resize decisions kept for Stats.
+	// Defaults to 32.
+	HistorySize int
+	// Clock is the source of the time of resize decisions. Defaults to the
+	// system clock.
+	Clock Clock
+	// Logger, if set, reports every resize decision.
+	Logger *slog.Logger
+}
+
+// ResizeDecision is a capacity change made by a MemoryGovernor, with the
+// observations that triggered it.
+type ResizeDecision struct {
+	// Time is when the decision was made
+	Time time.Time
+	// OldCapacity and NewCapacity are the capacities before and after
+	OldCapacity int
+	NewCapacity int
+	// Pressure is the memory pressure that was observed
+	Pressure float64
+	// Len is the number of entries at the time, or -1 if the cache doesn't
+	// report it
+	Len int
+}
+
+// MemoryGovernorStats describes the activity of a MemoryGovernor.
+type MemoryGovernorStats struct {
+	// Checks is the number of times memory pressure was checked
+	Checks uint64
+	// Shrinks and Grows count the capacity changes in each direction
+	Shrinks uint64
+	Grows   uint64
+	// Decisions are the most recent capacity changes, oldest first
+	Decisions []ResizeDecision
+}
+
+// MemoryGovernor adjusts the capacity of a cache according to memory pressure.
+// It shrinks the cache when the process approaches its memory limit and grows
+// it back, up to its original capacity, once the pressure subsides.
+// An Unbounded cache is shrunk from its current number of entries, so that
+// its size is only governed by memory pressure.
+type MemoryGovernor struct {
+	cache       Resizable
+	opts        MemoryGovernorOptions
+	maxCapacity int
+
+	// Protects stats and history, updated by the governor's goroutine
+	statsMutex  sync.Mutex
+	stats       MemoryGovernorStats
+	history     []ResizeDecision
+	historyNext int
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewMemoryGovernor starts watching memory pressure and resizing cache accordingly.
+// The current capacity of the cache is used as the maximum capacity.
+// The cache must be safe for concurrent use.
+func NewMemoryGovernor(cache Resizable, opts MemoryGovernorOptions) *MemoryGovernor {
+	if opts.Interval <= 0 {
+		opts.Interval = time.Second
+	}
+	if opts.HighWatermark <= 0 {
+		opts.HighWatermark = 0.9
+	}
+	if opts.LowWatermark <= 0 {
+		opts.LowWatermark = 0.7
+	}
+	if opts.ShrinkFactor <= 0 || opts.ShrinkFactor >= 1 {
+		opts.ShrinkFactor = 0.75
+	}
+	if opts.MinCapacity <= 0 {
+		opts.MinCapacity = 1
+	}
+	if opts.Pressure == nil {
+		opts.Pressure = RuntimeMemoryPressure
+	}
+	if opts.HistorySize <= 0 {
+		opts.HistorySize = 32
+	}
+	opts.Clock = clockOrDefault(opts.Clock)
+
+	g := &MemoryGovernor{
+		cache:       cache,
+		opts:        opts,
+		maxCapacity: cache.Capacity(),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go g.run()
+	return g
+}
+
+// run checks memory pressure periodically until the governor is closed.
+func (g *MemoryGovernor) run() {
+	defer close(g.done)
+	ticker := time.NewTicker(g.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			g.adjust()
+		case <-g.stop:
+			return
+		}
+	}
+}
+
+// adjust resizes the cache according to the current memory pressure.
+func (g *MemoryGovernor) adjust() {
+	pressure := g.opts.Pressure()
+	capacity := g.cache.Capacity()
+	length := -1
+	if cache, ok := g.cache.(interface{ Len() int }); ok {
+		length = cache.Len()
+	}
+
+	newCapacity := capacity
+	if pressure >= g.opts.HighWatermark {
+		size := capacity
+		if length >= 0 && capacity == Unbounded {
+			size = length
+		}
+		// Shrinking never grows a cache that is already below MinCapacity
+		newCapacity = min(capacity, max(g.opts.MinCapacity, int(float64(size)*g.opts.ShrinkFactor)))
+	} else if pressure <= g.opts.LowWatermark {
+		// Compare as floats, as the grown capacity may not fit in an int
+		grown := math.Ceil(float64(capacity) / g.opts.ShrinkFactor)
+		newCapacity = g.maxCapacity
+		if grown < float64(g.maxCapacity) {
+			newCapacity = int(grown)
+		}
+	}
+
+	var decision *ResizeDecision
+	if newCapacity != capacity && g.cache.SetCapacity(newCapacity) == nil {
+		decision = &ResizeDecision{
+			Time:        g.opts.Clock.Now(),
+			OldCapacity: capacity,
+			NewCapacity: newCapacity,
+			Pressure:    pressure,
+			Len:         length,
+		}
+	}
+	g.record(decision)
+	if decision != nil && g.opts.Logger != nil {
+		g.opts.Logger.Info("SieveCache: memory pressure changed the capacity",
+			"old_capacity", decision.OldCapacity, "new_capacity", decision.NewCapacity,
+			"pressure", decision.Pressure, "len", decision.Len)
+	}
+}
+
+// record counts a check, and the resize decision it led to, if any.
+func (g *MemoryGovernor) record(decision *ResizeDecision) {
+	g.statsMutex.Lock()
+	defer g.statsMutex.Unlock()
+	g.stats.Checks++
+	if decision == nil {
+		return
+	}
+	if decision.NewCapacity < decision.OldCapacity {
+		g.stats.Shrinks++
+	} else {
+		g.stats.Grows++
+	}
+	if len(g.history) < g.opts.HistorySize {
+		g.history = append(g.history, *decision)
+		return
+	}
+	g.history[g.historyNext] = *decision
+	g.historyNext = (g.historyNext + 1) % len(g.history)
+}
+
+// Stats returns the activity of the governor, including its most recent
+// resize decisions, to audit why the capacity of the cache changed.
+func (g *MemoryGovernor) Stats() MemoryGovernorStats {
+	g.statsMutex.Lock()
+	defer g.statsMutex.Unlock()
+	stats := g.stats
+	stats.Decisions = make([]ResizeDecision, 0, len(g.history))
+	stats.Decisions = append(stats.Decisions, g.history[g.historyNext:]...)
+	stats.Decisions = append(stats.Decisions, g.history[:g.historyNext]...)
+	return stats
+}
+
+// Close stops the governor. The cache keeps its current capacity.
+func (g *MemoryGovernor) Close() {
+	g.Shutdown(context.Background())
+}
+
+// Shutdown stops the governor like Close, but stops waiting for its goroutine
+// to exit when ctx is done, and returns the error of ctx.
+func (g *MemoryGovernor) Shutdown(ctx context.Context) error {
+	g.closeOnce.Do(func() {
+		close(g.stop)
+	})
+	return waitDone(ctx, g.done)
+}
+
+// runtimeMemorySamples are the runtime metrics used to compute memory pressure.
+var runtimeMemorySamples = []string{
+	"/gc/gomemlimit:bytes",
+	"/memory/classes/total:bytes",
+	"/memory/classes/heap/released:bytes",
+}
+
+// RuntimeMemoryPressure returns the memory used by the Go runtime as a fraction
+// of the limit set with GOMEMLIMIT or debug.SetMemoryLimit.
+// Returns 0 if no memory limit is set.
+func RuntimeMemoryPressure() float64 {
+	samples := make([]metrics.Sample, len(runtimeMemorySamples))
+	for i, name := range runtimeMemorySamples {
+		samples[i].Name = name
+	}
+	metrics.Read(samples)
+
+	for _, sample := range samples {
+		if sample.Value.Kind() != metrics.KindUint64 {
+			return 0
+		}
+	}
+	limit := samples[0].Value.Uint64()
+	if limit == 0 || limit == math.MaxInt64 {
+		return 0
+	}
+
+	// This mirrors how the runtime accounts for memory against the limit
+	used := samples[1].Value.Uint64() - samples[2].Value.Uint64()
+	return float64(used) / float64(limit)
+}
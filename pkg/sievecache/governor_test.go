@@ -0,0 +1,180 @@
+package sievecache
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"math"
+	"runtime/debug"
+	"testing"
+	"time"
+)
+
+func TestSetCapacity(t *testing.T) {
+	cache, _ := New[string, int](10)
+	for i := 0; i < 10; i++ {
+		cache.Insert(fmt.Sprintf("key%d", i), i)
+	}
+
+	if err := cache.SetCapacity(4); err != nil {
+		t.Fatalf("SetCapacity failed: %v", err)
+	}
+	if cache.Len() != 4 || cache.Capacity() != 4 {
+		t.Errorf("Expected 4 entries with capacity 4, got len=%d cap=%d", cache.Len(), cache.Capacity())
+	}
+
+	if err := cache.SetCapacity(20); err != nil {
+		t.Fatalf("SetCapacity failed: %v", err)
+	}
+	for i := 0; i < 30; i++ {
+		cache.Insert(fmt.Sprintf("new%d", i), i)
+	}
+	if cache.Len() != 20 {
+		t.Errorf("Expected 20 entries, got %d", cache.Len())
+	}
+
+	if err := cache.SetCapacity(-1); err == nil {
+		t.Error("Expected error for negative capacity")
+	}
+
+	sharded, _ := NewShardedWithShards[string, int](100, 4)
+	if err := sharded.SetCapacity(10); err != nil {
+		t.Fatalf("SetCapacity failed: %v", err)
+	}
+	if sharded.Capacity() != 10 {
+		t.Errorf("Expected capacity 10, got %d", sharded.Capacity())
+	}
+}
+
+func TestMemoryGovernorAdjust(t *testing.T) {
+	cache, _ := NewSync[int, int](1000)
+	pressure := 0.0
+
+	g := NewMemoryGovernor(cache, MemoryGovernorOptions{
+		Interval:    time.Hour,
+		MinCapacity: 500,
+		Pressure:    func() float64 { return pressure },
+	})
+	defer g.Close()
+
+	// High pressure shrinks the cache down to the minimum capacity
+	pressure = 0.95
+	g.adjust()
+	if cache.Capacity() != 750 {
+		t.Errorf("Expected capacity 750, got %d", cache.Capacity())
+	}
+	g.adjust()
+	g.adjust()
+	if cache.Capacity() != 500 {
+		t.Errorf("Expected capacity to stop at 500, got %d", cache.Capacity())
+	}
+
+	// Moderate pressure keeps the capacity
+	pressure = 0.8
+	g.adjust()
+	if cache.Capacity() != 500 {
+		t.Errorf("Expected capacity 500, got %d", cache.Capacity())
+	}
+
+	// Low pressure grows the cache back up to its original capacity
+	pressure = 0.1
+	for i := 0; i < 10; i++ {
+		g.adjust()
+	}
+	if cache.Capacity() != 1000 {
+		t.Errorf("Expected capacity 1000, got %d", cache.Capacity())
+	}
+}
+
+func TestMemoryGovernorStats(t *testing.T) {
+	cache, _ := NewSync[int, int](1000)
+	clock := NewFakeClock(time.Unix(1700000000, 0))
+	pressure := 0.95
+
+	var log bytes.Buffer
+	g := NewMemoryGovernor(cache, MemoryGovernorOptions{
+		Interval:    time.Hour,
+		HistorySize: 2,
+		Clock:       clock,
+		Pressure:    func() float64 { return pressure },
+		Logger:      slog.New(slog.NewTextHandler(&log, nil)),
+	})
+	defer g.Close()
+
+	g.adjust()
+	clock.Advance(time.Minute)
+	g.adjust()
+	pressure = 0.8
+	g.adjust()
+	pressure = 0.1
+	clock.Advance(time.Minute)
+	g.adjust()
+
+	stats := g.Stats()
+	if stats.Checks != 4 || stats.Shrinks != 2 || stats.Grows != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	// Only the last 2 decisions are kept, oldest first
+	if len(stats.Decisions) != 2 {
+		t.Fatalf("Expected 2 decisions, got %+v", stats.Decisions)
+	}
+	shrink, grow := stats.Decisions[0], stats.Decisions[1]
+	if shrink.OldCapacity != 750 || shrink.NewCapacity != 562 || shrink.Pressure != 0.95 || shrink.Len != 0 {
+		t.Errorf("Unexpected shrink decision %+v", shrink)
+	}
+	if grow.OldCapacity != 562 || grow.NewCapacity != 750 || !grow.Time.Equal(clock.Now()) {
+		t.Errorf("Unexpected grow decision %+v", grow)
+	}
+	if n := bytes.Count(log.Bytes(), []byte("changed the capacity")); n != 3 {
+		t.Errorf("Expected 3 logged resizes, got %d", n)
+	}
+}
+
+func TestRuntimeMemoryPressure(t *testing.T) {
+	previous := debug.SetMemoryLimit(-1)
+	defer debug.SetMemoryLimit(previous)
+
+	// Without a limit, there is no pressure
+	debug.SetMemoryLimit(math.MaxInt64)
+	if p := RuntimeMemoryPressure(); p != 0 {
+		t.Errorf("Expected no pressure without a limit, got %f", p)
+	}
+
+	debug.SetMemoryLimit(1 << 62)
+	if p := RuntimeMemoryPressure(); p < 0 || p > 0.01 {
+		t.Errorf("Expected negligible pressure with a huge limit, got %f", p)
+	}
+
+	debug.SetMemoryLimit(1 << 20)
+	if p := RuntimeMemoryPressure(); p < 1 {
+		t.Errorf("Expected pressure above 1 with a 1MB limit, got %f", p)
+	}
+}
+
+func TestMemoryGovernorUnbounded(t *testing.T) {
+	cache, _ := NewSyncWithOptions(Options[int, int]{Capacity: Unbounded})
+	for i := 0; i < 1000; i++ {
+		cache.Insert(i, i)
+	}
+	pressure := 0.95
+
+	g := NewMemoryGovernor(cache, MemoryGovernorOptions{
+		Interval: time.Hour,
+		Pressure: func() float64 { return pressure },
+	})
+	defer g.Close()
+
+	// The cache is shrunk from its number of entries
+	g.adjust()
+	if cache.Capacity() != 750 || cache.Len() != 750 {
+		t.Errorf("Expected 750 entries, got a capacity of %d and %d entries", cache.Capacity(), cache.Len())
+	}
+
+	pressure = 0.1
+	for i := 0; i < 200; i++ {
+		g.adjust()
+	}
+	if cache.Capacity() != Unbounded {
+		t.Errorf("Expected the cache to be unbounded again, got a capacity of %d", cache.Capacity())
+	}
+}
@@ -0,0 +1,12 @@
+//go:build go1.24
+
+package sievecache
+
+import "hash/maphash"
+
+// hashComparable hashes a key of any comparable type, according to its value.
+// It panics if the key is an interface holding a value that isn't comparable,
+// like a map would.
+func hashComparable[K comparable](key K) uint64 {
+	return maphash.Comparable(hashSeed, key)
+}
@@ -0,0 +1,25 @@
+//go:build go1.24
+
+package sievecache
+
+import "testing"
+
+// opaqueKey is a key type whose string representation doesn't identify it.
+type opaqueKey struct{ id int }
+
+func (opaqueKey) String() string { return "opaque" }
+
+func TestHashComparableKeys(t *testing.T) {
+	cache, _ := NewShardedWithShards[opaqueKey, int](16000, 16)
+	for i := 0; i < 1000; i++ {
+		cache.Insert(opaqueKey{i}, i)
+	}
+	for i, shard := range cache.table.Load().shards {
+		if n := shard.Len(); n == 0 || n > 200 {
+			t.Errorf("Expected keys to be spread across shards, shard %d has %d", i, n)
+		}
+	}
+	if hashKey(opaqueKey{1}) != hashKey(opaqueKey{1}) {
+		t.Error("Expected equal keys to have the same hash")
+	}
+}
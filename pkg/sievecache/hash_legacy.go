@@ -0,0 +1,11 @@
+//go:build !go1.24
+
+package sievecache
+
+import "hash/maphash"
+
+// hashComparable hashes a key of any comparable type, through its string
+// representation. maphash.Comparable requires Go 1.24.
+func hashComparable[K comparable](key K) uint64 {
+	return maphash.String(hashSeed, ToString(key))
+}
@@ -0,0 +1,209 @@
+package sievecache
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// HotKeyOptions configures hot key replication in a sharded cache.
+// Zero values are replaced with sensible defaults.
+type HotKeyOptions struct {
+	// MinShare is the minimum fraction of the sampled lookups a key must
+	// receive to be considered hot. Defaults to 0.05.
+	MinShare float64
+	// MaxKeys is the maximum number of replicated keys. Defaults to 16.
+	MaxKeys int
+	// SampleRate is the inverse of the fraction of lookups that are sampled.
+	// Defaults to 64, meaning that one lookup in 64 is sampled.
+	SampleRate int
+	// Window is the number of samples after which hot keys are detected
+	// again. Defaults to 1024.
+	Window int
+}
+
+// hotKeys tracks the hottest keys of a sharded cache and replicates their
+// values. Replicas are stored in an immutable map, replaced as a whole on
+// every change, so that lookups of hot keys don't take any lock.
+type hotKeys[K comparable, V any] struct {
+	opts HotKeyOptions
+
+	replicas atomic.Pointer[map[K]V]
+	// Incremented on every write, so that a detection that raced with a
+	// write doesn't publish stale values
+	writes atomic.Uint64
+	// Serializes changes to replicas
+	mutex sync.Mutex
+
+	// Sampled lookups of the current window, guarded by sampleMutex
+	sampleMutex sync.Mutex
+	counts      map[K]int
+	samples     int
+}
+
+// EnableHotKeyReplication starts detecting keys whose lookups dwarf the
+// others, by sampling lookups, and replicating their values so that they are
+// looked up without taking the lock of their shard. Replicas are invalidated
+// when their entry is written through the cache; entries modified through
+// Shard or GetShardByIndex aren't tracked. Hot keys are detected again after
+// every window of samples, and keys that cooled down stop being replicated.
+// A replica may outlive the eviction of its entry until the next detection.
+func (c *ShardedSieveCache[K, V]) EnableHotKeyReplication(opts HotKeyOptions) {
+	if opts.MinShare <= 0 {
+		opts.MinShare = 0.05
+	}
+	if opts.MaxKeys <= 0 {
+		opts.MaxKeys = 16
+	}
+	if opts.SampleRate <= 0 {
+		opts.SampleRate = 64
+	}
+	if opts.Window <= 0 {
+		opts.Window = 1024
+	}
+	c.hot.Store(&hotKeys[K, V]{
+		opts:   opts,
+		counts: make(map[K]int),
+	})
+}
+
+// DisableHotKeyReplication stops detecting and replicating hot keys.
+func (c *ShardedSieveCache[K, V]) DisableHotKeyReplication() {
+	c.hot.Store(nil)
+}
+
+// HotKeys returns the keys that are currently replicated.
+func (c *ShardedSieveCache[K, V]) HotKeys() []K {
+	h := c.hot.Load()
+	if h == nil {
+		return nil
+	}
+	replicas := h.replicas.Load()
+	if replicas == nil {
+		return nil
+	}
+	keys := make([]K, 0, len(*replicas))
+	for key := range *replicas {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// get returns the replicated value of key, if key is hot.
+func (h *hotKeys[K, V]) get(key K) (V, bool) {
+	if replicas := h.replicas.Load(); replicas != nil {
+		value, found := (*replicas)[key]
+		return value, found
+	}
+	var zero V
+	return zero, false
+}
+
+// sample records a lookup of key with a probability of 1/SampleRate, and
+// detects hot keys again once the window is complete.
+func (h *hotKeys[K, V]) sample(c *ShardedSieveCache[K, V], key K) {
+	if rand.Intn(h.opts.SampleRate) != 0 || !h.sampleMutex.TryLock() {
+		return
+	}
+	h.counts[key]++
+	h.samples++
+	if h.samples < h.opts.Window {
+		h.sampleMutex.Unlock()
+		return
+	}
+
+	// Collect the keys over the threshold, hottest first
+	threshold := max(1, int(h.opts.MinShare*float64(h.samples)))
+	var hot []K
+	for k, count := range h.counts {
+		if count >= threshold {
+			hot = append(hot, k)
+		}
+	}
+	counts := h.counts
+	sort.Slice(hot, func(i, j int) bool { return counts[hot[i]] > counts[hot[j]] })
+	hot = hot[:min(len(hot), h.opts.MaxKeys)]
+	h.counts = make(map[K]int, len(counts))
+	h.samples = 0
+	h.sampleMutex.Unlock()
+
+	h.replicate(c, hot)
+}
+
+// replicate replaces the replicas with the current values of keys.
+func (h *hotKeys[K, V]) replicate(c *ShardedSieveCache[K, V], keys []K) {
+	writes := h.writes.Load()
+	replicas := make(map[K]V, len(keys))
+	for _, key := range keys {
+		// Looking the key up in its shard also keeps it visited, as lookups
+		// served by replicas don't mark the entry
+		if value, found := c.getFromShards(key); found {
+			replicas[key] = value
+		}
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.replicas.Store(&replicas)
+	// A write may have happened after a value was read, and before the
+	// writer could see the new replicas to invalidate them
+	if h.writes.Load() != writes {
+		h.replicas.Store(nil)
+	}
+}
+
+// invalidate drops the replica of key, if any. It must be called after key
+// was written in its shard.
+func (h *hotKeys[K, V]) invalidate(key K) {
+	h.writes.Add(1)
+	if replicas := h.replicas.Load(); replicas == nil {
+		return
+	} else if _, found := (*replicas)[key]; !found {
+		return
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	replicas := h.replicas.Load()
+	if replicas == nil {
+		return
+	}
+	updated := make(map[K]V, len(*replicas))
+	for k, v := range *replicas {
+		if k != key {
+			updated[k] = v
+		}
+	}
+	h.replicas.Store(&updated)
+}
+
+// invalidateAll drops all the replicas. It must be called after entries
+// were written in the shards.
+func (h *hotKeys[K, V]) invalidateAll() {
+	h.writes.Add(1)
+	if h.replicas.Load() == nil {
+		return
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.replicas.Store(nil)
+}
+
+// invalidateHot drops the replica of key, if hot key replication is enabled,
+// and the entries of local caches.
+func (c *ShardedSieveCache[K, V]) invalidateHot(key K) {
+	if h := c.hot.Load(); h != nil {
+		h.invalidate(key)
+	}
+	c.invalidateLocal()
+}
+
+// invalidateAllHot drops all the replicas, if hot key replication is
+// enabled, and the entries of local caches.
+func (c *ShardedSieveCache[K, V]) invalidateAllHot() {
+	if h := c.hot.Load(); h != nil {
+		h.invalidateAll()
+	}
+	c.invalidateLocal()
+}
@@ -0,0 +1,88 @@
+package sievecache
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestHotKeyReplication(t *testing.T) {
+	cache, _ := NewShardedWithShards[int, int](1000, 8)
+	for i := 0; i < 100; i++ {
+		cache.Insert(i, i)
+	}
+	cache.EnableHotKeyReplication(HotKeyOptions{SampleRate: 1, Window: 100, MinShare: 0.5})
+
+	for i := 0; i < 100; i++ {
+		cache.Get(42)
+	}
+	if hot := cache.HotKeys(); len(hot) != 1 || hot[0] != 42 {
+		t.Fatalf("Expected 42 to be hot, got %v", hot)
+	}
+	if value, found := cache.Get(42); !found || value != 42 {
+		t.Errorf("Expected 42, got %d, %v", value, found)
+	}
+
+	// Writes invalidate the replica
+	cache.Insert(42, 4242)
+	if len(cache.HotKeys()) != 0 {
+		t.Error("Expected Insert to invalidate the replica")
+	}
+	if value, _ := cache.Get(42); value != 4242 {
+		t.Errorf("Expected 4242, got %d", value)
+	}
+
+	for i := 0; i < 100; i++ {
+		cache.Get(42)
+	}
+	cache.Remove(42)
+	if _, found := cache.Get(42); found {
+		t.Error("Expected Remove to invalidate the replica")
+	}
+
+	cache.Insert(42, 42)
+	for i := 0; i < 100; i++ {
+		cache.Get(42)
+	}
+	cache.Clear()
+	if _, found := cache.Get(42); found {
+		t.Error("Expected Clear to invalidate the replica")
+	}
+
+	cache.DisableHotKeyReplication()
+	if cache.HotKeys() != nil {
+		t.Error("Expected no hot keys once disabled")
+	}
+}
+
+func TestHotKeyReplicationConcurrent(t *testing.T) {
+	cache, _ := NewShardedWithShards[int, int](1000, 4)
+	cache.EnableHotKeyReplication(HotKeyOptions{SampleRate: 2, Window: 50})
+	cache.Insert(0, 0)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 2000; j++ {
+				cache.Get(0)
+				cache.Get(j % 50)
+				if i == 0 && j%10 == 0 {
+					cache.Insert(0, j)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// Once writes stopped, the replica must not be older than the entry
+	cache.Insert(0, -1)
+	for j := 0; j < 1000; j++ {
+		if value, _ := cache.Get(0); value != -1 {
+			t.Fatalf("Expected -1, got %d", value)
+		}
+	}
+	if err := cache.CheckInvariants(); err != nil {
+		t.Error(err)
+	}
+}
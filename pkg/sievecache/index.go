@@ -0,0 +1,245 @@
+package sievecache
+
+import (
+	"errors"
+	"maps"
+	"math/bits"
+)
+
+// Index selects the data structure mapping keys to entries.
+type Index int
+
+const (
+	// IndexMap is a built-in Go map, the default.
+	IndexMap Index = iota
+
+	// IndexOpenAddressing is a linear probing hash table that only stores
+	// a 32-bit hash and the slot of each entry, and reads keys from the
+	// entries themselves. It uses 8 bytes per slot, regardless of the size
+	// of the keys, instead of the key, an int and the overhead of a map,
+	// which makes a difference for very large caches. Lookups hash keys
+	// with the same function as a ShardedSieveCache.
+	IndexOpenAddressing
+
+	// IndexSwiss is a table with the layout of a SwissTable: slots are
+	// grouped by 8, and the control bytes of a group are matched against 7
+	// bits of the hash of a key at once. Like IndexOpenAddressing, it only
+	// stores a hash and the slot of each entry, plus a control byte. It
+	// tolerates a higher load, and removals leave tombstones that are
+	// compacted away in bulk when they accumulate, which makes insertions
+	// that evict cheaper.
+	IndexSwiss
+)
+
+// openTableMaxLoad is the maximum fraction of the slots of an open
+// addressing table in use, as a number of quarters.
+const openTableMaxLoad = 3
+
+// openEntry is a slot of an open addressing table.
+type openEntry struct {
+	// Low 32 bits of the hash of the key
+	hash uint32
+	// Slot of the entry plus one, or 0 if the slot is free
+	slot uint32
+}
+
+// openTable is a linear probing hash table of entry slots. Removals shift
+// the following entries back instead of leaving tombstones, so probe
+// sequences never get longer than they were when the entries were added.
+type openTable struct {
+	entries []openEntry
+	mask    uint32
+	count   int
+}
+
+// newOpenTable creates a table with enough slots for n entries.
+func newOpenTable(n int) *openTable {
+	size := 8
+	if want := n*4/openTableMaxLoad + 1; want > size {
+		size = 1 << bits.Len(uint(want-1))
+	}
+	return &openTable{entries: make([]openEntry, size), mask: uint32(size - 1)}
+}
+
+// find returns the position of the entry for slot, which must be present.
+func (t *openTable) find(hash uint32, slot int) uint32 {
+	for i := hash & t.mask; ; i = (i + 1) & t.mask {
+		if t.entries[i].slot == uint32(slot)+1 {
+			return i
+		}
+	}
+}
+
+// add adds an entry for slot, whose key must not be present.
+func (t *openTable) add(hash uint32, slot int) {
+	if (t.count+1)*4 > len(t.entries)*openTableMaxLoad {
+		t.grow()
+	}
+	i := hash & t.mask
+	for t.entries[i].slot != 0 {
+		i = (i + 1) & t.mask
+	}
+	t.entries[i] = openEntry{hash: hash, slot: uint32(slot) + 1}
+	t.count++
+}
+
+// remove removes the entry for slot, which must be present.
+func (t *openTable) remove(hash uint32, slot int) {
+	i := t.find(hash, slot)
+	// Shift back the following entries of the cluster that would no longer
+	// be reachable from their home position
+	for j := (i + 1) & t.mask; t.entries[j].slot != 0; j = (j + 1) & t.mask {
+		home := t.entries[j].hash & t.mask
+		if (j-home)&t.mask >= (j-i)&t.mask {
+			t.entries[i] = t.entries[j]
+			i = j
+		}
+	}
+	t.entries[i] = openEntry{}
+	t.count--
+}
+
+// grow doubles the number of slots. Stored hashes avoid hashing keys again.
+func (t *openTable) grow() {
+	old := t.entries
+	t.entries = make([]openEntry, 2*len(old))
+	t.mask = uint32(len(t.entries) - 1)
+	for _, e := range old {
+		if e.slot == 0 {
+			continue
+		}
+		i := e.hash & t.mask
+		for t.entries[i].slot != 0 {
+			i = (i + 1) & t.mask
+		}
+		t.entries[i] = e
+	}
+}
+
+// clone returns an independent copy of the table.
+func (t *openTable) clone() *openTable {
+	clone := *t
+	clone.entries = append([]openEntry(nil), t.entries...)
+	return &clone
+}
+
+// slotIndex maps the keys of a cache to the slots of its entries, with the
+// data structure selected by Options.Index. Methods that modify the index are
+// given the slot of the entry, so that they don't have to compare keys, and
+// can be called while the entry is being moved.
+type slotIndex[K comparable, V any] struct {
+	m     map[K]int
+	open  *openTable
+	swiss *swissTable
+}
+
+// newSlotIndex creates an index of the given kind for n entries.
+func newSlotIndex[K comparable, V any](index Index, n int) (slotIndex[K, V], error) {
+	switch index {
+	case IndexMap:
+		return slotIndex[K, V]{m: make(map[K]int, n)}, nil
+	case IndexOpenAddressing:
+		return slotIndex[K, V]{open: newOpenTable(n)}, nil
+	case IndexSwiss:
+		return slotIndex[K, V]{swiss: newSwissTable(n)}, nil
+	}
+	return slotIndex[K, V]{}, errors.New("SieveCache: unknown index")
+}
+
+// kind returns the data structure of the index.
+func (x *slotIndex[K, V]) kind() Index {
+	switch {
+	case x.open != nil:
+		return IndexOpenAddressing
+	case x.swiss != nil:
+		return IndexSwiss
+	}
+	return IndexMap
+}
+
+// get returns the slot of key among nodes.
+func (x *slotIndex[K, V]) get(key K, nodes []Node[K, V]) (int, bool) {
+	if x.open == nil {
+		if x.swiss != nil {
+			return swissGet(x.swiss, key, nodes)
+		}
+		idx, exists := x.m[key]
+		return idx, exists
+	}
+	t := x.open
+	hash := uint32(hashKey(key))
+	for i := hash & t.mask; ; i = (i + 1) & t.mask {
+		e := t.entries[i]
+		if e.slot == 0 {
+			return 0, false
+		}
+		if e.hash == hash && nodes[e.slot-1].Key == key {
+			return int(e.slot - 1), true
+		}
+	}
+}
+
+// add indexes key, which must not be present, at slot.
+func (x *slotIndex[K, V]) add(key K, slot int) {
+	switch {
+	case x.open != nil:
+		x.open.add(uint32(hashKey(key)), slot)
+	case x.swiss != nil:
+		x.swiss.add(uint32(hashKey(key)), slot)
+	default:
+		x.m[key] = slot
+	}
+}
+
+// remove removes key, which must be present at slot.
+func (x *slotIndex[K, V]) remove(key K, slot int) {
+	switch {
+	case x.open != nil:
+		x.open.remove(uint32(hashKey(key)), slot)
+	case x.swiss != nil:
+		x.swiss.remove(uint32(hashKey(key)), slot)
+	default:
+		delete(x.m, key)
+	}
+}
+
+// move indexes key, which must be present at src, at dst instead.
+func (x *slotIndex[K, V]) move(key K, src, dst int) {
+	switch {
+	case x.open != nil:
+		t := x.open
+		t.entries[t.find(uint32(hashKey(key)), src)].slot = uint32(dst) + 1
+	case x.swiss != nil:
+		t := x.swiss
+		t.slots[t.find(uint32(hashKey(key)), src)].slot = uint32(dst) + 1
+	default:
+		x.m[key] = dst
+	}
+}
+
+// len returns the number of indexed keys.
+func (x *slotIndex[K, V]) len() int {
+	switch {
+	case x.open != nil:
+		return x.open.count
+	case x.swiss != nil:
+		return x.swiss.count
+	}
+	return len(x.m)
+}
+
+// reset removes all keys, sizing the index for n entries.
+func (x *slotIndex[K, V]) reset(n int) {
+	*x, _ = newSlotIndex[K, V](x.kind(), n)
+}
+
+// clone returns an independent copy of the index.
+func (x *slotIndex[K, V]) clone() slotIndex[K, V] {
+	switch {
+	case x.open != nil:
+		return slotIndex[K, V]{open: x.open.clone()}
+	case x.swiss != nil:
+		return slotIndex[K, V]{swiss: x.swiss.clone()}
+	}
+	return slotIndex[K, V]{m: maps.Clone(x.m)}
+}
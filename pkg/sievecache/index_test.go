@@ -0,0 +1,120 @@
+package sievecache
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestIndexesRandomOperations(t *testing.T) {
+	for _, index := range []Index{IndexOpenAddressing, IndexSwiss} {
+		for _, policy := range []Policy{PolicySIEVE, PolicyWTinyLFU, PolicySLRU} {
+			rng := rand.New(rand.NewSource(1))
+			reference, _ := NewWithOptions(Options[int, int]{Capacity: 50, Policy: policy})
+			cache, _ := NewWithOptions(Options[int, int]{Capacity: 50, Policy: policy, Index: index})
+			for i := 0; i < 20000; i++ {
+				key := rng.Intn(100)
+				switch op := rng.Intn(10); {
+				case op < 4:
+					if cache.Insert(key, i) != reference.Insert(key, i) {
+						t.Fatalf("Index %d, policy %d, operation %d: Insert(%d) differs", index, policy, i, key)
+					}
+				case op < 7:
+					v1, ok1 := cache.Get(key)
+					v2, ok2 := reference.Get(key)
+					if v1 != v2 || ok1 != ok2 {
+						t.Fatalf("Index %d, policy %d, operation %d: Get(%d) differs", index, policy, i, key)
+					}
+				case op < 9:
+					cache.Remove(key)
+					reference.Remove(key)
+				default:
+					cache.Retain(func(k, _ int) bool { return k%7 != key%7 })
+					reference.Retain(func(k, _ int) bool { return k%7 != key%7 })
+				}
+				if err := cache.CheckInvariants(); err != nil {
+					t.Fatalf("Index %d, policy %d, operation %d: %v", index, policy, i, err)
+				}
+			}
+		}
+	}
+}
+
+func TestIndexGrowth(t *testing.T) {
+	for _, index := range []Index{IndexOpenAddressing, IndexSwiss} {
+		testIndexGrowth(t, index)
+	}
+}
+
+func testIndexGrowth(t *testing.T, index Index) {
+	cache, _ := NewWithOptions(Options[string, int]{Capacity: Unbounded, Index: index})
+	for i := 0; i < 10000; i++ {
+		cache.Insert(string(rune('a'+i%26))+string(rune(i)), i)
+	}
+	if err := cache.CheckInvariants(); err != nil {
+		t.Fatal(err)
+	}
+
+	clone := cache.Clone()
+	frozen := cache.Freeze()
+	cache.Clear()
+	if clone.Len() != 10000 || frozen.Len() != 10000 || !clone.ContainsKey("a"+string(rune(0))) {
+		t.Errorf("Expected copies to keep their entries, got %d and %d", clone.Len(), frozen.Len())
+	}
+	if value, found := frozen.Get("b" + string(rune(1))); !found || value != 1 {
+		t.Errorf("Expected 1, got %v (found: %v)", value, found)
+	}
+	if err := clone.CheckInvariants(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenAddressingIndexCollisions(t *testing.T) {
+	// Force every key into the same cluster to exercise backward shifts
+	table := newOpenTable(16)
+	for slot := 0; slot < 10; slot++ {
+		table.add(uint32(slot%3), slot)
+	}
+	for _, slot := range []int{0, 4, 9, 1} {
+		table.remove(uint32(slot%3), slot)
+	}
+	for _, slot := range []int{2, 3, 5, 6, 7, 8} {
+		i := uint32(slot%3) & table.mask
+		for table.entries[i].slot != uint32(slot)+1 {
+			if table.entries[i].slot == 0 {
+				t.Fatalf("Slot %d is no longer reachable", slot)
+			}
+			i = (i + 1) & table.mask
+		}
+	}
+	if table.count != 6 {
+		t.Errorf("Expected 6 entries, got %d", table.count)
+	}
+}
+
+func TestSwissIndexTombstones(t *testing.T) {
+	cache, _ := NewWithOptions(Options[int, int]{Capacity: 100, Index: IndexSwiss})
+	for i := 0; i < 1000; i++ {
+		cache.Insert(i, i)
+	}
+	groups := len(cache.indices.swiss.ctrl)
+	// Churn through many more keys than the capacity: tombstones must be
+	// compacted without growing the table
+	for i := 1000; i < 100000; i++ {
+		cache.Insert(i, i)
+	}
+	if err := cache.CheckInvariants(); err != nil {
+		t.Fatal(err)
+	}
+	x := cache.indices.swiss
+	if len(x.ctrl) != groups {
+		t.Errorf("Expected the table to keep %d groups, got %d", groups, len(x.ctrl))
+	}
+	if x.count+x.tombstones > x.maxLoad() {
+		t.Errorf("Expected at most %d used slots, got %d entries and %d tombstones", x.maxLoad(), x.count, x.tombstones)
+	}
+	for i := 100000 - 100; i < 100000; i++ {
+		if value, found := cache.Get(i); !found || value != i {
+			t.Fatalf("Expected %d, got %v (found: %v)", i, value, found)
+		}
+	}
+}
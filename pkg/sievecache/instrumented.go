@@ -0,0 +1,87 @@
+package sievecache
+
+import "time"
+
+// StatsRecorder receives the operations of an InstrumentedCache, to report
+// them to a custom metrics system. Its methods are called after every
+// operation, possibly concurrently, so they should be cheap.
+type StatsRecorder interface {
+	// RecordGet reports a lookup with Get or ContainsKey
+	RecordGet(hit bool, latency time.Duration)
+	// RecordInsert reports an insertion, which may have failed with err
+	RecordInsert(inserted bool, err error, latency time.Duration)
+	// RecordRemove reports an explicit removal
+	RecordRemove(found bool, latency time.Duration)
+}
+
+// InstrumentedCache wraps a Cache and reports every operation to a
+// StatsRecorder, so that any metrics system can be integrated without
+// changing the cache itself.
+// It is as safe for concurrent use as the cache it wraps.
+type InstrumentedCache[K comparable, V any] struct {
+	cache    Cache[K, V]
+	recorder StatsRecorder
+}
+
+// NewInstrumentedCache wraps cache, reporting its operations to recorder.
+func NewInstrumentedCache[K comparable, V any](cache Cache[K, V], recorder StatsRecorder) *InstrumentedCache[K, V] {
+	return &InstrumentedCache[K, V]{cache: cache, recorder: recorder}
+}
+
+// Unwrap returns the wrapped cache.
+func (c *InstrumentedCache[K, V]) Unwrap() Cache[K, V] {
+	return c.cache
+}
+
+// Get looks up a key and reports whether it was found.
+func (c *InstrumentedCache[K, V]) Get(key K) (V, bool) {
+	start := time.Now()
+	value, found := c.cache.Get(key)
+	c.recorder.RecordGet(found, time.Since(start))
+	return value, found
+}
+
+// Insert inserts a key-value pair and reports it.
+func (c *InstrumentedCache[K, V]) Insert(key K, value V) bool {
+	inserted, _ := c.TryInsert(key, value)
+	return inserted
+}
+
+// TryInsert inserts a key-value pair and reports it, along with its error.
+func (c *InstrumentedCache[K, V]) TryInsert(key K, value V) (bool, error) {
+	start := time.Now()
+	inserted, err := c.cache.TryInsert(key, value)
+	c.recorder.RecordInsert(inserted, err, time.Since(start))
+	return inserted, err
+}
+
+// Remove removes a key and reports whether it was found.
+func (c *InstrumentedCache[K, V]) Remove(key K) (V, bool) {
+	start := time.Now()
+	value, found := c.cache.Remove(key)
+	c.recorder.RecordRemove(found, time.Since(start))
+	return value, found
+}
+
+// ContainsKey checks if a key is present, and reports it as a lookup.
+func (c *InstrumentedCache[K, V]) ContainsKey(key K) bool {
+	start := time.Now()
+	found := c.cache.ContainsKey(key)
+	c.recorder.RecordGet(found, time.Since(start))
+	return found
+}
+
+// Len returns the number of entries in the wrapped cache.
+func (c *InstrumentedCache[K, V]) Len() int {
+	return c.cache.Len()
+}
+
+// Capacity returns the capacity of the wrapped cache.
+func (c *InstrumentedCache[K, V]) Capacity() int {
+	return c.cache.Capacity()
+}
+
+// Clear removes all entries from the wrapped cache.
+func (c *InstrumentedCache[K, V]) Clear() {
+	c.cache.Clear()
+}
@@ -0,0 +1,65 @@
+package sievecache
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// countingRecorder is a StatsRecorder that counts operations.
+type countingRecorder struct {
+	hits, misses, inserts, failures, removals int
+}
+
+func (r *countingRecorder) RecordGet(hit bool, _ time.Duration) {
+	if hit {
+		r.hits++
+	} else {
+		r.misses++
+	}
+}
+
+func (r *countingRecorder) RecordInsert(inserted bool, err error, _ time.Duration) {
+	if err != nil {
+		r.failures++
+	} else if inserted {
+		r.inserts++
+	}
+}
+
+func (r *countingRecorder) RecordRemove(found bool, _ time.Duration) {
+	if found {
+		r.removals++
+	}
+}
+
+func TestInstrumentedCache(t *testing.T) {
+	cache, _ := NewWithOptions(Options[string, int]{
+		Capacity: 10,
+		BeforeInsert: func(key string, value int) (int, error) {
+			if value < 0 {
+				return 0, errors.New("negative")
+			}
+			return value, nil
+		},
+	})
+	recorder := &countingRecorder{}
+	c := NewInstrumentedCache[string, int](cache, recorder)
+
+	c.Insert("a", 1)
+	c.Insert("b", 2)
+	c.Insert("c", -1)
+	c.Get("a")
+	c.Get("missing")
+	c.ContainsKey("b")
+	c.Remove("a")
+	c.Remove("a")
+
+	want := countingRecorder{hits: 2, misses: 1, inserts: 2, failures: 1, removals: 1}
+	if *recorder != want {
+		t.Errorf("Expected %+v, got %+v", want, *recorder)
+	}
+	if c.Len() != 1 {
+		t.Errorf("Expected 1 entry, got %d", c.Len())
+	}
+}
@@ -0,0 +1,124 @@
+package sievecache
+
+import "fmt"
+
+// CheckInvariants validates the internal consistency of the cache: every
+// entry is indexed at its position, the visited flags match the entries,
+// the number of entries and their cost don't exceed their bounds, and the
+// hand points to an entry. It returns an error describing the first violation found.
+// It is meant for tests and fuzzers of code that embeds the cache.
+func (c *SieveCache[K, V]) CheckInvariants() error {
+	n := len(c.nodes)
+	if c.capacity < 0 {
+		return fmt.Errorf("SieveCache: invalid capacity %d", c.capacity)
+	}
+	if n > c.capacity {
+		return fmt.Errorf("SieveCache: %d entries exceed the capacity of %d", n, c.capacity)
+	}
+	if c.indices.len() != n {
+		return fmt.Errorf("SieveCache: %d indexed keys for %d entries", c.indices.len(), n)
+	}
+	for i := range c.nodes {
+		key := c.nodes[i].Key
+		idx, exists := c.indices.get(key, c.nodes)
+		if !exists {
+			return fmt.Errorf("SieveCache: entry %d with key %v isn't indexed", i, key)
+		}
+		if idx != i {
+			return fmt.Errorf("SieveCache: key %v is indexed at %d instead of %d", key, idx, i)
+		}
+	}
+
+	if c.visited.Size() != n {
+		return fmt.Errorf("SieveCache: %d visited flags for %d entries", c.visited.Size(), n)
+	}
+	if n&0x3F != 0 {
+		if extra := c.visited.LoadWord(n>>6) >> (n & 0x3F); extra != 0 {
+			return fmt.Errorf("SieveCache: visited flags are set beyond the last entry")
+		}
+	}
+
+	if c.sizer != nil {
+		if len(c.costs) != n {
+			return fmt.Errorf("SieveCache: %d costs for %d entries", len(c.costs), n)
+		}
+		var total int64
+		for _, cost := range c.costs {
+			total += cost
+		}
+		if total != c.cost {
+			return fmt.Errorf("SieveCache: total cost is %d, but the entries cost %d", c.cost, total)
+		}
+	}
+	if c.maxCost > 0 && c.cost > c.maxCost {
+		return fmt.Errorf("SieveCache: total cost %d exceeds the maximum of %d", c.cost, c.maxCost)
+	}
+
+	if p := c.policy; p != nil {
+		if len(p.flags) != n {
+			return fmt.Errorf("SieveCache: %d policy flags for %d entries", len(p.flags), n)
+		}
+		inWindow, protected := 0, 0
+		for _, flags := range p.flags {
+			if flags&entryInWindow != 0 {
+				inWindow++
+			}
+			if flags&entryProtected != 0 {
+				protected++
+			}
+		}
+		if inWindow != p.windowCount {
+			return fmt.Errorf("SieveCache: %d entries in the window, but %d are counted", inWindow, p.windowCount)
+		}
+		if protected != p.protectedCount {
+			return fmt.Errorf("SieveCache: %d protected entries, but %d are counted", protected, p.protectedCount)
+		}
+		if size := c.admissionWindow(); p.windowCount > size {
+			return fmt.Errorf("SieveCache: %d entries in a window of %d", p.windowCount, size)
+		}
+	}
+
+	if c.handInitialized {
+		if c.hand < 0 || c.hand >= n {
+			return fmt.Errorf("SieveCache: hand %d is out of range for %d entries", c.hand, n)
+		}
+	} else if c.hand != 0 {
+		return fmt.Errorf("SieveCache: uninitialized hand at %d", c.hand)
+	}
+	return nil
+}
+
+// CheckInvariants validates the internal consistency of the cache.
+// See SieveCache.CheckInvariants for details.
+func (c *SyncSieveCache[K, V]) CheckInvariants() error {
+	c.rlock()
+	defer c.runlock()
+	if err := c.cache.CheckInvariants(); err != nil {
+		return err
+	}
+	if length := c.length.Load(); length != int64(len(c.cache.nodes)) {
+		return fmt.Errorf("SyncSieveCache: published length %d for %d entries", length, len(c.cache.nodes))
+	}
+	return nil
+}
+
+// CheckInvariants validates the internal consistency of every shard, and
+// checks that each entry is stored in the shard its key maps to.
+// See SieveCache.CheckInvariants for details.
+func (c *ShardedSieveCache[K, V]) CheckInvariants() error {
+	t := c.table.Load()
+	for i, shard := range t.all() {
+		if err := shard.CheckInvariants(); err != nil {
+			return fmt.Errorf("ShardedSieveCache: shard %d: %w", i, err)
+		}
+		for _, key := range shard.Keys() {
+			// Entries that haven't been migrated yet are still in their
+			// previous shards
+			hash := hashKey(key)
+			if t.shardFor(hash) != shard && (t.prev == nil || t.prevShardFor(hash) != shard) {
+				return fmt.Errorf("ShardedSieveCache: key %v is stored in the wrong shard %d", key, i)
+			}
+		}
+	}
+	return nil
+}
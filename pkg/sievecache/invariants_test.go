@@ -0,0 +1,108 @@
+package sievecache
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestCheckInvariantsRandomOperations(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	cache, _ := New[int, int](50)
+	for i := 0; i < 20000; i++ {
+		key := rng.Intn(100)
+		switch op := rng.Intn(10); {
+		case op < 4:
+			cache.Insert(key, i)
+		case op < 7:
+			cache.Get(key)
+		case op < 9:
+			cache.Remove(key)
+		default:
+			cache.Retain(func(k, _ int) bool { return k%7 != key%7 })
+		}
+		if err := cache.CheckInvariants(); err != nil {
+			t.Fatalf("Operation %d: %v", i, err)
+		}
+	}
+}
+
+func TestCheckInvariantsRemoveAtHand(t *testing.T) {
+	cache, _ := New[string, int](3)
+	cache.Insert("a", 1)
+	cache.Insert("b", 2)
+	cache.Insert("c", 3)
+	cache.Get("a")
+	cache.Get("b")
+	// Evicts c; the hand moves to b, now the last entry
+	cache.Insert("d", 4)
+	if cache.hand != 1 || !cache.handInitialized {
+		t.Fatalf("Unexpected hand position %d", cache.hand)
+	}
+
+	cache.Remove("d")
+	cache.Remove("b")
+	if err := cache.CheckInvariants(); err != nil {
+		t.Fatal(err)
+	}
+	cache.Remove("a")
+	if err := cache.CheckInvariants(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckInvariantsDetectsCorruption(t *testing.T) {
+	cache, _ := New[int, int](10)
+	for i := 0; i < 5; i++ {
+		cache.Insert(i, i)
+	}
+	if err := cache.CheckInvariants(); err != nil {
+		t.Fatal(err)
+	}
+
+	cache.indices.m[3] = 1
+	if cache.CheckInvariants() == nil {
+		t.Error("Expected a misplaced index to be detected")
+	}
+	cache.indices.m[3] = 3
+
+	cache.hand, cache.handInitialized = 5, true
+	if cache.CheckInvariants() == nil {
+		t.Error("Expected an out of range hand to be detected")
+	}
+	cache.hand, cache.handInitialized = 0, false
+
+	cache.visited.Append(false)
+	if cache.CheckInvariants() == nil {
+		t.Error("Expected extra visited flags to be detected")
+	}
+}
+
+func TestShardedCheckInvariants(t *testing.T) {
+	cache, _ := NewShardedWithShards[int, int](1000, 4)
+	for i := 0; i < 2000; i++ {
+		cache.Insert(i, i)
+		if i%3 == 0 {
+			cache.Remove(i / 2)
+		}
+	}
+	if err := cache.CheckInvariants(); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Reshard(7); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.CheckInvariants(); err != nil {
+		t.Fatal(err)
+	}
+
+	// An entry inserted directly into the wrong shard is detected
+	for i := 0; ; i++ {
+		if cache.getShardIndex(i) != 0 {
+			cache.GetShardByIndex(0).Insert(i, i)
+			break
+		}
+	}
+	if cache.CheckInvariants() == nil {
+		t.Error("Expected an entry in the wrong shard to be detected")
+	}
+}
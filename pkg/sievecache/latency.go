@@ -0,0 +1,193 @@
+package sievecache
+
+import (
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+// Latency histograms use log-linear buckets, like HDR histograms: durations
+// below latencyLinear nanoseconds have their own bucket, and every larger
+// power of two is split into latencySubBuckets buckets, so that the relative
+// error is bounded. The last bucket also counts every longer duration.
+const (
+	latencySubBucketBits = 2
+	latencySubBuckets    = 1 << latencySubBucketBits
+	latencyLinear        = 2 * latencySubBuckets
+	latencyMaxExponent   = 40 // about 18 minutes
+	latencyBuckets       = latencyLinear + (latencyMaxExponent-latencySubBucketBits)*latencySubBuckets
+)
+
+// LatencyHistogram is the distribution of the durations of an operation.
+type LatencyHistogram struct {
+	counts [latencyBuckets]uint64
+}
+
+// LatencyBucket is a bucket of a LatencyHistogram.
+type LatencyBucket struct {
+	// UpperBound is the longest duration counted in the bucket
+	UpperBound time.Duration
+	// Count is the number of operations that took at most UpperBound,
+	// including those of the previous buckets
+	Count uint64
+}
+
+// latencyBucketIndex returns the index of the bucket counting d nanoseconds.
+func latencyBucketIndex(d uint64) int {
+	if d < latencyLinear {
+		return int(d)
+	}
+	exponent := bits.Len64(d) - 1
+	if exponent > latencyMaxExponent {
+		return latencyBuckets - 1
+	}
+	sub := int(d>>(exponent-latencySubBucketBits)) & (latencySubBuckets - 1)
+	return latencyLinear + (exponent-latencySubBucketBits-1)*latencySubBuckets + sub
+}
+
+// latencyBucketUpperBound returns the longest duration counted in bucket i.
+func latencyBucketUpperBound(i int) time.Duration {
+	if i < latencyLinear {
+		return time.Duration(i)
+	}
+	if i == latencyBuckets-1 {
+		return time.Duration(1<<63 - 1)
+	}
+	i -= latencyLinear
+	exponent := i/latencySubBuckets + latencySubBucketBits + 1
+	sub := uint64(i%latencySubBuckets) + latencySubBuckets + 1
+	return time.Duration(sub<<(exponent-latencySubBucketBits) - 1)
+}
+
+// Count returns the number of recorded operations.
+func (h LatencyHistogram) Count() uint64 {
+	var count uint64
+	for _, n := range h.counts {
+		count += n
+	}
+	return count
+}
+
+// Quantile returns an upper bound of the duration under which a fraction q
+// of the operations completed, or 0 if no operations were recorded.
+// For example, Quantile(0.99) is the 99th percentile.
+func (h LatencyHistogram) Quantile(q float64) time.Duration {
+	total := h.Count()
+	if total == 0 {
+		return 0
+	}
+	rank := uint64(q * float64(total))
+	var count uint64
+	for i, n := range h.counts {
+		count += n
+		if count > rank || count == total {
+			return latencyBucketUpperBound(i)
+		}
+	}
+	return latencyBucketUpperBound(latencyBuckets - 1)
+}
+
+// Buckets returns the buckets of the histogram with cumulative counts, up to
+// the last one containing operations, for export to metric systems.
+func (h LatencyHistogram) Buckets() []LatencyBucket {
+	last := -1
+	for i, n := range h.counts {
+		if n > 0 {
+			last = i
+		}
+	}
+	buckets := make([]LatencyBucket, 0, last+1)
+	var count uint64
+	for i := 0; i <= last; i++ {
+		count += h.counts[i]
+		buckets = append(buckets, LatencyBucket{UpperBound: latencyBucketUpperBound(i), Count: count})
+	}
+	return buckets
+}
+
+// add returns the sum of h and other.
+func (h LatencyHistogram) add(other LatencyHistogram) LatencyHistogram {
+	for i, n := range other.counts {
+		h.counts[i] += n
+	}
+	return h
+}
+
+// delta returns the operations recorded in h but not in prev. If h has
+// fewer operations than prev in any bucket, it was reset, and is returned as is.
+func (h LatencyHistogram) delta(prev LatencyHistogram) LatencyHistogram {
+	for i := range h.counts {
+		if h.counts[i] < prev.counts[i] {
+			return h
+		}
+	}
+	for i := range h.counts {
+		h.counts[i] -= prev.counts[i]
+	}
+	return h
+}
+
+// latencyRecorder records the durations of an operation. Buckets are
+// updated atomically, so that durations can be recorded while only holding
+// a read lock.
+type latencyRecorder struct {
+	counts [latencyBuckets]atomic.Uint64
+}
+
+// since records the time elapsed since start.
+func (r *latencyRecorder) since(start time.Time) {
+	r.counts[latencyBucketIndex(uint64(max(0, time.Since(start))))].Add(1)
+}
+
+// snapshot returns the recorded durations.
+func (r *latencyRecorder) snapshot() LatencyHistogram {
+	var h LatencyHistogram
+	for i := range r.counts {
+		h.counts[i] = r.counts[i].Load()
+	}
+	return h
+}
+
+// reset forgets all the recorded durations.
+func (r *latencyRecorder) reset() {
+	for i := range r.counts {
+		r.counts[i].Store(0)
+	}
+}
+
+// load sets the recorded durations to those of h.
+func (r *latencyRecorder) load(h LatencyHistogram) {
+	for i := range r.counts {
+		r.counts[i].Store(h.counts[i])
+	}
+}
+
+// latencyRecorders records the durations of the operations of a cache.
+type latencyRecorders struct {
+	get    latencyRecorder
+	insert latencyRecorder
+	evict  latencyRecorder
+}
+
+// fill sets the latency histograms of s.
+func (r *latencyRecorders) fill(s *Stats) {
+	s.GetLatency = r.get.snapshot()
+	s.InsertLatency = r.insert.snapshot()
+	s.EvictLatency = r.evict.snapshot()
+}
+
+// reset forgets all the recorded durations.
+func (r *latencyRecorders) reset() {
+	r.get.reset()
+	r.insert.reset()
+	r.evict.reset()
+}
+
+// clone returns an independent copy of the recorders.
+func (r *latencyRecorders) clone() *latencyRecorders {
+	clone := &latencyRecorders{}
+	clone.get.load(r.get.snapshot())
+	clone.insert.load(r.insert.snapshot())
+	clone.evict.load(r.evict.snapshot())
+	return clone
+}
@@ -0,0 +1,83 @@
+package sievecache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatencyBuckets(t *testing.T) {
+	prev := -1
+	for _, d := range []uint64{0, 1, 7, 8, 9, 10, 15, 16, 100, 1000, 12345, 1 << 30, 1<<40 - 1} {
+		i := latencyBucketIndex(d)
+		if i < prev {
+			t.Fatalf("Buckets are not monotonic at %d", d)
+		}
+		prev = i
+		if upper := latencyBucketUpperBound(i); time.Duration(d) > upper {
+			t.Errorf("%d is above the upper bound of its bucket %v", d, upper)
+		}
+		if i > 0 && time.Duration(d) <= latencyBucketUpperBound(i-1) {
+			t.Errorf("%d should be in the previous bucket", d)
+		}
+	}
+	if i := latencyBucketIndex(1 << 62); i != latencyBuckets-1 {
+		t.Errorf("Expected long durations in the last bucket, got %d", i)
+	}
+
+	// The relative error is bounded
+	for d := uint64(8); d < 1<<20; d = d*3/2 + 1 {
+		upper := latencyBucketUpperBound(latencyBucketIndex(d))
+		if float64(upper) > 1.25*float64(d)+1 {
+			t.Errorf("Upper bound %v is too far from %d", upper, d)
+		}
+	}
+}
+
+func TestLatencyHistogram(t *testing.T) {
+	var h LatencyHistogram
+	if h.Quantile(0.5) != 0 || len(h.Buckets()) != 0 {
+		t.Error("Expected an empty histogram")
+	}
+	for i := 0; i < 99; i++ {
+		h.counts[latencyBucketIndex(100)]++
+	}
+	h.counts[latencyBucketIndex(1000000)]++
+
+	if h.Count() != 100 {
+		t.Errorf("Expected 100 operations, got %d", h.Count())
+	}
+	if q := h.Quantile(0.5); q < 100 || q > 125 {
+		t.Errorf("Expected a median of about 100ns, got %v", q)
+	}
+	if q := h.Quantile(1); q < time.Millisecond || q > 1250*time.Microsecond {
+		t.Errorf("Expected a maximum of about 1ms, got %v", q)
+	}
+	buckets := h.Buckets()
+	if last := buckets[len(buckets)-1]; last.Count != 100 || last.UpperBound < time.Millisecond {
+		t.Errorf("Unexpected last bucket %+v", last)
+	}
+}
+
+func TestRecordLatencies(t *testing.T) {
+	cache, _ := NewShardedWithOptions(Options[int, int]{Capacity: 10, RecordLatencies: true}, 2)
+	for i := 0; i < 20; i++ {
+		cache.Insert(i, i)
+		cache.Get(i)
+	}
+	stats := cache.Stats()
+	if stats.GetLatency.Count() != 20 || stats.InsertLatency.Count() != 20 {
+		t.Errorf("Expected 20 lookups and inserts, got %d and %d", stats.GetLatency.Count(), stats.InsertLatency.Count())
+	}
+	if stats.EvictLatency.Count() != stats.Evictions {
+		t.Errorf("Expected %d evictions, got %d", stats.Evictions, stats.EvictLatency.Count())
+	}
+
+	cache.Get(0)
+	if delta := cache.Stats().Delta(stats); delta.GetLatency.Count() != 1 || delta.InsertLatency.Count() != 0 {
+		t.Error("Expected the delta to contain a single lookup")
+	}
+	cache.ResetStats()
+	if cache.Stats().GetLatency.Count() != 0 {
+		t.Error("Expected the histograms to be reset")
+	}
+}
@@ -0,0 +1,92 @@
+package sievecache
+
+// localCacheSize is the number of entries of a LocalCache.
+const localCacheSize = 8
+
+// LocalCache is a tiny front cache of a ShardedSieveCache, owned by a single
+// goroutine, that keeps the last few entries it looked up. Lookups of these
+// entries don't take any lock, which eliminates lock traffic for immediately
+// repeated lookups, such as the same session or configuration key being
+// looked up several times while serving a request.
+//
+// Any write to the sharded cache makes every local cache drop its entries, so
+// that they are never stale, and lookups that miss go to the sharded cache,
+// which also marks the entry as visited again. Entries modified through
+// Shard or GetShardByIndex aren't tracked.
+//
+// A LocalCache must not be used by several goroutines concurrently.
+type LocalCache[K comparable, V any] struct {
+	cache *ShardedSieveCache[K, V]
+	// Epoch of the sharded cache the entries were looked up in
+	epoch   uint64
+	entries [localCacheSize]Item[K, V]
+	// Number of valid entries, and the slot the next entry replaces
+	count int
+	next  int
+}
+
+// Local returns a new LocalCache in front of the cache, to be used by the
+// calling goroutine, for example one per worker. Once it was called, every
+// write to the cache increments a counter shared by all the shards, which
+// has a small cost on write-heavy workloads.
+func (c *ShardedSieveCache[K, V]) Local() *LocalCache[K, V] {
+	c.localEnabled.Store(true)
+	return &LocalCache[K, V]{cache: c, epoch: c.localEpoch.Load()}
+}
+
+// invalidateLocal makes the local caches drop their entries. It must be
+// called after entries were written in the shards.
+func (c *ShardedSieveCache[K, V]) invalidateLocal() {
+	if c.localEnabled.Load() {
+		c.localEpoch.Add(1)
+	}
+}
+
+// Get returns the value of key, from the local entries if it was looked up
+// recently and the sharded cache wasn't written to since, or from the
+// sharded cache otherwise. Lookups served by the local entries are not
+// counted in the statistics of the sharded cache.
+func (l *LocalCache[K, V]) Get(key K) (V, bool) {
+	// The epoch is read before looking up the sharded cache, so that a
+	// concurrent write drops the value on the next lookup
+	epoch := l.cache.localEpoch.Load()
+	if epoch != l.epoch {
+		l.Clear()
+		l.epoch = epoch
+	}
+	for i := 0; i < l.count; i++ {
+		if l.entries[i].Key == key {
+			return l.copyValue(l.entries[i].Value), true
+		}
+	}
+
+	value, found := l.cache.Get(key)
+	if !found {
+		return value, false
+	}
+	l.entries[l.next] = Item[K, V]{Key: key, Value: value}
+	l.next = (l.next + 1) % localCacheSize
+	l.count = min(l.count+1, localCacheSize)
+	return l.copyValue(value), true
+}
+
+// Clear drops the local entries.
+func (l *LocalCache[K, V]) Clear() {
+	l.entries = [localCacheSize]Item[K, V]{}
+	l.count = 0
+	l.next = 0
+}
+
+// Cache returns the sharded cache the local cache is in front of.
+func (l *LocalCache[K, V]) Cache() *ShardedSieveCache[K, V] {
+	return l.cache
+}
+
+// copyValue returns a copy of value if the sharded cache has a Cloner, so
+// that callers can't modify the local entries.
+func (l *LocalCache[K, V]) copyValue(value V) V {
+	if l.cache.opts.Cloner != nil {
+		return l.cache.opts.Cloner(value)
+	}
+	return value
+}
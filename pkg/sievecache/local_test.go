@@ -0,0 +1,132 @@
+package sievecache
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestLocalCache(t *testing.T) {
+	cache, _ := NewShardedWithOptions(Options[int, int]{Capacity: 1000, RecordStats: true}, 4)
+	for i := 0; i < 20; i++ {
+		cache.Insert(i, i)
+	}
+	local := cache.Local()
+	if local.Cache() != cache {
+		t.Error("Expected Cache to return the sharded cache")
+	}
+
+	// Repeated lookups are served locally
+	if v, ok := local.Get(1); !ok || v != 1 {
+		t.Fatalf("Expected 1, got %d (%v)", v, ok)
+	}
+	before := cache.Stats().Hits
+	for i := 0; i < 10; i++ {
+		if v, ok := local.Get(1); !ok || v != 1 {
+			t.Fatalf("Expected 1, got %d (%v)", v, ok)
+		}
+	}
+	if hits := cache.Stats().Hits; hits != before {
+		t.Errorf("Expected repeated lookups to be served locally, got %d more hits", hits-before)
+	}
+
+	// Writes are never hidden by local entries
+	cache.Insert(1, 100)
+	if v, ok := local.Get(1); !ok || v != 100 {
+		t.Errorf("Expected 100 after an update, got %d (%v)", v, ok)
+	}
+	cache.Remove(1)
+	if _, ok := local.Get(1); ok {
+		t.Error("Expected a miss after a removal")
+	}
+	local.Get(2)
+	cache.Clear()
+	if _, ok := local.Get(2); ok {
+		t.Error("Expected a miss after Clear")
+	}
+	if _, ok := local.Get(1000); ok {
+		t.Error("Expected a miss for a missing key")
+	}
+
+	// Only the most recent entries are kept
+	for i := 0; i < 20; i++ {
+		cache.Insert(i, i)
+	}
+	local.Clear()
+	for i := 0; i < 20; i++ {
+		local.Get(i)
+	}
+	if local.count != localCacheSize {
+		t.Errorf("Expected %d local entries, got %d", localCacheSize, local.count)
+	}
+	for i := 20 - localCacheSize; i < 20; i++ {
+		if v, ok := local.Get(i); !ok || v != i {
+			t.Errorf("Expected %d, got %d (%v)", i, v, ok)
+		}
+	}
+}
+
+func TestLocalCacheInsertBatch(t *testing.T) {
+	cache, _ := NewShardedWithShards[string, int](100, 4)
+	cache.Insert("a", 1)
+	local := cache.Local()
+	if v, ok := local.Get("a"); !ok || v != 1 {
+		t.Fatalf("Expected 1, got %d (%v)", v, ok)
+	}
+	cache.InsertBatch(map[string]int{"a": 2, "b": 3})
+	if v, ok := local.Get("a"); !ok || v != 2 {
+		t.Errorf("Expected 2 after InsertBatch, got %d (%v)", v, ok)
+	}
+}
+
+func TestLocalCacheCloner(t *testing.T) {
+	cache, _ := NewShardedWithOptions(Options[int, []int]{
+		Capacity: 100,
+		Cloner:   func(v []int) []int { return append([]int(nil), v...) },
+	}, 2)
+	cache.Insert(1, []int{1})
+	local := cache.Local()
+	local.Get(1)
+	v, _ := local.Get(1)
+	v[0] = 42
+	if v, _ := local.Get(1); v[0] != 1 {
+		t.Errorf("Expected local entries to be copied, got %v", v)
+	}
+}
+
+func TestLocalCacheConcurrentWrites(t *testing.T) {
+	cache, _ := NewShardedWithShards[int, int](1000, 4)
+	cache.Insert(0, 0)
+
+	// Once a writer stored a value, a reader never sees an older one
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		local := cache.Local()
+		last := 0
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			v, ok := local.Get(0)
+			if !ok || v < last {
+				t.Errorf("Expected at least %d, got %d (%v)", last, v, ok)
+				return
+			}
+			last = v
+		}
+	}()
+	for i := 1; i <= 2000; i++ {
+		cache.Insert(0, i)
+	}
+	close(stop)
+	wg.Wait()
+
+	local := cache.Local()
+	if v, _ := local.Get(0); v != 2000 {
+		t.Errorf("Expected 2000, got %d", v)
+	}
+}
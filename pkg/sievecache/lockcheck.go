@@ -0,0 +1,20 @@
+//go:build !sievecache_debug
+
+package sievecache
+
+// lockChecks is true when the sievecache_debug build tag enables checking
+// how the locks of caches are acquired; see lockcheck_debug.go.
+const lockChecks = false
+
+// checkLock is called before a goroutine locks a cache.
+func checkLock(any, bool) {}
+
+// checkTryLock is called after a goroutine locked a cache without waiting.
+func checkTryLock(any, bool) {}
+
+// checkUnlock is called before a cache is unlocked.
+func checkUnlock(any) {}
+
+// forgetLockOrder forgets the order in which a cache was locked relative to
+// other caches.
+func forgetLockOrder(any) {}
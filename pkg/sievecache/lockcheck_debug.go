@@ -0,0 +1,208 @@
+//go:build sievecache_debug
+
+package sievecache
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strconv"
+	"sync"
+)
+
+// With the sievecache_debug build tag, the locks of every SyncSieveCache,
+// including the shards of a ShardedSieveCache, are tracked per goroutine.
+// Locking a cache that the same goroutine already holds, typically by
+// calling back into the cache from a WithLock callback, would deadlock, and
+// panics instead. Locking two caches in the opposite order of a previous
+// acquisition could deadlock with another goroutine, and panics too. Both
+// report the stacks of the acquisitions involved.
+//
+// Tracking is slow, and keeps the caches that were ever locked together
+// alive: it is meant for tests.
+const lockChecks = true
+
+// heldLock is a lock held by a goroutine.
+type heldLock struct {
+	lock  any
+	write bool
+	stack []byte
+}
+
+// lockTrackerState holds the locks held by each goroutine, and the order in
+// which locks were acquired.
+type lockTrackerState struct {
+	sync.Mutex
+	held map[uint64][]heldLock
+	// after[a][b] is the stack of the first acquisition of b while a was held
+	after map[any]map[any][]byte
+}
+
+var lockTracker = lockTrackerState{
+	held:  make(map[uint64][]heldLock),
+	after: make(map[any]map[any][]byte),
+}
+
+// checkLock is called before a goroutine locks a cache. It panics if the
+// goroutine already holds the lock, or if another lock it holds was ever
+// acquired after this one.
+func checkLock(lock any, write bool) {
+	stack := callerStack()
+	g := goroutineID(stack)
+
+	t := &lockTracker
+	t.Lock()
+	defer t.Unlock()
+	held := t.held[g]
+	for _, h := range held {
+		if h.lock == lock {
+			panic(fmt.Sprintf("SyncSieveCache: re-entrant %s of a cache already locked by the same goroutine, which would deadlock\n\nAcquired at:\n%s\nLocked again at:\n%s",
+				lockKind(write), h.stack, stack))
+		}
+	}
+	for _, h := range held {
+		if path := t.path(lock, h.lock); path != nil {
+			panic(fmt.Sprintf("SyncSieveCache: inconsistent lock order, which can deadlock\n\nFirst cache locked at:\n%s\nSecond cache locked at:\n%s\nPreviously, the second cache was held when the first one was locked at:\n%s",
+				h.stack, stack, path))
+		}
+	}
+	for _, h := range held {
+		next := t.after[h.lock]
+		if next == nil {
+			next = make(map[any][]byte)
+			t.after[h.lock] = next
+		}
+		if _, exists := next[lock]; !exists {
+			next[lock] = stack
+		}
+	}
+	t.held[g] = append(held, heldLock{lock: lock, write: write, stack: stack})
+}
+
+// checkTryLock is called after a goroutine locked a cache without waiting.
+// Acquisitions that don't wait can't deadlock, so their order isn't checked.
+func checkTryLock(lock any, write bool) {
+	stack := callerStack()
+	g := goroutineID(stack)
+
+	t := &lockTracker
+	t.Lock()
+	defer t.Unlock()
+	t.held[g] = append(t.held[g], heldLock{lock: lock, write: write, stack: stack})
+}
+
+// checkUnlock is called before a cache is unlocked.
+func checkUnlock(lock any) {
+	g := goroutineID(nil)
+
+	t := &lockTracker
+	t.Lock()
+	defer t.Unlock()
+	if t.release(g, lock) {
+		return
+	}
+	// Mutexes may be unlocked by another goroutine
+	for other := range t.held {
+		if t.release(other, lock) {
+			return
+		}
+	}
+}
+
+// release removes the most recent acquisition of lock from the locks held by
+// goroutine g, and returns false if it doesn't hold it.
+func (t *lockTrackerState) release(g uint64, lock any) bool {
+	held := t.held[g]
+	for i := len(held) - 1; i >= 0; i-- {
+		if held[i].lock == lock {
+			held = append(held[:i], held[i+1:]...)
+			if len(held) == 0 {
+				delete(t.held, g)
+			} else {
+				t.held[g] = held
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// path returns the stack of the first acquisition on a chain of locks
+// acquired after from and leading to to, or nil if there is none.
+func (t *lockTrackerState) path(from, to any) []byte {
+	visited := map[any]bool{from: true}
+	var search func(lock any) bool
+	search = func(lock any) bool {
+		for next := range t.after[lock] {
+			if next == to {
+				return true
+			}
+			if !visited[next] {
+				visited[next] = true
+				if search(next) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	for next, stack := range t.after[from] {
+		if next == to {
+			return stack
+		}
+		if !visited[next] {
+			visited[next] = true
+			if search(next) {
+				return stack
+			}
+		}
+	}
+	return nil
+}
+
+// forgetLockOrder forgets the order in which lock was acquired relative to
+// other locks, once no goroutine can hold them in that order anymore.
+func forgetLockOrder(lock any) {
+	t := &lockTracker
+	t.Lock()
+	defer t.Unlock()
+	delete(t.after, lock)
+	for _, next := range t.after {
+		delete(next, lock)
+	}
+}
+
+// lockKind describes a lock acquisition.
+func lockKind(write bool) string {
+	if write {
+		return "write lock"
+	}
+	return "read lock"
+}
+
+// callerStack returns the stack of the current goroutine.
+func callerStack() []byte {
+	buf := make([]byte, 4096)
+	for {
+		n := runtime.Stack(buf, false)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// goroutineID returns the ID of the current goroutine, from the header of
+// stack, or of a fresh stack if stack is nil.
+func goroutineID(stack []byte) uint64 {
+	if stack == nil {
+		var buf [64]byte
+		stack = buf[:runtime.Stack(buf[:], false)]
+	}
+	stack = bytes.TrimPrefix(stack, []byte("goroutine "))
+	if i := bytes.IndexByte(stack, ' '); i > 0 {
+		stack = stack[:i]
+	}
+	id, _ := strconv.ParseUint(string(stack), 10, 64)
+	return id
+}
@@ -0,0 +1,80 @@
+//go:build sievecache_debug
+
+package sievecache
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// expectLockPanic calls f, and checks that it panics with a message
+// containing want.
+func expectLockPanic(t *testing.T, want string, f func()) {
+	t.Helper()
+	defer func() {
+		t.Helper()
+		r := recover()
+		if r == nil {
+			t.Fatalf("Expected a panic containing %q", want)
+		}
+		if msg := fmt.Sprint(r); !strings.Contains(msg, want) || !strings.Contains(msg, "goroutine ") {
+			t.Errorf("Expected a panic containing %q and stacks, got %s", want, msg)
+		}
+	}()
+	f()
+}
+
+func TestLockCheckReentrant(t *testing.T) {
+	cache, _ := NewSync[int, int](100)
+	cache.Insert(1, 1)
+
+	expectLockPanic(t, "re-entrant write lock", func() {
+		cache.WithLock(func(*SieveCache[int, int]) {
+			cache.Insert(2, 2)
+		})
+	})
+	expectLockPanic(t, "re-entrant read lock", func() {
+		cache.WithLock(func(*SieveCache[int, int]) {
+			cache.Get(1)
+		})
+	})
+
+	// The panics released the lock
+	if !cache.Insert(3, 3) {
+		t.Error("Expected the cache to be usable after the panics")
+	}
+}
+
+func TestLockCheckOrder(t *testing.T) {
+	a, _ := NewSync[int, int](100)
+	b, _ := NewSync[int, int](100)
+
+	a.WithLock(func(*SieveCache[int, int]) {
+		b.Insert(1, 1)
+	})
+	// The same order is fine
+	a.WithLock(func(*SieveCache[int, int]) {
+		b.Insert(2, 2)
+	})
+	expectLockPanic(t, "inconsistent lock order", func() {
+		b.WithLock(func(*SieveCache[int, int]) {
+			a.Insert(1, 1)
+		})
+	})
+}
+
+func TestLockCheckShardedResharding(t *testing.T) {
+	// With jump hashing, shards kept by Reshard are locked before new shards
+	// when growing, and after removed shards when shrinking
+	cache, _ := NewShardedWithOptions(Options[int, int]{Capacity: 1000, ShardMapping: ShardMappingJump}, 4)
+	for i := 0; i < 500; i++ {
+		cache.Insert(i, i)
+	}
+	for _, n := range []int{8, 2, 6, 3} {
+		if err := cache.Reshard(n); err != nil {
+			t.Fatal(err)
+		}
+		cache.WithKeysLocked([]int{1, 2, 3, 4, 5}, func([]*SieveCache[int, int]) {})
+	}
+}
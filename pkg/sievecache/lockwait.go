@@ -0,0 +1,235 @@
+package sievecache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// LockTimeoutError is returned when the lock of a cache couldn't be acquired
+// before a context was done or a timeout expired.
+type LockTimeoutError struct {
+	// Waited is how long the lock was waited for
+	Waited time.Duration
+	// Err is the error of the context, context.DeadlineExceeded for a timeout
+	Err error
+}
+
+func (e *LockTimeoutError) Error() string {
+	return fmt.Sprintf("SieveCache: lock not acquired after %v: %v", e.Waited, e.Err)
+}
+
+// Unwrap returns the error of the context.
+func (e *LockTimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// Timeout returns true if the lock wasn't acquired because of a deadline or
+// a timeout, rather than a cancellation.
+func (e *LockTimeoutError) Timeout() bool {
+	return errors.Is(e.Err, context.DeadlineExceeded)
+}
+
+// Lock acquisition states of lockContext
+const (
+	lockWaiting int32 = iota
+	lockAcquired
+	lockAbandoned
+)
+
+// tryAcquire takes the write lock, or a read lock, if it is available, and
+// returns true if it did.
+func (c *SyncSieveCache[K, V]) tryAcquire(write bool) bool {
+	if write && !c.mutex.TryLock() || !write && !c.mutex.TryRLock() {
+		return false
+	}
+	checkTryLock(c, write)
+	return true
+}
+
+// lockContext takes the write lock, or a read lock, and returns a
+// *LockTimeoutError if ctx is done first. While the lock is busy, it is
+// waited for by another goroutine, which releases it right away if it gets
+// it after the caller gave up.
+func (c *SyncSieveCache[K, V]) lockContext(ctx context.Context, write bool) error {
+	checkLock(c, write)
+	if write && c.mutex.TryLock() || !write && c.mutex.TryRLock() {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		checkUnlock(c)
+		return &LockTimeoutError{Err: err}
+	}
+
+	start := time.Now()
+	var state atomic.Int32
+	acquired := make(chan struct{})
+	go func() {
+		if write {
+			c.mutex.Lock()
+		} else {
+			c.mutex.RLock()
+		}
+		if state.CompareAndSwap(lockWaiting, lockAcquired) {
+			close(acquired)
+		} else if write {
+			c.mutex.Unlock()
+		} else {
+			c.mutex.RUnlock()
+		}
+	}()
+
+	select {
+	case <-acquired:
+	case <-ctx.Done():
+		if state.CompareAndSwap(lockWaiting, lockAbandoned) {
+			checkUnlock(c)
+			return &LockTimeoutError{Waited: time.Since(start), Err: ctx.Err()}
+		}
+		<-acquired
+	}
+	c.recordLockWait(start)
+	return nil
+}
+
+// lockTimeout is like lockContext, with a timeout. A timeout of 0 or less
+// only takes the lock if it is available.
+func (c *SyncSieveCache[K, V]) lockTimeout(timeout time.Duration, write bool) error {
+	if c.tryAcquire(write) {
+		return nil
+	}
+	if timeout <= 0 {
+		return &LockTimeoutError{Err: context.DeadlineExceeded}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return c.lockContext(ctx, write)
+}
+
+// WithLockCtx is like WithLock, but gives up waiting for the lock when ctx
+// is done, and returns a *LockTimeoutError, so that a contended lock, or a
+// callback holding it for too long, can't hang a request. f itself isn't
+// interrupted once it runs.
+func (c *SyncSieveCache[K, V]) WithLockCtx(ctx context.Context, f func(*SieveCache[K, V])) error {
+	if err := c.lockContext(ctx, true); err != nil {
+		return err
+	}
+	defer c.unlock()
+	f(c.cache)
+	return nil
+}
+
+// TryWithLock is like WithLockCtx, but waits for the lock for at most
+// timeout. A timeout of 0 or less only runs f if the lock is available.
+func (c *SyncSieveCache[K, V]) TryWithLock(timeout time.Duration, f func(*SieveCache[K, V])) error {
+	if err := c.lockTimeout(timeout, true); err != nil {
+		return err
+	}
+	defer c.unlock()
+	f(c.cache)
+	return nil
+}
+
+// WithKeyLockCtx is like WithKeyLock, but gives up waiting for the lock of
+// the shard when ctx is done, and returns a *LockTimeoutError. While Reshard
+// is running, waiting for the lock of the previous shard of key is bounded
+// too. See SyncSieveCache.WithLockCtx.
+func (c *ShardedSieveCache[K, V]) WithKeyLockCtx(ctx context.Context, key K, f func(*SieveCache[K, V])) error {
+	defer c.invalidateAllHot()
+	return c.table.Load().withKeyShardCtx(ctx, key, f)
+}
+
+// TryWithKeyLock is like WithKeyLockCtx, but waits for the locks for at most
+// timeout in total. See SyncSieveCache.TryWithLock.
+func (c *ShardedSieveCache[K, V]) TryWithKeyLock(timeout time.Duration, key K, f func(*SieveCache[K, V])) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return c.WithKeyLockCtx(ctx, key, f)
+}
+
+// TryGet is like Get, but waits for the lock for at most timeout, for
+// best-effort caching on latency-critical paths. If the lock isn't acquired
+// in time, it returns false like a miss, without recording a lookup.
+// A timeout of 0 or less only looks up key if the lock is available.
+func (c *SyncSieveCache[K, V]) TryGet(key K, timeout time.Duration) (V, bool) {
+	if c.lockTimeout(timeout, false) != nil {
+		var zero V
+		return zero, false
+	}
+	defer c.runlock()
+	return c.cache.getShared(key)
+}
+
+// TryInsertWithin is like TryInsert, but waits for the lock for at most
+// timeout, and returns a *LockTimeoutError if it isn't acquired in time.
+// A timeout of 0 or less only inserts the value if the lock is available.
+func (c *SyncSieveCache[K, V]) TryInsertWithin(key K, value V, timeout time.Duration) (bool, error) {
+	if err := c.lockTimeout(timeout, true); err != nil {
+		return false, err
+	}
+	defer c.unlock()
+	return c.cache.TryInsert(key, value)
+}
+
+// TryGet is like Get, but waits for the locks for at most timeout in total.
+// See SyncSieveCache.TryGet. While Reshard is running, an entry that hasn't
+// been moved to its new shard yet is looked up in its previous shard within
+// the same timeout.
+func (c *ShardedSieveCache[K, V]) TryGet(key K, timeout time.Duration) (V, bool) {
+	if h := c.hot.Load(); h != nil {
+		if value, found := h.get(key); found {
+			owner := c.table.Load().shardFor(hashKey(key)).cache
+			owner.recordLookup(true)
+			return owner.copyValue(value), true
+		}
+	}
+	start := time.Now()
+	t := c.table.Load()
+	value, found := t.shardFor(hashKey(key)).TryGet(key, timeout)
+	if found || t.prev == nil {
+		return value, found
+	}
+
+	// The entry may not have been migrated yet
+	ctx, cancel := context.WithTimeout(context.Background(), timeout-time.Since(start))
+	defer cancel()
+	t.withKeyShardCtx(ctx, key, func(cache *SieveCache[K, V]) {
+		value, found = cache.getShared(key)
+	})
+	return value, found
+}
+
+// TryInsertWithin is like TryInsert, but waits for the locks for at most
+// timeout in total, including the lock of the previous shard of key while
+// Reshard is running. See SyncSieveCache.TryInsertWithin.
+// If Reshard replaces the shards after the value was stored, the value is
+// stored in the new shards too, without a timeout, so that it can't be left
+// behind in a shard that was already migrated.
+func (c *ShardedSieveCache[K, V]) TryInsertWithin(key K, value V, timeout time.Duration) (bool, error) {
+	defer c.invalidateHot(key)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	inserted, locked, first := false, false, true
+	var err error
+	c.update([]K{key}, func(t *shardTable[K, V]) {
+		if !first {
+			if locked {
+				t.withKeyShard(key, func(shard *SyncSieveCache[K, V]) {
+					shard.TryInsert(key, value)
+				})
+			}
+			return
+		}
+		first = false
+		lockErr := t.withKeyShardCtx(ctx, key, func(cache *SieveCache[K, V]) {
+			locked = true
+			inserted, err = cache.TryInsert(key, value)
+		})
+		if lockErr != nil {
+			err = lockErr
+		}
+	})
+	return inserted, err
+}
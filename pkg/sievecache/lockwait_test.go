@@ -0,0 +1,196 @@
+package sievecache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWithLockCtx(t *testing.T) {
+	cache, _ := NewSync[int, int](100)
+
+	if err := cache.WithLockCtx(context.Background(), func(c *SieveCache[int, int]) {
+		c.Insert(1, 1)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A callback holding the lock makes the others give up in time
+	release := make(chan struct{})
+	held := make(chan struct{})
+	go cache.WithLock(func(*SieveCache[int, int]) {
+		close(held)
+		<-release
+	})
+	<-held
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := cache.WithLockCtx(ctx, func(*SieveCache[int, int]) {
+		t.Error("Unexpected call without the lock")
+	})
+	var timeoutErr *LockTimeoutError
+	if !errors.As(err, &timeoutErr) || !timeoutErr.Timeout() || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected a lock timeout, got %v", err)
+	}
+	if timeoutErr.Waited < 5*time.Millisecond {
+		t.Errorf("Expected to wait for about 10ms, waited %v", timeoutErr.Waited)
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = cache.WithLockCtx(canceled, func(*SieveCache[int, int]) {})
+	if !errors.As(err, &timeoutErr) || timeoutErr.Timeout() || !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected a canceled lock acquisition, got %v", err)
+	}
+
+	if err := cache.TryWithLock(0, func(*SieveCache[int, int]) {}); err == nil {
+		t.Error("Expected TryWithLock to fail while the lock is held")
+	}
+
+	// A waiter that gets the lock in time runs
+	done := make(chan error)
+	go func() {
+		done <- cache.TryWithLock(time.Minute, func(c *SieveCache[int, int]) {
+			c.Insert(2, 2)
+		})
+	}()
+	time.Sleep(5 * time.Millisecond)
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// The locks abandoned by the callers that gave up were released
+	if err := cache.TryWithLock(time.Second, func(*SieveCache[int, int]) {}); err != nil {
+		t.Fatal(err)
+	}
+	if cache.Len() != 2 {
+		t.Errorf("Expected 2 entries, got %d", cache.Len())
+	}
+}
+
+func TestWithKeyLockCtx(t *testing.T) {
+	cache, _ := NewShardedWithShards[int, int](100, 4)
+	if err := cache.WithKeyLockCtx(context.Background(), 1, func(shard *SieveCache[int, int]) {
+		shard.Insert(1, 1)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := cache.Get(1); !ok || v != 1 {
+		t.Errorf("Expected 1, got %d (%v)", v, ok)
+	}
+
+	release := make(chan struct{})
+	held := make(chan struct{})
+	go cache.WithKeyLock(1, func(*SieveCache[int, int]) {
+		close(held)
+		<-release
+	})
+	<-held
+	err := cache.TryWithKeyLock(5*time.Millisecond, 1, func(*SieveCache[int, int]) {})
+	var timeoutErr *LockTimeoutError
+	if !errors.As(err, &timeoutErr) {
+		t.Errorf("Expected a lock timeout, got %v", err)
+	}
+	close(release)
+	if err := cache.TryWithKeyLock(time.Second, 1, func(*SieveCache[int, int]) {}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTryGetAndTryInsertWithin(t *testing.T) {
+	sharded, _ := NewShardedWithShards[int, int](100, 1)
+	cache := sharded.Shard(0)
+	caches := map[string]interface {
+		TryGet(int, time.Duration) (int, bool)
+		TryInsertWithin(int, int, time.Duration) (bool, error)
+	}{"sync": cache, "sharded": sharded}
+
+	for name, c := range caches {
+		if ok, err := c.TryInsertWithin(1, 10, 0); !ok || err != nil {
+			t.Fatalf("%s: expected the insert to succeed, got %v (%v)", name, ok, err)
+		}
+		if v, ok := c.TryGet(1, 0); !ok || v != 10 {
+			t.Errorf("%s: expected 10, got %d (%v)", name, v, ok)
+		}
+
+		release := make(chan struct{})
+		held := make(chan struct{})
+		go cache.WithLock(func(*SieveCache[int, int]) {
+			close(held)
+			<-release
+		})
+		<-held
+		if _, ok := c.TryGet(1, 0); ok {
+			t.Errorf("%s: expected a miss while the lock is held", name)
+		}
+		if _, ok := c.TryGet(1, time.Millisecond); ok {
+			t.Errorf("%s: expected a miss after waiting for the lock", name)
+		}
+		var timeoutErr *LockTimeoutError
+		if ok, err := c.TryInsertWithin(2, 20, time.Millisecond); ok || !errors.As(err, &timeoutErr) {
+			t.Errorf("%s: expected a lock timeout, got %v (%v)", name, ok, err)
+		}
+		close(release)
+
+		if v, ok := c.TryGet(1, time.Second); !ok || v != 10 {
+			t.Errorf("%s: expected 10 once the lock is released, got %d (%v)", name, v, ok)
+		}
+		cache.Remove(1)
+	}
+}
+
+func TestTryWithinDuringReshard(t *testing.T) {
+	cache, _ := NewShardedWithShards[int, int](1000, 2)
+	before := cache.table.Load()
+	if err := cache.Reshard(4); err != nil {
+		t.Fatal(err)
+	}
+	after := cache.table.Load()
+	// Replays a Reshard that didn't migrate the previous shards yet
+	cache.table.Store(&shardTable[int, int]{shards: after.shards, prev: before.shards, mapping: after.mapping, current: &cache.table})
+	const key = 1
+	prev, next := before.shardFor(hashKey(key)), after.shardFor(hashKey(key))
+	prev.Insert(key, 10)
+
+	var timeoutErr *LockTimeoutError
+	for _, shard := range []*SyncSieveCache[int, int]{prev, next} {
+		release := make(chan struct{})
+		held := make(chan struct{})
+		go shard.WithLock(func(*SieveCache[int, int]) {
+			close(held)
+			<-release
+		})
+		<-held
+		start := time.Now()
+		if ok, err := cache.TryInsertWithin(key, 20, 5*time.Millisecond); ok || !errors.As(err, &timeoutErr) {
+			t.Errorf("Expected a lock timeout, got %v (%v)", ok, err)
+		}
+		if _, ok := cache.TryGet(key, 5*time.Millisecond); ok {
+			t.Error("Expected a miss while a shard is locked")
+		}
+		if err := cache.TryWithKeyLock(5*time.Millisecond, key, func(*SieveCache[int, int]) {}); !errors.As(err, &timeoutErr) {
+			t.Errorf("Expected a lock timeout, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("Expected bounded waits, waited %v", elapsed)
+		}
+		close(release)
+	}
+
+	// The entry wasn't lost by the calls that gave up
+	if v, ok := cache.TryGet(key, time.Second); !ok || v != 10 {
+		t.Errorf("Expected 10 from the previous shard, got %d (%v)", v, ok)
+	}
+	if _, err := cache.TryInsertWithin(key, 20, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if prev.ContainsKey(key) || !next.ContainsKey(key) {
+		t.Error("Expected the entry to be moved to its new shard")
+	}
+	if v, ok := cache.TryGet(key, 0); !ok || v != 20 {
+		t.Errorf("Expected 20, got %d (%v)", v, ok)
+	}
+}
@@ -0,0 +1,173 @@
+package sievecache
+
+import (
+	"slices"
+	"unsafe"
+)
+
+// mapLoadFactor approximates the average load factor of Go maps.
+const mapLoadFactor = 0.875
+
+// EstimatedMemory returns the approximate number of heap bytes used by the
+// cache structures: the index, the node slice, the visited bits and the
+// state of the policy.
+// Keys and values are accounted for by their in-place size only; memory they
+// reference (string contents, slices, maps, pointers) is not included.
+func (c *SieveCache[K, V]) EstimatedMemory() int64 {
+	var zeroKey K
+	var zeroNode Node[K, V]
+
+	total := int64(unsafe.Sizeof(*c))
+
+	// Nodes are stored by value, and the backing array is sized for its capacity
+	total += int64(cap(c.nodes)) * int64(unsafe.Sizeof(zeroNode))
+
+	// Visited flags
+	total += c.visited.EstimatedMemory()
+
+	// Costs, the doorkeeper, and the flags and frequencies of policies
+	// other than SIEVE
+	total += int64(cap(c.costs)) * 8
+	if c.doorkeeper != nil {
+		total += int64(len(c.doorkeeper.current)+len(c.doorkeeper.previous)) * 8
+	}
+	if c.policy != nil {
+		total += int64(cap(c.policy.flags))
+		if c.policy.sketch != nil {
+			total += c.policy.sketch.EstimatedMemory()
+		}
+	}
+
+	// Open addressing tables store 8 bytes per slot, and Swiss tables an
+	// extra control byte
+	if c.indices.open != nil {
+		return total + int64(len(c.indices.open.entries))*int64(unsafe.Sizeof(openEntry{}))
+	}
+	if c.indices.swiss != nil {
+		return total + int64(len(c.indices.swiss.slots))*int64(unsafe.Sizeof(openEntry{})+1)
+	}
+
+	// Go maps never shrink, and the index map is sized for the capacity when
+	// the node slice is, so use the largest of both as the number of slots.
+	// Each slot stores a key, an int and roughly one byte of control metadata.
+	slots := max(len(c.indices.m), cap(c.nodes))
+	slotSize := int64(unsafe.Sizeof(zeroKey)) + int64(unsafe.Sizeof(int(0))) + 1
+	total += int64(float64(slots)/mapLoadFactor) * slotSize
+
+	return total
+}
+
+// EstimatedMemory returns the approximate number of heap bytes used by the cache structures.
+// See SieveCache.EstimatedMemory for what is included.
+func (c *SyncSieveCache[K, V]) EstimatedMemory() int64 {
+	c.rlock()
+	defer c.runlock()
+	return int64(unsafe.Sizeof(*c)) + c.cache.EstimatedMemory()
+}
+
+// EstimatedMemory returns the approximate number of heap bytes used by the
+// structures of all shards.
+// See SieveCache.EstimatedMemory for what is included.
+func (c *ShardedSieveCache[K, V]) EstimatedMemory() int64 {
+	shards := c.table.Load().all()
+	total := int64(unsafe.Sizeof(*c)) + int64(unsafe.Sizeof(shardTable[K, V]{})) + int64(len(shards))*int64(unsafe.Sizeof(c))
+	for _, shard := range shards {
+		total += shard.EstimatedMemory()
+	}
+	return total
+}
+
+// minAutoShrinkSlots is the number of slots below which a cache is never
+// shrunk automatically, as reallocating would save little.
+const minAutoShrinkSlots = 1024
+
+// ShrinkToFit releases the storage reserved beyond the current number of
+// entries, which is kept after mass removals, so that a long-lived cache
+// that occasionally spikes returns the memory to the runtime. Storage grows
+// again on demand as entries are inserted.
+func (c *SieveCache[K, V]) ShrinkToFit() {
+	n := len(c.nodes)
+	c.nodes = slices.Clip(slices.Clone(c.nodes))
+	c.visited.Clip()
+	if c.sizer != nil {
+		c.costs = slices.Clip(slices.Clone(c.costs))
+	}
+	if p := c.policy; p != nil {
+		p.flags = slices.Clip(slices.Clone(p.flags))
+		p.window = slices.Clip(slices.Clone(p.window[p.windowHead:]))
+		p.windowHead = 0
+	}
+
+	// Go maps never shrink, so the index is rebuilt
+	indices, _ := newSlotIndex[K, V](c.indices.kind(), n)
+	for i := range c.nodes {
+		indices.add(c.nodes[i].Key, i)
+	}
+	c.indices = indices
+}
+
+// maybeShrink shrinks the cache if fewer entries than the ShrinkThreshold
+// fraction of its slots are in use.
+func (c *SieveCache[K, V]) maybeShrink() {
+	slots := cap(c.nodes)
+	if slots >= minAutoShrinkSlots && float64(len(c.nodes)) < c.shrinkThreshold*float64(slots) {
+		c.ShrinkToFit()
+	}
+}
+
+// ShrinkToFit releases the storage reserved beyond the current number of entries.
+// See SieveCache.ShrinkToFit for details.
+func (c *SyncSieveCache[K, V]) ShrinkToFit() {
+	c.lock()
+	defer c.unlock()
+	c.cache.ShrinkToFit()
+}
+
+// ShrinkToFit releases the storage reserved beyond the current number of
+// entries of each shard, one shard at a time.
+// See SieveCache.ShrinkToFit for details.
+func (c *ShardedSieveCache[K, V]) ShrinkToFit() {
+	for _, shard := range c.table.Load().all() {
+		shard.ShrinkToFit()
+	}
+}
+
+// OverheadPerEntry returns the approximate number of bytes of bookkeeping per
+// entry: the memory reported by EstimatedMemory, minus the in-place size of
+// the keys and values of the entries, divided by their number. Slots
+// preallocated for entries that aren't there yet count as overhead, so it is
+// lowest for a full cache. With IndexMap, the copy of each key held by the
+// map counts as overhead too. Returns 0 for an empty cache.
+//
+// For a full cache with the default options, the overhead stays below 64
+// bytes per entry for keys of up to 16 bytes, and for any key size with
+// IndexOpenAddressing or IndexSwiss.
+func (c *SieveCache[K, V]) OverheadPerEntry() float64 {
+	return overheadPerEntry[K, V](c.EstimatedMemory(), len(c.nodes))
+}
+
+// OverheadPerEntry returns the approximate number of bytes of bookkeeping per entry.
+// See SieveCache.OverheadPerEntry for details.
+func (c *SyncSieveCache[K, V]) OverheadPerEntry() float64 {
+	c.rlock()
+	defer c.runlock()
+	return overheadPerEntry[K, V](int64(unsafe.Sizeof(*c))+c.cache.EstimatedMemory(), c.cache.Len())
+}
+
+// OverheadPerEntry returns the approximate number of bytes of bookkeeping per
+// entry across all shards. See SieveCache.OverheadPerEntry for details.
+func (c *ShardedSieveCache[K, V]) OverheadPerEntry() float64 {
+	return overheadPerEntry[K, V](c.EstimatedMemory(), c.Len())
+}
+
+// overheadPerEntry returns the memory of a cache not used by its n keys and
+// values, divided by n, or 0 if n is 0.
+func overheadPerEntry[K comparable, V any](memory int64, n int) float64 {
+	if n == 0 {
+		return 0
+	}
+	var zeroKey K
+	var zeroValue V
+	payload := int64(n) * int64(unsafe.Sizeof(zeroKey)+unsafe.Sizeof(zeroValue))
+	return float64(memory-payload) / float64(n)
+}
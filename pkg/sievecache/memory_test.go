@@ -0,0 +1,233 @@
+package sievecache
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/metrics"
+	"testing"
+)
+
+func TestEstimatedMemory(t *testing.T) {
+	small, _ := New[int, int](10)
+	large, _ := New[int, int](100000)
+	if small.EstimatedMemory() >= large.EstimatedMemory() {
+		t.Errorf("Expected a larger cache to use more memory: %d vs %d",
+			small.EstimatedMemory(), large.EstimatedMemory())
+	}
+
+	// Releasing memory is reflected in the estimate
+	before := large.EstimatedMemory()
+	large.ClearWithOptions(ClearOptions[int, int]{ReleaseMemory: true})
+	if after := large.EstimatedMemory(); after >= before {
+		t.Errorf("Expected estimate to drop after releasing memory: %d vs %d", after, before)
+	}
+
+	syncCache, _ := NewSync[int, int](1000)
+	sharded, _ := NewShardedWithShards[int, int](1000, 4)
+	if syncCache.EstimatedMemory() <= 0 || sharded.EstimatedMemory() <= 0 {
+		t.Error("Expected positive estimates")
+	}
+}
+
+func TestEstimatedMemoryAccuracy(t *testing.T) {
+	const capacity = 200000
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	cache, _ := New[int, int](capacity)
+	for i := 0; i < capacity; i++ {
+		cache.Insert(i, i)
+	}
+
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	actual := int64(after.HeapAlloc) - int64(before.HeapAlloc)
+	estimated := cache.EstimatedMemory()
+
+	t.Logf("estimated=%d actual=%d", estimated, actual)
+
+	// The estimate only needs to be in the right ballpark
+	if estimated < actual/2 || estimated > actual*2 {
+		t.Errorf("Estimate %d is too far from measured heap growth %d", estimated, actual)
+	}
+	runtime.KeepAlive(cache)
+}
+
+func TestPointerFreeStorageIsNotScanned(t *testing.T) {
+	// For pointer-free key and value types, the storage of the entries
+	// doesn't add to the heap the garbage collector has to scan
+	const n = 1 << 18
+	before := scannableHeap()
+	cache, _ := New[int, [4]int64](n)
+	for i := 0; i < n; i++ {
+		cache.Insert(i, [4]int64{int64(i)})
+	}
+	scanned := scannableHeap() - before
+	if memory := cache.EstimatedMemory(); scanned > memory/100 {
+		t.Errorf("Expected the storage not to be scanned, %d of %d bytes are", scanned, memory)
+	}
+
+	// Inserting doesn't allocate per-entry objects either
+	allocs := testing.AllocsPerRun(100, func() {
+		cache.Remove(500)
+		cache.Insert(500, [4]int64{500})
+	})
+	if allocs != 0 {
+		t.Errorf("Expected no allocations, got %f", allocs)
+	}
+	runtime.KeepAlive(cache)
+}
+
+// scannableHeap returns the size of the heap the garbage collector scans,
+// after a collection.
+func scannableHeap() int64 {
+	runtime.GC()
+	sample := []metrics.Sample{{Name: "/gc/scan/heap:bytes"}}
+	metrics.Read(sample)
+	return int64(sample[0].Value.Uint64())
+}
+
+func TestInsertEvictChurnDoesNotAllocate(t *testing.T) {
+	keys := make([]string, 10000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%d", i)
+	}
+
+	caches := map[string]interface{ Insert(string, int) bool }{}
+	caches["base"], _ = New[string, int](1000)
+	caches["sync"], _ = NewSync[string, int](1000)
+	caches["sharded"], _ = NewShardedWithShards[string, int](1000, 8)
+
+	for name, cache := range caches {
+		// Warm up so that all internal storage is allocated
+		for _, key := range keys {
+			cache.Insert(key, 0)
+		}
+
+		i := 0
+		allocs := testing.AllocsPerRun(5000, func() {
+			cache.Insert(keys[i%len(keys)], i)
+			i++
+		})
+		if allocs != 0 && !(lockChecks && name != "base") {
+			t.Errorf("%s: expected no allocations per insert, got %f", name, allocs)
+		}
+	}
+}
+
+func TestOverheadPerEntry(t *testing.T) {
+	empty, _ := New[string, int](100)
+	if overhead := empty.OverheadPerEntry(); overhead != 0 {
+		t.Errorf("Expected no overhead for an empty cache, got %f", overhead)
+	}
+
+	for _, index := range []Index{IndexMap, IndexOpenAddressing, IndexSwiss} {
+		cache, _ := NewSyncWithOptions(Options[string, int]{Capacity: 10000, Index: index})
+		for i := 0; i < 10000; i++ {
+			cache.Insert(fmt.Sprint(i), i)
+		}
+		if overhead := cache.OverheadPerEntry(); overhead <= 0 || overhead >= 64 {
+			t.Errorf("Index %d: expected less than 64 bytes of overhead per entry, got %f", index, overhead)
+		}
+	}
+
+	// With large keys, only the indexes that don't copy keys stay below the target
+	cache, _ := NewShardedWithOptions(Options[[64]byte, int]{Capacity: 10000, Index: IndexOpenAddressing}, 4)
+	for i := 0; i < 10000; i++ {
+		cache.Insert([64]byte{byte(i), byte(i >> 8)}, i)
+	}
+	if overhead := cache.OverheadPerEntry(); overhead >= 64 {
+		t.Errorf("Expected less than 64 bytes of overhead per entry, got %f", overhead)
+	}
+}
+
+func TestShrinkToFit(t *testing.T) {
+	cache, _ := NewWithOptions(Options[int, int]{Capacity: 100000, Policy: PolicyWTinyLFU})
+	for i := 0; i < 100000; i++ {
+		cache.Insert(i, i)
+	}
+	cache.Get(42)
+	cache.Retain(func(k, _ int) bool { return k < 1000 })
+	before := cache.EstimatedMemory()
+
+	cache.ShrinkToFit()
+	// The frequency sketch stays sized for the capacity
+	if after := cache.EstimatedMemory(); after >= before/4 {
+		t.Errorf("Expected ShrinkToFit to release most of the memory: %d vs %d", after, before)
+	}
+	if err := cache.CheckInvariants(); err != nil {
+		t.Fatal(err)
+	}
+	if idx, _ := cache.indices.get(42, cache.nodes); !cache.visited.Get(idx) {
+		t.Error("Expected visited flags to be kept")
+	}
+	if value, found := cache.Get(999); !found || value != 999 {
+		t.Errorf("Expected 999, got %v (found: %v)", value, found)
+	}
+
+	// Storage grows back on demand
+	for i := 0; i < 100000; i++ {
+		cache.Insert(i, i)
+	}
+	if cache.Len() != 100000 {
+		t.Errorf("Expected a full cache, got %d entries", cache.Len())
+	}
+}
+
+func TestShrinkThreshold(t *testing.T) {
+	if _, err := NewWithOptions(Options[int, int]{Capacity: 10, ShrinkThreshold: 1}); err == nil {
+		t.Error("Expected a shrink threshold of 1 to be rejected")
+	}
+
+	cache, _ := NewSyncWithOptions(Options[int, int]{Capacity: 10000, ShrinkThreshold: 0.25})
+	for i := 0; i < 10000; i++ {
+		cache.Insert(i, i)
+	}
+	for i := 0; i < 7000; i++ {
+		cache.Remove(i)
+	}
+	if slots := cap(cache.cache.nodes); slots != 10000 {
+		t.Errorf("Expected no shrinking above the threshold, got %d slots", slots)
+	}
+	for i := 7000; i < 7600; i++ {
+		cache.Remove(i)
+	}
+	// The cache shrinks once, when it crosses the threshold
+	if slots := cap(cache.cache.nodes); slots != 2499 {
+		t.Errorf("Expected the cache to shrink to 2499 slots, got %d", slots)
+	}
+	if err := cache.CheckInvariants(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInitialSize(t *testing.T) {
+	if _, err := NewWithOptions(Options[int, int]{Capacity: 10, InitialSize: -1}); err == nil {
+		t.Error("Expected a negative initial size to be rejected")
+	}
+
+	full, _ := New[int, int](1000000)
+	cache, _ := NewWithOptions(Options[int, int]{Capacity: 1000000, InitialSize: 100, Policy: PolicySLRU})
+	if cap(cache.nodes) != 100 || cache.EstimatedMemory() >= full.EstimatedMemory()/100 {
+		t.Errorf("Expected storage for 100 entries, got %d slots", cap(cache.nodes))
+	}
+	for i := 0; i < 1000; i++ {
+		cache.Insert(i, i)
+	}
+	if cache.Len() != 1000 {
+		t.Errorf("Expected storage to grow on demand, got %d entries", cache.Len())
+	}
+	cache.Clear()
+	if cap(cache.nodes) != 100 {
+		t.Errorf("Expected Clear to preallocate 100 slots, got %d", cap(cache.nodes))
+	}
+
+	sharded, _ := NewShardedWithOptions(Options[int, int]{Capacity: 1000000, InitialSize: 1000}, 4)
+	for _, shard := range sharded.table.Load().shards {
+		if slots := cap(shard.cache.nodes); slots != 250 {
+			t.Errorf("Expected 250 slots per shard, got %d", slots)
+		}
+	}
+}
@@ -4,6 +4,8 @@ package sievecache
 type Node[K comparable, V any] struct {
 	Key   K
 	Value V
+	// Version of the value, assigned by the cache when it was stored
+	version uint64
 }
 
 // NewNode creates a new cache node
@@ -13,3 +15,9 @@ func NewNode[K comparable, V any](key K, value V) Node[K, V] {
 		Value: value,
 	}
 }
+
+// Item is a key-value pair returned by the cache iteration methods.
+type Item[K comparable, V any] struct {
+	Key   K
+	Value V
+}
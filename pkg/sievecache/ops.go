@@ -0,0 +1,129 @@
+package sievecache
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// Op is a kind of cache operation recorded by RecentOps.
+type Op uint8
+
+const (
+	// OpGet is a lookup with Get
+	OpGet Op = iota + 1
+	// OpInsert is an insertion with Insert or one of its variants
+	OpInsert
+	// OpRemove is an explicit removal
+	OpRemove
+	// OpEvict is an eviction
+	OpEvict
+)
+
+// String returns the name of the operation.
+func (op Op) String() string {
+	switch op {
+	case OpGet:
+		return "get"
+	case OpInsert:
+		return "insert"
+	case OpRemove:
+		return "remove"
+	case OpEvict:
+		return "evict"
+	}
+	return "unknown"
+}
+
+// OpRecord is a cache operation recorded by RecentOps.
+type OpRecord struct {
+	// Op is the kind of operation
+	Op Op
+	// Time is when the operation started
+	Time time.Time
+	// KeyHash is a hash of the key, so that records don't retain keys.
+	// Hashes are only consistent within a process.
+	KeyHash uint64
+	// Result is true for hits, new entries, removed entries and evictions
+	Result bool
+	// Latency is the duration of the operation
+	Latency time.Duration
+}
+
+// opRecorder keeps the most recent operations of a cache in a ring buffer.
+// It has its own lock, so that lookups can be recorded while only holding a
+// read lock on the cache.
+type opRecorder struct {
+	mutex sync.Mutex
+	ops   []OpRecord
+	next  int
+	size  int
+}
+
+// newOpRecorder creates a recorder keeping the last size operations.
+func newOpRecorder(size int) *opRecorder {
+	return &opRecorder{ops: make([]OpRecord, 0, size), size: size}
+}
+
+// since records an operation on the key with the given hash that started at
+// start. ok points to its result, and may be nil for operations that always
+// succeed. It is meant to be deferred.
+func (r *opRecorder) since(op Op, keyHash uint64, ok *bool, start time.Time) {
+	record := OpRecord{Op: op, Time: start, KeyHash: keyHash, Result: ok == nil || *ok, Latency: time.Since(start)}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if len(r.ops) < r.size {
+		r.ops = append(r.ops, record)
+		return
+	}
+	r.ops[r.next] = record
+	r.next = (r.next + 1) % r.size
+}
+
+// recent returns the recorded operations, oldest first.
+func (r *opRecorder) recent() []OpRecord {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	ops := make([]OpRecord, 0, len(r.ops))
+	ops = append(ops, r.ops[r.next:]...)
+	return append(ops, r.ops[:r.next]...)
+}
+
+// clone returns an independent copy of the recorder.
+func (r *opRecorder) clone() *opRecorder {
+	clone := newOpRecorder(r.size)
+	clone.ops = append(clone.ops, r.recent()...)
+	return clone
+}
+
+// RecentOps returns the most recent operations on the cache, oldest first,
+// or nil unless the cache was created with RecordOps set.
+func (c *SieveCache[K, V]) RecentOps() []OpRecord {
+	if c.ops == nil {
+		return nil
+	}
+	return c.ops.recent()
+}
+
+// RecentOps returns the most recent operations on the cache, oldest first,
+// or nil unless the cache was created with RecordOps set.
+func (c *SyncSieveCache[K, V]) RecentOps() []OpRecord {
+	c.rlock()
+	defer c.runlock()
+	return c.cache.RecentOps()
+}
+
+// RecentOps returns the most recent operations on all shards, oldest first,
+// or nil unless the cache was created with RecordOps set. Each shard keeps
+// its own operations, and lookups served by hot key replicas are not
+// recorded.
+func (c *ShardedSieveCache[K, V]) RecentOps() []OpRecord {
+	var ops []OpRecord
+	for _, shard := range c.table.Load().all() {
+		ops = append(ops, shard.RecentOps()...)
+	}
+	slices.SortStableFunc(ops, func(a, b OpRecord) int {
+		return a.Time.Compare(b.Time)
+	})
+	return ops
+}
@@ -0,0 +1,66 @@
+package sievecache
+
+import "testing"
+
+func TestRecentOps(t *testing.T) {
+	if _, err := NewWithOptions(Options[string, int]{Capacity: 2, RecordOps: -1}); err == nil {
+		t.Error("Expected an error for a negative RecordOps")
+	}
+	cache, _ := NewWithOptions(Options[string, int]{Capacity: 2, RecordOps: 4})
+	if ops := (&SieveCache[string, int]{}).RecentOps(); ops != nil {
+		t.Errorf("Expected no operations without RecordOps, got %v", ops)
+	}
+
+	cache.Insert("a", 1)
+	cache.Get("a")
+	cache.Get("b")
+	cache.Remove("a")
+	cache.Insert("b", 2)
+	cache.Insert("c", 3)
+	cache.Insert("d", 4)
+
+	// Only the last 4 operations are kept: the last insert evicted "c"
+	ops := cache.RecentOps()
+	expected := []struct {
+		op     Op
+		key    string
+		result bool
+	}{
+		{OpInsert, "b", true},
+		{OpInsert, "c", true},
+		{OpEvict, "c", true},
+		{OpInsert, "d", true},
+	}
+	if len(ops) != len(expected) {
+		t.Fatalf("Expected %d operations, got %v", len(expected), ops)
+	}
+	for i, e := range expected {
+		if ops[i].Op != e.op || ops[i].KeyHash != hashKey(e.key) || ops[i].Result != e.result {
+			t.Errorf("Operation %d: expected %v %q %v, got %+v", i, e.op, e.key, e.result, ops[i])
+		}
+	}
+	// The eviction is recorded when it completes, within the insert
+	if ops[2].Time.Before(ops[3].Time) {
+		t.Error("Expected the eviction to start after the insert that caused it")
+	}
+	if ops[3].Op.String() != "insert" {
+		t.Errorf("Unexpected operation name %q", ops[3].Op)
+	}
+}
+
+func TestShardedRecentOps(t *testing.T) {
+	cache, _ := NewShardedWithOptions(Options[int, int]{Capacity: 100, RecordOps: 10}, 4)
+	for i := 0; i < 5; i++ {
+		cache.Insert(i, i)
+		cache.Get(i)
+	}
+	ops := cache.RecentOps()
+	if len(ops) != 10 {
+		t.Fatalf("Expected 10 operations, got %d", len(ops))
+	}
+	for i := 1; i < len(ops); i++ {
+		if ops[i].Time.Before(ops[i-1].Time) {
+			t.Fatal("Expected operations to be sorted by time")
+		}
+	}
+}
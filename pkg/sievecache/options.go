@@ -0,0 +1,139 @@
+package sievecache
+
+import "math"
+
+// Options configures a cache created with NewWithOptions, NewSyncWithOptions
+// or NewShardedWithOptions. Zero values select the defaults.
+type Options[K comparable, V any] struct {
+	// Capacity is the maximum number of entries. It must not be negative.
+	// A capacity of 0 creates a null cache, which never stores anything, so
+	// that caching can be disabled without changing call sites.
+	// For a sharded cache, this is the total capacity across all shards.
+	Capacity int
+
+	// MaxEvictionScan bounds the number of entries the SIEVE hand may
+	// examine during a single eviction. When every examined entry has been
+	// visited, the next entry is evicted anyway. The hand keeps moving from
+	// there, so visited flags are still cleared exactly once per pass and
+	// the total scanning work stays proportional to the number of accesses;
+	// the limit only bounds the latency of an individual insert.
+	//
+	// Zero means unbounded: an eviction may scan the whole cache, which is
+	// the exact SIEVE behavior.
+	MaxEvictionScan int
+
+	// Sizer returns the cost of an entry, typically its size in bytes.
+	// It is called when an entry is inserted or updated; changes made to a
+	// value in place, for example with GetMut, don't update its cost.
+	// It must not access the cache.
+	Sizer func(key K, value V) int64
+
+	// MaxCost bounds the total cost of the entries, as computed by Sizer,
+	// which is then required. Entries are evicted until a new entry fits,
+	// and entries whose cost alone exceeds MaxCost are not stored.
+	// Zero means that only the number of entries is bounded. Combined with
+	// a capacity of Unbounded, only the cost is.
+	// For a sharded cache, this is the total cost across all shards.
+	MaxCost int64
+
+	// MaxItemCost is the maximum cost of a single entry, as computed by
+	// Sizer, which is then required. Larger entries are rejected, so that a
+	// single enormous value can't wipe out the whole cache. Zero means that
+	// entries are only limited by MaxCost.
+	MaxItemCost int64
+
+	// BeforeInsert, if set, is called with every value passed to Insert,
+	// Swap or InsertBatch, and returns the value to store instead, for
+	// example a defensive copy or a sanitized version. If it returns an
+	// error, the value is rejected like a value exceeding MaxItemCost, and
+	// TryInsert returns the error. It must not access the cache.
+	BeforeInsert func(key K, value V) (V, error)
+
+	// Cloner, if set, returns a deep copy of a value. The cache then stores
+	// copies of inserted values, and Get, Items, Values and other methods
+	// returning values return copies, so that callers mutating slices or
+	// maps in place can't corrupt cached values. GetPointer, GetMut and the
+	// ForEach methods still give access to the stored values.
+	Cloner func(value V) V
+
+	// Policy selects how entries are chosen for eviction. Defaults to
+	// PolicySIEVE.
+	Policy Policy
+
+	// Index selects the data structure mapping keys to entries. Defaults
+	// to IndexMap.
+	Index Index
+
+	// ShardMapping selects how a sharded cache maps keys to shards.
+	// Defaults to ShardMappingRange. It is ignored by other caches.
+	ShardMapping ShardMapping
+
+	// InitialSize is the number of entries storage is preallocated for,
+	// when it should be smaller than the capacity, so that creating many
+	// caches with a large capacity doesn't eagerly allocate their storage.
+	// Storage then grows on demand. Zero preallocates storage for the full
+	// capacity, or none for an Unbounded capacity. It must not be negative.
+	// For a sharded cache, this is the total across all shards.
+	InitialSize int
+
+	// ShrinkThreshold, if set, makes Remove and Retain call ShrinkToFit
+	// when they leave fewer entries than this fraction of the slots
+	// allocated for entries, for example 0.25, so that memory is returned
+	// to the runtime after a burst of removals. Caches with fewer than 1024
+	// slots are never shrunk automatically. It must be between 0 and 1.
+	ShrinkThreshold float64
+
+	// DoorkeeperSize, if set, enables a doorkeeper: once the cache is full,
+	// a new entry is only stored if its key was inserted before, recently.
+	// Keys that are only inserted once, which SIEVE would otherwise have to
+	// evict one by one, are then turned away like entries exceeding
+	// MaxItemCost, and TryInsert returns ErrNotAdmitted. The doorkeeper is
+	// a Bloom filter using 3 to 6 bytes per key, that remembers between
+	// DoorkeeperSize and twice as many of the most recently turned away
+	// keys, for example the capacity. It must not be negative.
+	// For a sharded cache, this is the total across all shards.
+	DoorkeeperSize int
+
+	// AdmissionRate, if set, caps the number of new entries per second that
+	// may displace existing entries once the cache is full, so that a
+	// backup job or a crawler sweeping millions of keys that are only
+	// accessed once can't flush the working set. New entries above that
+	// rate are rejected like entries exceeding MaxItemCost, and TryInsert
+	// returns ErrNotAdmitted. Entries stored in free space and updates of
+	// existing entries are never limited. It must not be negative.
+	// For a sharded cache, this is the total rate across all shards.
+	AdmissionRate float64
+
+	// AdmissionBurst is the number of new entries that may displace
+	// existing ones at once, before AdmissionRate applies. Defaults to the
+	// rate, with a minimum of 1.
+	AdmissionBurst int
+
+	// RecordStats enables counting lookups, which Stats reports along with
+	// the hit ratio over the last minutes. Every lookup then reads the clock
+	// and updates shared counters, which has a small cost.
+	RecordStats bool
+
+	// RecordLatencies enables recording histograms of the durations of Get,
+	// Insert and Evict, which Stats reports. Every such operation then reads
+	// the clock twice, which is significant compared to a lookup.
+	RecordLatencies bool
+
+	// RecordOps is the number of recent operations kept for RecentOps, with
+	// their result and latency, to help reproduce bugs involving the cache.
+	// Every operation then reads the clock twice and takes a lock. Zero
+	// disables recording. For a sharded cache, each shard keeps that many.
+	RecordOps int
+
+	// Clock is the source of the current time for statistics.
+	// Defaults to the system clock.
+	Clock Clock
+
+	// newKeyIndex creates the index of the keys of each cache, if set
+	newKeyIndex func() keyIndex[K]
+}
+
+// Unbounded is a capacity without a limit on the number of entries, for
+// caches whose size is bounded by MaxCost or adjusted by a MemoryGovernor.
+// Storage isn't preallocated for such caches.
+const Unbounded = math.MaxInt
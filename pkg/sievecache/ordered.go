@@ -0,0 +1,266 @@
+package sievecache
+
+import (
+	"cmp"
+	"math/rand"
+	"slices"
+)
+
+// keyIndex is kept up to date with the keys of a cache.
+type keyIndex[K comparable] interface {
+	add(key K)
+	remove(key K)
+	clear()
+	clone() keyIndex[K]
+}
+
+// Maximum level of the skip list of an orderedIndex, which keeps it
+// efficient up to 4^orderedIndexMaxLevel keys
+const orderedIndexMaxLevel = 16
+
+// orderedIndexNode is a node of the skip list of an orderedIndex.
+type orderedIndexNode[K cmp.Ordered] struct {
+	key  K
+	next []*orderedIndexNode[K]
+}
+
+// orderedIndex is a keyIndex keeping the keys sorted in a skip list, so that
+// a range of keys can be found in logarithmic time.
+type orderedIndex[K cmp.Ordered] struct {
+	head  orderedIndexNode[K]
+	level int
+	// State of the xorshift generator choosing the levels of new nodes
+	rng uint64
+}
+
+// newOrderedIndex creates an empty orderedIndex.
+func newOrderedIndex[K cmp.Ordered]() keyIndex[K] {
+	x := &orderedIndex[K]{rng: rand.Uint64() | 1}
+	x.clear()
+	return x
+}
+
+// randomLevel returns the level of a new node: 1, 2 with probability 1/4,
+// 3 with probability 1/16, and so on.
+func (x *orderedIndex[K]) randomLevel() int {
+	x.rng ^= x.rng << 13
+	x.rng ^= x.rng >> 7
+	x.rng ^= x.rng << 17
+	level := 1
+	for r := x.rng; level < orderedIndexMaxLevel && r&3 == 0; r >>= 2 {
+		level++
+	}
+	return level
+}
+
+// findPath returns, for each level, the last node whose key is lower than key.
+func (x *orderedIndex[K]) findPath(key K) [orderedIndexMaxLevel]*orderedIndexNode[K] {
+	var path [orderedIndexMaxLevel]*orderedIndexNode[K]
+	node := &x.head
+	for i := x.level - 1; i >= 0; i-- {
+		for node.next[i] != nil && node.next[i].key < key {
+			node = node.next[i]
+		}
+		path[i] = node
+	}
+	return path
+}
+
+// seek returns the first node whose key is at least key, or nil.
+func (x *orderedIndex[K]) seek(key K) *orderedIndexNode[K] {
+	if x.level == 0 {
+		return nil
+	}
+	return x.findPath(key)[0].next[0]
+}
+
+func (x *orderedIndex[K]) add(key K) {
+	path := x.findPath(key)
+	if next := path[0]; x.level > 0 && next.next[0] != nil && next.next[0].key == key {
+		return
+	}
+	level := x.randomLevel()
+	for ; x.level < level; x.level++ {
+		path[x.level] = &x.head
+	}
+	node := &orderedIndexNode[K]{key: key, next: make([]*orderedIndexNode[K], level)}
+	for i := 0; i < level; i++ {
+		node.next[i] = path[i].next[i]
+		path[i].next[i] = node
+	}
+}
+
+func (x *orderedIndex[K]) remove(key K) {
+	if x.level == 0 {
+		return
+	}
+	path := x.findPath(key)
+	node := path[0].next[0]
+	if node == nil || node.key != key {
+		return
+	}
+	for i := range node.next {
+		path[i].next[i] = node.next[i]
+	}
+	for x.level > 0 && x.head.next[x.level-1] == nil {
+		x.level--
+	}
+}
+
+func (x *orderedIndex[K]) clear() {
+	x.head.next = make([]*orderedIndexNode[K], orderedIndexMaxLevel)
+	x.level = 0
+}
+
+func (x *orderedIndex[K]) clone() keyIndex[K] {
+	clone := &orderedIndex[K]{rng: x.rng}
+	clone.clear()
+	// Keys are appended in order, so each level is extended at its tail
+	var tails [orderedIndexMaxLevel]*orderedIndexNode[K]
+	for i := range tails {
+		tails[i] = &clone.head
+	}
+	for node := x.head.next[0]; node != nil; node = node.next[0] {
+		level := clone.randomLevel()
+		copied := &orderedIndexNode[K]{key: node.key, next: make([]*orderedIndexNode[K], level)}
+		for i := 0; i < level; i++ {
+			tails[i].next[i] = copied
+			tails[i] = copied
+		}
+		clone.level = max(clone.level, level)
+	}
+	return clone
+}
+
+// rangeItems returns the entries of c whose keys are in [from, to), sorted
+// by key. The index must be the one of c.
+func rangeItems[K cmp.Ordered, V any](c *SieveCache[K, V], from, to K) []Item[K, V] {
+	var items []Item[K, V]
+	x := c.keyIndex.(*orderedIndex[K])
+	for node := x.seek(from); node != nil && node.key < to; node = node.next[0] {
+		idx, _ := c.indices.get(node.key, c.nodes)
+		items = append(items, Item[K, V]{Key: node.key, Value: c.copyValue(c.nodes[idx].Value)})
+	}
+	return items
+}
+
+// sortedKeys returns the keys of c in increasing order. The index must be the one of c.
+func sortedKeys[K cmp.Ordered, V any](c *SieveCache[K, V]) []K {
+	keys := make([]K, 0, len(c.nodes))
+	x := c.keyIndex.(*orderedIndex[K])
+	for node := x.head.next[0]; node != nil; node = node.next[0] {
+		keys = append(keys, node.key)
+	}
+	return keys
+}
+
+// withOrderedIndex returns opts configured to maintain a sorted index of the keys.
+func withOrderedIndex[K cmp.Ordered, V any](opts Options[K, V]) Options[K, V] {
+	opts.newKeyIndex = newOrderedIndex[K]
+	return opts
+}
+
+// OrderedSieveCache is a SieveCache that also keeps its keys sorted, so that
+// ranges of keys can be retrieved efficiently, for example with
+// time-bucketed or lexicographically structured keys. Maintaining the index
+// makes insertions and removals take logarithmic time.
+type OrderedSieveCache[K cmp.Ordered, V any] struct {
+	*SieveCache[K, V]
+}
+
+// NewOrderedWithOptions creates a new OrderedSieveCache configured by opts.
+func NewOrderedWithOptions[K cmp.Ordered, V any](opts Options[K, V]) (*OrderedSieveCache[K, V], error) {
+	cache, err := NewWithOptions(withOrderedIndex(opts))
+	if err != nil {
+		return nil, err
+	}
+	return &OrderedSieveCache[K, V]{cache}, nil
+}
+
+// Range returns the entries whose keys are at least from and lower than to,
+// sorted by key. Entries are not marked as visited.
+func (c *OrderedSieveCache[K, V]) Range(from, to K) []Item[K, V] {
+	return rangeItems(c.SieveCache, from, to)
+}
+
+// KeysSorted returns all keys in increasing order.
+func (c *OrderedSieveCache[K, V]) KeysSorted() []K {
+	return sortedKeys(c.SieveCache)
+}
+
+// OrderedSyncSieveCache is a SyncSieveCache that also keeps its keys sorted.
+// See OrderedSieveCache.
+type OrderedSyncSieveCache[K cmp.Ordered, V any] struct {
+	*SyncSieveCache[K, V]
+}
+
+// NewOrderedSyncWithOptions creates a new OrderedSyncSieveCache configured by opts.
+func NewOrderedSyncWithOptions[K cmp.Ordered, V any](opts Options[K, V]) (*OrderedSyncSieveCache[K, V], error) {
+	cache, err := NewSyncWithOptions(withOrderedIndex(opts))
+	if err != nil {
+		return nil, err
+	}
+	return &OrderedSyncSieveCache[K, V]{cache}, nil
+}
+
+// Range returns the entries whose keys are at least from and lower than to,
+// sorted by key. Only a read lock is taken.
+func (c *OrderedSyncSieveCache[K, V]) Range(from, to K) []Item[K, V] {
+	c.rlock()
+	defer c.runlock()
+	return rangeItems(c.cache, from, to)
+}
+
+// KeysSorted returns all keys in increasing order.
+func (c *OrderedSyncSieveCache[K, V]) KeysSorted() []K {
+	c.rlock()
+	defer c.runlock()
+	return sortedKeys(c.cache)
+}
+
+// OrderedShardedSieveCache is a ShardedSieveCache whose shards also keep
+// their keys sorted. See OrderedSieveCache.
+type OrderedShardedSieveCache[K cmp.Ordered, V any] struct {
+	*ShardedSieveCache[K, V]
+}
+
+// NewOrderedShardedWithOptions creates a new OrderedShardedSieveCache
+// configured by opts, with numShards shards.
+func NewOrderedShardedWithOptions[K cmp.Ordered, V any](opts Options[K, V], numShards int) (*OrderedShardedSieveCache[K, V], error) {
+	cache, err := NewShardedWithOptions(withOrderedIndex(opts), numShards)
+	if err != nil {
+		return nil, err
+	}
+	return &OrderedShardedSieveCache[K, V]{cache}, nil
+}
+
+// Range returns the entries whose keys are at least from and lower than to,
+// sorted by key. The ranges of all shards are merged; shards are locked one
+// at a time, so this isn't a consistent snapshot of the whole cache.
+func (c *OrderedShardedSieveCache[K, V]) Range(from, to K) []Item[K, V] {
+	var items []Item[K, V]
+	for _, shard := range c.table.Load().all() {
+		shard.rlock()
+		items = append(items, rangeItems(shard.cache, from, to)...)
+		shard.runlock()
+	}
+	slices.SortFunc(items, func(a, b Item[K, V]) int {
+		return cmp.Compare(a.Key, b.Key)
+	})
+	// An entry migrated by Reshard meanwhile may have been seen twice
+	return slices.CompactFunc(items, func(a, b Item[K, V]) bool {
+		return a.Key == b.Key
+	})
+}
+
+// KeysSorted returns all keys in increasing order. See Range.
+func (c *OrderedShardedSieveCache[K, V]) KeysSorted() []K {
+	var keys []K
+	for _, shard := range c.table.Load().all() {
+		shard.rlock()
+		keys = append(keys, sortedKeys(shard.cache)...)
+		shard.runlock()
+	}
+	slices.Sort(keys)
+	return slices.Compact(keys)
+}
@@ -0,0 +1,87 @@
+package sievecache
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func TestOrderedRange(t *testing.T) {
+	cache, err := NewOrderedWithOptions(Options[int, int]{Capacity: 200})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 20000; i++ {
+		key := rng.Intn(1000)
+		switch rng.Intn(10) {
+		case 0:
+			cache.Remove(key)
+		case 1:
+			cache.Retain(func(k, _ int) bool { return k%7 != 0 })
+		default:
+			cache.Insert(key, -key)
+		}
+	}
+
+	keys := cache.Keys()
+	slices.Sort(keys)
+	if sorted := cache.KeysSorted(); !slices.Equal(sorted, keys) {
+		t.Fatalf("Expected sorted keys %v, got %v", keys, sorted)
+	}
+
+	from, to := 250, 600
+	var expected []int
+	for _, key := range keys {
+		if key >= from && key < to {
+			expected = append(expected, key)
+		}
+	}
+	items := cache.Range(from, to)
+	if len(items) != len(expected) {
+		t.Fatalf("Expected %d entries in range, got %d", len(expected), len(items))
+	}
+	for i, item := range items {
+		if item.Key != expected[i] || item.Value != -item.Key {
+			t.Fatalf("Unexpected entry %v at %d, expected key %d", item, i, expected[i])
+		}
+	}
+
+	// Clones have their own index
+	clone := cache.Clone()
+	cache.Clear()
+	if len(cache.KeysSorted()) != 0 || len(cache.Range(0, 1000)) != 0 {
+		t.Error("Expected an empty index after Clear")
+	}
+	if clone.Len() != len(keys) || len(rangeItems(clone, 0, 1000)) != len(keys) {
+		t.Error("Expected the clone to keep its index")
+	}
+}
+
+func TestOrderedSharded(t *testing.T) {
+	cache, err := NewOrderedShardedWithOptions(Options[string, int]{Capacity: 1000}, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"2024-03", "2024-01", "2023-12", "2024-02", "2024-04"} {
+		cache.Insert(key, len(key))
+	}
+	items := cache.Range("2024-01", "2024-04")
+	if len(items) != 3 || items[0].Key != "2024-01" || items[2].Key != "2024-03" {
+		t.Errorf("Unexpected range %v", items)
+	}
+	if err := cache.Reshard(3); err != nil {
+		t.Fatal(err)
+	}
+	if keys := cache.KeysSorted(); !slices.Equal(keys, []string{"2023-12", "2024-01", "2024-02", "2024-03", "2024-04"}) {
+		t.Errorf("Unexpected sorted keys %v", keys)
+	}
+
+	sync, _ := NewOrderedSyncWithOptions(Options[string, int]{Capacity: 2})
+	sync.Insert("b", 1)
+	sync.Insert("a", 2)
+	sync.Insert("c", 3)
+	if keys := sync.KeysSorted(); len(keys) != 2 || !slices.IsSorted(keys) {
+		t.Errorf("Expected 2 sorted keys, got %v", keys)
+	}
+}
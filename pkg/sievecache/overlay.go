@@ -0,0 +1,126 @@
+package sievecache
+
+// overlayEntry is a write buffered by an OverlayCache.
+type overlayEntry[V any] struct {
+	value   V
+	removed bool
+}
+
+// OverlayCache layers writes on top of a shared parent cache. Reads fall
+// through to the parent for keys that were not written locally, and writes
+// stay local until Commit applies them to the parent, or Discard drops them.
+// It is meant for request-scoped speculative work and tests.
+// An OverlayCache is not safe for concurrent use, even if its parent is.
+type OverlayCache[K comparable, V any] struct {
+	parent  Cache[K, V]
+	writes  map[K]overlayEntry[V]
+	cleared bool
+}
+
+// NewOverlayCache creates an empty overlay on top of parent.
+func NewOverlayCache[K comparable, V any](parent Cache[K, V]) *OverlayCache[K, V] {
+	return &OverlayCache[K, V]{parent: parent, writes: make(map[K]overlayEntry[V])}
+}
+
+// Parent returns the cache the overlay is layered on.
+func (c *OverlayCache[K, V]) Parent() Cache[K, V] {
+	return c.parent
+}
+
+// Get returns the value written locally, or the value of the parent if the
+// key was not written locally.
+func (c *OverlayCache[K, V]) Get(key K) (V, bool) {
+	if entry, ok := c.writes[key]; ok {
+		return entry.value, !entry.removed
+	}
+	if c.cleared {
+		var zero V
+		return zero, false
+	}
+	return c.parent.Get(key)
+}
+
+// ContainsKey checks if a key is present, without marking it as visited in
+// the parent.
+func (c *OverlayCache[K, V]) ContainsKey(key K) bool {
+	if entry, ok := c.writes[key]; ok {
+		return !entry.removed
+	}
+	return !c.cleared && c.parent.ContainsKey(key)
+}
+
+// Insert writes a key-value pair locally.
+// Returns true if the key was not present.
+func (c *OverlayCache[K, V]) Insert(key K, value V) bool {
+	inserted, _ := c.TryInsert(key, value)
+	return inserted
+}
+
+// TryInsert writes a key-value pair locally. Values are only checked by the
+// parent on Commit, so it never returns an error.
+func (c *OverlayCache[K, V]) TryInsert(key K, value V) (bool, error) {
+	inserted := !c.ContainsKey(key)
+	c.writes[key] = overlayEntry[V]{value: value}
+	return inserted, nil
+}
+
+// Remove removes a key locally, and returns its previous value.
+func (c *OverlayCache[K, V]) Remove(key K) (V, bool) {
+	value, found := c.Get(key)
+	var zero V
+	c.writes[key] = overlayEntry[V]{value: zero, removed: true}
+	return value, found
+}
+
+// Len returns the number of entries, as seen through the overlay.
+func (c *OverlayCache[K, V]) Len() int {
+	n := 0
+	if !c.cleared {
+		n = c.parent.Len()
+	}
+	for key, entry := range c.writes {
+		inParent := !c.cleared && c.parent.ContainsKey(key)
+		if entry.removed && inParent {
+			n--
+		} else if !entry.removed && !inParent {
+			n++
+		}
+	}
+	return n
+}
+
+// Capacity returns the capacity of the parent.
+func (c *OverlayCache[K, V]) Capacity() int {
+	return c.parent.Capacity()
+}
+
+// Clear removes all entries locally. The parent is only cleared on Commit.
+func (c *OverlayCache[K, V]) Clear() {
+	c.cleared = true
+	clear(c.writes)
+}
+
+// Commit applies the local writes to the parent, and empties the overlay.
+// Returns the first error returned by the parent, after applying the other
+// writes.
+func (c *OverlayCache[K, V]) Commit() error {
+	var firstErr error
+	if c.cleared {
+		c.parent.Clear()
+	}
+	for key, entry := range c.writes {
+		if entry.removed {
+			c.parent.Remove(key)
+		} else if _, err := c.parent.TryInsert(key, entry.value); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	c.Discard()
+	return firstErr
+}
+
+// Discard drops the local writes, so that reads fall through to the parent again.
+func (c *OverlayCache[K, V]) Discard() {
+	c.cleared = false
+	clear(c.writes)
+}
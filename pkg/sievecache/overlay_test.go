@@ -0,0 +1,48 @@
+package sievecache
+
+import "testing"
+
+func TestOverlayCache(t *testing.T) {
+	parent, _ := NewSync[string, int](10)
+	parent.Insert("a", 1)
+	parent.Insert("b", 2)
+
+	overlay := NewOverlayCache[string, int](parent)
+	if value, found := overlay.Get("a"); !found || value != 1 {
+		t.Errorf("Expected reads to fall through to the parent, got %v (found: %v)", value, found)
+	}
+
+	overlay.Insert("a", 10)
+	overlay.Insert("c", 3)
+	overlay.Remove("b")
+	if value, _ := overlay.Get("a"); value != 10 || overlay.ContainsKey("b") || overlay.Len() != 2 {
+		t.Errorf("Expected local writes to be visible, got a=%d, len=%d", value, overlay.Len())
+	}
+	if value, _ := parent.Get("a"); value != 1 || !parent.ContainsKey("b") || parent.ContainsKey("c") {
+		t.Error("Expected the parent to be untouched before Commit")
+	}
+
+	overlay.Discard()
+	if value, _ := overlay.Get("a"); value != 1 || overlay.Len() != 2 {
+		t.Errorf("Expected Discard to drop local writes, got a=%d, len=%d", value, overlay.Len())
+	}
+
+	overlay.Insert("a", 10)
+	overlay.Remove("b")
+	if err := overlay.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := parent.Get("a"); value != 10 || parent.ContainsKey("b") || parent.Len() != 1 {
+		t.Errorf("Expected Commit to apply local writes, got a=%d, len=%d", value, parent.Len())
+	}
+
+	overlay.Clear()
+	overlay.Insert("d", 4)
+	if overlay.ContainsKey("a") || overlay.Len() != 1 || parent.Len() != 1 {
+		t.Error("Expected Clear to hide the parent without clearing it")
+	}
+	overlay.Commit()
+	if parent.ContainsKey("a") || !parent.ContainsKey("d") {
+		t.Error("Expected Commit to clear the parent before applying writes")
+	}
+}
@@ -0,0 +1,185 @@
+package sievecache
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Snapshotter is implemented by caches that can write snapshots of themselves.
+type Snapshotter interface {
+	SaveToWriter(w io.Writer, opts SnapshotOptions) error
+}
+
+// Persister periodically checkpoints a cache to a file, so that long-running
+// services can warm-start after a crash or a deploy.
+// Snapshots are written to a temporary file which is then atomically renamed,
+// so the target file always contains a complete snapshot.
+type Persister struct {
+	source   Snapshotter
+	path     string
+	interval time.Duration
+	opts     SnapshotOptions
+
+	// Serializes snapshot writes
+	mutex   sync.Mutex
+	lastErr error
+	logger  *slog.Logger
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	// Closed once the final snapshot is written
+	closed   chan struct{}
+	closeErr error
+}
+
+// NewPersister creates a persister that writes a snapshot of source to path
+// every interval, and once more when closed.
+// If interval is less than or equal to zero, snapshots are only written on
+// Save and Close.
+// The source must be safe for concurrent use, such as a SyncSieveCache.
+func NewPersister(source Snapshotter, path string, interval time.Duration, opts SnapshotOptions) *Persister {
+	p := &Persister{
+		source:   source,
+		path:     path,
+		interval: interval,
+		opts:     opts,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		closed:   make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+// run writes snapshots periodically until the persister is closed.
+func (p *Persister) run() {
+	defer close(p.done)
+	if p.interval <= 0 {
+		<-p.stop
+		return
+	}
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := p.save(); err != nil {
+				p.logError(err)
+			}
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// Save immediately writes a snapshot to the target file.
+// It returns ErrClosed if the persister was closed.
+func (p *Persister) Save() error {
+	select {
+	case <-p.stop:
+		return ErrClosed
+	default:
+	}
+	return p.save()
+}
+
+// save writes a snapshot to the target file.
+func (p *Persister) save() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.lastErr = writeFileAtomic(p.path, func(w io.Writer) error {
+		return p.source.SaveToWriter(w, p.opts)
+	})
+	return p.lastErr
+}
+
+// SetLogger sets a logger reporting the failures of periodic snapshots,
+// which are otherwise only returned by LastError. A nil logger disables logging.
+func (p *Persister) SetLogger(logger *slog.Logger) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.logger = logger
+}
+
+// logError reports the failure of a periodic snapshot.
+func (p *Persister) logError(err error) {
+	p.mutex.Lock()
+	logger := p.logger
+	p.mutex.Unlock()
+	if logger != nil {
+		logger.Error("SieveCache: failed to write snapshot", "path", p.path, "error", err)
+	}
+}
+
+// LastError returns the error of the most recent snapshot attempt, or nil if it succeeded.
+func (p *Persister) LastError() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.lastErr
+}
+
+// Close stops periodic checkpointing and writes a final snapshot.
+// It returns the error of the final snapshot. Calling Close more than once
+// doesn't write another snapshot, and returns the same error.
+func (p *Persister) Close() error {
+	return p.Shutdown(context.Background())
+}
+
+// Shutdown stops periodic checkpointing and writes a final snapshot, like
+// Close, but stops waiting for it when ctx is done, and returns the error
+// of ctx.
+func (p *Persister) Shutdown(ctx context.Context) error {
+	p.closeOnce.Do(func() {
+		close(p.stop)
+		go func() {
+			<-p.done
+			p.closeErr = p.save()
+			close(p.closed)
+		}()
+	})
+	if err := waitDone(ctx, p.closed); err != nil {
+		return err
+	}
+	return p.closeErr
+}
+
+// writeFileAtomic writes a file by calling write on a temporary file in the
+// same directory, syncing it, and renaming it over path.
+func writeFileAtomic(path string, write func(w io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	// Remove the temporary file if anything goes wrong
+	success := false
+	defer func() {
+		if !success {
+			tmp.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	if err := write(tmp); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	success = true
+	return nil
+}
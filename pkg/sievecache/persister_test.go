@@ -0,0 +1,104 @@
+package sievecache
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPersisterPeriodic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.snap")
+	cache, _ := NewSync[string, int](10)
+	cache.Insert("a", 1)
+
+	p := NewPersister(cache, path, 5*time.Millisecond, SnapshotOptions{})
+	defer p.Close()
+
+	// Wait for a periodic checkpoint
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(path); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for periodic snapshot")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPersisterClose(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cache.snap")
+	cache, _ := NewSync[string, int](10)
+
+	p := NewPersister(cache, path, 0, SnapshotOptions{})
+	cache.Insert("a", 1)
+	cache.Insert("b", 2)
+
+	if err := p.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	// Closing twice doesn't write another snapshot
+	if err := p.Close(); err != nil {
+		t.Fatalf("Second Close failed: %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Snapshot file missing: %v", err)
+	}
+	defer f.Close()
+	restored, err := LoadFromReader[string, int](f, SnapshotOptions{})
+	if err != nil {
+		t.Fatalf("Failed to load snapshot: %v", err)
+	}
+	if restored.Len() != 2 {
+		t.Errorf("Expected 2 entries, got %d", restored.Len())
+	}
+
+	// No temporary files must be left behind
+	files, _ := os.ReadDir(dir)
+	if len(files) != 1 {
+		t.Errorf("Expected only the snapshot file, got %d files", len(files))
+	}
+}
+
+type failingSnapshotter struct{}
+
+func (failingSnapshotter) SaveToWriter(w io.Writer, opts SnapshotOptions) error {
+	w.Write([]byte("partial"))
+	return errors.New("boom")
+}
+
+func TestPersisterFailureKeepsPreviousFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cache.snap")
+	if err := os.WriteFile(path, []byte("previous"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewPersister(failingSnapshotter{}, path, 0, SnapshotOptions{})
+	err := p.Close()
+	if err == nil {
+		t.Error("Expected Close to report the snapshot error")
+	}
+	if again := p.Close(); again != err {
+		t.Errorf("Expected a second Close to return the same error, got %v", again)
+	}
+	if p.LastError() == nil {
+		t.Error("Expected LastError to report the snapshot error")
+	}
+
+	data, _ := os.ReadFile(path)
+	if string(data) != "previous" {
+		t.Errorf("Expected previous file to be untouched, got %q", data)
+	}
+	files, _ := os.ReadDir(dir)
+	if len(files) != 1 {
+		t.Errorf("Expected temporary file to be removed, got %d files", len(files))
+	}
+}
@@ -0,0 +1,328 @@
+package sievecache
+
+import (
+	"errors"
+
+	"github.com/jedisct1/go-sieve-cache/pkg/freqsketch"
+)
+
+// Policy selects how a cache decides which entries to keep.
+type Policy int
+
+const (
+	// PolicySIEVE is the SIEVE algorithm, the default.
+	PolicySIEVE Policy = iota
+
+	// PolicyWTinyLFU is Window TinyLFU: new entries are admitted into a
+	// small window holding 1% of the capacity, evicted in insertion order.
+	// An entry leaving the window only enters the main region, managed by
+	// SIEVE, if it was accessed more often than the entry SIEVE would evict
+	// to make room for it; otherwise it is evicted instead. Access
+	// frequencies are estimated by a compact sketch. This protects the
+	// working set from bursts of keys that are only accessed once, while
+	// the window still lets new keys prove themselves.
+	// It only applies to caches whose capacity is at least 2 and bounded.
+	PolicyWTinyLFU
+
+	// PolicySLRU splits the entries into a probation segment and a protected
+	// segment holding up to 80% of the capacity. New entries start in
+	// probation, and are evicted the first time the hand reaches them unless
+	// they were accessed since they were inserted. Entries accessed while in
+	// probation are promoted to the protected segment when the hand passes
+	// them. Protected entries are demoted back to probation instead of being
+	// evicted, so that reused entries survive a full pass of the hand without
+	// being accessed.
+	PolicySLRU
+
+	// PolicyCounters replaces the visited bit of each entry with a 2-bit
+	// saturating counter, approximating CLOCK-Pro. When the hand passes an
+	// entry that was accessed, its counter is incremented; otherwise it is
+	// decremented, and the entry is only evicted once it reaches 0. An entry
+	// accessed during several passes of the hand thus survives up to 3
+	// passes without being accessed, while entries accessed once are
+	// evicted as quickly as with SIEVE.
+	PolicyCounters
+)
+
+// Per-entry policy flags
+const (
+	// The entry is in the admission window of PolicyWTinyLFU
+	entryInWindow uint8 = 1 << 0
+	// The entry is in the protected segment of PolicySLRU
+	entryProtected uint8 = 1 << 1
+	// Access counter of PolicyCounters
+	entryCountShift       = 2
+	entryCountMask  uint8 = 3 << entryCountShift
+	entryCountUnit  uint8 = 1 << entryCountShift
+)
+
+// policyState holds the state of a policy other than PolicySIEVE.
+type policyState[K comparable] struct {
+	policy Policy
+	// Flags of each entry, in the same order as the nodes
+	flags []uint8
+	// Access frequencies, or nil if the policy doesn't use them
+	sketch *freqsketch.Sketch
+
+	// Keys that entered the window, oldest first, starting at windowHead.
+	// Keys that were removed or promoted since are skipped when they come out.
+	window      []K
+	windowHead  int
+	windowCount int
+
+	// Number of entries in the protected segment
+	protectedCount int
+}
+
+// newPolicyState creates the state of policy for a cache of the given capacity,
+// with storage preallocated for prealloc entries, or returns nil for PolicySIEVE.
+func newPolicyState[K comparable](policy Policy, capacity, prealloc int) (*policyState[K], error) {
+	switch policy {
+	case PolicySIEVE:
+		return nil, nil
+	case PolicyWTinyLFU:
+		return &policyState[K]{
+			policy: policy,
+			flags:  make([]uint8, 0, prealloc),
+			sketch: freqsketch.New(min(capacity, 1<<20)),
+		}, nil
+	case PolicySLRU, PolicyCounters:
+		return &policyState[K]{
+			policy: policy,
+			flags:  make([]uint8, 0, prealloc),
+		}, nil
+	}
+	return nil, errors.New("SieveCache: unknown policy")
+}
+
+// clone returns an independent copy of the state.
+func (p *policyState[K]) clone() *policyState[K] {
+	clone := *p
+	clone.flags = append([]uint8(nil), p.flags...)
+	clone.window = append([]K(nil), p.window[p.windowHead:]...)
+	clone.windowHead = 0
+	if p.sketch != nil {
+		clone.sketch = p.sketch.Clone()
+	}
+	return &clone
+}
+
+// reset forgets all the entries, but not the access frequencies.
+func (p *policyState[K]) reset(prealloc int) {
+	p.flags = make([]uint8, 0, prealloc)
+	p.window = nil
+	p.windowHead = 0
+	p.windowCount = 0
+	p.protectedCount = 0
+}
+
+// forget updates the counters for the removal of an entry with the given flags.
+func (p *policyState[K]) forget(flags uint8) {
+	if flags&entryInWindow != 0 {
+		p.windowCount--
+	}
+	if flags&entryProtected != 0 {
+		p.protectedCount--
+	}
+}
+
+// windowSize returns the number of entries of the admission window of a
+// cache with the given capacity, or 0 if the policy doesn't apply.
+func windowSize(capacity int) int {
+	if capacity < 2 || capacity == Unbounded {
+		return 0
+	}
+	return max(1, capacity/100)
+}
+
+// admissionWindow returns the size of the admission window of the cache, or
+// 0 if it doesn't have one.
+func (c *SieveCache[K, V]) admissionWindow() int {
+	if c.policy == nil || c.policy.policy != PolicyWTinyLFU {
+		return 0
+	}
+	return windowSize(c.capacity)
+}
+
+// recordAccess records an access to key in the frequency sketch, if the
+// policy uses one. It only requires shared access to the cache.
+func (c *SieveCache[K, V]) recordAccess(key K) {
+	if c.policy != nil && c.policy.sketch != nil {
+		c.policy.sketch.Add(hashKey(key))
+	}
+}
+
+// inWindow returns true if the entry at idx is in the admission window.
+func (c *SieveCache[K, V]) inWindow(idx int) bool {
+	return c.policy != nil && c.policy.flags[idx]&entryInWindow != 0
+}
+
+// admitToWindow puts the entry that was just appended into the admission
+// window, and moves the entries that overflow the window into the main
+// region, or evicts them if they are accessed less often than the main
+// region's eviction candidate.
+func (c *SieveCache[K, V]) admitToWindow(key K) {
+	p := c.policy
+	size := c.admissionWindow()
+	if size == 0 {
+		return
+	}
+	p.flags[len(p.flags)-1] |= entryInWindow
+	p.window = append(p.window, key)
+	p.windowCount++
+
+	for p.windowCount > size {
+		candidate, idx := c.popWindow()
+		if len(c.nodes) <= c.capacity {
+			c.leaveWindow(idx)
+			continue
+		}
+		victim := c.findVictim()
+		if !c.inWindow(victim) && p.sketch.Estimate(hashKey(candidate)) > p.sketch.Estimate(hashKey(c.nodes[victim].Key)) {
+			// Moving the candidate out of the window first keeps it out of
+			// it if it is moved to the victim's slot
+			c.leaveWindow(idx)
+			c.evictIndex(victim)
+		} else {
+			c.Remove(candidate)
+			c.evictions++
+		}
+	}
+	c.compactWindow(size)
+}
+
+// shrinkWindow moves the oldest entries of the window to the main region
+// until the window fits the capacity.
+func (c *SieveCache[K, V]) shrinkWindow() {
+	size := c.admissionWindow()
+	for c.policy.windowCount > size {
+		_, idx := c.popWindow()
+		c.leaveWindow(idx)
+	}
+	c.compactWindow(size)
+}
+
+// leaveWindow moves the entry at idx from the window to the main region.
+func (c *SieveCache[K, V]) leaveWindow(idx int) {
+	c.policy.flags[idx] &^= entryInWindow
+	c.policy.windowCount--
+}
+
+// popWindow removes the oldest entry from the window queue, and returns its
+// key and index. The entry is still flagged as being in the window.
+// The window must not be empty.
+func (c *SieveCache[K, V]) popWindow() (K, int) {
+	p := c.policy
+	var zero K
+	for {
+		key := p.window[p.windowHead]
+		p.window[p.windowHead] = zero
+		p.windowHead++
+		// A key that was removed and inserted again may be queued twice, in
+		// which case it leaves the window early
+		if idx, exists := c.indices.get(key, c.nodes); exists && p.flags[idx]&entryInWindow != 0 {
+			return key, idx
+		}
+	}
+}
+
+// compactWindow drops the keys of the window queue that are no longer in
+// the window, once they make up most of it.
+func (c *SieveCache[K, V]) compactWindow(size int) {
+	p := c.policy
+	if len(p.window)-p.windowHead <= 2*size+16 {
+		if p.windowHead > len(p.window)/2 {
+			p.window = append(p.window[:0], p.window[p.windowHead:]...)
+			p.windowHead = 0
+		}
+		return
+	}
+	queued := make(map[K]struct{}, p.windowCount)
+	window := make([]K, 0, p.windowCount)
+	for _, key := range p.window[p.windowHead:] {
+		idx, exists := c.indices.get(key, c.nodes)
+		if _, dup := queued[key]; dup || !exists || p.flags[idx]&entryInWindow == 0 {
+			continue
+		}
+		queued[key] = struct{}{}
+		window = append(window, key)
+	}
+	p.window = window
+	p.windowHead = 0
+}
+
+// scansEntries returns true if the policy updates the entries the hand
+// passes, so that they must be examined one at a time.
+func (p *policyState[K]) scansEntries() bool {
+	return p.policy == PolicySLRU || p.policy == PolicyCounters
+}
+
+// findScannedCandidate is the version of findEvictionCandidate for policies
+// that update the entries the hand passes.
+func (c *SieveCache[K, V]) findScannedCandidate(start int) int {
+	n := len(c.nodes)
+	// Entries may be passed several times before a victim is found: once to
+	// be demoted with PolicySLRU, and once per unit of their counter with
+	// PolicyCounters
+	passes := 2
+	if c.policy.policy == PolicyCounters {
+		passes = int(entryCountMask>>entryCountShift) + 1
+	}
+	budget := passes * n
+	if c.maxScan > 0 && c.maxScan < budget {
+		budget = c.maxScan
+	}
+
+	idx := start
+	for ; budget > 0; budget-- {
+		if c.passEntry(idx) {
+			return idx
+		}
+		idx--
+		if idx < 0 {
+			idx = n - 1
+		}
+	}
+
+	// The scan budget was exhausted: evict the next entry in scan order
+	return idx
+}
+
+// passEntry updates the entry at idx as the hand passes it, and returns true
+// if it should be evicted.
+func (c *SieveCache[K, V]) passEntry(idx int) bool {
+	p := c.policy
+	flags := p.flags[idx]
+	visited := c.visited.Get(idx)
+	if visited {
+		c.visited.Set(idx, false)
+	}
+
+	if p.policy == PolicyCounters {
+		switch {
+		case visited:
+			if flags&entryCountMask != entryCountMask {
+				p.flags[idx] += entryCountUnit
+			}
+		case flags&entryCountMask != 0:
+			p.flags[idx] -= entryCountUnit
+		default:
+			return true
+		}
+		return false
+	}
+
+	switch {
+	case visited:
+		if flags&entryProtected == 0 && p.protectedCount < c.capacity-c.capacity/5 {
+			p.flags[idx] |= entryProtected
+			p.protectedCount++
+		}
+	case flags&entryProtected != 0:
+		p.flags[idx] &^= entryProtected
+		p.protectedCount--
+	default:
+		return true
+	}
+	return false
+}
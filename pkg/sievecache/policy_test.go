@@ -0,0 +1,196 @@
+package sievecache
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestUnknownPolicy(t *testing.T) {
+	if _, err := NewWithOptions(Options[int, int]{Capacity: 10, Policy: Policy(42)}); err == nil {
+		t.Error("Expected an error for an unknown policy")
+	}
+}
+
+// scanResistance returns the hit ratio on a working set of keys that are
+// accessed repeatedly, interleaved with a scan of keys accessed only once.
+func scanResistance(t *testing.T, policy Policy) float64 {
+	cache, err := NewWithOptions(Options[int, int]{Capacity: 1000, Policy: policy})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rng := rand.New(rand.NewSource(1))
+	hits, lookups := 0, 0
+	scan := 1000000
+	for i := 0; i < 100000; i++ {
+		var key int
+		if i%2 == 0 {
+			key = rng.Intn(800)
+		} else {
+			key = scan
+			scan++
+		}
+		if _, ok := cache.Get(key); ok {
+			if key < 800 {
+				hits++
+			}
+		} else {
+			cache.Insert(key, key)
+		}
+		if key < 800 {
+			lookups++
+		}
+	}
+	if err := cache.CheckInvariants(); err != nil {
+		t.Fatal(err)
+	}
+	return float64(hits) / float64(lookups)
+}
+
+func TestWTinyLFUScanResistance(t *testing.T) {
+	sieve := scanResistance(t, PolicySIEVE)
+	tinyLFU := scanResistance(t, PolicyWTinyLFU)
+	if tinyLFU < sieve+0.2 {
+		t.Errorf("Expected W-TinyLFU to protect the working set: hit ratio %v, %v with SIEVE", tinyLFU, sieve)
+	}
+}
+
+func TestSLRUScanResistance(t *testing.T) {
+	sieve := scanResistance(t, PolicySIEVE)
+	slru := scanResistance(t, PolicySLRU)
+	if slru <= sieve {
+		t.Errorf("Expected SLRU to protect the working set: hit ratio %v, %v with SIEVE", slru, sieve)
+	}
+}
+
+func TestSLRUSegments(t *testing.T) {
+	cache, _ := NewWithOptions(Options[int, int]{Capacity: 10, Policy: PolicySLRU})
+	for i := 0; i < 10; i++ {
+		cache.Insert(i, i)
+	}
+	cache.Get(0)
+	cache.Get(1)
+
+	// The hand promotes the accessed entries, and evicts one-hit entries
+	for i := 10; i < 20; i++ {
+		cache.Insert(i, i)
+	}
+	if cache.policy.protectedCount != 2 {
+		t.Errorf("Expected 2 protected entries, got %d", cache.policy.protectedCount)
+	}
+
+	// Protected entries survive a pass of the hand without being accessed
+	for i := 20; i < 30; i++ {
+		cache.Insert(i, i)
+	}
+	if !cache.ContainsKey(0) || !cache.ContainsKey(1) {
+		t.Error("Expected the protected entries to survive a pass of the hand")
+	}
+	if err := cache.CheckInvariants(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCountersPolicy(t *testing.T) {
+	cache, _ := NewWithOptions(Options[int, int]{Capacity: 10, Policy: PolicyCounters})
+	cache.Insert(0, 0)
+
+	// Accesses seen by the hand increment the counter up to 3
+	for i := 0; i < 5; i++ {
+		cache.Get(0)
+		if cache.passEntry(0) {
+			t.Fatal("Expected an accessed entry to be kept")
+		}
+	}
+	if count := cache.policy.flags[0] >> entryCountShift; count != 3 {
+		t.Fatalf("Expected a saturated counter of 3, got %d", count)
+	}
+
+	// The entry then survives 3 passes without being accessed
+	for i := 0; i < 3; i++ {
+		if cache.passEntry(0) {
+			t.Fatalf("Expected the entry to survive pass %d", i)
+		}
+	}
+	if !cache.passEntry(0) {
+		t.Error("Expected the entry to be evicted once its counter reached 0")
+	}
+}
+
+func TestWTinyLFUAdmitsNewKeys(t *testing.T) {
+	cache, _ := NewWithOptions(Options[int, int]{Capacity: 200, Policy: PolicyWTinyLFU})
+	for i := 0; i < 200; i++ {
+		cache.Insert(i, i)
+	}
+	// A new key that is accessed repeatedly eventually enters the main region
+	for i := 0; i < 10; i++ {
+		cache.Insert(1000, 1000)
+		cache.Get(1000)
+		cache.Insert(2000+i, i)
+	}
+	if !cache.ContainsKey(1000) {
+		t.Error("Expected a frequently accessed key to be admitted")
+	}
+	if cache.Len() != 200 {
+		t.Errorf("Expected 200 entries, got %d", cache.Len())
+	}
+}
+
+func TestPolicyInvariants(t *testing.T) {
+	for _, policy := range []Policy{PolicyWTinyLFU, PolicySLRU, PolicyCounters} {
+		testPolicyInvariants(t, policy)
+	}
+}
+
+func testPolicyInvariants(t *testing.T, policy Policy) {
+	rng := rand.New(rand.NewSource(1))
+	cache, _ := NewWithOptions(Options[int, int]{Capacity: 300, Policy: policy})
+	for i := 0; i < 50000; i++ {
+		key := rng.Intn(1000)
+		switch op := rng.Intn(20); {
+		case op < 10:
+			cache.Get(key)
+		case op < 16:
+			cache.Insert(key, i)
+		case op < 18:
+			cache.Remove(key)
+		case op < 19:
+			cache.Evict()
+		default:
+			if err := cache.SetCapacity(100 + rng.Intn(400)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if i%100 == 0 {
+			if err := cache.CheckInvariants(); err != nil {
+				t.Fatalf("Policy %d, after %d operations: %v", policy, i, err)
+			}
+		}
+	}
+
+	clone := cache.Clone()
+	cache.Clear()
+	if err := cache.CheckInvariants(); err != nil {
+		t.Fatal(err)
+	}
+	if err := clone.CheckInvariants(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestShardedWTinyLFU(t *testing.T) {
+	cache, err := NewShardedWithOptions(Options[int, int]{Capacity: 1000, Policy: PolicyWTinyLFU}, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5000; i++ {
+		cache.Insert(i%300, i)
+		cache.Insert(10000+i, i)
+		cache.Get(i % 300)
+	}
+	if err := cache.CheckInvariants(); err != nil {
+		t.Fatal(err)
+	}
+	if cache.Len() > 1000 {
+		t.Errorf("Expected at most 1000 entries, got %d", cache.Len())
+	}
+}
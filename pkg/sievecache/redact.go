@@ -0,0 +1,35 @@
+package sievecache
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// KeyRedactor replaces the string representation of a key, as returned by
+// ToString, with a value that doesn't reveal it, in the output of features
+// that externalize keys, like debug dumps and the sievedebug handler, so
+// that sensitive keys such as emails or tokens never leave the process in
+// cleartext. A redactor should be deterministic, so that entries can be
+// followed across outputs.
+type KeyRedactor func(key string) string
+
+// RedactSHA256 is a KeyRedactor replacing keys with a prefix of their SHA-256
+// hash. Low-entropy keys, like emails or numeric identifiers, can still be
+// recovered by hashing candidates; RedactHMAC prevents that.
+func RedactSHA256(key string) string {
+	digest := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(digest[:8])
+}
+
+// RedactHMAC returns a KeyRedactor replacing keys with a prefix of their
+// HMAC-SHA256 under secret, which can't be recovered without the secret.
+// Keys are redacted identically by every process using the same secret.
+func RedactHMAC(secret []byte) KeyRedactor {
+	secret = append([]byte(nil), secret...)
+	return func(key string) string {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(key))
+		return hex.EncodeToString(mac.Sum(nil)[:8])
+	}
+}
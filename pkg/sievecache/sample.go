@@ -0,0 +1,88 @@
+package sievecache
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// samplePositions returns n distinct positions chosen uniformly at random
+// among 0 to total-1, in increasing order, using Floyd's algorithm so that
+// only n positions are ever stored.
+func samplePositions(n, total int) []int {
+	n = min(n, total)
+	if n <= 0 {
+		return nil
+	}
+	chosen := make(map[int]struct{}, n)
+	positions := make([]int, 0, n)
+	for j := total - n; j < total; j++ {
+		pos := rand.Intn(j + 1)
+		if _, dup := chosen[pos]; dup {
+			pos = j
+		}
+		chosen[pos] = struct{}{}
+		positions = append(positions, pos)
+	}
+	sort.Ints(positions)
+	return positions
+}
+
+// itemsAt returns the entries at the given positions, skipping positions
+// beyond the last entry.
+func (c *SieveCache[K, V]) itemsAt(positions []int) []Item[K, V] {
+	items := make([]Item[K, V], 0, len(positions))
+	for _, pos := range positions {
+		if pos < len(c.nodes) {
+			items = append(items, Item[K, V]{Key: c.nodes[pos].Key, Value: c.copyValue(c.nodes[pos].Value)})
+		}
+	}
+	return items
+}
+
+// Sample returns n entries chosen uniformly at random, or all the entries if
+// there are fewer than n, without iterating over the whole cache. Sampled
+// entries are not marked as visited.
+func (c *SieveCache[K, V]) Sample(n int) []Item[K, V] {
+	return c.itemsAt(samplePositions(n, len(c.nodes)))
+}
+
+// Sample returns n entries chosen uniformly at random, or all the entries if
+// there are fewer than n. Only a read lock is taken.
+func (c *SyncSieveCache[K, V]) Sample(n int) []Item[K, V] {
+	c.rlock()
+	defer c.runlock()
+	return c.cache.Sample(n)
+}
+
+// Sample returns n entries chosen uniformly at random across all shards, or
+// all the entries if there are fewer than n. Shards are locked one at a time,
+// so fewer entries may be returned if entries are concurrently removed.
+func (c *ShardedSieveCache[K, V]) Sample(n int) []Item[K, V] {
+	shards := c.table.Load().all()
+	lens := make([]int, len(shards))
+	total := 0
+	for i, shard := range shards {
+		lens[i] = shard.Len()
+		total += lens[i]
+	}
+
+	positions := samplePositions(n, total)
+	items := make([]Item[K, V], 0, len(positions))
+	base := 0
+	for i, shard := range shards {
+		// Positions are sorted, so those of each shard are contiguous
+		end := sort.SearchInts(positions, base+lens[i])
+		local := positions[:end]
+		for j := range local {
+			local[j] -= base
+		}
+		if len(local) > 0 {
+			shard.rlock()
+			items = append(items, shard.cache.itemsAt(local)...)
+			shard.runlock()
+		}
+		positions = positions[end:]
+		base += lens[i]
+	}
+	return items
+}
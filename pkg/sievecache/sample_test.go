@@ -0,0 +1,63 @@
+package sievecache
+
+import "testing"
+
+func TestSample(t *testing.T) {
+	cache, _ := New[int, int](100)
+	if items := cache.Sample(10); len(items) != 0 {
+		t.Errorf("Expected an empty sample, got %v", items)
+	}
+	for i := 0; i < 100; i++ {
+		cache.Insert(i, i*10)
+	}
+
+	// Samples are distinct entries, drawn uniformly
+	counts := make([]int, 100)
+	for round := 0; round < 1000; round++ {
+		items := cache.Sample(10)
+		if len(items) != 10 {
+			t.Fatalf("Expected 10 entries, got %d", len(items))
+		}
+		seen := make(map[int]bool)
+		for _, item := range items {
+			if seen[item.Key] || item.Value != item.Key*10 {
+				t.Fatalf("Unexpected sample %v", items)
+			}
+			seen[item.Key] = true
+			counts[item.Key]++
+		}
+	}
+	for key, count := range counts {
+		if count < 50 || count > 150 {
+			t.Errorf("Key %d was sampled %d times, expected about 100", key, count)
+		}
+	}
+
+	if items := cache.Sample(1000); len(items) != 100 {
+		t.Errorf("Expected all 100 entries, got %d", len(items))
+	}
+	if cache.VisitedRatio() != 0 {
+		t.Error("Expected sampling not to mark entries as visited")
+	}
+}
+
+func TestShardedSample(t *testing.T) {
+	cache, _ := NewSharded[int, int](1000)
+	for i := 0; i < 500; i++ {
+		cache.Insert(i, i)
+	}
+	items := cache.Sample(50)
+	if len(items) != 50 {
+		t.Fatalf("Expected 50 entries, got %d", len(items))
+	}
+	seen := make(map[int]bool)
+	for _, item := range items {
+		if seen[item.Key] || item.Key < 0 || item.Key >= 500 {
+			t.Fatalf("Unexpected sample %v", items)
+		}
+		seen[item.Key] = true
+	}
+	if items := cache.Sample(1000); len(items) != 500 {
+		t.Errorf("Expected all 500 entries, got %d", len(items))
+	}
+}
@@ -1,35 +1,130 @@
 package sievecache
 
 import (
+	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"hash/maphash"
+	"math/bits"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
 )
 
-// Default number of shards to use if not specified explicitly.
+// DefaultShards is a reasonable shard count for NewShardedWithShards.
+// NewSharded derives the number of shards from GOMAXPROCS instead.
 const DefaultShards = 16
 
+// MaxShards is the maximum number of shards of a ShardedSieveCache. Any
+// number of shards up to that is supported, not only powers of two.
+const MaxShards = 1 << 16
+
+// maxDefaultShards is the maximum number of shards chosen by NewSharded.
+const maxDefaultShards = 256
+
+// reshardBatchSize is the maximum number of entries Reshard moves out of a
+// shard while holding its lock.
+const reshardBatchSize = 256
+
 // ShardedSieveCache is a thread-safe implementation of SieveCache that uses multiple shards to reduce contention.
 type ShardedSieveCache[K comparable, V any] struct {
+	// Current shards, replaced by Reshard
+	table atomic.Pointer[shardTable[K, V]]
+	// Options used to create shards, with a per-shard capacity set separately
+	opts Options[K, V]
+	// Serializes Reshard with the other operations that change every shard
+	reshardMutex sync.Mutex
+	// Eviction counts of the shards at the last Rebalance, guarded by reshardMutex
+	rebalanceEvictions []uint64
+	// Set by SetEnabled, so that Reshard disables new shards too; written under reshardMutex
+	disabled atomic.Bool
+	// Hot key replicas, or nil if hot key replication is disabled
+	hot atomic.Pointer[hotKeys[K, V]]
+	// Incremented after every write once Local was called, so that local
+	// caches drop their entries
+	localEpoch   atomic.Uint64
+	localEnabled atomic.Bool
+}
+
+// shardTable is an immutable set of shards.
+type shardTable[K comparable, V any] struct {
 	// Array of shard mutexes, each containing a separate SieveCache instance
 	shards []*SyncSieveCache[K, V]
-	// Number of shards in the cache
-	numShards int
+	// Shards whose entries are being moved to shards by Reshard, or nil
+	prev []*SyncSieveCache[K, V]
+	// How keys are mapped to shards
+	mapping ShardMapping
+	// The table of the cache, set while prev isn't nil, to tell when
+	// Reshard replaced this table
+	current *atomic.Pointer[shardTable[K, V]]
 }
 
-// NewSharded creates a new sharded cache with the specified capacity, using the default number of shards.
+// NewSharded creates a new sharded cache with the specified capacity.
+// The number of shards is derived from GOMAXPROCS; see defaultShardCount.
 func NewSharded[K comparable, V any](capacity int) (*ShardedSieveCache[K, V], error) {
-	return NewShardedWithShards[K, V](capacity, DefaultShards)
+	return NewShardedWithShards[K, V](capacity, defaultShardCount(capacity))
+}
+
+// MustNewSharded is like NewSharded, but panics if capacity is invalid.
+// It simplifies the initialization of package-level variables.
+func MustNewSharded[K comparable, V any](capacity int) *ShardedSieveCache[K, V] {
+	cache, err := NewSharded[K, V](capacity)
+	if err != nil {
+		panic(err)
+	}
+	return cache
+}
+
+// defaultShardCount returns the power of two closest above 4 × GOMAXPROCS,
+// so that concurrent goroutines rarely contend on the same shard, capped to
+// maxDefaultShards. Fewer shards are used for small capacities, so that each
+// shard can hold at least one entry.
+func defaultShardCount(capacity int) int {
+	n := 1
+	for n < 4*runtime.GOMAXPROCS(0) && n < maxDefaultShards {
+		n <<= 1
+	}
+	for n > 1 && n > capacity {
+		n >>= 1
+	}
+	return n
 }
 
 // NewShardedWithShards creates a new sharded cache with the specified capacity and number of shards.
 func NewShardedWithShards[K comparable, V any](capacity int, numShards int) (*ShardedSieveCache[K, V], error) {
-	if capacity <= 0 {
-		return nil, errors.New("ShardedSieveCache: capacity must be greater than 0")
+	return NewShardedWithOptions(Options[K, V]{Capacity: capacity}, numShards)
+}
+
+// NewShardedWithOptions creates a new sharded cache configured by opts, with
+// the specified number of shards. The capacity is distributed across shards,
+// and the other options apply to each shard.
+// The number of shards may be any number between 1 and MaxShards.
+func NewShardedWithOptions[K comparable, V any](opts Options[K, V], numShards int) (*ShardedSieveCache[K, V], error) {
+	capacity := opts.Capacity
+	if capacity < 0 {
+		return nil, errors.New("ShardedSieveCache: capacity must not be negative")
 	}
-	if numShards <= 0 {
-		return nil, errors.New("ShardedSieveCache: number of shards must be greater than 0")
+	if err := checkShardCount(numShards); err != nil {
+		return nil, err
+	}
+	if opts.ShardMapping != ShardMappingRange && opts.ShardMapping != ShardMappingJump {
+		return nil, errors.New("ShardedSieveCache: unknown shard mapping")
+	}
+
+	shards, err := newShards(opts, numShards)
+	if err != nil {
+		return nil, err
 	}
+	c := &ShardedSieveCache[K, V]{opts: opts}
+	c.table.Store(&shardTable[K, V]{shards: shards, mapping: opts.ShardMapping})
+	return c, nil
+}
+
+// newShards creates numShards shards configured by opts, distributing the capacity across them.
+func newShards[K comparable, V any](opts Options[K, V], numShards int) ([]*SyncSieveCache[K, V], error) {
+	capacity := opts.Capacity
 
 	// Calculate per-shard capacity
 	baseCapacityPerShard := capacity / numShards
@@ -43,22 +138,46 @@ func NewShardedWithShards[K comparable, V any](capacity int, numShards int) (*Sh
 			shardCapacity++
 		}
 
-		// Ensure at least capacity 1 per shard
-		if shardCapacity < 1 {
+		// Ensure at least capacity 1 per shard, unless this is a null cache
+		if shardCapacity < 1 && capacity > 0 {
 			shardCapacity = 1
 		}
+		if capacity == Unbounded {
+			shardCapacity = Unbounded
+		}
 
-		cache, err := NewSync[K, V](shardCapacity)
+		shardOpts := opts
+		shardOpts.Capacity = shardCapacity
+		if opts.MaxCost > 0 {
+			shardOpts.MaxCost = shardMaxCost(opts.MaxCost, numShards, i)
+		}
+		if opts.InitialSize > 0 {
+			shardOpts.InitialSize = (opts.InitialSize + numShards - 1) / numShards
+		}
+		if opts.AdmissionRate > 0 {
+			shardOpts.AdmissionRate = opts.AdmissionRate / float64(numShards)
+			shardOpts.AdmissionBurst = (opts.AdmissionBurst + numShards - 1) / numShards
+		}
+		if opts.DoorkeeperSize > 0 {
+			shardOpts.DoorkeeperSize = (opts.DoorkeeperSize + numShards - 1) / numShards
+		}
+		cache, err := NewSyncWithOptions(shardOpts)
 		if err != nil {
 			return nil, err
 		}
 		shards[i] = cache
 	}
+	return shards, nil
+}
 
-	return &ShardedSieveCache[K, V]{
-		shards:    shards,
-		numShards: numShards,
-	}, nil
+// shardMaxCost returns the share of maxCost of the shard at index i,
+// distributing the remainder to the first shards. Each shard gets at least 1.
+func shardMaxCost(maxCost int64, numShards, i int) int64 {
+	shardCost := maxCost / int64(numShards)
+	if int64(i) < maxCost%int64(numShards) {
+		shardCost++
+	}
+	return max(1, shardCost)
 }
 
 // DefaultSharded creates a new sharded cache with a default capacity of 100 and default shard count.
@@ -90,44 +209,47 @@ func FromSync[K comparable, V any](syncCache *SyncSieveCache[K, V]) *ShardedSiev
 	return shardedCache
 }
 
+// hashSeed is the per-process seed of key hashes.
 var hashSeed = maphash.MakeSeed()
 
-// getShard returns the shard index for a given key.
-func (c *ShardedSieveCache[K, V]) getShardIndex(key K) int {
+// hashKey returns the hash used to pick the shard of key.
+// Hashes are only consistent within a process.
+func hashKey[K comparable](key K) uint64 {
+	// Use type switch to handle common key types efficiently.
+	// Switching on a pointer to the key avoids boxing it, which would allocate.
+	switch k := any(&key).(type) {
+	case *string:
+		return maphash.String(hashSeed, *k)
+	case *int:
+		return hashUint64(uint64(*k))
+	case *int64:
+		return hashUint64(uint64(*k))
+	case *int32:
+		return hashUint64(uint64(*k))
+	case *uint:
+		return hashUint64(uint64(*k))
+	case *uint64:
+		return hashUint64(*k)
+	case *uint32:
+		return hashUint64(uint64(*k))
+	}
+	return hashComparable(key)
+}
+
+// hashUint64 hashes an integer key.
+func hashUint64(k uint64) uint64 {
 	var h maphash.Hash
 	h.SetSeed(hashSeed)
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], k)
+	h.Write(buf[:])
+	return h.Sum64()
+}
 
-	// Use type switch to handle different key types efficiently
-	switch k := any(key).(type) {
-	case string:
-		h.WriteString(k)
-	case []byte:
-		h.Write(k)
-	case int:
-		var buf [8]byte
-		buf[0] = byte(k)
-		buf[1] = byte(k >> 8)
-		buf[2] = byte(k >> 16)
-		buf[3] = byte(k >> 24)
-		h.Write(buf[:4])
-	case int64:
-		var buf [8]byte
-		buf[0] = byte(k)
-		buf[1] = byte(k >> 8)
-		buf[2] = byte(k >> 16)
-		buf[3] = byte(k >> 24)
-		buf[4] = byte(k >> 32)
-		buf[5] = byte(k >> 40)
-		buf[6] = byte(k >> 48)
-		buf[7] = byte(k >> 56)
-		h.Write(buf[:])
-	default:
-		// For other types, convert to string
-		h.WriteString(ToString(k))
-	}
-
-	hashValue := h.Sum64()
-	return int(hashValue % uint64(c.numShards))
+// getShardIndex returns the shard index for a given key.
+func (c *ShardedSieveCache[K, V]) getShardIndex(key K) int {
+	t := c.table.Load()
+	return t.mapping.index(hashKey(key), len(t.shards))
 }
 
 // ToString converts a value to string for hashing.
@@ -144,25 +266,428 @@ func ToString(v any) string {
 	return fmt.Sprintf("%v", v)
 }
 
-// getShard returns the shard for a given key.
-func (c *ShardedSieveCache[K, V]) getShard(key K) *SyncSieveCache[K, V] {
-	index := c.getShardIndex(key)
-	return c.shards[index]
+// ShardMapping selects how a ShardedSieveCache maps keys to shards.
+type ShardMapping int
+
+const (
+	// ShardMappingRange spreads keys evenly across shards with a
+	// multiply-shift reduction of their hash, the default. It is the
+	// fastest mapping, but changing the number of shards moves nearly
+	// every key to a different shard.
+	ShardMappingRange ShardMapping = iota
+
+	// ShardMappingJump maps keys with jump consistent hashing. When the
+	// number of shards changes from n to m, only the keys of
+	// |m-n|/max(m,n) of the entries change shards: growing only moves keys
+	// to the new shards, and shrinking only moves the keys of the removed
+	// shards. Reshard then keeps the other shards and their entries as
+	// they are. Mapping a key takes about ln(n) steps.
+	ShardMappingJump
+)
+
+// index returns the shard of n shards for a key with the given hash.
+func (m ShardMapping) index(hash uint64, n int) int {
+	if m == ShardMappingJump {
+		return jumpHash(hash, n)
+	}
+	return shardIndex(hash, n)
+}
+
+// jumpHash is the jump consistent hash function of Lamping and Veach.
+func jumpHash(hash uint64, n int) int {
+	b, j := int64(-1), int64(0)
+	for j < int64(n) {
+		b = j
+		hash = hash*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((hash>>33)+1)))
+	}
+	return int(b)
+}
+
+// shardFor returns the shard for a key with the given hash.
+func (t *shardTable[K, V]) shardFor(hash uint64) *SyncSieveCache[K, V] {
+	return t.shards[t.mapping.index(hash, len(t.shards))]
+}
+
+// prevShardFor returns the shard being migrated for a key with the given
+// hash. t.prev must not be nil.
+func (t *shardTable[K, V]) prevShardFor(hash uint64) *SyncSieveCache[K, V] {
+	return t.prev[t.mapping.index(hash, len(t.prev))]
+}
+
+// shardIndex maps a key hash to one of n shards, with Lemire's multiply and
+// shift reduction instead of a modulo: the index is the high word of the
+// product of the hash and n. This is cheaper than a division for any n, and
+// only depends on the high bits of the hash, so that the keys of a shard
+// don't share the low bits that IndexOpenAddressing and IndexSwiss use to
+// place them.
+func shardIndex(hash uint64, n int) int {
+	hi, _ := bits.Mul64(hash, uint64(n))
+	return int(hi)
+}
+
+// checkShardCount validates a number of shards.
+func checkShardCount(numShards int) error {
+	if numShards <= 0 {
+		return errors.New("ShardedSieveCache: number of shards must be greater than 0")
+	}
+	if numShards > MaxShards {
+		return fmt.Errorf("ShardedSieveCache: number of shards must not exceed %d", MaxShards)
+	}
+	return nil
+}
+
+// all returns all the shards that may hold entries: the shards being
+// migrated first, then the current ones.
+func (t *shardTable[K, V]) all() []*SyncSieveCache[K, V] {
+	if t.prev == nil {
+		return t.shards
+	}
+	all := make([]*SyncSieveCache[K, V], 0, len(t.prev)+len(t.shards))
+	for i, shard := range t.prev {
+		// Shards kept by Reshard are in both
+		if i >= len(t.shards) || shard != t.shards[i] {
+			all = append(all, shard)
+		}
+	}
+	return append(all, t.shards...)
+}
+
+// withKeyShard calls f with the shard that owns key.
+// While entries are being migrated from previous shards, the entry for key is
+// moved to its new shard first, and the lock of its previous shard is held
+// while f runs, so that Reshard can't concurrently move a stale entry over.
+// Locks of previous shards are always acquired before locks of current shards,
+// and only while t is the table of the cache: otherwise, the newest table is
+// used instead, so that shards kept by several calls to Reshard are never
+// locked in different orders.
+func (t *shardTable[K, V]) withKeyShard(key K, f func(shard *SyncSieveCache[K, V])) {
+	hash := hashKey(key)
+	shard := t.shardFor(hash)
+	if t.prev == nil {
+		f(shard)
+		return
+	}
+	prev := t.prevShardFor(hash)
+	if prev == shard {
+		// The shard was kept, and the entry doesn't move
+		f(shard)
+		return
+	}
+	stale := false
+	prev.WithLock(func(prev *SieveCache[K, V]) {
+		if stale = t.current.Load() != t; stale {
+			return
+		}
+		moveEntry(prev, shard, key)
+		f(shard)
+	})
+	if stale {
+		t.current.Load().withKeyShard(key, f)
+	}
+}
+
+// withKeyShardCtx is like withKeyShard, but gives up waiting for the locks
+// of the shards when ctx is done, and returns a *LockTimeoutError. f is
+// called with the lock of the shard that owns key held.
+func (t *shardTable[K, V]) withKeyShardCtx(ctx context.Context, key K, f func(*SieveCache[K, V])) error {
+	hash := hashKey(key)
+	shard := t.shardFor(hash)
+	if t.prev == nil || t.prevShardFor(hash) == shard {
+		return shard.WithLockCtx(ctx, f)
+	}
+	prev := t.prevShardFor(hash)
+	if err := prev.lockContext(ctx, true); err != nil {
+		return err
+	}
+	if t.current.Load() != t {
+		prev.unlock()
+		return t.current.Load().withKeyShardCtx(ctx, key, f)
+	}
+	defer prev.unlock()
+	// The entry is only removed from its previous shard once the lock of
+	// its new shard is held, so that it isn't lost if ctx is done first
+	return shard.WithLockCtx(ctx, func(cache *SieveCache[K, V]) {
+		moveEntryLocked(prev.cache, cache, key)
+		f(cache)
+	})
+}
+
+// update runs op on the current shard table, and again on the new one if
+// Reshard replaced it in the meantime, so that a mutation applied to shards
+// being migrated isn't lost. op must be idempotent, and keys are the keys it
+// stores.
+func (c *ShardedSieveCache[K, V]) update(keys []K, op func(t *shardTable[K, V])) {
+	t := c.table.Load()
+	for replaced := false; ; replaced = true {
+		op(t)
+		next := c.table.Load()
+		if next == t && replaced {
+			t.dropMisplaced(keys)
+			next = c.table.Load()
+		}
+		if next == t {
+			return
+		}
+		t = next
+	}
+}
+
+// dropMisplaced removes the entries for keys from the shards of t that don't
+// own them. Such entries were stored through a table Reshard replaced, in a
+// shard it kept, after the shard was migrated. They are stale, as they are
+// stored again in t, and would otherwise be counted, or moved back over newer
+// values by a later Reshard.
+func (t *shardTable[K, V]) dropMisplaced(keys []K) {
+	if t.mapping != ShardMappingJump {
+		return
+	}
+	for _, shard := range t.all() {
+		shard.WithLock(func(cache *SieveCache[K, V]) {
+			for _, key := range keys {
+				hash := hashKey(key)
+				if shard != t.shardFor(hash) && (t.prev == nil || shard != t.prevShardFor(hash)) {
+					cache.Remove(key)
+				}
+			}
+		})
+	}
+}
+
+// moveEntry moves the entry for key, if any, from a shard being migrated to
+// its new shard, keeping its visited flag. If the new shard already has an
+// entry for key, it is more recent and is kept.
+func moveEntry[K comparable, V any](from *SieveCache[K, V], to *SyncSieveCache[K, V], key K) {
+	if _, exists := from.indices.get(key, from.nodes); !exists {
+		return
+	}
+	to.WithLock(func(c *SieveCache[K, V]) {
+		moveEntryLocked(from, c, key)
+	})
+}
+
+// moveEntryLocked is moveEntry, with the lock of the new shard held.
+func moveEntryLocked[K comparable, V any](from, to *SieveCache[K, V], key K) {
+	idx, exists := from.indices.get(key, from.nodes)
+	if !exists {
+		return
+	}
+	visited := from.visited.Get(idx)
+	value, _ := from.Remove(key)
+	to.mergeEntry(key, value, visited, keepExisting[K, V])
+}
+
+// keepExisting is a merge conflict function that keeps the existing value.
+func keepExisting[K comparable, V any](_ K, existing, _ V) V {
+	return existing
 }
 
 // Capacity returns the total capacity of the cache (sum of all shard capacities).
 func (c *ShardedSieveCache[K, V]) Capacity() int {
 	total := 0
-	for _, shard := range c.shards {
+	for _, shard := range c.table.Load().shards {
+		if shard.Capacity() == Unbounded {
+			return Unbounded
+		}
 		total += shard.Capacity()
 	}
 	return total
 }
 
+// Cost returns the total cost of the entries across all shards.
+func (c *ShardedSieveCache[K, V]) Cost() int64 {
+	var total int64
+	for _, shard := range c.table.Load().all() {
+		total += shard.Cost()
+	}
+	return total
+}
+
+// Rejections returns the number of entries that were not stored, across all
+// shards. See SieveCache.Rejections.
+func (c *ShardedSieveCache[K, V]) Rejections() uint64 {
+	var total uint64
+	for _, shard := range c.table.Load().all() {
+		total += shard.Rejections()
+	}
+	return total
+}
+
+// MaxCost returns the bound on the total cost of the entries across all
+// shards, or 0 if there is none.
+func (c *ShardedSieveCache[K, V]) MaxCost() int64 {
+	var total int64
+	for _, shard := range c.table.Load().shards {
+		total += shard.MaxCost()
+	}
+	return total
+}
+
+// SetMaxCost changes the bound on the total cost of the entries, distributing
+// it evenly across shards and evicting entries where needed.
+// See SieveCache.SetMaxCost for details.
+func (c *ShardedSieveCache[K, V]) SetMaxCost(maxCost int64) error {
+	defer c.invalidateAllHot()
+	if err := checkMaxCost(maxCost, c.opts.Sizer != nil); err != nil {
+		return err
+	}
+
+	c.reshardMutex.Lock()
+	defer c.reshardMutex.Unlock()
+	shards := c.table.Load().shards
+	for i, shard := range shards {
+		shardCost := int64(0)
+		if maxCost > 0 {
+			shardCost = shardMaxCost(maxCost, len(shards), i)
+		}
+		if err := shard.SetMaxCost(shardCost); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetCapacity changes the total capacity of the cache, distributing it evenly
+// across shards and evicting entries where needed.
+// Each shard keeps a capacity of at least 1, unless capacity is 0, which
+// removes all entries and turns the cache into a null cache.
+func (c *ShardedSieveCache[K, V]) SetCapacity(capacity int) error {
+	defer c.invalidateAllHot()
+	if capacity < 0 {
+		return errors.New("ShardedSieveCache: capacity must not be negative")
+	}
+
+	c.reshardMutex.Lock()
+	defer c.reshardMutex.Unlock()
+	shards := c.table.Load().shards
+	baseCapacityPerShard := capacity / len(shards)
+	remaining := capacity % len(shards)
+	for i, shard := range shards {
+		shardCapacity := baseCapacityPerShard
+		if i < remaining {
+			shardCapacity++
+		}
+		if capacity > 0 {
+			shardCapacity = max(1, shardCapacity)
+		}
+		if capacity == Unbounded {
+			shardCapacity = Unbounded
+		}
+		if err := shard.SetCapacity(shardCapacity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ShardCapacities returns the capacity of each shard.
+func (c *ShardedSieveCache[K, V]) ShardCapacities() []int {
+	shards := c.table.Load().shards
+	capacities := make([]int, len(shards))
+	for i, shard := range shards {
+		capacities[i] = shard.Capacity()
+	}
+	return capacities
+}
+
+// SetShardCapacities sets the capacity of each shard, evicting entries where
+// needed. This allows uneven capacities, for example when some shards are
+// known to receive more keys than others. There must be one capacity per
+// shard, and each must be greater than 0.
+func (c *ShardedSieveCache[K, V]) SetShardCapacities(capacities []int) error {
+	defer c.invalidateAllHot()
+	c.reshardMutex.Lock()
+	defer c.reshardMutex.Unlock()
+	return c.setShardCapacities(capacities)
+}
+
+// setShardCapacities is SetShardCapacities without locking.
+func (c *ShardedSieveCache[K, V]) setShardCapacities(capacities []int) error {
+	shards := c.table.Load().shards
+	if len(capacities) != len(shards) {
+		return fmt.Errorf("ShardedSieveCache: expected %d shard capacities, got %d", len(shards), len(capacities))
+	}
+	for _, capacity := range capacities {
+		if capacity <= 0 {
+			return errors.New("ShardedSieveCache: capacity must be greater than 0")
+		}
+	}
+	for i, shard := range shards {
+		if err := shard.SetCapacity(capacities[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Rebalance redistributes the total capacity across shards, so that shards
+// that keep evicting borrow the free slots of shards that are barely used,
+// and a skewed key distribution doesn't leave capacity unused.
+// Every shard keeps room for the entries it holds, and the remaining slots are
+// shared according to demand, estimated as the number of entries each shard
+// holds plus the number of entries it evicted since the previous call.
+// Rebalance is meant to be called periodically.
+func (c *ShardedSieveCache[K, V]) Rebalance() error {
+	defer c.invalidateAllHot()
+	c.reshardMutex.Lock()
+	defer c.reshardMutex.Unlock()
+
+	// Only the number of entries is rebalanced, which unbounded caches don't limit
+	if c.Capacity() == Unbounded {
+		return nil
+	}
+
+	shards := c.table.Load().shards
+	if len(c.rebalanceEvictions) != len(shards) {
+		// The shards have been replaced, and their counts start at 0
+		c.rebalanceEvictions = make([]uint64, len(shards))
+	}
+
+	spare := 0
+	totalDemand := 0
+	capacities := make([]int, len(shards))
+	demands := make([]int, len(shards))
+	for i, shard := range shards {
+		shard.WithLock(func(cache *SieveCache[K, V]) {
+			capacities[i] = max(1, len(cache.nodes))
+			spare += cache.capacity - capacities[i]
+			demands[i] = len(cache.nodes) + int(counterDelta(cache.evictions, c.rebalanceEvictions[i]))
+		})
+		totalDemand += demands[i]
+	}
+	if totalDemand == 0 {
+		return nil
+	}
+
+	// Split the spare slots proportionally, using cumulative sums so that
+	// the capacities add up to the total exactly
+	cumulative, assigned := 0, 0
+	for i, demand := range demands {
+		cumulative += demand
+		upTo := int(int64(spare) * int64(cumulative) / int64(totalDemand))
+		capacities[i] += upTo - assigned
+		assigned = upTo
+	}
+	if err := c.setShardCapacities(capacities); err != nil {
+		return err
+	}
+
+	// Evictions caused by shrinking shards are not demand
+	for i, shard := range shards {
+		shard.WithLock(func(cache *SieveCache[K, V]) {
+			c.rebalanceEvictions[i] = cache.evictions
+		})
+	}
+	return nil
+}
+
 // Len returns the total number of entries in the cache (sum of all shard lengths).
+// It doesn't lock, so polling it doesn't contend with other operations, but
+// the result is approximate while entries are being inserted or removed
+// concurrently.
 func (c *ShardedSieveCache[K, V]) Len() int {
 	total := 0
-	for _, shard := range c.shards {
+	for _, shard := range c.table.Load().all() {
 		total += shard.Len()
 	}
 	return total
@@ -170,7 +695,7 @@ func (c *ShardedSieveCache[K, V]) Len() int {
 
 // IsEmpty returns true when no values are currently cached in any shard.
 func (c *ShardedSieveCache[K, V]) IsEmpty() bool {
-	for _, shard := range c.shards {
+	for _, shard := range c.table.Load().all() {
 		if !shard.IsEmpty() {
 			return false
 		}
@@ -180,36 +705,334 @@ func (c *ShardedSieveCache[K, V]) IsEmpty() bool {
 
 // ContainsKey returns true if there is a value in the cache mapped to by key.
 func (c *ShardedSieveCache[K, V]) ContainsKey(key K) bool {
-	return c.getShard(key).ContainsKey(key)
+	t := c.table.Load()
+	hash := hashKey(key)
+	if t.shardFor(hash).ContainsKey(key) {
+		return true
+	}
+	return t.prev != nil && t.prevShardFor(hash).ContainsKey(key)
 }
 
 // Get returns the value in the cache mapped to by key.
 func (c *ShardedSieveCache[K, V]) Get(key K) (V, bool) {
-	return c.getShard(key).Get(key)
+	if h := c.hot.Load(); h != nil {
+		if value, found := h.get(key); found {
+			owner := c.table.Load().shardFor(hashKey(key)).cache
+			owner.recordLookup(true)
+			return owner.copyValue(value), true
+		}
+		h.sample(c, key)
+	}
+	return c.getFromShards(key)
+}
+
+// getFromShards is Get without looking up hot key replicas.
+func (c *ShardedSieveCache[K, V]) getFromShards(key K) (V, bool) {
+	t := c.table.Load()
+	shard := t.shardFor(hashKey(key))
+	if value, found := shard.Get(key); found || t.prev == nil {
+		return value, found
+	}
+
+	// The entry may not have been migrated yet
+	var value V
+	var found bool
+	t.withKeyShard(key, func(shard *SyncSieveCache[K, V]) {
+		value, found = shard.Get(key)
+	})
+	return value, found
+}
+
+// getManyParallelMin is the number of keys from which GetMany searches
+// shards concurrently. Smaller batches are searched by the calling goroutine,
+// as starting goroutines would take longer than the lookups.
+const getManyParallelMin = 64
+
+// GetMany looks up all the keys, and returns the values of those that are in
+// the cache. Keys are grouped by shard, each shard is locked once for all its
+// keys, and shards are searched concurrently, which cuts the latency of
+// looking up hundreds of keys at once. Hot key replicas are bypassed.
+func (c *ShardedSieveCache[K, V]) GetMany(keys []K) map[K]V {
+	result := make(map[K]V, len(keys))
+	t := c.table.Load()
+	if t.prev != nil {
+		// Entries may have to be found in previous shards
+		for _, key := range keys {
+			if value, found := c.getFromShards(key); found {
+				result[key] = value
+			}
+		}
+		return result
+	}
+
+	// Group the keys by shard with a counting sort, like insertBatch
+	shardIndices := make([]int, len(keys))
+	ends := make([]int, len(t.shards))
+	for j, key := range keys {
+		i := t.mapping.index(hashKey(key), len(t.shards))
+		shardIndices[j] = i
+		ends[i]++
+	}
+	groups := make([]int, 0, len(t.shards))
+	for i := range ends {
+		if ends[i] > 0 {
+			groups = append(groups, i)
+		}
+		if i > 0 {
+			ends[i] += ends[i-1]
+		}
+	}
+	sorted := make([]Item[K, V], len(keys))
+	offsets := make([]int, len(t.shards))
+	copy(offsets[1:], ends)
+	for j, key := range keys {
+		i := shardIndices[j]
+		sorted[offsets[i]].Key = key
+		offsets[i]++
+	}
+
+	// Each group is searched into its own range of sorted
+	found := make([]bool, len(keys))
+	search := func(g int) {
+		i := groups[g]
+		start := 0
+		if i > 0 {
+			start = ends[i-1]
+		}
+		shard := t.shards[i]
+		shard.rlock()
+		defer shard.runlock()
+		for j := start; j < ends[i]; j++ {
+			sorted[j].Value, found[j] = shard.cache.getShared(sorted[j].Key)
+		}
+	}
+	if len(keys) < getManyParallelMin || len(groups) == 1 {
+		for g := range groups {
+			search(g)
+		}
+	} else {
+		parallelFor(len(groups), 0, search)
+	}
+
+	for j, item := range sorted {
+		if found[j] {
+			result[item.Key] = item.Value
+		}
+	}
+	return result
 }
 
 // GetMut gets a mutable reference to the value in the cache mapped to by key via a callback function.
 func (c *ShardedSieveCache[K, V]) GetMut(key K, f func(*V)) bool {
-	return c.getShard(key).GetMut(key, f)
+	defer c.invalidateHot(key)
+	t := c.table.Load()
+	shard := t.shardFor(hashKey(key))
+	if shard.GetMut(key, f) {
+		return true
+	}
+	if t.prev == nil {
+		return false
+	}
+
+	// The entry may not have been migrated yet
+	var found bool
+	t.withKeyShard(key, func(shard *SyncSieveCache[K, V]) {
+		found = shard.GetMut(key, f)
+	})
+	return found
 }
 
 // Insert maps key to value in the cache, possibly evicting old entries from the appropriate shard.
 func (c *ShardedSieveCache[K, V]) Insert(key K, value V) bool {
-	return c.getShard(key).Insert(key, value)
+	defer c.invalidateHot(key)
+	inserted, first := false, true
+	c.update([]K{key}, func(t *shardTable[K, V]) {
+		t.withKeyShard(key, func(shard *SyncSieveCache[K, V]) {
+			if shard.Insert(key, value) && first {
+				inserted = true
+			}
+		})
+		first = false
+	})
+	return inserted
+}
+
+// GetVersioned is like Get, but also returns the version of the value.
+// Hot key replicas are bypassed. Versions are tracked by each shard, and
+// change when Reshard or Rebalance move an entry to another shard.
+// See SieveCache.GetVersioned.
+func (c *ShardedSieveCache[K, V]) GetVersioned(key K) (V, uint64, bool) {
+	var value V
+	var version uint64
+	var found bool
+	c.table.Load().withKeyShard(key, func(shard *SyncSieveCache[K, V]) {
+		value, version, found = shard.GetVersioned(key)
+	})
+	return value, version, found
+}
+
+// InsertIfVersion maps key to value only if the current version of its
+// value is version, as a single atomic operation.
+// See SieveCache.InsertIfVersion.
+func (c *ShardedSieveCache[K, V]) InsertIfVersion(key K, value V, version uint64) bool {
+	defer c.invalidateHot(key)
+	stored, first := false, true
+	c.update([]K{key}, func(t *shardTable[K, V]) {
+		t.withKeyShard(key, func(shard *SyncSieveCache[K, V]) {
+			if shard.InsertIfVersion(key, value, version) && first {
+				stored = true
+			}
+		})
+		first = false
+	})
+	return stored
+}
+
+// TryInsert is like Insert, but also returns the reason a value was rejected.
+// See SieveCache.TryInsert.
+func (c *ShardedSieveCache[K, V]) TryInsert(key K, value V) (bool, error) {
+	defer c.invalidateHot(key)
+	inserted, first := false, true
+	var err error
+	c.update([]K{key}, func(t *shardTable[K, V]) {
+		t.withKeyShard(key, func(shard *SyncSieveCache[K, V]) {
+			ok, e := shard.TryInsert(key, value)
+			if first {
+				inserted, err = ok, e
+			}
+		})
+		first = false
+	})
+	return inserted, err
+}
+
+// Swap maps key to value, and returns the previous value of key and whether
+// it was present, as a single atomic operation.
+func (c *ShardedSieveCache[K, V]) Swap(key K, value V) (V, bool) {
+	defer c.invalidateHot(key)
+	var previous V
+	loaded, first := false, true
+	c.update([]K{key}, func(t *shardTable[K, V]) {
+		t.withKeyShard(key, func(shard *SyncSieveCache[K, V]) {
+			if v, ok := shard.Swap(key, value); ok && first {
+				previous, loaded = v, true
+			}
+		})
+		first = false
+	})
+	return previous, loaded
+}
+
+// InsertBatch inserts all the entries of items, possibly evicting old entries.
+// Entries are grouped by shard, and each shard is locked once for all its
+// entries, which is much cheaper than calling Insert for every entry.
+// Returns the number of new entries.
+func (c *ShardedSieveCache[K, V]) InsertBatch(items map[K]V) int {
+	defer c.invalidateLocal()
+	if h := c.hot.Load(); h != nil {
+		defer func() {
+			for key := range items {
+				h.invalidate(key)
+			}
+		}()
+	}
+	// Only shards kept by Reshard can be left with stale entries
+	var keys []K
+	if c.opts.ShardMapping == ShardMappingJump {
+		keys = make([]K, 0, len(items))
+		for key := range items {
+			keys = append(keys, key)
+		}
+	}
+	inserted, first := 0, true
+	c.update(keys, func(t *shardTable[K, V]) {
+		n := t.insertBatch(items)
+		if first {
+			inserted, first = n, false
+		}
+	})
+	return inserted
+}
+
+// insertBatch inserts items into the shards of t and returns the number of new entries.
+func (t *shardTable[K, V]) insertBatch(items map[K]V) int {
+	inserted := 0
+	if t.prev != nil {
+		// Entries may have to be moved from previous shards
+		for key, value := range items {
+			t.withKeyShard(key, func(shard *SyncSieveCache[K, V]) {
+				if shard.Insert(key, value) {
+					inserted++
+				}
+			})
+		}
+		return inserted
+	}
+
+	// Group the entries by shard with a counting sort, so that all the
+	// partitions share a single allocation
+	unsorted := make([]Item[K, V], 0, len(items))
+	shardIndices := make([]int, 0, len(items))
+	ends := make([]int, len(t.shards))
+	for key, value := range items {
+		i := t.mapping.index(hashKey(key), len(t.shards))
+		unsorted = append(unsorted, Item[K, V]{Key: key, Value: value})
+		shardIndices = append(shardIndices, i)
+		ends[i]++
+	}
+	for i := 1; i < len(ends); i++ {
+		ends[i] += ends[i-1]
+	}
+	sorted := make([]Item[K, V], len(unsorted))
+	offsets := make([]int, len(t.shards))
+	copy(offsets[1:], ends)
+	for j, item := range unsorted {
+		i := shardIndices[j]
+		sorted[offsets[i]] = item
+		offsets[i]++
+	}
+
+	start := 0
+	for i, end := range ends {
+		if end == start {
+			continue
+		}
+		partition := sorted[start:end]
+		t.shards[i].WithLock(func(cache *SieveCache[K, V]) {
+			for _, item := range partition {
+				if cache.Insert(item.Key, item.Value) {
+					inserted++
+				}
+			}
+		})
+		start = end
+	}
+	return inserted
 }
 
 // Remove removes the cache entry mapped to by key.
 func (c *ShardedSieveCache[K, V]) Remove(key K) (V, bool) {
-	return c.getShard(key).Remove(key)
+	defer c.invalidateHot(key)
+	var value V
+	var found bool
+	c.update([]K{key}, func(t *shardTable[K, V]) {
+		t.withKeyShard(key, func(shard *SyncSieveCache[K, V]) {
+			if v, ok := shard.Remove(key); ok && !found {
+				value, found = v, true
+			}
+		})
+	})
+	return value, found
 }
 
 // Evict removes and returns a value from the cache that was not recently accessed.
 // It tries each shard in turn until it finds a value to evict.
 func (c *ShardedSieveCache[K, V]) Evict() (V, bool) {
+	defer c.invalidateAllHot()
 	var zero V
 
 	// Try each shard in turn
-	for _, shard := range c.shards {
+	for _, shard := range c.table.Load().all() {
 		value, found := shard.Evict()
 		if found {
 			return value, true
@@ -219,18 +1042,78 @@ func (c *ShardedSieveCache[K, V]) Evict() (V, bool) {
 	return zero, false
 }
 
+// EvictAhead evicts entries until at least headroom slots are free, so that
+// subsequent inserts don't have to evict. The headroom is distributed across
+// shards like the capacity. Returns the number of evicted entries.
+func (c *ShardedSieveCache[K, V]) EvictAhead(headroom int) int {
+	defer c.invalidateAllHot()
+	shards := c.table.Load().shards
+	baseHeadroom := headroom / len(shards)
+	remaining := headroom % len(shards)
+
+	evicted := 0
+	for i, shard := range shards {
+		shardHeadroom := baseHeadroom
+		if i < remaining {
+			shardHeadroom++
+		}
+		evicted += shard.EvictAhead(shardHeadroom)
+	}
+	return evicted
+}
+
+// SetEnabled enables or disables every shard of the cache.
+// See SieveCache.SetEnabled for details.
+func (c *ShardedSieveCache[K, V]) SetEnabled(enabled bool) {
+	defer c.invalidateAllHot()
+	c.reshardMutex.Lock()
+	defer c.reshardMutex.Unlock()
+	c.disabled.Store(!enabled)
+	for _, shard := range c.table.Load().all() {
+		shard.SetEnabled(enabled)
+	}
+}
+
+// Enabled returns false if the cache was disabled with SetEnabled.
+func (c *ShardedSieveCache[K, V]) Enabled() bool {
+	return !c.disabled.Load()
+}
+
 // Clear removes all entries from the cache.
 func (c *ShardedSieveCache[K, V]) Clear() {
-	for _, shard := range c.shards {
+	defer c.invalidateAllHot()
+	for _, shard := range c.table.Load().all() {
 		shard.Clear()
 	}
 }
 
+// ClearWithOptions removes all entries from the cache, shard by shard,
+// optionally calling a function for each removed entry and releasing memory.
+func (c *ShardedSieveCache[K, V]) ClearWithOptions(opts ClearOptions[K, V]) {
+	defer c.invalidateAllHot()
+	for _, shard := range c.table.Load().all() {
+		shard.ClearWithOptions(opts)
+	}
+}
+
+// Drain removes all entries from the cache and returns them.
+// Each shard is drained atomically, but entries inserted into an already
+// drained shard while Drain is running remain in the cache.
+func (c *ShardedSieveCache[K, V]) Drain() []Item[K, V] {
+	defer c.invalidateAllHot()
+	var allItems []Item[K, V]
+	for _, shard := range c.table.Load().all() {
+		allItems = append(allItems, shard.Drain()...)
+	}
+	return allItems
+}
+
 // Keys returns a slice of all keys in the cache.
 func (c *ShardedSieveCache[K, V]) Keys() []K {
 	// First count total keys to allocate proper size
+	shards := c.table.Load().all()
 	totalKeys := 0
-	for _, shard := range c.shards {
+	for _, shard := range shards {
 		totalKeys += shard.Len()
 	}
 
@@ -238,18 +1121,37 @@ func (c *ShardedSieveCache[K, V]) Keys() []K {
 	allKeys := make([]K, 0, totalKeys)
 
 	// Collect keys from all shards
-	for _, shard := range c.shards {
+	for _, shard := range shards {
 		allKeys = append(allKeys, shard.Keys()...)
 	}
 
 	return allKeys
 }
 
+// VisitedKeys returns up to limit keys of entries that were accessed since
+// the hand of their shard last passed them, collected shard by shard.
+// See SieveCache.VisitedKeys for details.
+func (c *ShardedSieveCache[K, V]) VisitedKeys(limit int) []K {
+	var keys []K
+	for _, shard := range c.table.Load().all() {
+		remaining := 0
+		if limit > 0 {
+			remaining = limit - len(keys)
+			if remaining <= 0 {
+				break
+			}
+		}
+		keys = append(keys, shard.VisitedKeys(remaining)...)
+	}
+	return keys
+}
+
 // Values returns a slice of all values in the cache.
 func (c *ShardedSieveCache[K, V]) Values() []V {
 	// First count total values to allocate proper size
+	shards := c.table.Load().all()
 	totalValues := 0
-	for _, shard := range c.shards {
+	for _, shard := range shards {
 		totalValues += shard.Len()
 	}
 
@@ -257,7 +1159,7 @@ func (c *ShardedSieveCache[K, V]) Values() []V {
 	allValues := make([]V, 0, totalValues)
 
 	// Collect values from all shards
-	for _, shard := range c.shards {
+	for _, shard := range shards {
 		allValues = append(allValues, shard.Values()...)
 	}
 
@@ -265,42 +1167,105 @@ func (c *ShardedSieveCache[K, V]) Values() []V {
 }
 
 // Items returns a slice of all key-value pairs in the cache.
-func (c *ShardedSieveCache[K, V]) Items() []struct {
-	Key   K
-	Value V
-} {
+func (c *ShardedSieveCache[K, V]) Items() []Item[K, V] {
 	// First count total items to allocate proper size
+	shards := c.table.Load().all()
 	totalItems := 0
-	for _, shard := range c.shards {
+	for _, shard := range shards {
 		totalItems += shard.Len()
 	}
 
 	// Pre-allocate slice with exact capacity
-	allItems := make([]struct {
-		Key   K
-		Value V
-	}, 0, totalItems)
+	allItems := make([]Item[K, V], 0, totalItems)
 
 	// Collect items from all shards
-	for _, shard := range c.shards {
+	for _, shard := range shards {
 		allItems = append(allItems, shard.Items()...)
 	}
 
 	return allItems
 }
 
+// ItemsChan streams all key-value pairs in the cache over the returned channel.
+// Shards are exported one after the other, in batches of batchSize, and no lock
+// is held between batches. See SyncSieveCache.ItemsChan for the consistency guarantees.
+// The channel is closed once all entries have been sent or ctx is done.
+func (c *ShardedSieveCache[K, V]) ItemsChan(ctx context.Context, batchSize int) <-chan Item[K, V] {
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	ch := make(chan Item[K, V], batchSize)
+	shards := c.table.Load().all()
+
+	go func() {
+		defer close(ch)
+		for _, shard := range shards {
+			if !shard.sendItems(ctx, ch, batchSize) {
+				return
+			}
+		}
+	}()
+
+	return ch
+}
+
+// Clone returns an independent copy of the cache, shard by shard.
+// It waits for a concurrent Reshard to complete.
+func (c *ShardedSieveCache[K, V]) Clone() *ShardedSieveCache[K, V] {
+	c.reshardMutex.Lock()
+	defer c.reshardMutex.Unlock()
+
+	current := c.table.Load().shards
+	shards := make([]*SyncSieveCache[K, V], len(current))
+	for i, shard := range current {
+		shards[i] = shard.Clone()
+	}
+	clone := &ShardedSieveCache[K, V]{opts: c.opts}
+	clone.table.Store(&shardTable[K, V]{shards: shards, mapping: c.opts.ShardMapping})
+	clone.disabled.Store(c.disabled.Load())
+	return clone
+}
+
+// Merge copies all entries of other into this cache, possibly evicting entries.
+// See SieveCache.Merge for details.
+// The two caches may have different numbers of shards.
+func (c *ShardedSieveCache[K, V]) Merge(other *ShardedSieveCache[K, V], conflict func(key K, existing, incoming V) V) {
+	if other == c {
+		return
+	}
+	defer c.invalidateAllHot()
+
+	// The conflict function may not be idempotent, so the shards must not
+	// change while merging
+	c.reshardMutex.Lock()
+	defer c.reshardMutex.Unlock()
+	table := c.table.Load()
+	for _, otherShard := range other.table.Load().all() {
+		snapshot := otherShard.Snapshot()
+		snapshot.forEachInEvictionOrder(func(idx int) {
+			node := snapshot.nodes[idx]
+			visited := snapshot.visited.Get(idx)
+			table.shardFor(hashKey(node.Key)).WithLock(func(shard *SieveCache[K, V]) {
+				shard.mergeEntry(node.Key, node.Value, visited, conflict)
+			})
+		})
+	}
+}
+
 // ForEachValue applies a function to all values in the cache across all shards.
 func (c *ShardedSieveCache[K, V]) ForEachValue(f func(*V)) {
+	defer c.invalidateAllHot()
 	// Process each shard sequentially
-	for _, shard := range c.shards {
+	for _, shard := range c.table.Load().all() {
 		shard.ForEachValue(f)
 	}
 }
 
 // ForEachEntry applies a function to all key-value pairs in the cache across all shards.
 func (c *ShardedSieveCache[K, V]) ForEachEntry(f func(K, *V)) {
+	defer c.invalidateAllHot()
 	// Process each shard sequentially
-	for _, shard := range c.shards {
+	for _, shard := range c.table.Load().all() {
 		shard.ForEachEntry(f)
 	}
 }
@@ -308,48 +1273,379 @@ func (c *ShardedSieveCache[K, V]) ForEachEntry(f func(K, *V)) {
 // WithKeyLock gets exclusive access to a specific shard based on the key.
 // This can be useful for performing multiple operations atomically on entries
 // that share the same shard.
+// While Reshard is running, only the entry for key is guaranteed to have been
+// moved to the shard; other entries may still be in their previous shards.
 func (c *ShardedSieveCache[K, V]) WithKeyLock(key K, f func(*SieveCache[K, V])) {
-	c.getShard(key).WithLock(f)
+	defer c.invalidateAllHot()
+	c.table.Load().withKeyShard(key, func(shard *SyncSieveCache[K, V]) {
+		shard.WithLock(f)
+	})
+}
+
+// WithKeysLocked gets exclusive access to the shards of all the keys at once,
+// so that operations involving several keys are atomic, for example moving a
+// value from one key to another. f receives the shard of each key, in the
+// same order as keys; keys sharing a shard get the same one.
+// Shards are locked in the order of their indices, and all of them are
+// released while waiting for a busy one after the first, so that concurrent
+// calls and Reshard can't deadlock. f must not access the cache through other
+// methods.
+// While Reshard is running, the entries for keys are moved to their new shards
+// first; other entries may still be in their previous shards.
+func (c *ShardedSieveCache[K, V]) WithKeysLocked(keys []K, f func(shards []*SieveCache[K, V])) {
+	defer c.invalidateAllHot()
+	t, indices, order := c.lockKeyShards(keys)
+	defer t.unlockShards(order)
+	shards := make([]*SieveCache[K, V], len(keys))
+	for j, i := range indices {
+		shards[j] = t.shards[i].cache
+	}
+	f(shards)
+}
+
+// lockKeyShards locks the shards of keys in the current table, in the order
+// of their indices. It returns the table, the index of the shard of each
+// key, and the sorted indices of the locked shards.
+func (c *ShardedSieveCache[K, V]) lockKeyShards(keys []K) (*shardTable[K, V], []int, []int) {
+	indices := make([]int, len(keys))
+	for {
+		t := c.table.Load()
+		if t.prev != nil {
+			for _, key := range keys {
+				t.withKeyShard(key, func(*SyncSieveCache[K, V]) {})
+			}
+		}
+		for j, key := range keys {
+			indices[j] = t.mapping.index(hashKey(key), len(t.shards))
+		}
+		order := slices.Clone(indices)
+		slices.Sort(order)
+		order = slices.Compact(order)
+		if !t.tryLockShards(order) {
+			continue
+		}
+
+		// Reshard may have replaced the table before the shards were locked
+		if c.table.Load() == t {
+			return t, indices, order
+		}
+		t.unlockShards(order)
+	}
+}
+
+// tryLockShards locks the shards at the given sorted indices, and returns
+// true once they are all locked. If a shard is busy, it releases the shards
+// it already locked and waits for that shard before returning false, as
+// Reshard may lock shards of an older table in a different order.
+func (t *shardTable[K, V]) tryLockShards(order []int) bool {
+	for k, i := range order {
+		shard := t.shards[i]
+		if k == 0 {
+			shard.lock()
+			continue
+		}
+		if !shard.tryLock() {
+			t.unlockShards(order[:k])
+			shard.lock()
+			shard.unlock()
+			return false
+		}
+	}
+	return true
+}
+
+// unlockShards unlocks the shards at the given indices, in reverse order.
+func (t *shardTable[K, V]) unlockShards(order []int) {
+	for k := len(order) - 1; k >= 0; k-- {
+		t.shards[order[k]].unlock()
+	}
 }
 
 // NumShards returns the number of shards in this cache.
 func (c *ShardedSieveCache[K, V]) NumShards() int {
-	return c.numShards
+	return len(c.table.Load().shards)
 }
 
 // GetShardByIndex gets a specific shard by index.
 // Returns nil if the index is out of bounds.
 func (c *ShardedSieveCache[K, V]) GetShardByIndex(index int) *SyncSieveCache[K, V] {
-	if index < 0 || index >= c.numShards {
+	return c.Shard(index)
+}
+
+// Shard returns the shard at index, or nil if the index is out of bounds,
+// for shard-local maintenance such as taking a snapshot of each shard
+// without stopping the others. The shard is a SyncSieveCache, whose methods
+// lock it, and it is replaced by Reshard, unless ShardMappingJump kept it.
+// Entries must only be inserted into the shard their key maps to.
+func (c *ShardedSieveCache[K, V]) Shard(index int) *SyncSieveCache[K, V] {
+	shards := c.table.Load().shards
+	if index < 0 || index >= len(shards) {
+		return nil
+	}
+	return shards[index]
+}
+
+// WithShardLock gets exclusive access to the shard at index, like
+// SyncSieveCache.WithLock, while the other shards keep serving requests.
+// Returns an error if the index is out of bounds.
+// While Reshard is running, entries that belong to the shard may still be in
+// their previous shards.
+func (c *ShardedSieveCache[K, V]) WithShardLock(index int, f func(*SieveCache[K, V])) error {
+	shard := c.Shard(index)
+	if shard == nil {
+		return errors.New("ShardedSieveCache: shard index out of range")
+	}
+	defer c.invalidateAllHot()
+	shard.WithLock(f)
+	return nil
+}
+
+// Reshard changes the number of shards, keeping the total capacity.
+// Entries are moved to their new shards incrementally, a batch at a time, so
+// the cache remains fully usable while Reshard runs: an entry that hasn't been
+// moved yet is moved when it is accessed. Entries are moved approximately from
+// the coldest to the hottest, so the hottest are the most likely to survive if
+// the new shards evict. Reshard returns once all entries have been moved.
+func (c *ShardedSieveCache[K, V]) Reshard(numShards int) error {
+	if err := checkShardCount(numShards); err != nil {
+		return err
+	}
+
+	c.reshardMutex.Lock()
+	defer c.reshardMutex.Unlock()
+	current := c.table.Load()
+	if numShards == len(current.shards) {
 		return nil
 	}
-	return c.shards[index]
+
+	opts := c.opts
+	opts.Capacity = c.Capacity()
+	opts.MaxCost = c.MaxCost()
+	shards, err := newShards(opts, numShards)
+	if err != nil {
+		return err
+	}
+	if c.disabled.Load() {
+		for _, shard := range shards {
+			shard.SetEnabled(false)
+		}
+	}
+
+	// With jump hashing, the shards kept are only left by the keys moving to
+	// new shards, so they keep their entries. Their sizes are only raised
+	// before the migration and lowered after it, so that no entry is
+	// evicted to make room for the entries moving in.
+	kept := 0
+	if current.mapping == ShardMappingJump {
+		kept = min(len(current.shards), numShards)
+	}
+	replaced := make([]*SyncSieveCache[K, V], kept)
+	for i := 0; i < kept; i++ {
+		replaced[i] = shards[i]
+		if err := resizeShard(current.shards[i], shards[i], true); err != nil {
+			return err
+		}
+		shards[i] = current.shards[i]
+	}
+
+	c.table.Store(&shardTable[K, V]{shards: shards, prev: current.shards, mapping: current.mapping, current: &c.table})
+	for i, prev := range current.shards {
+		migrateShard(prev, shards, current.mapping, i < kept)
+	}
+	c.table.Store(&shardTable[K, V]{shards: shards, mapping: current.mapping})
+	// Wait for the calls to withKeyShard that saw the migration table as
+	// current to complete, so that the next Reshard can't lock the shards
+	// they hold in the opposite order
+	for _, prev := range current.shards {
+		prev.WithLock(func(*SieveCache[K, V]) {})
+		forgetLockOrder(prev)
+	}
+	for i, target := range replaced {
+		if err := resizeShard(shards[i], target, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resizeShard sets the capacity and the maximum cost of shard to those of
+// target, if they are larger, or if grow is false, if they are smaller.
+func resizeShard[K comparable, V any](shard, target *SyncSieveCache[K, V], grow bool) error {
+	if capacity := target.Capacity(); (capacity > shard.Capacity()) == grow && capacity != shard.Capacity() {
+		if err := shard.SetCapacity(capacity); err != nil {
+			return err
+		}
+	}
+	if maxCost := target.MaxCost(); (maxCost > shard.MaxCost()) == grow && maxCost != shard.MaxCost() {
+		return shard.SetMaxCost(maxCost)
+	}
+	return nil
+}
+
+// migrateShard moves the entries of prev to their shard in shards, in
+// batches of reshardBatchSize so that the lock of prev is never held for
+// long. If prev was kept in shards, only the entries that belong to other
+// shards are moved; otherwise, prev is emptied.
+func migrateShard[K comparable, V any](prev *SyncSieveCache[K, V], shards []*SyncSieveCache[K, V], mapping ShardMapping, kept bool) {
+	if kept {
+		keys := prev.Keys()
+		for start := 0; start < len(keys); start += reshardBatchSize {
+			batch := keys[start:min(start+reshardBatchSize, len(keys))]
+			prev.WithLock(func(cache *SieveCache[K, V]) {
+				for _, key := range batch {
+					if to := shards[mapping.index(hashKey(key), len(shards))]; to != prev {
+						moveEntry(cache, to, key)
+					}
+				}
+			})
+		}
+		return
+	}
+
+	keys := make([]K, 0, reshardBatchSize)
+	for done := false; !done; {
+		prev.WithLock(func(cache *SieveCache[K, V]) {
+			// Collect the next entries the hand would reach
+			keys = keys[:0]
+			n := len(cache.nodes)
+			start := n - 1
+			if cache.handInitialized && cache.hand < n {
+				start = cache.hand
+			}
+			for i := 0; i < min(n, reshardBatchSize); i++ {
+				keys = append(keys, cache.nodes[(start-i+n)%n].Key)
+			}
+
+			for _, key := range keys {
+				moveEntry(cache, shards[mapping.index(hashKey(key), len(shards))], key)
+			}
+			done = len(cache.nodes) == 0
+		})
+	}
 }
 
 // Retain only keeps elements specified by the predicate.
 // Removes all entries for which f returns false.
 func (c *ShardedSieveCache[K, V]) Retain(f func(K, V) bool) {
+	defer c.invalidateAllHot()
 	// Process each shard sequentially
-	for _, shard := range c.shards {
+	for _, shard := range c.table.Load().all() {
 		shard.Retain(f)
 	}
 }
 
-// RecommendedCapacity analyzes the current cache utilization and recommends a new capacity.
-func (c *ShardedSieveCache[K, V]) RecommendedCapacity(minFactor, maxFactor, lowThreshold, highThreshold float64) int {
-	// For each shard, calculate the recommended capacity
-	totalRecommended := 0
+// ForEachValueParallel is like ForEachValue, but processes up to workers
+// shards concurrently. If workers is less than or equal to 0, GOMAXPROCS is
+// used. f must be safe for concurrent use. If f panics, the panic is
+// propagated to the caller once all workers have stopped.
+func (c *ShardedSieveCache[K, V]) ForEachValueParallel(workers int, f func(*V)) {
+	defer c.invalidateAllHot()
+	forEachShardParallel(c.table.Load().all(), workers, func(shard *SyncSieveCache[K, V]) {
+		shard.ForEachValue(f)
+	})
+}
+
+// ForEachEntryParallel is like ForEachEntry, but processes up to workers
+// shards concurrently. See ForEachValueParallel for details.
+func (c *ShardedSieveCache[K, V]) ForEachEntryParallel(workers int, f func(K, *V)) {
+	defer c.invalidateAllHot()
+	forEachShardParallel(c.table.Load().all(), workers, func(shard *SyncSieveCache[K, V]) {
+		shard.ForEachEntry(f)
+	})
+}
+
+// RetainParallel is like Retain, but processes up to workers shards
+// concurrently. See ForEachValueParallel for details.
+func (c *ShardedSieveCache[K, V]) RetainParallel(workers int, f func(K, V) bool) {
+	defer c.invalidateAllHot()
+	forEachShardParallel(c.table.Load().all(), workers, func(shard *SyncSieveCache[K, V]) {
+		shard.Retain(f)
+	})
+}
+
+// forEachShardParallel calls f for every shard, from up to workers goroutines.
+// After a panic, the remaining shards are skipped, and the first panic value
+// is re-raised in the calling goroutine once all workers have returned.
+func forEachShardParallel[K comparable, V any](shards []*SyncSieveCache[K, V], workers int, f func(shard *SyncSieveCache[K, V])) {
+	parallelFor(len(shards), workers, func(i int) {
+		f(shards[i])
+	})
+}
+
+// parallelFor calls f for every integer from 0 to n-1, from up to workers
+// goroutines, like forEachShardParallel.
+func parallelFor(n, workers int, f func(i int)) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	workers = min(workers, n)
+
+	var next atomic.Int64
+	var panicOnce sync.Once
+	var panicked atomic.Bool
+	var panicValue any
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					panicOnce.Do(func() { panicValue = r })
+					panicked.Store(true)
+				}
+			}()
+			for !panicked.Load() {
+				i := int(next.Add(1)) - 1
+				if i >= n {
+					return
+				}
+				f(i)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if panicked.Load() {
+		panic(panicValue)
+	}
+}
 
-	for _, shard := range c.shards {
-		shardRecommended := shard.RecommendedCapacity(minFactor, maxFactor, lowThreshold, highThreshold)
-		totalRecommended += shardRecommended
+// FillRatio returns the total number of entries divided by the total
+// capacity, or 0 for a null or Unbounded cache.
+func (c *ShardedSieveCache[K, V]) FillRatio() float64 {
+	capacity := c.Capacity()
+	if capacity == 0 || capacity == Unbounded {
+		return 0
 	}
+	return float64(c.Len()) / float64(capacity)
+}
 
-	// Ensure we return at least the original capacity for an empty cache
-	// and at least the number of shards otherwise
-	if c.IsEmpty() {
-		return c.Capacity()
+// VisitedRatio returns the fraction of the entries of all shards that were
+// accessed since the hand of their shard last passed them, or 0 if the cache
+// is empty.
+func (c *ShardedSieveCache[K, V]) VisitedRatio() float64 {
+	entries, visited := 0, 0
+	for _, shard := range c.table.Load().all() {
+		shard.rlock()
+		entries += len(shard.cache.nodes)
+		visited += shard.cache.visited.CountSetBits()
+		shard.runlock()
+	}
+	if entries == 0 {
+		return 0
 	}
+	return float64(visited) / float64(entries)
+}
 
-	return max(c.numShards, totalRecommended)
+// RecommendedCapacity analyzes the current cache utilization and recommends a
+// new total capacity, as the sum of the capacities recommended for each shard,
+// so that shards with different access patterns are sized independently.
+func (c *ShardedSieveCache[K, V]) RecommendedCapacity(minFactor, maxFactor, lowThreshold, highThreshold float64) int {
+	return c.AdviseCapacity(ThresholdAdvisor{
+		MinFactor:     minFactor,
+		MaxFactor:     maxFactor,
+		LowThreshold:  lowThreshold,
+		HighThreshold: highThreshold,
+	})
 }
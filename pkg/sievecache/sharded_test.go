@@ -1,8 +1,11 @@
 package sievecache
 
 import (
+	"context"
 	"fmt"
+	"runtime"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -117,6 +120,118 @@ func TestParallelAccess(t *testing.T) {
 	}
 }
 
+func TestWithShardLock(t *testing.T) {
+	cache, _ := NewShardedWithShards[int, int](1000, 4)
+	for i := 0; i < 100; i++ {
+		cache.Insert(i, i)
+	}
+
+	total := 0
+	for i := 0; i < cache.NumShards(); i++ {
+		if cache.Shard(i) != cache.GetShardByIndex(i) {
+			t.Errorf("Expected Shard(%d) to match GetShardByIndex", i)
+		}
+		err := cache.WithShardLock(i, func(shard *SieveCache[int, int]) {
+			for _, key := range shard.Keys() {
+				if cache.getShardIndex(key) != i {
+					t.Errorf("Key %d is in shard %d, expected %d", key, i, cache.getShardIndex(key))
+				}
+			}
+			total += shard.Len()
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if total != 100 {
+		t.Errorf("Expected 100 entries across shards, got %d", total)
+	}
+
+	if cache.Shard(-1) != nil || cache.Shard(4) != nil {
+		t.Error("Expected nil for out of range shards")
+	}
+	if err := cache.WithShardLock(4, func(*SieveCache[int, int]) { t.Error("Unexpected call") }); err == nil {
+		t.Error("Expected an error for an out of range shard")
+	}
+}
+
+func TestWithKeysLocked(t *testing.T) {
+	for _, mapping := range []ShardMapping{ShardMappingRange, ShardMappingJump} {
+		cache, _ := NewShardedWithOptions(Options[int, int]{Capacity: 1000, ShardMapping: mapping}, 8)
+		const accounts = 20
+		for i := 0; i < accounts; i++ {
+			cache.Insert(i, 100)
+		}
+		all := make([]int, accounts)
+		for i := range all {
+			all[i] = i
+		}
+
+		// Transfers between accounts keep the total unchanged, even while
+		// the cache is resharded
+		stop := make(chan struct{})
+		var reshardWg sync.WaitGroup
+		reshardWg.Add(1)
+		go func() {
+			defer reshardWg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if err := cache.Reshard([]int{3, 8, 13}[i%3]); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+		var wg sync.WaitGroup
+		for w := 0; w < 4; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				for i := 0; i < 500; i++ {
+					from, to := (w+i)%accounts, (w*7+i*3)%accounts
+					cache.WithKeysLocked([]int{from, to}, func(shards []*SieveCache[int, int]) {
+						a, _ := shards[0].Get(from)
+						shards[0].Insert(from, a-1)
+						b, _ := shards[1].Get(to)
+						shards[1].Insert(to, b+1)
+					})
+					if i%50 == 0 {
+						cache.WithKeysLocked(all, func(shards []*SieveCache[int, int]) {
+							total := 0
+							for j, shard := range shards {
+								v, _ := shard.Get(all[j])
+								total += v
+							}
+							if total != accounts*100 {
+								t.Errorf("Expected a total of %d, got %d", accounts*100, total)
+							}
+						})
+					}
+				}
+			}(w)
+		}
+		wg.Wait()
+		close(stop)
+		reshardWg.Wait()
+
+		total := 0
+		for i := 0; i < accounts; i++ {
+			v, _ := cache.Get(i)
+			total += v
+		}
+		if total != accounts*100 {
+			t.Errorf("Mapping %d: expected a total of %d, got %d", mapping, accounts*100, total)
+		}
+		if err := cache.CheckInvariants(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
 func TestWithKeyLock(t *testing.T) {
 	// Create a sharded cache with a single shard for this test
 	cache, _ := NewShardedWithShards[string, string](100, 1)
@@ -276,3 +391,635 @@ func TestGetMutConcurrent(t *testing.T) {
 		}
 	}
 }
+
+func TestShardedItemsChan(t *testing.T) {
+	cache, _ := NewShardedWithShards[string, int](100, 4)
+	for i := 0; i < 50; i++ {
+		cache.Insert(fmt.Sprintf("key%d", i), i)
+	}
+
+	count := 0
+	for range cache.ItemsChan(context.Background(), 8) {
+		count++
+	}
+	if count != 50 {
+		t.Errorf("Expected 50 items, got %d", count)
+	}
+}
+
+func TestShardedCloneAndMerge(t *testing.T) {
+	a, _ := NewShardedWithShards[string, int](100, 4)
+	for i := 0; i < 10; i++ {
+		a.Insert(fmt.Sprintf("key%d", i), i)
+	}
+
+	clone := a.Clone()
+	if clone.Len() != 10 || clone.NumShards() != 4 {
+		t.Errorf("Unexpected clone: len=%d shards=%d", clone.Len(), clone.NumShards())
+	}
+	clone.Insert("extra", 42)
+	if a.ContainsKey("extra") {
+		t.Error("Original was affected by changes to the clone")
+	}
+
+	// Merge caches with different shard counts
+	b, _ := NewShardedWithShards[string, int](100, 3)
+	b.Insert("key0", 100)
+	b.Insert("other", 7)
+	a.Merge(b, func(key string, existing, incoming int) int {
+		return existing + incoming
+	})
+	if val, _ := a.Get("key0"); val != 100 {
+		t.Errorf("Expected 100, got %d", val)
+	}
+	if val, ok := a.Get("other"); !ok || val != 7 {
+		t.Errorf("Expected 7, got %d", val)
+	}
+}
+
+func TestShardedDrain(t *testing.T) {
+	cache, _ := NewShardedWithShards[string, int](100, 4)
+	for i := 0; i < 20; i++ {
+		cache.Insert(fmt.Sprintf("key%d", i), i)
+	}
+
+	if items := cache.Drain(); len(items) != 20 {
+		t.Errorf("Expected 20 drained items, got %d", len(items))
+	}
+	if !cache.IsEmpty() {
+		t.Error("Expected empty cache after drain")
+	}
+}
+
+func TestDefaultShardCount(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(0))
+
+	for _, tc := range []struct{ procs, capacity, expected int }{
+		{1, 1000, 4},
+		{3, 1000, 16},
+		{8, 1000, 32},
+		{8, 20, 16},
+		{8, 1, 1},
+		{1000, 1 << 20, maxDefaultShards},
+	} {
+		runtime.GOMAXPROCS(tc.procs)
+		if n := defaultShardCount(tc.capacity); n != tc.expected {
+			t.Errorf("GOMAXPROCS=%d, capacity=%d: expected %d shards, got %d", tc.procs, tc.capacity, tc.expected, n)
+		}
+	}
+
+	runtime.GOMAXPROCS(2)
+	cache, _ := NewSharded[string, int](1000)
+	if cache.NumShards() != 8 {
+		t.Errorf("Expected 8 shards, got %d", cache.NumShards())
+	}
+}
+
+func TestReshard(t *testing.T) {
+	cache, _ := NewShardedWithOptions(Options[int, int]{Capacity: 1000, MaxEvictionScan: 8}, 4)
+	for i := 0; i < 500; i++ {
+		cache.Insert(i, i)
+	}
+
+	for _, numShards := range []int{16, 3, 1, 8} {
+		if err := cache.Reshard(numShards); err != nil {
+			t.Fatalf("Reshard(%d) failed: %v", numShards, err)
+		}
+		if cache.NumShards() != numShards {
+			t.Errorf("Expected %d shards, got %d", numShards, cache.NumShards())
+		}
+		if cache.Capacity() != 1000 {
+			t.Errorf("Expected capacity 1000, got %d", cache.Capacity())
+		}
+		if cache.Len() != 500 {
+			t.Errorf("Expected 500 entries, got %d", cache.Len())
+		}
+		for i := 0; i < 500; i++ {
+			if v, ok := cache.Get(i); !ok || v != i {
+				t.Fatalf("Expected %d for key %d after Reshard(%d), got %v (%v)", i, i, numShards, v, ok)
+			}
+		}
+		if cache.GetShardByIndex(0).cache.maxScan != 8 {
+			t.Error("Expected new shards to keep the options")
+		}
+	}
+
+	if err := cache.Reshard(0); err == nil {
+		t.Error("Expected error for zero shards")
+	}
+}
+
+func TestReshardJumpMapping(t *testing.T) {
+	if _, err := NewShardedWithOptions(Options[int, int]{Capacity: 100, ShardMapping: -1}, 4); err == nil {
+		t.Error("Expected an error for an unknown shard mapping")
+	}
+
+	cache, err := NewShardedWithOptions(Options[int, int]{Capacity: 10000, MaxCost: 100000, Sizer: func(int, int) int64 { return 10 }, ShardMapping: ShardMappingJump}, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const n = 4000
+	for i := 0; i < n; i++ {
+		cache.Insert(i, i)
+	}
+
+	for _, numShards := range []int{5, 16, 3} {
+		before := make([]int, n)
+		for i := range before {
+			before[i] = cache.getShardIndex(i)
+		}
+		first := cache.GetShardByIndex(0)
+		prevShards := cache.NumShards()
+		if err := cache.Reshard(numShards); err != nil {
+			t.Fatalf("Reshard(%d) failed: %v", numShards, err)
+		}
+		if cache.GetShardByIndex(0) != first {
+			t.Errorf("Reshard(%d) replaced a shard it should have kept", numShards)
+		}
+
+		// Only the share of the keys of the added or removed shards moves
+		moved := 0
+		for i := 0; i < n; i++ {
+			if cache.getShardIndex(i) != before[i] {
+				moved++
+			}
+		}
+		expected := n * (max(numShards, prevShards) - min(numShards, prevShards)) / max(numShards, prevShards)
+		if moved < expected*3/4 || moved > expected*5/4 {
+			t.Errorf("Reshard(%d): %d keys moved, expected about %d", numShards, moved, expected)
+		}
+
+		if cache.Len() != n {
+			t.Errorf("Expected %d entries, got %d", n, cache.Len())
+		}
+		for i := 0; i < n; i++ {
+			if v, ok := cache.Get(i); !ok || v != i {
+				t.Fatalf("Expected %d for key %d after Reshard(%d), got %v (%v)", i, i, numShards, v, ok)
+			}
+		}
+		if cache.Capacity() != 10000 || cache.MaxCost() != 100000 {
+			t.Errorf("Expected capacity 10000 and max cost 100000, got %d and %d", cache.Capacity(), cache.MaxCost())
+		}
+		if err := cache.CheckInvariants(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReshardJumpStaleTable(t *testing.T) {
+	cache, _ := NewShardedWithOptions(Options[int, int]{Capacity: 1000, ShardMapping: ShardMappingJump}, 2)
+	before := cache.table.Load()
+	if err := cache.Reshard(4); err != nil {
+		t.Fatal(err)
+	}
+	after := cache.table.Load()
+	migrating := &shardTable[int, int]{shards: after.shards, prev: before.shards, mapping: after.mapping, current: &cache.table}
+
+	key := 0
+	for before.shardFor(hashKey(key)) == after.shardFor(hashKey(key)) {
+		key++
+	}
+	kept := before.shardFor(hashKey(key))
+
+	// Replays a write by a caller that loaded the table before Reshard, and
+	// wrote to a kept shard after it was migrated, then to the migration
+	// table after Reshard replaced it
+	staleWrite := func(write func(shard *SyncSieveCache[int, int])) {
+		cache.table.Store(before)
+		cache.update([]int{key}, func(table *shardTable[int, int]) {
+			switch table {
+			case before:
+				write(kept)
+				cache.table.Store(migrating)
+			case migrating:
+				cache.table.Store(after)
+			}
+			table.withKeyShard(key, write)
+		})
+	}
+
+	staleWrite(func(shard *SyncSieveCache[int, int]) { shard.Insert(key, 1) })
+	staleWrite(func(shard *SyncSieveCache[int, int]) { shard.Insert(key, 2) })
+	if kept.ContainsKey(key) {
+		t.Error("A stale entry was left in a kept shard")
+	}
+	if v, ok := cache.Get(key); !ok || v != 2 || cache.Len() != 1 {
+		t.Errorf("Expected a single entry with 2, got %d (%v), %d entries", v, ok, cache.Len())
+	}
+
+	staleWrite(func(shard *SyncSieveCache[int, int]) { shard.Remove(key) })
+	if err := cache.Reshard(2); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := cache.Get(key); ok || cache.Len() != 0 {
+		t.Errorf("Expected the entry to stay removed, got %d, %d entries", v, cache.Len())
+	}
+}
+
+func TestReshardConcurrent(t *testing.T) {
+	for _, mapping := range []ShardMapping{ShardMappingRange, ShardMappingJump} {
+		cache, _ := NewShardedWithOptions(Options[int, int]{Capacity: 100000, ShardMapping: mapping}, 4)
+		testReshardConcurrent(t, cache)
+		if err := cache.CheckInvariants(); err != nil {
+			t.Errorf("Mapping %d: %v", mapping, err)
+		}
+	}
+}
+
+func testReshardConcurrent(t *testing.T, cache *ShardedSieveCache[int, int]) {
+
+	const workers = 4
+	const keysPerWorker = 500
+	stop := make(chan struct{})
+	var reshardWg sync.WaitGroup
+	reshardWg.Add(1)
+	go func() {
+		defer reshardWg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if err := cache.Reshard([]int{16, 3, 7, 4}[i%4]); err != nil {
+				t.Errorf("Reshard failed: %v", err)
+				return
+			}
+		}
+	}()
+
+	// Each worker owns a range of keys, so it knows what they must map to
+	expected := make([]map[int]int, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		expected[w] = make(map[int]int)
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			state := expected[w]
+			for i := 0; i < 20000; i++ {
+				key := w*keysPerWorker + i%keysPerWorker
+				switch i % 3 {
+				case 0, 1:
+					cache.Insert(key, i)
+					state[key] = i
+				case 2:
+					cache.Remove(key)
+					delete(state, key)
+				}
+
+				// A lookup may miss while an entry is being moved, but must
+				// never see a stale or removed value
+				if v, ok := cache.Get(key); ok {
+					if want, exists := state[key]; !exists || v != want {
+						t.Errorf("Key %d: expected %d (%v), got %d", key, want, exists, v)
+						return
+					}
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(stop)
+	reshardWg.Wait()
+
+	total := 0
+	for _, state := range expected {
+		total += len(state)
+		for key, want := range state {
+			if v, ok := cache.Get(key); !ok || v != want {
+				t.Errorf("Key %d: expected %d, got %d (%v)", key, want, v, ok)
+			}
+		}
+	}
+	if cache.Len() != total {
+		t.Errorf("Expected %d entries, got %d", total, cache.Len())
+	}
+}
+
+func TestRebalance(t *testing.T) {
+	cache, _ := NewShardedWithShards[int, int](400, 4)
+
+	// Only use keys of the first shard, which keeps evicting while the
+	// other shards stay nearly empty
+	hot := 0
+	for key := 0; hot < 1000; key++ {
+		if cache.getShardIndex(key) == 0 {
+			cache.Insert(key, key)
+			hot++
+		}
+	}
+	for key := 0; cache.Len() < 110; key++ {
+		if cache.getShardIndex(key) == 1 && !cache.ContainsKey(key) {
+			cache.Insert(key, key)
+		}
+	}
+
+	if err := cache.Rebalance(); err != nil {
+		t.Fatalf("Rebalance failed: %v", err)
+	}
+	capacities := cache.ShardCapacities()
+	if cache.Capacity() != 400 {
+		t.Errorf("Expected the total capacity to stay 400, got %v", capacities)
+	}
+	if capacities[0] < 300 || capacities[1] < 10 || capacities[2] != 1 || capacities[3] != 1 {
+		t.Errorf("Expected the capacity to move to the hot shard, got %v", capacities)
+	}
+	if n := cache.GetShardByIndex(1).Len(); n != 10 {
+		t.Errorf("Expected the second shard to keep its 10 entries, got %d", n)
+	}
+
+	// Rebalancing never evicts entries that fit in their shard
+	if err := cache.Rebalance(); err != nil {
+		t.Fatalf("Rebalance failed: %v", err)
+	}
+	if cache.Len() != 110 || cache.Capacity() != 400 {
+		t.Errorf("Expected 110 entries and capacity 400, got %d and %d", cache.Len(), cache.Capacity())
+	}
+
+	if err := cache.SetShardCapacities([]int{1, 2, 3}); err == nil {
+		t.Error("Expected error for a wrong number of capacities")
+	}
+	if err := cache.SetShardCapacities([]int{1, 2, 0, 4}); err == nil {
+		t.Error("Expected error for a zero capacity")
+	}
+	if err := cache.SetShardCapacities([]int{10, 20, 30, 40}); err != nil {
+		t.Fatalf("SetShardCapacities failed: %v", err)
+	}
+	if cache.Capacity() != 100 || cache.GetShardByIndex(0).Len() != 10 {
+		t.Errorf("Expected capacity 100 with 10 entries in the first shard, got %d and %d",
+			cache.Capacity(), cache.GetShardByIndex(0).Len())
+	}
+}
+
+func TestParallelIteration(t *testing.T) {
+	cache, _ := NewShardedWithShards[int, int](16000, 16)
+	for i := 0; i < 1000; i++ {
+		cache.Insert(i, i)
+	}
+
+	// At most 4 shards are processed at the same time
+	var running, maxRunning atomic.Int64
+	cache.ForEachEntryParallel(4, func(key int, value *int) {
+		n := running.Add(1)
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		*value = key * 2
+		running.Add(-1)
+	})
+	if m := maxRunning.Load(); m > 4 {
+		t.Errorf("Expected at most 4 concurrent calls, got %d", m)
+	}
+
+	var sum atomic.Int64
+	cache.ForEachValueParallel(0, func(value *int) {
+		sum.Add(int64(*value))
+		*value++
+	})
+	if sum.Load() != 999*1000 {
+		t.Errorf("Expected sum %d, got %d", 999*1000, sum.Load())
+	}
+
+	cache.RetainParallel(3, func(key, value int) bool {
+		return value == key*2+1 && key%2 == 0
+	})
+	if cache.Len() != 500 {
+		t.Errorf("Expected 500 entries, got %d", cache.Len())
+	}
+}
+
+func TestParallelIterationPanic(t *testing.T) {
+	cache, _ := NewShardedWithShards[int, int](16000, 16)
+	for i := 0; i < 1000; i++ {
+		cache.Insert(i, i)
+	}
+
+	defer func() {
+		if r := recover(); r != "boom" {
+			t.Errorf("Expected the panic to be propagated, got %v", r)
+		}
+		// The cache remains usable
+		cache.Insert(1000, 1000)
+		if _, ok := cache.Get(1000); !ok {
+			t.Error("Expected the cache to remain usable after a panic")
+		}
+	}()
+	cache.ForEachValueParallel(4, func(value *int) {
+		if *value == 500 {
+			panic("boom")
+		}
+	})
+	t.Error("Expected a panic")
+}
+
+func TestGetMany(t *testing.T) {
+	cache, _ := NewShardedWithShards[int, int](10000, 8)
+	for i := 0; i < 1000; i++ {
+		cache.Insert(i, i*10)
+	}
+
+	for _, n := range []int{0, 10, 500} {
+		keys := make([]int, 0, 2*n+1)
+		for i := 0; i < n; i++ {
+			// Every other key is missing
+			keys = append(keys, i, 5000+i)
+		}
+		if n > 0 {
+			keys = append(keys, 0)
+		}
+		result := cache.GetMany(keys)
+		if len(result) != n {
+			t.Errorf("Expected %d values, got %d", n, len(result))
+		}
+		for i := 0; i < n; i++ {
+			if v, ok := result[i]; !ok || v != i*10 {
+				t.Errorf("Expected %d for key %d, got %d (%v)", i*10, i, v, ok)
+			}
+		}
+	}
+
+	if err := cache.Reshard(3); err != nil {
+		t.Fatal(err)
+	}
+	if result := cache.GetMany([]int{1, 2, 3}); len(result) != 3 {
+		t.Errorf("Expected 3 values after Reshard, got %d", len(result))
+	}
+}
+
+func TestInsertBatch(t *testing.T) {
+	cache, _ := NewShardedWithShards[int, int](16000, 16)
+	cache.Insert(0, -1)
+
+	items := make(map[int]int)
+	for i := 0; i < 1000; i++ {
+		items[i] = i
+	}
+	if n := cache.InsertBatch(items); n != 999 {
+		t.Errorf("Expected 999 new entries, got %d", n)
+	}
+	if cache.Len() != 1000 {
+		t.Errorf("Expected 1000 entries, got %d", cache.Len())
+	}
+	for i := 0; i < 1000; i++ {
+		if v, ok := cache.Get(i); !ok || v != i {
+			t.Fatalf("Expected %d for key %d, got %v (%v)", i, i, v, ok)
+		}
+	}
+	if n := cache.InsertBatch(nil); n != 0 {
+		t.Errorf("Expected no new entries for an empty batch, got %d", n)
+	}
+
+	// Batches inserted while resharding are not lost
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cache.Reshard(5)
+	}()
+	more := make(map[int]int)
+	for i := 1000; i < 2000; i++ {
+		more[i] = i
+	}
+	cache.InsertBatch(more)
+	<-done
+	for i := 0; i < 2000; i++ {
+		if v, ok := cache.Get(i); !ok || v != i {
+			t.Fatalf("Expected %d for key %d, got %v (%v)", i, i, v, ok)
+		}
+	}
+}
+
+func TestShardedMaxCost(t *testing.T) {
+	cache, err := NewShardedWithOptions(Options[int, []byte]{
+		Capacity: Unbounded,
+		Sizer:    func(_ int, value []byte) int64 { return int64(len(value)) },
+		MaxCost:  4000,
+	}, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cache.Capacity() != Unbounded || cache.MaxCost() != 4000 {
+		t.Errorf("Unexpected bounds: capacity %d, maximum cost %d", cache.Capacity(), cache.MaxCost())
+	}
+
+	for i := 0; i < 1000; i++ {
+		cache.Insert(i, make([]byte, 10))
+	}
+	if cost := cache.Cost(); cost > 4000 || cost < 3000 {
+		t.Errorf("Expected a cost close to 4000, got %d", cost)
+	}
+
+	if err := cache.Reshard(8); err != nil {
+		t.Fatal(err)
+	}
+	if cache.MaxCost() != 4000 || cache.Capacity() != Unbounded {
+		t.Error("Expected Reshard to keep the bounds")
+	}
+	if err := cache.SetMaxCost(800); err != nil {
+		t.Fatal(err)
+	}
+	if cache.Cost() > 800 {
+		t.Errorf("Expected a cost below 800, got %d", cache.Cost())
+	}
+	if err := cache.CheckInvariants(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestShardedUtilization(t *testing.T) {
+	cache, _ := NewShardedWithShards[int, int](1000, 4)
+	for i := 0; i < 500; i++ {
+		cache.Insert(i, i)
+	}
+	for i := 0; i < 100; i++ {
+		cache.Get(i)
+	}
+	if cache.FillRatio() != 0.5 {
+		t.Errorf("Expected a fill ratio of 0.5, got %v", cache.FillRatio())
+	}
+	if cache.VisitedRatio() != 0.2 {
+		t.Errorf("Expected a visited ratio of 0.2, got %v", cache.VisitedRatio())
+	}
+
+	unbounded, _ := NewShardedWithShards[int, int](Unbounded, 4)
+	unbounded.Insert(1, 1)
+	if recommended := unbounded.RecommendedCapacity(0.5, 2.0, 0.3, 0.7); recommended != Unbounded {
+		t.Errorf("Expected an unbounded recommendation, got %d", recommended)
+	}
+}
+
+func TestShardedSwap(t *testing.T) {
+	cache, _ := NewSharded[string, string](100)
+	if _, loaded := cache.Swap("key", "v1"); loaded {
+		t.Error("Expected no previous value")
+	}
+	old, loaded := cache.Swap("key", "v2")
+	if !loaded || old != "v1" {
+		t.Errorf("Expected previous value v1, got %q, %v", old, loaded)
+	}
+	if value, _ := cache.Get("key"); value != "v2" {
+		t.Errorf("Expected v2, got %q", value)
+	}
+}
+
+func TestShardedInsertIfVersion(t *testing.T) {
+	cache, _ := NewSharded[string, string](100)
+	cache.Insert("key", "v1")
+	_, version, _ := cache.GetVersioned("key")
+	if !cache.InsertIfVersion("key", "v2", version) {
+		t.Fatal("Expected the current version to match")
+	}
+	if cache.InsertIfVersion("key", "v3", version) {
+		t.Error("Expected a stale version not to match")
+	}
+	if value, _ := cache.Get("key"); value != "v2" {
+		t.Errorf("Expected v2, got %q", value)
+	}
+}
+
+func TestShardIndexDistribution(t *testing.T) {
+	for _, n := range []int{1, 3, 7, 12, 16, 100} {
+		counts := make([]int, n)
+		keys := 1000 * n
+		for i := 0; i < keys; i++ {
+			idx := shardIndex(hashKey(i), n)
+			if idx < 0 || idx >= n {
+				t.Fatalf("Shard index %d out of range for %d shards", idx, n)
+			}
+			counts[idx]++
+		}
+		for i, count := range counts {
+			if count < 800 || count > 1200 {
+				t.Errorf("%d shards: shard %d has %d keys instead of about 1000", n, i, count)
+			}
+		}
+	}
+}
+
+func TestShardCountValidation(t *testing.T) {
+	if _, err := NewShardedWithShards[int, int](100, MaxShards+1); err == nil {
+		t.Error("Expected an error for too many shards")
+	}
+	cache, err := NewShardedWithShards[int, int](1000, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1000; i++ {
+		cache.Insert(i, i)
+	}
+	if err := cache.Reshard(0); err == nil {
+		t.Error("Expected an error when resharding to 0 shards")
+	}
+	if err := cache.Reshard(MaxShards + 1); err == nil {
+		t.Error("Expected an error when resharding to too many shards")
+	}
+	if err := cache.Reshard(12); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.CheckInvariants(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,49 @@
+package sievecache
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrClosed is returned by Persister.Save once the persister was shut down.
+var ErrClosed = errors.New("SieveCache: closed")
+
+// Shutdowner is implemented by the tasks that run in the background on
+// behalf of a cache: Persister, BackgroundEvictor and MemoryGovernor.
+// Shutdown stops the task, finishes its pending work, like writing a final
+// snapshot, and waits for it to complete or for ctx to be done, in which
+// case it returns the error of ctx while the work goes on in the background.
+// Calling Shutdown more than once waits for the same work, and returns the
+// same result once it completed.
+type Shutdowner interface {
+	Shutdown(ctx context.Context) error
+}
+
+var (
+	_ Shutdowner = (*Persister)(nil)
+	_ Shutdowner = (*BackgroundEvictor)(nil)
+	_ Shutdowner = (*MemoryGovernor)(nil)
+)
+
+// Shutdown shuts down tasks one after the other, in order, so that for
+// example a BackgroundEvictor can be stopped before a Persister writes its
+// final snapshot. It returns the errors of all tasks, joined.
+func Shutdown(ctx context.Context, tasks ...Shutdowner) error {
+	var errs []error
+	for _, task := range tasks {
+		if err := task.Shutdown(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// waitDone waits for done to be closed, or for ctx to be done.
+func waitDone(ctx context.Context, done <-chan struct{}) error {
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,64 @@
+package sievecache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// blockingSnapshotter is a Snapshotter whose snapshots wait for release.
+type blockingSnapshotter struct {
+	release chan struct{}
+}
+
+func (s blockingSnapshotter) SaveToWriter(w io.Writer, _ SnapshotOptions) error {
+	<-s.release
+	return nil
+}
+
+func TestShutdown(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.snap")
+	cache, _ := NewSync[string, int](10)
+	p := NewPersister(cache, path, time.Hour, SnapshotOptions{})
+	e := NewBackgroundEvictor(cache, BackgroundEvictorOptions{})
+	g := NewMemoryGovernor(cache, MemoryGovernorOptions{})
+	cache.Insert("a", 1)
+
+	if err := Shutdown(context.Background(), e, g, p); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("Expected a final snapshot: %v", err)
+	}
+	if err := p.Save(); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed, got %v", err)
+	}
+	// Shutting down again returns the same result
+	if err := Shutdown(context.Background(), e, g, p); err != nil {
+		t.Errorf("Second Shutdown failed: %v", err)
+	}
+	p.Close()
+	e.Close()
+	g.Close()
+}
+
+func TestShutdownContextDone(t *testing.T) {
+	source := blockingSnapshotter{release: make(chan struct{})}
+	p := NewPersister(source, filepath.Join(t.TempDir(), "cache.snap"), 0, SnapshotOptions{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := p.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected context.DeadlineExceeded, got %v", err)
+	}
+
+	// The final snapshot is still written in the background
+	close(source.release)
+	if err := p.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+}
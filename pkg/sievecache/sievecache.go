@@ -2,40 +2,191 @@ package sievecache
 
 import (
 	"errors"
-	"math"
+	"math/bits"
+	"slices"
+	"time"
+
+	"github.com/jedisct1/go-sieve-cache/pkg/bitset"
 )
 
 // SieveCache provides an efficient in-memory cache with the SIEVE eviction algorithm.
 // This is the single-threaded implementation.
 type SieveCache[K comparable, V any] struct {
-	// Map of keys to indices in the nodes slice (pointer, 8 bytes)
-	indices map[K]int
+	// Index of keys to slots in the nodes slice (two pointers, 16 bytes)
+	indices slotIndex[K, V]
 	// Slice of all cache nodes (pointer + len + cap, 24 bytes)
 	nodes []Node[K, V]
 	// Bit array for visited flags using 1 bit per entry (pointer, 8 bytes)
-	visited *BitSet
+	visited *bitset.BitSet
 	// Grouping integer fields together for better memory alignment (each 8 bytes)
-	capacity int
-	hand     int
+	capacity  int
+	hand      int
+	maxScan   int
+	evictions uint64
+	// Last version assigned to a value
+	version uint64
+	// Cost of each node when a sizer is set, their sum and its bounds
+	sizer       func(key K, value V) int64
+	costs       []int64
+	cost        int64
+	maxCost     int64
+	maxItemCost int64
+	rejections  uint64
+	// Lookup statistics, or nil if they are not recorded
+	stats *statsRecorder
+	// Operation latencies, or nil if they are not recorded
+	latencies *latencyRecorders
+	// Recent operations, or nil if they are not recorded
+	ops *opRecorder
+	// State of the policy, or nil for PolicySIEVE
+	policy *policyState[K]
+	// Index of the keys, or nil
+	keyIndex keyIndex[K]
+	// Hook validating or transforming inserted values, or nil
+	beforeInsert func(key K, value V) (V, error)
+	// Function copying stored and returned values, or nil
+	cloner func(value V) V
+	// Fraction of the slots in use below which removals shrink the storage, or 0
+	shrinkThreshold float64
+	// Number of entries to preallocate storage for, or 0 for the capacity
+	initialSize int
+	// Limit of the rate of new entries displacing existing ones, or nil
+	admission *tokenBucket
+	// Filter of the keys of new entries seen once, or nil
+	doorkeeper *doorkeeper
 	// Place smaller fields last to minimize padding (bool is 1 byte)
 	handInitialized bool
+	disabled        bool
 }
 
 // New creates a new cache with the given capacity.
-// Returns an error if capacity is less than or equal to zero.
+// A capacity of 0 creates a null cache: every lookup misses and inserts are dropped.
+// Returns an error if capacity is negative.
 func New[K comparable, V any](capacity int) (*SieveCache[K, V], error) {
-	if capacity <= 0 {
-		return nil, errors.New("SieveCache: capacity must be greater than 0")
+	return NewWithOptions(Options[K, V]{Capacity: capacity})
+}
+
+// MustNew is like New, but panics if capacity is invalid.
+// It simplifies the initialization of package-level variables.
+func MustNew[K comparable, V any](capacity int) *SieveCache[K, V] {
+	cache, err := New[K, V](capacity)
+	if err != nil {
+		panic(err)
+	}
+	return cache
+}
+
+// NewWithOptions creates a new cache configured by opts.
+// Returns an error if the capacity, the maximum eviction scan, a maximum
+// cost, the initial size, the admission rate, the doorkeeper size or the
+// number of recorded operations is negative, if a maximum
+// cost is set without a sizer, or if the shrink threshold isn't between 0
+// and 1.
+func NewWithOptions[K comparable, V any](opts Options[K, V]) (*SieveCache[K, V], error) {
+	capacity := opts.Capacity
+	if capacity < 0 {
+		return nil, errors.New("SieveCache: capacity must not be negative")
+	}
+	if opts.MaxEvictionScan < 0 {
+		return nil, errors.New("SieveCache: maximum eviction scan must not be negative")
+	}
+	if err := checkMaxCost(opts.MaxCost, opts.Sizer != nil); err != nil {
+		return nil, err
+	}
+	if opts.MaxItemCost < 0 {
+		return nil, errors.New("SieveCache: maximum item cost must not be negative")
+	}
+	if opts.MaxItemCost > 0 && opts.Sizer == nil {
+		return nil, errors.New("SieveCache: a maximum item cost requires a sizer")
+	}
+	if opts.InitialSize < 0 {
+		return nil, errors.New("SieveCache: initial size must not be negative")
+	}
+	if opts.ShrinkThreshold < 0 || opts.ShrinkThreshold >= 1 {
+		return nil, errors.New("SieveCache: shrink threshold must be between 0 and 1")
+	}
+	if opts.AdmissionRate < 0 || opts.AdmissionBurst < 0 {
+		return nil, errors.New("SieveCache: admission rate must not be negative")
+	}
+	if opts.DoorkeeperSize < 0 {
+		return nil, errors.New("SieveCache: doorkeeper size must not be negative")
+	}
+	if opts.RecordOps < 0 {
+		return nil, errors.New("SieveCache: number of recorded operations must not be negative")
+	}
+
+	prealloc := initialCapacity(capacity, opts.InitialSize)
+	policy, err := newPolicyState[K](opts.Policy, capacity, prealloc)
+	if err != nil {
+		return nil, err
 	}
 
-	return &SieveCache[K, V]{
-		indices:         make(map[K]int, capacity),
-		nodes:           make([]Node[K, V], 0, capacity),
-		visited:         NewBitSet(capacity),
+	indices, err := newSlotIndex[K, V](opts.Index, prealloc)
+	if err != nil {
+		return nil, err
+	}
+	c := &SieveCache[K, V]{
+		indices:         indices,
+		nodes:           make([]Node[K, V], 0, prealloc),
+		visited:         bitset.WithCapacity(prealloc),
 		hand:            0,
 		handInitialized: false,
 		capacity:        capacity,
-	}, nil
+		maxScan:         opts.MaxEvictionScan,
+		sizer:           opts.Sizer,
+		maxCost:         opts.MaxCost,
+		maxItemCost:     opts.MaxItemCost,
+		policy:          policy,
+		beforeInsert:    opts.BeforeInsert,
+		cloner:          opts.Cloner,
+		shrinkThreshold: opts.ShrinkThreshold,
+		initialSize:     opts.InitialSize,
+	}
+	if opts.newKeyIndex != nil {
+		c.keyIndex = opts.newKeyIndex()
+	}
+	if c.sizer != nil {
+		c.costs = make([]int64, 0, prealloc)
+	}
+	if opts.RecordStats {
+		c.stats = newStatsRecorder(opts.Clock)
+	}
+	if opts.RecordLatencies {
+		c.latencies = &latencyRecorders{}
+	}
+	if opts.RecordOps > 0 {
+		c.ops = newOpRecorder(opts.RecordOps)
+	}
+	if opts.AdmissionRate > 0 {
+		c.admission = newTokenBucket(opts.AdmissionRate, opts.AdmissionBurst, opts.Clock)
+	}
+	if opts.DoorkeeperSize > 0 {
+		c.doorkeeper = newDoorkeeper(opts.DoorkeeperSize)
+	}
+	return c, nil
+}
+
+// checkMaxCost validates a maximum cost.
+func checkMaxCost(maxCost int64, hasSizer bool) error {
+	if maxCost < 0 {
+		return errors.New("SieveCache: maximum cost must not be negative")
+	}
+	if maxCost > 0 && !hasSizer {
+		return errors.New("SieveCache: a maximum cost requires a sizer")
+	}
+	return nil
+}
+
+// initialCapacity returns the number of entries to preallocate storage for,
+// given the InitialSize option.
+func initialCapacity(capacity, initialSize int) int {
+	if initialSize > 0 {
+		return min(capacity, initialSize)
+	}
+	if capacity == Unbounded {
+		return 0
+	}
+	return capacity
 }
 
 // Capacity returns the maximum number of entries the cache can hold.
@@ -55,7 +206,7 @@ func (c *SieveCache[K, V]) IsEmpty() bool {
 
 // ContainsKey returns true if there is a value in the cache mapped to by key.
 func (c *SieveCache[K, V]) ContainsKey(key K) bool {
-	_, exists := c.indices[key]
+	_, exists := c.indices.get(key, c.nodes)
 	return exists
 }
 
@@ -63,16 +214,46 @@ func (c *SieveCache[K, V]) ContainsKey(key K) bool {
 // If no value exists for key, returns the zero value of V and false.
 // This operation marks the entry as "visited" in the SIEVE algorithm,
 // which affects eviction decisions.
-func (c *SieveCache[K, V]) Get(key K) (V, bool) {
+func (c *SieveCache[K, V]) Get(key K) (value V, found bool) {
+	if c.latencies != nil {
+		defer c.latencies.get.since(time.Now())
+	}
+	if c.ops != nil {
+		defer c.ops.since(OpGet, hashKey(key), &found, time.Now())
+	}
 	var zero V
-	idx, exists := c.indices[key]
+	idx, exists := c.indices.get(key, c.nodes)
+	c.recordLookup(exists)
+	c.recordAccess(key)
 	if !exists {
 		return zero, false
 	}
 
 	// Mark as visited for the SIEVE algorithm
 	c.visited.Set(idx, true)
-	return c.nodes[idx].Value, true
+	return c.copyValue(c.nodes[idx].Value), true
+}
+
+// getShared is like Get, but only requires shared access to the cache: the
+// visited flag is set atomically, so it can be called concurrently with other
+// calls to getShared and with read-only methods.
+func (c *SieveCache[K, V]) getShared(key K) (value V, found bool) {
+	if c.latencies != nil {
+		defer c.latencies.get.since(time.Now())
+	}
+	if c.ops != nil {
+		defer c.ops.since(OpGet, hashKey(key), &found, time.Now())
+	}
+	var zero V
+	idx, exists := c.indices.get(key, c.nodes)
+	c.recordLookup(exists)
+	c.recordAccess(key)
+	if !exists {
+		return zero, false
+	}
+
+	c.visited.SetAtomic(idx)
+	return c.copyValue(c.nodes[idx].Value), true
 }
 
 // GetPointer returns a pointer to the value in the cache mapped to by key.
@@ -80,7 +261,9 @@ func (c *SieveCache[K, V]) Get(key K) (V, bool) {
 // This operation marks the entry as "visited" in the SIEVE algorithm,
 // which affects eviction decisions.
 func (c *SieveCache[K, V]) GetPointer(key K) *V {
-	idx, exists := c.indices[key]
+	idx, exists := c.indices.get(key, c.nodes)
+	c.recordLookup(exists)
+	c.recordAccess(key)
 	if !exists {
 		return nil
 	}
@@ -93,46 +276,269 @@ func (c *SieveCache[K, V]) GetPointer(key K) *V {
 // Insert maps key to value in the cache, possibly evicting old entries.
 // If the key already exists, its value is updated and the entry is marked as visited.
 // Returns true when this is a new entry, and false if an existing entry was updated.
+// When a sizer is configured, entries exceeding MaxItemCost or MaxCost are
+// rejected: Insert returns false, the rejection is counted, and an existing
+// entry for key is removed. The same applies to values rejected by BeforeInsert.
 func (c *SieveCache[K, V]) Insert(key K, value V) bool {
+	inserted, _ := c.TryInsert(key, value)
+	return inserted
+}
+
+// TryInsert is like Insert, but also returns the reason a value was
+// rejected: the error returned by BeforeInsert, ErrValueTooLarge if its
+// cost exceeds MaxItemCost or MaxCost, or ErrNotAdmitted if it would
+// displace an entry but is turned away by the doorkeeper or exceeds
+// AdmissionRate.
+func (c *SieveCache[K, V]) TryInsert(key K, value V) (inserted bool, err error) {
+	if c.latencies != nil {
+		defer c.latencies.insert.since(time.Now())
+	}
+	if c.ops != nil {
+		defer c.ops.since(OpInsert, hashKey(key), &inserted, time.Now())
+	}
+	c.recordAccess(key)
+
+	if c.beforeInsert != nil {
+		if value, err = c.beforeInsert(key, value); err != nil {
+			c.rejections++
+			c.Remove(key)
+			return false, err
+		}
+	}
+	value = c.copyValue(value)
+
 	// Check if key already exists
-	if idx, exists := c.indices[key]; exists {
+	if idx, exists := c.indices.get(key, c.nodes); exists {
 		// Update existing entry
 		c.visited.Set(idx, true)
-		c.nodes[idx].Value = value
-		return false
+		if !c.updateValue(idx, key, value) {
+			return false, ErrValueTooLarge
+		}
+		return false, nil
 	}
 
-	// Evict if at capacity
-	if len(c.nodes) >= c.capacity {
+	// A null or disabled cache doesn't store anything
+	if c.dropsInserts() {
+		return false, nil
+	}
+
+	var cost int64
+	if c.sizer != nil {
+		cost = c.sizer(key, value)
+		if c.tooCostly(cost) {
+			c.rejections++
+			return false, ErrValueTooLarge
+		}
+	}
+	if err := c.admits(key, cost); err != nil {
+		c.rejections++
+		return false, err
+	}
+
+	// Evict if at capacity, unless the policy admits new entries into a
+	// window first
+	if len(c.nodes) >= c.capacity && c.admissionWindow() == 0 {
+		c.Evict()
+	}
+	for c.maxCost > 0 && c.cost+cost > c.maxCost && len(c.nodes) > 0 {
 		c.Evict()
 	}
 
 	// Add new node to the end
 	node := NewNode(key, value)
+	node.version = c.nextVersion()
 	c.nodes = append(c.nodes, node)
 	idx := len(c.nodes) - 1
 	c.visited.Append(false) // Initialize as not visited
-	c.indices[key] = idx
+	c.indices.add(key, idx)
+	if c.keyIndex != nil {
+		c.keyIndex.add(key)
+	}
+	if c.sizer != nil {
+		c.costs = append(c.costs, cost)
+		c.cost += cost
+	}
+	if c.policy != nil {
+		c.policy.flags = append(c.policy.flags, 0)
+		c.admitToWindow(key)
+	}
+	return true, nil
+}
+
+// nextVersion returns a new version for a value, greater than all the
+// versions previously assigned by the cache, even to removed entries.
+func (c *SieveCache[K, V]) nextVersion() uint64 {
+	c.version++
+	return c.version
+}
+
+// GetVersioned is like Get, but also returns the version of the value. The
+// version changes every time the value is replaced or modified through the
+// cache, except through GetPointer, and increases even if the entry is
+// removed and inserted again, so it can be passed to InsertIfVersion to
+// detect concurrent modifications.
+func (c *SieveCache[K, V]) GetVersioned(key K) (V, uint64, bool) {
+	value, found := c.Get(key)
+	return value, c.versionOf(key), found
+}
+
+// versionOf returns the version of the value of key, or 0 if it is absent.
+func (c *SieveCache[K, V]) versionOf(key K) uint64 {
+	if idx, exists := c.indices.get(key, c.nodes); exists {
+		return c.nodes[idx].version
+	}
+	return 0
+}
+
+// InsertIfVersion maps key to value like Insert, but only if the current
+// version of the value of key is version, as returned by GetVersioned. A
+// version of 0 requires key to be absent. Returns true if the value was
+// stored, and false if the version didn't match or the value was rejected.
+func (c *SieveCache[K, V]) InsertIfVersion(key K, value V, version uint64) bool {
+	if c.versionOf(key) != version {
+		return false
+	}
+	_, err := c.TryInsert(key, value)
+	return err == nil && c.ContainsKey(key)
+}
+
+// copyValue returns a copy of value made by the cloner, if any.
+func (c *SieveCache[K, V]) copyValue(value V) V {
+	if c.cloner == nil {
+		return value
+	}
+	return c.cloner(value)
+}
+
+// Swap maps key to value like Insert, and returns the previous value of key
+// and whether it was present.
+func (c *SieveCache[K, V]) Swap(key K, value V) (V, bool) {
+	var previous V
+	idx, loaded := c.indices.get(key, c.nodes)
+	if loaded {
+		previous = c.nodes[idx].Value
+	}
+	c.Insert(key, value)
+	return previous, loaded
+}
+
+// updateValue replaces the value of the entry at idx, updating its cost.
+// If the cost bound is exceeded, entries are evicted, and an entry that can
+// no longer fit at all is removed, in which case false is returned.
+func (c *SieveCache[K, V]) updateValue(idx int, key K, value V) bool {
+	if c.sizer == nil {
+		c.nodes[idx].Value = value
+		c.nodes[idx].version = c.nextVersion()
+		return true
+	}
+
+	cost := c.sizer(key, value)
+	if c.tooCostly(cost) {
+		c.rejections++
+		c.Remove(key)
+		return false
+	}
+	c.nodes[idx].Value = value
+	c.nodes[idx].version = c.nextVersion()
+	c.cost += cost - c.costs[idx]
+	c.costs[idx] = cost
+	c.evictOverCost()
 	return true
 }
 
+// tooCostly returns true if an entry with the given cost must be rejected,
+// because it exceeds the maximum item cost or can never fit.
+func (c *SieveCache[K, V]) tooCostly(cost int64) bool {
+	return (c.maxItemCost > 0 && cost > c.maxItemCost) || (c.maxCost > 0 && cost > c.maxCost)
+}
+
+// Rejections returns the number of entries that were not stored because
+// BeforeInsert returned an error, their cost exceeded MaxItemCost or
+// MaxCost, or they were not admitted by the doorkeeper or AdmissionRate.
+func (c *SieveCache[K, V]) Rejections() uint64 {
+	return c.rejections
+}
+
+// evictOverCost evicts entries until their total cost is within the bound.
+func (c *SieveCache[K, V]) evictOverCost() {
+	for c.maxCost > 0 && c.cost > c.maxCost && len(c.nodes) > 0 {
+		c.Evict()
+	}
+}
+
+// Cost returns the total cost of the entries, as computed by the sizer.
+// It is always 0 if no sizer was configured.
+func (c *SieveCache[K, V]) Cost() int64 {
+	return c.cost
+}
+
+// MaxCost returns the bound on the total cost of the entries, or 0 if there is none.
+func (c *SieveCache[K, V]) MaxCost() int64 {
+	return c.maxCost
+}
+
+// SetMaxCost changes the bound on the total cost of the entries, evicting
+// entries until they fit. A maximum cost of 0 removes the bound.
+// Returns an error if maxCost is negative, or if no sizer was configured.
+func (c *SieveCache[K, V]) SetMaxCost(maxCost int64) error {
+	if err := checkMaxCost(maxCost, c.sizer != nil); err != nil {
+		return err
+	}
+	c.maxCost = maxCost
+	c.evictOverCost()
+	return nil
+}
+
+// dropsInserts returns true if the cache is a null cache or is disabled, so
+// that new entries must not be stored.
+func (c *SieveCache[K, V]) dropsInserts() bool {
+	return c.capacity == 0 || c.disabled
+}
+
+// SetEnabled enables or disables the cache. Disabling it removes all
+// entries, and until it is enabled again, inserts are dropped and every
+// lookup misses, so that caching can be turned off at runtime without
+// changing call sites. Counters such as the number of evictions are kept.
+// The memory of the entries is released while the cache is disabled.
+func (c *SieveCache[K, V]) SetEnabled(enabled bool) {
+	if !enabled && !c.disabled {
+		c.reset(true)
+	}
+	c.disabled = !enabled
+}
+
+// Enabled returns false if the cache was disabled with SetEnabled.
+func (c *SieveCache[K, V]) Enabled() bool {
+	return !c.disabled
+}
+
 // Remove removes the cache entry mapped to by key.
 // Returns the value removed from the cache and true if the key was present.
 // If key did not map to any value, returns the zero value of V and false.
-func (c *SieveCache[K, V]) Remove(key K) (V, bool) {
+func (c *SieveCache[K, V]) Remove(key K) (value V, found bool) {
+	if c.ops != nil {
+		defer c.ops.since(OpRemove, hashKey(key), &found, time.Now())
+	}
+	if c.shrinkThreshold > 0 {
+		defer c.maybeShrink()
+	}
 	var zero V
-	idx, exists := c.indices[key]
+	idx, exists := c.indices.get(key, c.nodes)
 	if !exists {
 		return zero, false
 	}
 
-	delete(c.indices, key)
+	c.indices.remove(key, idx)
+	if c.keyIndex != nil {
+		c.keyIndex.remove(key)
+	}
 
 	// If this is the last element, just remove it
 	if idx == len(c.nodes)-1 {
 		node := c.nodes[len(c.nodes)-1]
-		c.nodes = c.nodes[:len(c.nodes)-1]
+		c.truncateNodes(len(c.nodes) - 1)
 		c.visited.Truncate(len(c.nodes))
+		c.clampHand()
 		return node.Value, true
 	}
 
@@ -151,135 +557,395 @@ func (c *SieveCache[K, V]) Remove(key K) (V, bool) {
 		}
 	}
 
-	// Remove the node by replacing it with the last one and updating the map
+	// Remove the node by replacing it with the last one
 	removedNode := c.nodes[idx]
 	lastIdx := len(c.nodes) - 1
-	lastNode := c.nodes[lastIdx]
 
 	// Move the last node to the removed position
-	c.nodes[idx] = lastNode
+	c.moveNode(idx, lastIdx)
 	c.visited.Set(idx, c.visited.Get(lastIdx))
 
 	// Truncate slices
-	c.nodes = c.nodes[:lastIdx]
+	c.truncateNodes(lastIdx)
 	c.visited.Truncate(lastIdx)
 
-	// Update the indices map for the moved node
-	if idx < len(c.nodes) {
-		c.indices[lastNode.Key] = idx
+	return removedNode.Value, true
+}
+
+// moveNode moves the node at src to dst, replacing the node at dst, whose key
+// must already be removed from the index. The slot at src must then be truncated.
+func (c *SieveCache[K, V]) moveNode(dst, src int) {
+	c.indices.move(c.nodes[src].Key, src, dst)
+	c.nodes[dst] = c.nodes[src]
+	if c.sizer != nil {
+		c.cost -= c.costs[dst]
+		c.costs[dst] = c.costs[src]
+		c.costs[src] = 0
+	}
+	if c.policy != nil {
+		c.policy.forget(c.policy.flags[dst])
+		c.policy.flags[dst] = c.policy.flags[src]
+		c.policy.flags[src] = 0
 	}
+}
 
-	return removedNode.Value, true
+// truncateNodes shrinks the node slice to n entries, clearing the vacated slots
+// so that the keys and values they hold can be garbage collected.
+func (c *SieveCache[K, V]) truncateNodes(n int) {
+	var zero Node[K, V]
+	for i := n; i < len(c.nodes); i++ {
+		c.nodes[i] = zero
+	}
+	c.nodes = c.nodes[:n]
+	if c.sizer != nil {
+		for _, cost := range c.costs[n:] {
+			c.cost -= cost
+		}
+		c.costs = c.costs[:n]
+	}
+	if c.policy != nil {
+		for _, flags := range c.policy.flags[n:] {
+			c.policy.forget(flags)
+		}
+		c.policy.flags = c.policy.flags[:n]
+	}
+}
+
+// clampHand moves the hand to the previous node, which is now the last one,
+// when the node it pointed to was removed from the end of the slice.
+func (c *SieveCache[K, V]) clampHand() {
+	if !c.handInitialized || c.hand < len(c.nodes) {
+		return
+	}
+	if len(c.nodes) == 0 {
+		c.hand = 0
+		c.handInitialized = false
+	} else {
+		c.hand = len(c.nodes) - 1
+	}
 }
 
 // Evict removes and returns a value from the cache that was not recently accessed.
-// This method implements the SIEVE eviction algorithm.
-// Returns the evicted value and true if a suitable entry was found, or the zero
-// value of V and false if all entries have been recently accessed or the cache is empty.
+// This method implements the SIEVE eviction algorithm: the hand moves towards
+// the beginning of the cache, clearing visited flags, until it finds an entry
+// that wasn't visited. If every entry was visited, the entry the hand started
+// from is evicted after a full pass. When a maximum eviction scan is
+// configured, the entry following the scanned ones is evicted instead.
+// Returns the evicted value and true, or the zero value of V and false if the
+// cache is empty.
 func (c *SieveCache[K, V]) Evict() (V, bool) {
+	if c.latencies != nil {
+		defer c.latencies.evict.since(time.Now())
+	}
+	var start time.Time
+	if c.ops != nil {
+		start = time.Now()
+	}
 	var zero V
 	if len(c.nodes) == 0 {
 		return zero, false
 	}
-
-	// Start from the hand pointer or the end if hand is not initialized
-	var currentIdx int
-	if c.handInitialized {
-		currentIdx = c.hand
-	} else {
-		currentIdx = len(c.nodes) - 1
+	idx := c.findVictim()
+	if c.ops != nil {
+		defer c.ops.since(OpEvict, hashKey(c.nodes[idx].Key), nil, start)
 	}
-	startIdx := currentIdx
-
-	// Track whether we've wrapped around
-	wrapped := false
-	foundIdx := -1
+	return c.evictIndex(idx), true
+}
 
-	// Scan for a non-visited entry
+// findVictim returns the index of the entry Evict would evict, and moves the
+// hand to it. With PolicyWTinyLFU, entries of the admission window are
+// skipped, unless there are no other entries. With PolicySLRU and
+// PolicyCounters, the scan also updates the entries it passes.
+func (c *SieveCache[K, V]) findVictim() int {
+	n := len(c.nodes)
+	skipWindow := c.policy != nil && c.policy.windowCount < n
 	for {
-		// If current node is not visited, mark it for eviction
-		if !c.visited.Get(currentIdx) {
-			foundIdx = currentIdx
-			break
+		// Start from the hand pointer or the end if hand is not initialized
+		start := n - 1
+		if c.handInitialized && c.hand < n {
+			start = c.hand
 		}
-
-		// Mark as non-visited for next scan
-		c.visited.Set(currentIdx, false)
-
-		// Move to previous node or wrap to end
-		if currentIdx > 0 {
-			currentIdx--
+		var idx int
+		if c.policy != nil && c.policy.scansEntries() {
+			idx = c.findScannedCandidate(start)
 		} else {
-			// Wrap around to end of slice
-			if wrapped {
-				// If we've already wrapped, break to avoid infinite loop
-				break
-			}
-			wrapped = true
-			currentIdx = len(c.nodes) - 1
+			idx = c.findEvictionCandidate(start)
+		}
+		c.hand = idx
+		c.handInitialized = true
+		if !skipWindow || !c.inWindow(idx) {
+			return idx
 		}
 
-		// If we've looped back to start, we've checked all nodes
-		if currentIdx == startIdx {
-			break
+		// Move past the window entry
+		c.hand = idx - 1
+		if c.hand < 0 {
+			c.hand = n - 1
 		}
 	}
+}
 
-	// If we found a node to evict
-	if foundIdx >= 0 {
-		evictIdx := foundIdx
+// evictIndex evicts the entry at evictIdx, which the hand points to, and
+// returns its value.
+func (c *SieveCache[K, V]) evictIndex(evictIdx int) V {
+	n := len(c.nodes)
 
-		// Update the hand pointer to the previous node or wrap to end
-		if evictIdx > 0 {
-			c.hand = evictIdx - 1
-		} else if len(c.nodes) > 1 {
-			c.hand = len(c.nodes) - 2
-		} else {
-			// Keep hand at 0 but mark as not initialized
-			c.handInitialized = false
-		}
+	// Update the hand pointer to the previous node or wrap to end
+	if evictIdx > 0 {
+		c.hand = evictIdx - 1
 		c.handInitialized = true
+	} else if n > 1 {
+		c.hand = n - 2
+		c.handInitialized = true
+	} else {
+		c.hand = 0
+		c.handInitialized = false
+	}
 
-		// Remove the key from the map
-		delete(c.indices, c.nodes[evictIdx].Key)
-
-		// Remove the node and return its value
-		nodeToEvict := c.nodes[evictIdx]
+	c.evictions++
 
-		if evictIdx == len(c.nodes)-1 {
-			// If last node, just remove it
-			c.nodes = c.nodes[:len(c.nodes)-1]
-			c.visited.Truncate(len(c.nodes))
-			return nodeToEvict.Value, true
-		}
+	// Remove the key from the index
+	nodeToEvict := c.nodes[evictIdx]
+	c.indices.remove(nodeToEvict.Key, evictIdx)
+	if c.keyIndex != nil {
+		c.keyIndex.remove(nodeToEvict.Key)
+	}
 
-		// Otherwise swap with the last node
-		lastIdx := len(c.nodes) - 1
-		lastNode := c.nodes[lastIdx]
-		c.nodes[evictIdx] = lastNode
+	// Swap with the last node unless it is the one being evicted
+	lastIdx := n - 1
+	if evictIdx != lastIdx {
+		c.moveNode(evictIdx, lastIdx)
 		c.visited.Set(evictIdx, c.visited.Get(lastIdx))
-		c.nodes = c.nodes[:lastIdx]
-		c.visited.Truncate(lastIdx)
+	}
+	c.truncateNodes(lastIdx)
+	c.visited.Truncate(lastIdx)
+
+	return nodeToEvict.Value
+}
+
+// EvictAhead evicts entries until at least headroom slots are free, so that
+// subsequent inserts don't have to evict. The headroom is capped to the
+// capacity. Returns the number of evicted entries.
+func (c *SieveCache[K, V]) EvictAhead(headroom int) int {
+	target := c.capacity - min(headroom, c.capacity)
+	evicted := 0
+	for len(c.nodes) > target {
+		c.Evict()
+		evicted++
+	}
+	return evicted
+}
+
+// findEvictionCandidate returns the index of the entry to evict, scanning
+// from start towards the beginning of the cache and then wrapping around to
+// the end. Visited flags of the scanned entries are cleared a word at a time.
+// At most maxScan entries are scanned if a limit is configured, and never
+// more than one full pass.
+func (c *SieveCache[K, V]) findEvictionCandidate(start int) int {
+	n := len(c.nodes)
+	budget := n
+	if c.maxScan > 0 && c.maxScan < n {
+		budget = c.maxScan
+	}
 
-		// Update the indices map for the moved node
-		c.indices[lastNode.Key] = evictIdx
+	idx := start
+	for budget > 0 {
+		// Don't scan past the start position after wrapping around
+		segmentStop := 0
+		if idx > start {
+			segmentStop = start + 1
+		}
+		stop := max(segmentStop, idx-budget+1)
+		if found := c.visited.ClearDown(idx, stop); found >= 0 {
+			return found
+		}
 
-		return nodeToEvict.Value, true
+		budget -= idx - stop + 1
+		idx = stop - 1
+		if idx < 0 {
+			idx = n - 1
+		}
 	}
 
-	return zero, false
+	// All the scanned entries were visited: evict the next one in scan order,
+	// which is the start entry after a full pass
+	return idx
+}
+
+// SetCapacity changes the maximum number of entries the cache can hold.
+// If the cache holds more entries than the new capacity, entries are evicted
+// using the SIEVE algorithm until they fit.
+// A capacity of 0 removes all entries and turns the cache into a null cache.
+// Returns an error if capacity is negative.
+func (c *SieveCache[K, V]) SetCapacity(capacity int) error {
+	if capacity < 0 {
+		return errors.New("SieveCache: capacity must not be negative")
+	}
+	for len(c.nodes) > capacity {
+		c.Evict()
+	}
+	c.capacity = capacity
+	if c.policy != nil {
+		if c.policy.sketch != nil {
+			c.policy.sketch.EnsureCapacity(min(capacity, 1<<20))
+		}
+		c.shrinkWindow()
+	}
+	return nil
 }
 
 // Clear removes all entries from the cache.
 func (c *SieveCache[K, V]) Clear() {
-	// Pre-allocate map with capacity hint to avoid rehashing during growth
-	c.indices = make(map[K]int, c.capacity)
+	c.reset(false)
+}
+
+// ClearOptions configures ClearWithOptions.
+type ClearOptions[K comparable, V any] struct {
+	// OnRemove, if set, is called for each entry removed from the cache,
+	// after the cache has been cleared.
+	OnRemove func(key K, value V)
+	// ReleaseMemory drops the backing storage instead of preallocating it
+	// again, for the full capacity or InitialSize. Storage is then
+	// reallocated on demand as entries are inserted.
+	ReleaseMemory bool
+}
+
+// ClearWithOptions removes all entries from the cache, optionally calling a
+// function for each removed entry and releasing the memory used by the cache.
+func (c *SieveCache[K, V]) ClearWithOptions(opts ClearOptions[K, V]) {
+	nodes := c.nodes
+	c.reset(opts.ReleaseMemory)
+
+	// The cache is already consistent if the callback panics
+	if opts.OnRemove != nil {
+		for _, node := range nodes {
+			opts.OnRemove(node.Key, node.Value)
+		}
+	}
+}
+
+// reset removes all entries, either preallocating storage as when the cache
+// was created or releasing it.
+func (c *SieveCache[K, V]) reset(releaseMemory bool) {
+	prealloc := initialCapacity(c.capacity, c.initialSize)
+	if releaseMemory {
+		prealloc = 0
+	}
+	// Pre-allocate the index with capacity hint to avoid rehashing during growth
+	c.indices.reset(prealloc)
 	// Pre-allocate slice with capacity hint to minimize reallocations
-	c.nodes = make([]Node[K, V], 0, c.capacity)
+	c.nodes = make([]Node[K, V], 0, prealloc)
 	// Initialize bit set
-	c.visited = NewBitSet(c.capacity)
+	c.visited = bitset.WithCapacity(prealloc)
+	if c.sizer != nil {
+		c.costs = make([]int64, 0, prealloc)
+	}
+	c.cost = 0
 	c.hand = 0
 	c.handInitialized = false
+	if c.policy != nil {
+		c.policy.reset(prealloc)
+	}
+	if c.keyIndex != nil {
+		c.keyIndex.clear()
+	}
+}
+
+// Clone returns an independent copy of the cache, including visited flags and hand position.
+// Values are copied shallowly: if V contains pointers, slices or maps, the
+// copies share the underlying data.
+func (c *SieveCache[K, V]) Clone() *SieveCache[K, V] {
+	// Keep the spare capacity so that the copy doesn't reallocate on insert
+	nodes := make([]Node[K, V], len(c.nodes), cap(c.nodes))
+	copy(nodes, c.nodes)
+
+	clone := &SieveCache[K, V]{
+		indices:         c.indices.clone(),
+		nodes:           nodes,
+		visited:         c.visited.Clone(),
+		capacity:        c.capacity,
+		hand:            c.hand,
+		maxScan:         c.maxScan,
+		evictions:       c.evictions,
+		sizer:           c.sizer,
+		costs:           slices.Clone(c.costs),
+		cost:            c.cost,
+		maxCost:         c.maxCost,
+		maxItemCost:     c.maxItemCost,
+		rejections:      c.rejections,
+		version:         c.version,
+		handInitialized: c.handInitialized,
+		disabled:        c.disabled,
+		beforeInsert:    c.beforeInsert,
+		cloner:          c.cloner,
+		shrinkThreshold: c.shrinkThreshold,
+		initialSize:     c.initialSize,
+	}
+	if c.stats != nil {
+		clone.stats = c.stats.clone()
+	}
+	if c.latencies != nil {
+		clone.latencies = c.latencies.clone()
+	}
+	if c.ops != nil {
+		clone.ops = c.ops.clone()
+	}
+	if c.policy != nil {
+		clone.policy = c.policy.clone()
+	}
+	if c.keyIndex != nil {
+		clone.keyIndex = c.keyIndex.clone()
+	}
+	if c.admission != nil {
+		clone.admission = c.admission.clone()
+	}
+	if c.doorkeeper != nil {
+		clone.doorkeeper = c.doorkeeper.clone()
+	}
+	return clone
+}
+
+// Merge copies all entries of other into this cache, possibly evicting entries.
+// Entries of other are inserted from the coldest to the hottest, so that the
+// most recently used ones are the most likely to survive if the combined
+// entries don't fit, and visited flags are carried over.
+// When a key exists in both caches, conflict is called with the existing and
+// incoming values to compute the merged value. If conflict is nil, the
+// incoming value wins.
+func (c *SieveCache[K, V]) Merge(other *SieveCache[K, V], conflict func(key K, existing, incoming V) V) {
+	if other == c {
+		return
+	}
+	other.forEachInEvictionOrder(func(idx int) {
+		node := other.nodes[idx]
+		c.mergeEntry(node.Key, node.Value, other.visited.Get(idx), conflict)
+	})
+}
+
+// mergeEntry inserts a single entry as part of a merge.
+func (c *SieveCache[K, V]) mergeEntry(key K, value V, visited bool, conflict func(key K, existing, incoming V) V) {
+	if idx, exists := c.indices.get(key, c.nodes); exists {
+		if conflict != nil {
+			value = conflict(key, c.nodes[idx].Value, value)
+		}
+		if visited {
+			c.visited.Set(idx, true)
+		}
+		c.updateValue(idx, key, value)
+		return
+	}
+
+	if c.Insert(key, value) && visited {
+		idx, _ := c.indices.get(key, c.nodes)
+		c.visited.Set(idx, true)
+	}
+}
+
+// Drain removes all entries from the cache and returns them.
+func (c *SieveCache[K, V]) Drain() []Item[K, V] {
+	items := c.Items()
+	c.Clear()
+	return items
 }
 
 // Keys returns a slice of all keys in the cache.
@@ -292,35 +958,83 @@ func (c *SieveCache[K, V]) Keys() []K {
 	return keys
 }
 
+// VisitedKeys returns up to limit keys of entries that were accessed since
+// the hand last passed them, which are the entries the next evictions will
+// spare. If limit is less than or equal to 0, all of them are returned.
+// Visited flags are left unchanged.
+func (c *SieveCache[K, V]) VisitedKeys(limit int) []K {
+	var keys []K
+	n := len(c.nodes)
+	// Read words atomically, since lookups may set flags under a read lock
+	for w := 0; w<<6 < n; w++ {
+		word := c.visited.LoadWord(w)
+		for word != 0 {
+			idx := w<<6 + bits.TrailingZeros64(word)
+			if idx >= n || (limit > 0 && len(keys) >= limit) {
+				return keys
+			}
+			keys = append(keys, c.nodes[idx].Key)
+			word &= word - 1
+		}
+	}
+	return keys
+}
+
 // Values returns a slice of all values in the cache.
 func (c *SieveCache[K, V]) Values() []V {
 	// Pre-allocate with exact capacity
 	values := make([]V, len(c.nodes))
 	for i, node := range c.nodes {
-		values[i] = node.Value
+		values[i] = c.copyValue(node.Value)
 	}
 	return values
 }
 
 // Items returns a slice of all key-value pairs in the cache.
-func (c *SieveCache[K, V]) Items() []struct {
-	Key   K
-	Value V
-} {
+func (c *SieveCache[K, V]) Items() []Item[K, V] {
 	// Pre-allocate with exact capacity
-	items := make([]struct {
-		Key   K
-		Value V
-	}, len(c.nodes))
+	items := make([]Item[K, V], len(c.nodes))
 
 	for i, node := range c.nodes {
 		items[i].Key = node.Key
-		items[i].Value = node.Value
+		items[i].Value = c.copyValue(node.Value)
 	}
 
 	return items
 }
 
+// ItemsInEvictionOrder returns all key-value pairs in the order in which the
+// SIEVE hand considers them for eviction, starting with the current eviction candidate.
+// Entries that are marked as visited will be skipped once by the hand
+// (their flag is cleared instead), so this is the scan order, not necessarily
+// the exact order in which entries will be evicted.
+// This is useful for debugging eviction behavior and for tiered demotion.
+func (c *SieveCache[K, V]) ItemsInEvictionOrder() []Item[K, V] {
+	items := make([]Item[K, V], 0, len(c.nodes))
+	c.forEachInEvictionOrder(func(idx int) {
+		items = append(items, Item[K, V]{Key: c.nodes[idx].Key, Value: c.copyValue(c.nodes[idx].Value)})
+	})
+	return items
+}
+
+// forEachInEvictionOrder calls f with the index of every node, in the order the hand scans them.
+func (c *SieveCache[K, V]) forEachInEvictionOrder(f func(idx int)) {
+	n := len(c.nodes)
+	if n == 0 {
+		return
+	}
+
+	// The hand moves from its current position towards the start of the slice,
+	// then wraps around to the end
+	start := n - 1
+	if c.handInitialized && c.hand < n {
+		start = c.hand
+	}
+	for i := 0; i < n; i++ {
+		f((start - i + n) % n)
+	}
+}
+
 // ForEach iterates over all entries in the cache and applies the function f to each pair.
 // The iteration order is not specified and should not be relied upon.
 func (c *SieveCache[K, V]) ForEach(f func(k K, v V)) {
@@ -334,6 +1048,7 @@ func (c *SieveCache[K, V]) ForEach(f func(k K, v V)) {
 func (c *SieveCache[K, V]) ForEachValue(f func(v *V)) {
 	for i := range c.nodes {
 		f(&c.nodes[i].Value)
+		c.nodes[i].version = c.nextVersion()
 	}
 }
 
@@ -345,6 +1060,9 @@ func (c *SieveCache[K, V]) Retain(f func(k K, v V) bool) {
 	if nodeCount == 0 {
 		return
 	}
+	if c.shrinkThreshold > 0 {
+		defer c.maybeShrink()
+	}
 
 	// Start with a small capacity and grow as needed
 	// This avoids over-allocation for large caches with few removals
@@ -366,29 +1084,27 @@ func (c *SieveCache[K, V]) Retain(f func(k K, v V) bool) {
 	for i := len(toRemove) - 1; i >= 0; i-- {
 		idx := toRemove[i]
 
-		// Remove from map
-		delete(c.indices, c.nodes[idx].Key)
+		// Remove from the index
+		c.indices.remove(c.nodes[idx].Key, idx)
+		if c.keyIndex != nil {
+			c.keyIndex.remove(c.nodes[idx].Key)
+		}
 
 		// If it's the last element, just remove it
 		if idx == len(c.nodes)-1 {
-			c.nodes = c.nodes[:len(c.nodes)-1]
+			c.truncateNodes(len(c.nodes) - 1)
 			c.visited.Truncate(len(c.nodes))
+			c.clampHand()
 		} else {
 			// Replace with the last element
 			lastIdx := len(c.nodes) - 1
-			lastNode := c.nodes[lastIdx]
 
 			// Move the last node to the removed position
-			c.nodes[idx] = lastNode
+			c.moveNode(idx, lastIdx)
 			c.visited.Set(idx, c.visited.Get(lastIdx))
-			c.nodes = c.nodes[:lastIdx]
+			c.truncateNodes(lastIdx)
 			c.visited.Truncate(lastIdx)
 
-			// Update indices map if not removed
-			if idx < len(c.nodes) {
-				c.indices[lastNode.Key] = idx
-			}
-
 			// Update hand if needed
 			if c.handInitialized {
 				if c.hand == idx {
@@ -409,6 +1125,25 @@ func (c *SieveCache[K, V]) Retain(f func(k K, v V) bool) {
 	}
 }
 
+// FillRatio returns the number of entries divided by the capacity, or 0 for
+// a null or Unbounded cache.
+func (c *SieveCache[K, V]) FillRatio() float64 {
+	if c.capacity == 0 || c.capacity == Unbounded {
+		return 0
+	}
+	return float64(len(c.nodes)) / float64(c.capacity)
+}
+
+// VisitedRatio returns the fraction of the entries that were accessed since
+// the hand last passed them, or 0 if the cache is empty.
+// RecommendedCapacity uses it as the utilization of the cache.
+func (c *SieveCache[K, V]) VisitedRatio() float64 {
+	if len(c.nodes) == 0 {
+		return 0
+	}
+	return float64(c.visited.CountSetBits()) / float64(len(c.nodes))
+}
+
 // RecommendedCapacity analyzes the current cache utilization and recommends a new capacity.
 // Parameters:
 // - minFactor: Minimum scaling factor (e.g., 0.5 means recommend at least 50% of current capacity)
@@ -416,58 +1151,10 @@ func (c *SieveCache[K, V]) Retain(f func(k K, v V) bool) {
 // - lowThreshold: Utilization threshold below which capacity is reduced
 // - highThreshold: Utilization threshold above which capacity is increased
 func (c *SieveCache[K, V]) RecommendedCapacity(minFactor, maxFactor, lowThreshold, highThreshold float64) int {
-	// If the cache is empty, return the current capacity
-	if len(c.nodes) == 0 {
-		return c.capacity
-	}
-
-	// Count entries with visited flag set
-	visitedCount := c.visited.CountSetBits()
-
-	// Calculate the utilization ratio (visited entries / total entries)
-	utilizationRatio := float64(visitedCount) / float64(len(c.nodes))
-
-	// Calculate fill ratio (total entries / capacity)
-	fillRatio := float64(len(c.nodes)) / float64(c.capacity)
-
-	// Low fill ratio threshold (consider the cache underfilled below this)
-	lowFillThreshold := 0.1 // 10% filled
-
-	// Fill ratio takes precedence over utilization:
-	// If the cache is severely underfilled, we should decrease capacity
-	// regardless of utilization
-	if fillRatio < lowFillThreshold {
-		// Calculate how much to decrease based on how empty the cache is
-		fillBelowThreshold := 0.0
-		if fillRatio > 0.0 {
-			fillBelowThreshold = (lowFillThreshold - fillRatio) / lowFillThreshold
-		} else {
-			fillBelowThreshold = 1.0
-		}
-		// Apply the minFactor as a floor
-		scalingFactor := 1.0 - (1.0-minFactor)*fillBelowThreshold
-
-		// Apply the scaling factor to current capacity and ensure it's at least 1
-		return max(1, int(math.Round(float64(c.capacity)*scalingFactor)))
-	}
-
-	// For normal fill levels, use the original logic based on utilization
-	var scalingFactor float64
-	if utilizationRatio >= highThreshold {
-		// High utilization - recommend increasing the capacity
-		// Scale between 1.0 and maxFactor based on utilization above the high threshold
-		utilizationAboveThreshold := (utilizationRatio - highThreshold) / (1.0 - highThreshold)
-		scalingFactor = 1.0 + (maxFactor-1.0)*utilizationAboveThreshold
-	} else if utilizationRatio <= lowThreshold {
-		// Low utilization - recommend decreasing capacity
-		// Scale between minFactor and 1.0 based on how far below the low threshold
-		utilizationBelowThreshold := (lowThreshold - utilizationRatio) / lowThreshold
-		scalingFactor = 1.0 - (1.0-minFactor)*utilizationBelowThreshold
-	} else {
-		// Normal utilization - keep current capacity
-		scalingFactor = 1.0
-	}
-
-	// Apply the scaling factor to current capacity and ensure it's at least 1
-	return max(1, int(math.Round(float64(c.capacity)*scalingFactor)))
+	return c.AdviseCapacity(ThresholdAdvisor{
+		MinFactor:     minFactor,
+		MaxFactor:     maxFactor,
+		LowThreshold:  lowThreshold,
+		HighThreshold: highThreshold,
+	})
 }
@@ -1,7 +1,10 @@
 package sievecache
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"slices"
 	"testing"
 )
 
@@ -271,3 +274,634 @@ func TestRecommendedCapacity(t *testing.T) {
 		t.Errorf("Expected between 95-100, got %d", recommended)
 	}
 }
+
+func TestUtilizationRatios(t *testing.T) {
+	cache, _ := New[int, int](10)
+	if cache.FillRatio() != 0 || cache.VisitedRatio() != 0 {
+		t.Error("Expected ratios of 0 for an empty cache")
+	}
+	for i := 0; i < 8; i++ {
+		cache.Insert(i, i)
+	}
+	cache.Get(0)
+	cache.Get(1)
+	if cache.FillRatio() != 0.8 {
+		t.Errorf("Expected a fill ratio of 0.8, got %v", cache.FillRatio())
+	}
+	if cache.VisitedRatio() != 0.25 {
+		t.Errorf("Expected a visited ratio of 0.25, got %v", cache.VisitedRatio())
+	}
+
+	unbounded, _ := New[int, int](Unbounded)
+	unbounded.Insert(1, 1)
+	if unbounded.FillRatio() != 0 {
+		t.Error("Expected a fill ratio of 0 for an unbounded cache")
+	}
+}
+
+func TestItemsInEvictionOrder(t *testing.T) {
+	cache, _ := New[string, int](5)
+	if len(cache.ItemsInEvictionOrder()) != 0 {
+		t.Error("Expected no items for an empty cache")
+	}
+
+	for i := 0; i < 5; i++ {
+		cache.Insert(fmt.Sprintf("key%d", i), i)
+	}
+
+	// Without a hand, the scan starts from the most recently inserted entry
+	items := cache.ItemsInEvictionOrder()
+	for i, item := range items {
+		if item.Value != 4-i {
+			t.Errorf("Position %d: expected %d, got %d", i, 4-i, item.Value)
+		}
+	}
+
+	// Unvisited entries are evicted in the reported order
+	cache.Get("key4")
+	cache.Get("key2")
+	order := cache.ItemsInEvictionOrder()
+	var expected []int
+	for _, item := range order {
+		if item.Key != "key4" && item.Key != "key2" {
+			expected = append(expected, item.Value)
+		}
+	}
+	for _, want := range expected {
+		got, ok := cache.Evict()
+		if !ok || got != want {
+			t.Errorf("Expected eviction of %d, got %d", want, got)
+		}
+	}
+}
+
+func TestClone(t *testing.T) {
+	cache, _ := New[string, int](3)
+	cache.Insert("a", 1)
+	cache.Insert("b", 2)
+	cache.Insert("c", 3)
+	cache.Get("a")
+	cache.Evict()
+
+	clone := cache.Clone()
+	if clone.Len() != cache.Len() || clone.Capacity() != cache.Capacity() {
+		t.Errorf("Clone differs: len=%d cap=%d", clone.Len(), clone.Capacity())
+	}
+	if clone.Stats().Evictions != 1 {
+		t.Errorf("Expected the eviction count to be copied, got %d", clone.Stats().Evictions)
+	}
+
+	// Same visited flags and hand position mean the same eviction decisions
+	cache.Insert("d", 4)
+	clone.Insert("d", 4)
+	if fmt.Sprint(cache.Items()) != fmt.Sprint(clone.Items()) {
+		t.Errorf("Clone diverged: %v vs %v", cache.Items(), clone.Items())
+	}
+
+	// The copies are independent
+	clone.Insert("e", 5)
+	if cache.ContainsKey("e") {
+		t.Error("Original was affected by changes to the clone")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	a, _ := New[string, int](10)
+	a.Insert("x", 1)
+	a.Insert("shared", 10)
+
+	b, _ := New[string, int](10)
+	b.Insert("y", 2)
+	b.Insert("shared", 20)
+
+	a.Merge(b, func(key string, existing, incoming int) int {
+		return existing + incoming
+	})
+
+	if a.Len() != 3 {
+		t.Errorf("Expected 3 entries, got %d", a.Len())
+	}
+	if val, _ := a.Get("shared"); val != 30 {
+		t.Errorf("Expected merged value 30, got %d", val)
+	}
+	if val, _ := a.Get("y"); val != 2 {
+		t.Errorf("Expected 2, got %d", val)
+	}
+
+	// Without a conflict function, the incoming value wins
+	c, _ := New[string, int](10)
+	c.Insert("shared", 99)
+	a.Merge(c, nil)
+	if val, _ := a.Get("shared"); val != 99 {
+		t.Errorf("Expected 99, got %d", val)
+	}
+
+	// Hot entries of the other cache survive when capacity is exceeded
+	small, _ := New[string, int](2)
+	big, _ := New[string, int](10)
+	for i := 0; i < 5; i++ {
+		big.Insert(fmt.Sprintf("key%d", i), i)
+	}
+	big.Get("key1")
+	small.Merge(big, nil)
+	if !small.ContainsKey("key1") {
+		t.Errorf("Expected hot entry to survive the merge, got %v", small.Keys())
+	}
+}
+
+func TestDrain(t *testing.T) {
+	cache, _ := New[string, int](10)
+	cache.Insert("a", 1)
+	cache.Insert("b", 2)
+
+	items := cache.Drain()
+	if len(items) != 2 {
+		t.Errorf("Expected 2 drained items, got %d", len(items))
+	}
+	if !cache.IsEmpty() {
+		t.Errorf("Expected empty cache after drain, got %d entries", cache.Len())
+	}
+	if len(cache.Drain()) != 0 {
+		t.Error("Expected no items when draining an empty cache")
+	}
+}
+
+func TestClearWithOptions(t *testing.T) {
+	cache, _ := New[string, int](1000)
+	for i := 0; i < 10; i++ {
+		cache.Insert(fmt.Sprintf("key%d", i), i)
+	}
+
+	removed := make(map[string]int)
+	cache.ClearWithOptions(ClearOptions[string, int]{
+		OnRemove: func(key string, value int) {
+			removed[key] = value
+		},
+		ReleaseMemory: true,
+	})
+
+	if len(removed) != 10 {
+		t.Errorf("Expected 10 removal callbacks, got %d", len(removed))
+	}
+	if !cache.IsEmpty() {
+		t.Error("Expected empty cache")
+	}
+	if cap(cache.nodes) != 0 {
+		t.Errorf("Expected backing storage to be released, got capacity %d", cap(cache.nodes))
+	}
+
+	// The cache keeps working normally after releasing memory
+	for i := 0; i < 1200; i++ {
+		cache.Insert(fmt.Sprintf("key%d", i), i)
+	}
+	if cache.Len() != 1000 {
+		t.Errorf("Expected 1000 entries, got %d", cache.Len())
+	}
+	if val, ok := cache.Get("key1199"); !ok || val != 1199 {
+		t.Errorf("Expected 1199, got %v", val)
+	}
+}
+
+func TestEvictAllVisited(t *testing.T) {
+	cache, _ := New[string, int](3)
+	cache.Insert("a", 1)
+	cache.Insert("b", 2)
+	cache.Insert("c", 3)
+	cache.Get("a")
+	cache.Get("b")
+	cache.Get("c")
+
+	// After a full pass clearing visited flags, the entry the hand started
+	// from is evicted
+	value, ok := cache.Evict()
+	if !ok || value != 3 {
+		t.Errorf("Expected to evict 3, got %v (%v)", value, ok)
+	}
+
+	cache.Get("a")
+	cache.Get("b")
+	cache.Insert("d", 4)
+	cache.Insert("e", 5)
+	if cache.Len() != cache.Capacity() {
+		t.Errorf("Expected the cache to stay at capacity %d, got %d entries", cache.Capacity(), cache.Len())
+	}
+}
+
+func TestMaxEvictionScan(t *testing.T) {
+	if _, err := NewWithOptions(Options[int, int]{Capacity: 10, MaxEvictionScan: -1}); err == nil {
+		t.Error("Expected an error for a negative maximum eviction scan")
+	}
+
+	const capacity = 1000
+	cache, err := NewWithOptions(Options[int, int]{Capacity: capacity, MaxEvictionScan: 10})
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	for i := 0; i < capacity; i++ {
+		cache.Insert(i, i)
+	}
+	for i := 0; i < capacity; i++ {
+		cache.Get(i)
+	}
+
+	// Only 10 visited flags are cleared before the next entry is evicted
+	value, ok := cache.Evict()
+	if !ok || value != capacity-11 {
+		t.Errorf("Expected to evict %d, got %v (%v)", capacity-11, value, ok)
+	}
+	if n := cache.visited.CountSetBits(); n != capacity-1-10 {
+		t.Errorf("Expected %d visited entries, got %d", capacity-1-10, n)
+	}
+
+	// The hand continues from where it stopped
+	value, ok = cache.Evict()
+	if !ok || value != capacity-22 {
+		t.Errorf("Expected to evict %d, got %v (%v)", capacity-22, value, ok)
+	}
+	if cache.Len() != capacity-2 {
+		t.Errorf("Expected %d entries, got %d", capacity-2, cache.Len())
+	}
+
+	clone := cache.Clone()
+	if clone.maxScan != 10 {
+		t.Errorf("Expected the clone to keep the maximum eviction scan, got %d", clone.maxScan)
+	}
+}
+
+func TestVisitedKeys(t *testing.T) {
+	cache, _ := New[int, int](200)
+	for i := 0; i < 200; i++ {
+		cache.Insert(i, i)
+	}
+	for i := 0; i < 200; i += 3 {
+		cache.Get(i)
+	}
+
+	keys := cache.VisitedKeys(0)
+	if len(keys) != 67 {
+		t.Fatalf("Expected 67 visited keys, got %d", len(keys))
+	}
+	for _, key := range keys {
+		if key%3 != 0 {
+			t.Errorf("Key %d wasn't visited", key)
+		}
+	}
+	if keys := cache.VisitedKeys(10); len(keys) != 10 {
+		t.Errorf("Expected 10 keys with a limit, got %d", len(keys))
+	}
+
+	// Listing visited keys doesn't change the flags
+	if len(cache.VisitedKeys(0)) != 67 {
+		t.Error("Expected the visited flags to be unchanged")
+	}
+
+	sharded, _ := NewShardedWithShards[int, int](4000, 4)
+	for i := 0; i < 200; i++ {
+		sharded.Insert(i, i)
+	}
+	for i := 0; i < 200; i += 2 {
+		sharded.Get(i)
+	}
+	if keys := sharded.VisitedKeys(0); len(keys) != 100 {
+		t.Errorf("Expected 100 visited keys, got %d", len(keys))
+	}
+	if keys := sharded.VisitedKeys(30); len(keys) != 30 {
+		t.Errorf("Expected 30 keys with a limit, got %d", len(keys))
+	}
+}
+
+func TestMustNew(t *testing.T) {
+	if MustNew[string, int](10).Capacity() != 10 {
+		t.Error("Expected a capacity of 10")
+	}
+	if MustNewSync[string, int](10).Capacity() != 10 {
+		t.Error("Expected a capacity of 10")
+	}
+	if MustNewSharded[string, int](10).Capacity() != 10 {
+		t.Error("Expected a capacity of 10")
+	}
+
+	for name, f := range map[string]func(){
+		"MustNew":        func() { MustNew[string, int](-1) },
+		"MustNewSync":    func() { MustNewSync[string, int](-1) },
+		"MustNewSharded": func() { MustNewSharded[string, int](-1) },
+	} {
+		func() {
+			defer func() {
+				if _, ok := recover().(error); !ok {
+					t.Errorf("%s: expected a panic with an error", name)
+				}
+			}()
+			f()
+		}()
+	}
+}
+
+func TestNullCache(t *testing.T) {
+	cache, err := New[string, int](0)
+	if err != nil {
+		t.Fatalf("Failed to create a null cache: %v", err)
+	}
+	if cache.Insert("a", 1) {
+		t.Error("Expected a null cache not to store entries")
+	}
+	if _, found := cache.Get("a"); found || cache.Len() != 0 {
+		t.Error("Expected every lookup to miss")
+	}
+	if err := cache.CheckInvariants(); err != nil {
+		t.Error(err)
+	}
+	if _, err := New[string, int](-1); err == nil {
+		t.Error("Expected an error for a negative capacity")
+	}
+
+	// Caching can be disabled at runtime
+	cache, _ = New[string, int](10)
+	cache.Insert("a", 1)
+	if err := cache.SetCapacity(0); err != nil {
+		t.Fatal(err)
+	}
+	cache.Insert("b", 2)
+	if cache.Len() != 0 {
+		t.Errorf("Expected an empty cache, got %d entries", cache.Len())
+	}
+	cache.SetCapacity(10)
+	cache.Insert("b", 2)
+	if !cache.ContainsKey("b") {
+		t.Error("Expected the cache to store entries again")
+	}
+
+	syncCache, _ := NewSync[string, int](0)
+	syncCache.Insert("a", 1)
+	if syncCache.Len() != 0 || syncCache.ContainsKey("a") {
+		t.Error("Expected a null sync cache not to store entries")
+	}
+
+	sharded, err := NewSharded[string, int](0)
+	if err != nil {
+		t.Fatalf("Failed to create a null sharded cache: %v", err)
+	}
+	sharded.Insert("a", 1)
+	if n := sharded.InsertBatch(map[string]int{"b": 2, "c": 3}); n != 0 || sharded.Len() != 0 {
+		t.Error("Expected a null sharded cache not to store entries")
+	}
+	if err := sharded.Rebalance(); err != nil {
+		t.Error(err)
+	}
+	sharded, _ = NewShardedWithShards[string, int](100, 4)
+	sharded.Insert("a", 1)
+	if err := sharded.SetCapacity(0); err != nil || sharded.Len() != 0 || sharded.Capacity() != 0 {
+		t.Error("Expected SetCapacity(0) to disable the sharded cache")
+	}
+
+	byteCache, _ := NewByteCache[string](0)
+	if inserted, err := byteCache.Insert("a", []byte("x")); inserted || err != nil || byteCache.Len() != 0 {
+		t.Error("Expected a null byte cache not to store entries")
+	}
+}
+
+func TestSetEnabled(t *testing.T) {
+	cache, _ := New[string, int](10)
+	cache.Insert("a", 1)
+	cache.Insert("b", 2)
+	cache.Insert("c", 3)
+	cache.Evict()
+
+	cache.SetEnabled(false)
+	if cache.Enabled() {
+		t.Error("Expected the cache to be disabled")
+	}
+	if cache.Insert("d", 4) || cache.Len() != 0 {
+		t.Error("Expected a disabled cache to drop entries")
+	}
+	if _, found := cache.Get("a"); found {
+		t.Error("Expected lookups to miss while disabled")
+	}
+	if cache.evictions != 1 {
+		t.Errorf("Expected the eviction count to be kept, got %d", cache.evictions)
+	}
+
+	// Disabling releases the preallocated storage
+	large, _ := New[int, int](10000)
+	before := large.EstimatedMemory()
+	large.SetEnabled(false)
+	if after := large.EstimatedMemory(); after >= before/10 {
+		t.Errorf("Expected disabling to release memory, got %d bytes, %d before", after, before)
+	}
+
+	cache.SetEnabled(true)
+	if _, found := cache.Get("a"); found {
+		t.Error("Expected entries from before the cache was disabled to be gone")
+	}
+	cache.Insert("a", 1)
+	if !cache.ContainsKey("a") {
+		t.Error("Expected the cache to store entries again")
+	}
+
+	sharded, _ := NewShardedWithShards[int, int](100, 4)
+	sharded.Insert(1, 1)
+	sharded.SetEnabled(false)
+	sharded.Insert(2, 2)
+	if sharded.Len() != 0 || sharded.Enabled() {
+		t.Error("Expected a disabled sharded cache to drop entries")
+	}
+	// Shards created by Reshard are disabled too
+	sharded.Reshard(8)
+	sharded.Insert(3, 3)
+	if sharded.Len() != 0 {
+		t.Error("Expected new shards to be disabled")
+	}
+	sharded.SetEnabled(true)
+	sharded.Insert(3, 3)
+	if !sharded.ContainsKey(3) {
+		t.Error("Expected the sharded cache to store entries again")
+	}
+}
+
+func TestMaxCost(t *testing.T) {
+	cache, err := NewWithOptions(Options[string, string]{
+		Capacity: Unbounded,
+		Sizer:    func(_ string, value string) int64 { return int64(len(value)) },
+		MaxCost:  10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cache.Insert("a", "xxxx")
+	cache.Insert("b", "xxxx")
+	if cache.Cost() != 8 {
+		t.Errorf("Expected a cost of 8, got %d", cache.Cost())
+	}
+	cache.Get("a")
+
+	// Inserting c evicts b, which wasn't visited
+	cache.Insert("c", "xxxx")
+	if cache.ContainsKey("b") || !cache.ContainsKey("a") || cache.Cost() != 8 {
+		t.Errorf("Expected b to be evicted, got keys %v and a cost of %d", cache.Keys(), cache.Cost())
+	}
+
+	// Entries that can never fit are not stored, and updates change the cost
+	if cache.Insert("d", "xxxxxxxxxxx") || cache.ContainsKey("d") {
+		t.Error("Expected an entry larger than the maximum cost to be rejected")
+	}
+	cache.Insert("a", "x")
+	if cache.Cost() != 5 {
+		t.Errorf("Expected a cost of 5, got %d", cache.Cost())
+	}
+	cache.Insert("a", "xxxxxxxxxxx")
+	if cache.ContainsKey("a") || cache.Cost() != 4 {
+		t.Error("Expected an entry updated beyond the maximum cost to be removed")
+	}
+
+	if err := cache.SetMaxCost(3); err != nil {
+		t.Fatal(err)
+	}
+	if cache.Len() != 0 || cache.Cost() != 0 {
+		t.Errorf("Expected all entries to be evicted, got %d", cache.Len())
+	}
+	if err := cache.CheckInvariants(); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := NewWithOptions(Options[string, string]{Capacity: 10, MaxCost: 10}); err == nil {
+		t.Error("Expected an error for a maximum cost without a sizer")
+	}
+	if _, err := NewWithOptions(Options[string, string]{Capacity: 10, MaxCost: -1}); err == nil {
+		t.Error("Expected an error for a negative maximum cost")
+	}
+}
+
+func TestMaxCostRandomOperations(t *testing.T) {
+	cache, _ := NewWithOptions(Options[int, int]{
+		Capacity: 50,
+		Sizer:    func(_, value int) int64 { return int64(value % 17) },
+		MaxCost:  200,
+	})
+	for i := 0; i < 10000; i++ {
+		key := i * 7 % 101
+		switch i % 5 {
+		case 0, 1, 2:
+			cache.Insert(key, i)
+		case 3:
+			cache.Remove(key)
+		default:
+			cache.Retain(func(k, _ int) bool { return k%13 != key%13 })
+		}
+		if err := cache.CheckInvariants(); err != nil {
+			t.Fatalf("Operation %d: %v", i, err)
+		}
+	}
+}
+
+func TestMaxItemCost(t *testing.T) {
+	cache, _ := NewWithOptions(Options[string, []byte]{
+		Capacity:    100,
+		Sizer:       func(_ string, value []byte) int64 { return int64(len(value)) },
+		MaxItemCost: 100,
+	})
+	for i := 0; i < 10; i++ {
+		cache.Insert(fmt.Sprint(i), make([]byte, 50))
+	}
+
+	if cache.Insert("huge", make([]byte, 1000)) || cache.ContainsKey("huge") {
+		t.Error("Expected an entry above the maximum item cost to be rejected")
+	}
+	if cache.Len() != 10 {
+		t.Errorf("Expected no eviction, got %d entries", cache.Len())
+	}
+	if cache.Rejections() != 1 {
+		t.Errorf("Expected 1 rejection, got %d", cache.Rejections())
+	}
+
+	// Updating an entry with an oversized value removes it
+	cache.Insert("0", make([]byte, 101))
+	if cache.ContainsKey("0") || cache.Rejections() != 2 {
+		t.Error("Expected an oversized update to remove the entry")
+	}
+
+	if _, err := NewWithOptions(Options[string, []byte]{Capacity: 10, MaxItemCost: 10}); err == nil {
+		t.Error("Expected an error for a maximum item cost without a sizer")
+	}
+}
+
+func TestBeforeInsert(t *testing.T) {
+	errEmpty := errors.New("empty value")
+	cache, _ := NewWithOptions(Options[string, []byte]{
+		Capacity: 10,
+		BeforeInsert: func(key string, value []byte) ([]byte, error) {
+			if len(value) == 0 {
+				return nil, errEmpty
+			}
+			return bytes.Clone(value), nil
+		},
+	})
+
+	value := []byte("value")
+	if inserted, err := cache.TryInsert("key", value); !inserted || err != nil {
+		t.Fatalf("Expected the value to be inserted, got %v, %v", inserted, err)
+	}
+	value[0] = 'V'
+	if stored, _ := cache.Get("key"); string(stored) != "value" {
+		t.Errorf("Expected a copy to be stored, got %q", stored)
+	}
+
+	// A rejected value removes the existing entry
+	if inserted, err := cache.TryInsert("key", nil); inserted || err != errEmpty {
+		t.Errorf("Expected the hook's error, got %v, %v", inserted, err)
+	}
+	if cache.ContainsKey("key") || cache.Rejections() != 1 {
+		t.Errorf("Expected the entry to be removed and the rejection counted")
+	}
+
+	sharded, _ := NewShardedWithOptions(Options[string, int]{
+		Capacity: 100,
+		BeforeInsert: func(key string, value int) (int, error) {
+			if value < 0 {
+				return 0, errEmpty
+			}
+			return value * 2, nil
+		},
+	}, 4)
+	sharded.Insert("a", 21)
+	if value, _ := sharded.Get("a"); value != 42 {
+		t.Errorf("Expected the transformed value 42, got %d", value)
+	}
+	if _, err := sharded.TryInsert("b", -1); err != errEmpty {
+		t.Errorf("Expected the hook's error, got %v", err)
+	}
+}
+
+func TestTryInsertTooLarge(t *testing.T) {
+	cache, _ := NewSyncWithOptions(Options[string, string]{
+		Capacity:    10,
+		Sizer:       func(key, value string) int64 { return int64(len(value)) },
+		MaxItemCost: 4,
+	})
+	if _, err := cache.TryInsert("key", "too large"); err != ErrValueTooLarge {
+		t.Errorf("Expected ErrValueTooLarge, got %v", err)
+	}
+	cache.Insert("key", "ok")
+	if _, err := cache.TryInsert("key", "too large"); err != ErrValueTooLarge || cache.ContainsKey("key") {
+		t.Errorf("Expected the update to be rejected and the entry removed, got %v", err)
+	}
+}
+
+func TestCloner(t *testing.T) {
+	cache, _ := NewSyncWithOptions(Options[string, []int]{Capacity: 10, Cloner: slices.Clone[[]int]})
+	value := []int{1, 2, 3}
+	cache.Insert("key", value)
+	value[0] = 100
+
+	got, _ := cache.Get("key")
+	if got[0] != 1 {
+		t.Errorf("Expected the cache to store a copy, got %v", got)
+	}
+	got[1] = 200
+	if again, _ := cache.Get("key"); again[1] != 2 {
+		t.Errorf("Expected Get to return a copy, got %v", again)
+	}
+	if items := cache.Items(); items[0].Value[1] != 2 {
+		t.Errorf("Expected Items to return the stored value, got %v", items)
+	}
+}
@@ -0,0 +1,286 @@
+package sievecache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+)
+
+// Snapshot format:
+//
+//	magic   [8]byte  "SIEVSNAP"
+//	version uint16
+//	flags   uint16
+//	body    sections (metadata, visited flags, entries), or nonce || AES-256-GCM
+//	        ciphertext of those sections when the encrypted flag is set
+//
+// Each section is encoded as a uint64 length, the data, and a CRC-32C of the data.
+// All integers are little-endian.
+const (
+	snapshotMagic         = "SIEVSNAP"
+	snapshotVersion       = 1
+	snapshotHeaderSize    = len(snapshotMagic) + 4
+	snapshotFlagEncrypted = 1 << 0
+)
+
+var (
+	// ErrSnapshotFormat is returned when the input is not a cache snapshot.
+	ErrSnapshotFormat = errors.New("SieveCache: not a snapshot")
+	// ErrSnapshotVersion is returned when the snapshot uses an unsupported format version.
+	ErrSnapshotVersion = errors.New("SieveCache: unsupported snapshot version")
+	// ErrSnapshotCorrupt is returned when a snapshot is truncated or fails checksum verification.
+	ErrSnapshotCorrupt = errors.New("SieveCache: snapshot is corrupted")
+)
+
+// crcTable is the CRC-32C table used for section checksums.
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// SnapshotOptions configures how cache snapshots are written and read.
+type SnapshotOptions struct {
+	// Codec serializes keys and values. Defaults to GobCodec when nil.
+	Codec Codec
+	// EncryptionKey, when set, encrypts and authenticates the snapshot with
+	// AES-256-GCM. It must be SnapshotKeySize bytes long, and the same key
+	// must be provided to load the snapshot.
+	EncryptionKey []byte
+}
+
+// SaveToWriter writes a snapshot of the cache to w.
+// The snapshot preserves the entries, their visited flags and the hand position,
+// so a cache restored with LoadFromReader makes the same eviction decisions.
+func (c *SieveCache[K, V]) SaveToWriter(w io.Writer, opts SnapshotOptions) error {
+	var flags uint16
+	if opts.EncryptionKey != nil {
+		flags |= snapshotFlagEncrypted
+	}
+	header := make([]byte, snapshotHeaderSize)
+	copy(header, snapshotMagic)
+	binary.LittleEndian.PutUint16(header[len(snapshotMagic):], snapshotVersion)
+	binary.LittleEndian.PutUint16(header[len(snapshotMagic)+2:], flags)
+
+	var body bytes.Buffer
+	if err := c.writeSnapshotBody(&body, opts.Codec); err != nil {
+		return err
+	}
+
+	payload := body.Bytes()
+	if flags&snapshotFlagEncrypted != 0 {
+		// The header is authenticated along with the encrypted body
+		sealed, err := sealSnapshot(opts.EncryptionKey, payload, header)
+		if err != nil {
+			return err
+		}
+		payload = sealed
+	}
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+// writeSnapshotBody writes the metadata, visited flags and entries sections to w.
+func (c *SieveCache[K, V]) writeSnapshotBody(w io.Writer, codec Codec) error {
+	// Metadata: capacity, number of entries and hand position (-1 if unset)
+	hand := int64(-1)
+	if c.handInitialized {
+		hand = int64(c.hand)
+	}
+	meta := make([]byte, 24)
+	binary.LittleEndian.PutUint64(meta[0:], uint64(c.capacity))
+	binary.LittleEndian.PutUint64(meta[8:], uint64(len(c.nodes)))
+	binary.LittleEndian.PutUint64(meta[16:], uint64(hand))
+	if err := writeSnapshotSection(w, meta); err != nil {
+		return err
+	}
+
+	// Visited flags, one bit per entry
+	numWords := (len(c.nodes) + 63) >> 6
+	visited := make([]byte, 8*numWords)
+	for i := 0; i < numWords; i++ {
+		binary.LittleEndian.PutUint64(visited[8*i:], c.visited.LoadWord(i))
+	}
+	if err := writeSnapshotSection(w, visited); err != nil {
+		return err
+	}
+
+	// Keys and values, in slot order
+	var entries bytes.Buffer
+	enc := codecOrDefault(codec).NewEncoder(&entries)
+	for i := range c.nodes {
+		if err := enc.Encode(&c.nodes[i].Key); err != nil {
+			return fmt.Errorf("SieveCache: failed to encode key: %w", err)
+		}
+		if err := enc.Encode(&c.nodes[i].Value); err != nil {
+			return fmt.Errorf("SieveCache: failed to encode value: %w", err)
+		}
+	}
+	return writeSnapshotSection(w, entries.Bytes())
+}
+
+// writeSnapshotSection writes a length-prefixed, checksummed section.
+func writeSnapshotSection(w io.Writer, data []byte) error {
+	var length [8]byte
+	binary.LittleEndian.PutUint64(length[:], uint64(len(data)))
+	if _, err := w.Write(length[:]); err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	var checksum [4]byte
+	binary.LittleEndian.PutUint32(checksum[:], crc32.Checksum(data, crcTable))
+	_, err := w.Write(checksum[:])
+	return err
+}
+
+// readSnapshotSection reads and verifies a section written by writeSnapshotSection.
+func readSnapshotSection(r io.Reader) ([]byte, error) {
+	var length [8]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSnapshotCorrupt, err)
+	}
+
+	// Read incrementally so that a corrupted length can't trigger a huge allocation
+	n := binary.LittleEndian.Uint64(length[:])
+	var data bytes.Buffer
+	if n > 1<<62 {
+		return nil, fmt.Errorf("%w: invalid section length", ErrSnapshotCorrupt)
+	}
+	if _, err := io.CopyN(&data, r, int64(n)); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSnapshotCorrupt, err)
+	}
+
+	var checksum [4]byte
+	if _, err := io.ReadFull(r, checksum[:]); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSnapshotCorrupt, err)
+	}
+	if crc32.Checksum(data.Bytes(), crcTable) != binary.LittleEndian.Uint32(checksum[:]) {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrSnapshotCorrupt)
+	}
+	return data.Bytes(), nil
+}
+
+// LoadFromReader restores a cache from a snapshot written by SaveToWriter.
+// The same codec and encryption key must be used for saving and loading.
+// Returns an error wrapping ErrSnapshotFormat, ErrSnapshotVersion, ErrSnapshotCorrupt
+// or ErrSnapshotAuthentication if the snapshot can't be trusted.
+func LoadFromReader[K comparable, V any](r io.Reader, opts SnapshotOptions) (*SieveCache[K, V], error) {
+	br := bufio.NewReader(r)
+
+	header := make([]byte, snapshotHeaderSize)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSnapshotFormat, err)
+	}
+	if string(header[:len(snapshotMagic)]) != snapshotMagic {
+		return nil, ErrSnapshotFormat
+	}
+	if version := binary.LittleEndian.Uint16(header[len(snapshotMagic):]); version != snapshotVersion {
+		return nil, fmt.Errorf("%w: %d", ErrSnapshotVersion, version)
+	}
+	flags := binary.LittleEndian.Uint16(header[len(snapshotMagic)+2:])
+
+	var body io.Reader = br
+	encrypted := flags&snapshotFlagEncrypted != 0
+	switch {
+	case encrypted && opts.EncryptionKey == nil:
+		return nil, fmt.Errorf("%w: snapshot is encrypted but no key was provided", ErrSnapshotAuthentication)
+	case !encrypted && opts.EncryptionKey != nil:
+		return nil, fmt.Errorf("%w: snapshot is not encrypted", ErrSnapshotAuthentication)
+	case encrypted:
+		data, err := io.ReadAll(br)
+		if err != nil {
+			return nil, fmt.Errorf("SieveCache: failed to read snapshot: %w", err)
+		}
+		plaintext, err := openSnapshot(opts.EncryptionKey, data, header)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(plaintext)
+	}
+
+	meta, err := readSnapshotSection(body)
+	if err != nil {
+		return nil, err
+	}
+	if len(meta) != 24 {
+		return nil, fmt.Errorf("%w: invalid metadata section", ErrSnapshotCorrupt)
+	}
+	capacity := int64(binary.LittleEndian.Uint64(meta[0:]))
+	count := int64(binary.LittleEndian.Uint64(meta[8:]))
+	hand := int64(binary.LittleEndian.Uint64(meta[16:]))
+	if capacity < 0 || capacity > math.MaxInt || count < 0 || count > capacity || hand < -1 || hand >= max(count, 1) {
+		return nil, fmt.Errorf("%w: invalid metadata", ErrSnapshotCorrupt)
+	}
+
+	visited, err := readSnapshotSection(body)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(visited)) != 8*((count+63)>>6) {
+		return nil, fmt.Errorf("%w: invalid visited section", ErrSnapshotCorrupt)
+	}
+
+	entries, err := readSnapshotSection(body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Storage is only preallocated for the entries of the snapshot, so that
+	// a large capacity can't force a huge allocation before they are read
+	cache, err := NewWithOptions(Options[K, V]{Capacity: int(capacity), InitialSize: max(int(count), 1)})
+	if err != nil {
+		return nil, err
+	}
+
+	dec := codecOrDefault(opts.Codec).NewDecoder(bytes.NewReader(entries))
+	for i := 0; i < int(count); i++ {
+		var key K
+		var value V
+		if err := dec.Decode(&key); err != nil {
+			return nil, fmt.Errorf("SieveCache: failed to decode key: %w", err)
+		}
+		if err := dec.Decode(&value); err != nil {
+			return nil, fmt.Errorf("SieveCache: failed to decode value: %w", err)
+		}
+		if _, exists := cache.indices.get(key, cache.nodes); exists {
+			return nil, fmt.Errorf("%w: duplicate key", ErrSnapshotCorrupt)
+		}
+
+		node := NewNode(key, value)
+		node.version = cache.nextVersion()
+		cache.nodes = append(cache.nodes, node)
+		cache.indices.add(key, i)
+		word := binary.LittleEndian.Uint64(visited[8*(i>>6):])
+		cache.visited.Set(i, word&(1<<(i&0x3F)) != 0)
+	}
+
+	if hand >= 0 && count > 0 {
+		cache.hand = int(hand)
+		cache.handInitialized = true
+	}
+
+	return cache, nil
+}
+
+// SaveToWriter writes a snapshot of the cache to w while holding a read lock.
+func (c *SyncSieveCache[K, V]) SaveToWriter(w io.Writer, opts SnapshotOptions) error {
+	c.rlock()
+	defer c.runlock()
+	return c.cache.SaveToWriter(w, opts)
+}
+
+// LoadSyncFromReader restores a thread-safe cache from a snapshot written by SaveToWriter.
+func LoadSyncFromReader[K comparable, V any](r io.Reader, opts SnapshotOptions) (*SyncSieveCache[K, V], error) {
+	cache, err := LoadFromReader[K, V](r, opts)
+	if err != nil {
+		return nil, err
+	}
+	return FromSieveCache(cache), nil
+}
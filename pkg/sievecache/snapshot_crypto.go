@@ -0,0 +1,62 @@
+package sievecache
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// SnapshotKeySize is the required length in bytes of a snapshot encryption key.
+const SnapshotKeySize = 32
+
+// ErrSnapshotAuthentication is returned when an encrypted snapshot cannot be
+// decrypted, either because the key is wrong or because the data was tampered with.
+var ErrSnapshotAuthentication = errors.New("SieveCache: snapshot authentication failed")
+
+// newSnapshotAEAD creates an AES-256-GCM instance for the given key.
+func newSnapshotAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != SnapshotKeySize {
+		return nil, fmt.Errorf("SieveCache: snapshot key must be %d bytes, got %d", SnapshotKeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealSnapshot encrypts and authenticates plaintext along with the additional
+// data aad, returning nonce || ciphertext.
+func sealSnapshot(key, plaintext, aad []byte) ([]byte, error) {
+	aead, err := newSnapshotAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// A random nonce is used for every snapshot
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("SieveCache: failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+// openSnapshot verifies and decrypts data produced by sealSnapshot.
+func openSnapshot(key, data, aad []byte) ([]byte, error) {
+	aead, err := newSnapshotAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize()+aead.Overhead() {
+		return nil, ErrSnapshotAuthentication
+	}
+
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, aad)
+	if err != nil {
+		return nil, ErrSnapshotAuthentication
+	}
+	return plaintext, nil
+}
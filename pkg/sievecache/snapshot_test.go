@@ -0,0 +1,210 @@
+package sievecache
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+)
+
+type snapshotValue struct {
+	Name  string
+	Count int
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	codecs := map[string]Codec{
+		"default": nil,
+		"gob":     GobCodec{},
+		"json":    JSONCodec{},
+	}
+
+	for name, codec := range codecs {
+		t.Run(name, func(t *testing.T) {
+			cache, _ := New[string, snapshotValue](10)
+			for i := 0; i < 10; i++ {
+				cache.Insert(fmt.Sprintf("key%d", i), snapshotValue{Name: fmt.Sprintf("v%d", i), Count: i})
+			}
+			// Mark some entries as visited and move the hand
+			cache.Get("key3")
+			cache.Get("key7")
+			cache.Evict()
+
+			var buf bytes.Buffer
+			if err := cache.SaveToWriter(&buf, SnapshotOptions{Codec: codec}); err != nil {
+				t.Fatalf("Failed to save snapshot: %v", err)
+			}
+
+			restored, err := LoadFromReader[string, snapshotValue](&buf, SnapshotOptions{Codec: codec})
+			if err != nil {
+				t.Fatalf("Failed to load snapshot: %v", err)
+			}
+
+			if restored.Capacity() != cache.Capacity() {
+				t.Errorf("Expected capacity %d, got %d", cache.Capacity(), restored.Capacity())
+			}
+			if restored.Len() != cache.Len() {
+				t.Errorf("Expected length %d, got %d", cache.Len(), restored.Len())
+			}
+			// Compare without Get, which would mark entries as visited
+			restoredItems := restored.Items()
+			for i, item := range cache.Items() {
+				if restoredItems[i] != item {
+					t.Errorf("Expected %v at slot %d, got %v", item, i, restoredItems[i])
+				}
+			}
+
+			// Both caches must make the same eviction decisions
+			for i := 0; i < 3; i++ {
+				want, _ := cache.Evict()
+				got, _ := restored.Evict()
+				if want != got {
+					t.Errorf("Eviction %d: expected %v, got %v", i, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestSnapshotEmptyCache(t *testing.T) {
+	cache, _ := New[int, int](5)
+
+	var buf bytes.Buffer
+	if err := cache.SaveToWriter(&buf, SnapshotOptions{}); err != nil {
+		t.Fatalf("Failed to save snapshot: %v", err)
+	}
+
+	restored, err := LoadFromReader[int, int](&buf, SnapshotOptions{})
+	if err != nil {
+		t.Fatalf("Failed to load snapshot: %v", err)
+	}
+	if !restored.IsEmpty() || restored.Capacity() != 5 {
+		t.Errorf("Expected empty cache with capacity 5, got len=%d cap=%d", restored.Len(), restored.Capacity())
+	}
+}
+
+func TestSnapshotInvalidInput(t *testing.T) {
+	if _, err := LoadFromReader[string, int](bytes.NewReader(nil), SnapshotOptions{}); !errors.Is(err, ErrSnapshotFormat) {
+		t.Errorf("Expected ErrSnapshotFormat for empty input, got %v", err)
+	}
+	if _, err := LoadFromReader[string, int](bytes.NewReader([]byte("not a snapshot at all")), SnapshotOptions{}); !errors.Is(err, ErrSnapshotFormat) {
+		t.Errorf("Expected ErrSnapshotFormat for garbage input, got %v", err)
+	}
+
+	cache, _ := New[string, int](5)
+	cache.Insert("a", 1)
+	cache.Insert("b", 2)
+	var buf bytes.Buffer
+	if err := cache.SaveToWriter(&buf, SnapshotOptions{}); err != nil {
+		t.Fatalf("Failed to save snapshot: %v", err)
+	}
+	data := buf.Bytes()
+
+	// Unsupported version
+	badVersion := append([]byte(nil), data...)
+	badVersion[len(snapshotMagic)] = 99
+	if _, err := LoadFromReader[string, int](bytes.NewReader(badVersion), SnapshotOptions{}); !errors.Is(err, ErrSnapshotVersion) {
+		t.Errorf("Expected ErrSnapshotVersion, got %v", err)
+	}
+
+	// Truncation at any point after the header must be detected
+	for n := snapshotHeaderSize; n < len(data); n++ {
+		if _, err := LoadFromReader[string, int](bytes.NewReader(data[:n]), SnapshotOptions{}); !errors.Is(err, ErrSnapshotCorrupt) {
+			t.Fatalf("Expected ErrSnapshotCorrupt when truncated to %d bytes, got %v", n, err)
+		}
+	}
+
+	// Any flipped bit after the header must be detected
+	for i := snapshotHeaderSize; i < len(data); i++ {
+		corrupted := append([]byte(nil), data...)
+		corrupted[i] ^= 0x10
+		if _, err := LoadFromReader[string, int](bytes.NewReader(corrupted), SnapshotOptions{}); !errors.Is(err, ErrSnapshotCorrupt) {
+			t.Fatalf("Expected ErrSnapshotCorrupt with byte %d corrupted, got %v", i, err)
+		}
+	}
+}
+
+func TestSnapshotLargeCapacity(t *testing.T) {
+	// Storage for the capacity must not be preallocated on load
+	cache, _ := NewWithOptions(Options[string, int]{Capacity: math.MaxInt - 1, InitialSize: 1})
+	cache.Insert("a", 1)
+	var buf bytes.Buffer
+	if err := cache.SaveToWriter(&buf, SnapshotOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := LoadFromReader[string, int](&buf, SnapshotOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Capacity() != math.MaxInt-1 || cap(restored.nodes) > 1 {
+		t.Errorf("Expected capacity %d with storage for 1 entry, got %d and %d", math.MaxInt-1, restored.Capacity(), cap(restored.nodes))
+	}
+	if v, ok := restored.Get("a"); !ok || v != 1 {
+		t.Errorf("Expected 1, got %d (%v)", v, ok)
+	}
+}
+
+func TestSyncSnapshot(t *testing.T) {
+	cache, _ := NewSync[string, int](10)
+	cache.Insert("a", 1)
+	cache.Insert("b", 2)
+
+	var buf bytes.Buffer
+	if err := cache.SaveToWriter(&buf, SnapshotOptions{Codec: JSONCodec{}}); err != nil {
+		t.Fatalf("Failed to save snapshot: %v", err)
+	}
+
+	restored, err := LoadSyncFromReader[string, int](&buf, SnapshotOptions{Codec: JSONCodec{}})
+	if err != nil {
+		t.Fatalf("Failed to load snapshot: %v", err)
+	}
+	if val, ok := restored.Get("b"); !ok || val != 2 {
+		t.Errorf("Expected 2, got %v", val)
+	}
+}
+
+func TestEncryptedSnapshot(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, SnapshotKeySize)
+	cache, _ := New[string, string](10)
+	cache.Insert("token", "secret-token-value")
+
+	var buf bytes.Buffer
+	if err := cache.SaveToWriter(&buf, SnapshotOptions{EncryptionKey: key}); err != nil {
+		t.Fatalf("Failed to save snapshot: %v", err)
+	}
+	if bytes.Contains(buf.Bytes(), []byte("secret-token-value")) {
+		t.Error("Encrypted snapshot contains the plaintext value")
+	}
+	sealed := append([]byte(nil), buf.Bytes()...)
+
+	restored, err := LoadFromReader[string, string](bytes.NewReader(sealed), SnapshotOptions{EncryptionKey: key})
+	if err != nil {
+		t.Fatalf("Failed to load snapshot: %v", err)
+	}
+	if val, ok := restored.Get("token"); !ok || val != "secret-token-value" {
+		t.Errorf("Expected secret-token-value, got %v", val)
+	}
+
+	// Wrong key
+	wrongKey := bytes.Repeat([]byte{0x43}, SnapshotKeySize)
+	if _, err := LoadFromReader[string, string](bytes.NewReader(sealed), SnapshotOptions{EncryptionKey: wrongKey}); err != ErrSnapshotAuthentication {
+		t.Errorf("Expected ErrSnapshotAuthentication with wrong key, got %v", err)
+	}
+
+	// Tampered data
+	sealed[len(sealed)-1] ^= 1
+	if _, err := LoadFromReader[string, string](bytes.NewReader(sealed), SnapshotOptions{EncryptionKey: key}); err != ErrSnapshotAuthentication {
+		t.Errorf("Expected ErrSnapshotAuthentication with tampered data, got %v", err)
+	}
+
+	// Key mismatch between encrypted and plain snapshots
+	if _, err := LoadFromReader[string, string](bytes.NewReader(sealed), SnapshotOptions{}); !errors.Is(err, ErrSnapshotAuthentication) {
+		t.Errorf("Expected ErrSnapshotAuthentication without key, got %v", err)
+	}
+
+	// Invalid key size
+	if err := cache.SaveToWriter(&buf, SnapshotOptions{EncryptionKey: []byte("short")}); err == nil {
+		t.Error("Expected error with invalid key size")
+	}
+}
@@ -0,0 +1,129 @@
+package sievecache
+
+import "errors"
+
+// StateEntry is an entry of a State.
+type StateEntry[K comparable, V any] struct {
+	Key     K    `json:"key"`
+	Value   V    `json:"value"`
+	Visited bool `json:"visited"`
+}
+
+// State is the exact eviction state of a cache: its entries in slot order,
+// their visited flags and the hand position. It can be serialized, for
+// example as JSON, and imported into a cache to set up a deterministic
+// eviction order in tests.
+// The hand moves from its position towards slot 0, then wraps around to the
+// last slot. Policies other than PolicySIEVE keep extra state that is not
+// exported: imported entries start in their main segment.
+type State[K comparable, V any] struct {
+	Capacity int `json:"capacity"`
+	// Hand is the slot of the next eviction candidate, or -1 if the hand
+	// hasn't moved yet, in which case evictions start from the last slot
+	Hand    int                `json:"hand"`
+	Entries []StateEntry[K, V] `json:"entries"`
+}
+
+// ExportState returns the eviction state of the cache.
+// It can be called concurrently with lookups under a read lock.
+func (c *SieveCache[K, V]) ExportState() State[K, V] {
+	state := State[K, V]{
+		Capacity: c.capacity,
+		Hand:     -1,
+		Entries:  make([]StateEntry[K, V], len(c.nodes)),
+	}
+	if c.handInitialized {
+		state.Hand = c.hand
+	}
+	for i := range c.nodes {
+		state.Entries[i] = StateEntry[K, V]{
+			Key:     c.nodes[i].Key,
+			Value:   c.copyValue(c.nodes[i].Value),
+			Visited: c.visited.LoadWord(i>>6)&(1<<(i&0x3F)) != 0,
+		}
+	}
+	return state
+}
+
+// ImportState replaces the content of the cache with state, including its
+// capacity, so that entries are evicted in the same order as in the cache
+// the state was exported from. Values are not passed to BeforeInsert.
+// Returns an error, leaving the cache unchanged, if the state is
+// inconsistent or exceeds the cost bounds of the cache.
+func (c *SieveCache[K, V]) ImportState(state State[K, V]) error {
+	n := len(state.Entries)
+	if state.Capacity < 0 {
+		return errors.New("SieveCache: capacity must not be negative")
+	}
+	if n > state.Capacity {
+		return errors.New("SieveCache: state has more entries than its capacity")
+	}
+	if state.Hand < -1 || state.Hand >= max(n, 1) {
+		return errors.New("SieveCache: state has an invalid hand position")
+	}
+	seen := make(map[K]struct{}, n)
+	for _, entry := range state.Entries {
+		if _, dup := seen[entry.Key]; dup {
+			return errors.New("SieveCache: state has duplicate keys")
+		}
+		seen[entry.Key] = struct{}{}
+	}
+	var costs []int64
+	if c.sizer != nil {
+		costs = make([]int64, n)
+		var total int64
+		for i, entry := range state.Entries {
+			costs[i] = c.sizer(entry.Key, entry.Value)
+			total += costs[i]
+			if c.tooCostly(costs[i]) || (c.maxCost > 0 && total > c.maxCost) {
+				return ErrValueTooLarge
+			}
+		}
+	}
+
+	c.capacity = state.Capacity
+	c.reset(false)
+	for i, entry := range state.Entries {
+		node := NewNode(entry.Key, c.copyValue(entry.Value))
+		node.version = c.nextVersion()
+		c.nodes = append(c.nodes, node)
+		c.visited.Append(entry.Visited)
+		c.indices.add(entry.Key, i)
+		if c.keyIndex != nil {
+			c.keyIndex.add(entry.Key)
+		}
+		if c.sizer != nil {
+			c.costs = append(c.costs, costs[i])
+			c.cost += costs[i]
+		}
+		if c.policy != nil {
+			c.policy.flags = append(c.policy.flags, 0)
+		}
+	}
+	if state.Hand >= 0 && n > 0 {
+		c.hand = state.Hand
+		c.handInitialized = true
+	}
+	if c.policy != nil {
+		if c.policy.sketch != nil {
+			c.policy.sketch.EnsureCapacity(min(c.capacity, 1<<20))
+		}
+		c.shrinkWindow()
+	}
+	return nil
+}
+
+// ExportState returns the eviction state of the cache.
+func (c *SyncSieveCache[K, V]) ExportState() State[K, V] {
+	c.rlock()
+	defer c.runlock()
+	return c.cache.ExportState()
+}
+
+// ImportState replaces the content of the cache with state. See
+// SieveCache.ImportState.
+func (c *SyncSieveCache[K, V]) ImportState(state State[K, V]) error {
+	c.lock()
+	defer c.unlock()
+	return c.cache.ImportState(state)
+}
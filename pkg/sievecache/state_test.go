@@ -0,0 +1,83 @@
+package sievecache
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestImportState(t *testing.T) {
+	cache, _ := New[string, int](3)
+	err := cache.ImportState(State[string, int]{
+		Capacity: 3,
+		Hand:     1,
+		Entries: []StateEntry[string, int]{
+			{Key: "a", Value: 1},
+			{Key: "b", Value: 2, Visited: true},
+			{Key: "c", Value: 3},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.CheckInvariants(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The hand skips "b", which is visited, and evicts "a"
+	if value, ok := cache.Evict(); !ok || value != 1 {
+		t.Errorf("Expected to evict 1, got %v", value)
+	}
+	if !cache.ContainsKey("b") || !cache.ContainsKey("c") || cache.ContainsKey("a") {
+		t.Errorf("Unexpected keys after eviction: %v", cache.Keys())
+	}
+}
+
+func TestExportStateRoundTrip(t *testing.T) {
+	cache, _ := NewSync[string, int](4)
+	for i, key := range []string{"a", "b", "c", "d", "e"} {
+		cache.Insert(key, i)
+	}
+	cache.Get("c")
+
+	data, err := json.Marshal(cache.ExportState())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var state State[string, int]
+	if err := json.Unmarshal(data, &state); err != nil {
+		t.Fatal(err)
+	}
+	restored, _ := NewSync[string, int](1)
+	if err := restored.ImportState(state); err != nil {
+		t.Fatal(err)
+	}
+	if restored.Capacity() != 4 {
+		t.Errorf("Expected capacity 4, got %d", restored.Capacity())
+	}
+
+	for i := 0; i < 3; i++ {
+		want, _ := cache.cache.Evict()
+		got, _ := restored.cache.Evict()
+		if got != want {
+			t.Errorf("Eviction %d: expected %d, got %d", i, want, got)
+		}
+	}
+}
+
+func TestImportStateInvalid(t *testing.T) {
+	cache, _ := New[string, int](2)
+	cache.Insert("kept", 1)
+	invalid := []State[string, int]{
+		{Capacity: 1, Hand: -1, Entries: []StateEntry[string, int]{{Key: "a"}, {Key: "b"}}},
+		{Capacity: 2, Hand: 2, Entries: []StateEntry[string, int]{{Key: "a"}, {Key: "b"}}},
+		{Capacity: 2, Hand: -1, Entries: []StateEntry[string, int]{{Key: "a"}, {Key: "a"}}},
+	}
+	for i, state := range invalid {
+		if err := cache.ImportState(state); err == nil {
+			t.Errorf("Expected state %d to be rejected", i)
+		}
+	}
+	if !cache.ContainsKey("kept") {
+		t.Error("Expected a rejected state to leave the cache unchanged")
+	}
+}
@@ -0,0 +1,276 @@
+package sievecache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// statsBucketDuration is the period covered by each bucket of windowed statistics.
+const statsBucketDuration = time.Minute
+
+// statsBuckets is the number of buckets of windowed statistics: the current
+// one, and enough previous ones for the longest window.
+const statsBuckets = 16
+
+// HitCounts is a number of lookups that found an entry, and of lookups that didn't.
+type HitCounts struct {
+	Hits   uint64
+	Misses uint64
+}
+
+// HitRatio returns the fraction of lookups that found an entry, or 0 if there were no lookups.
+func (h HitCounts) HitRatio() float64 {
+	if h.Hits+h.Misses == 0 {
+		return 0
+	}
+	return float64(h.Hits) / float64(h.Hits+h.Misses)
+}
+
+// add returns the sum of h and other.
+func (h HitCounts) add(other HitCounts) HitCounts {
+	return HitCounts{Hits: h.Hits + other.Hits, Misses: h.Misses + other.Misses}
+}
+
+// Stats is a snapshot of the statistics of a cache.
+// Lookups are only counted when the cache was created with RecordStats set.
+type Stats struct {
+	// Lookups since the cache was created
+	HitCounts
+	// Entries evicted to make room for others
+	Evictions uint64
+	// Entries rejected by BeforeInsert, because of their cost, or by the
+	// doorkeeper or AdmissionRate. See SieveCache.Rejections.
+	Rejections uint64
+
+	// Lookups over sliding windows. They are counted per minute, and the
+	// oldest minute of a window is weighted by the fraction of it that is
+	// still in the window, so they are approximations.
+	LastMinute    HitCounts
+	Last5Minutes  HitCounts
+	Last15Minutes HitCounts
+
+	// Durations of operations, only recorded when the cache was created
+	// with RecordLatencies set. Durations don't include waiting for locks.
+	GetLatency    LatencyHistogram
+	InsertLatency LatencyHistogram
+	EvictLatency  LatencyHistogram
+
+	// Lock acquisitions of a SyncSieveCache or of the shards of a
+	// ShardedSieveCache that had to wait, and the total time spent waiting.
+	// They are always recorded. Frequent contention suggests switching to a
+	// ShardedSieveCache, or using more shards.
+	LockContentions uint64
+	LockWaitTime    time.Duration
+}
+
+// add returns the sum of s and other.
+func (s Stats) add(other Stats) Stats {
+	return Stats{
+		HitCounts:     s.HitCounts.add(other.HitCounts),
+		Evictions:     s.Evictions + other.Evictions,
+		Rejections:    s.Rejections + other.Rejections,
+		LastMinute:    s.LastMinute.add(other.LastMinute),
+		Last5Minutes:  s.Last5Minutes.add(other.Last5Minutes),
+		Last15Minutes: s.Last15Minutes.add(other.Last15Minutes),
+		GetLatency:    s.GetLatency.add(other.GetLatency),
+		InsertLatency: s.InsertLatency.add(other.InsertLatency),
+		EvictLatency:  s.EvictLatency.add(other.EvictLatency),
+
+		LockContentions: s.LockContentions + other.LockContentions,
+		LockWaitTime:    s.LockWaitTime + other.LockWaitTime,
+	}
+}
+
+// Delta returns the counters accumulated since prev was taken, so that
+// exporters can report interval counts rather than cumulative ones.
+// Counters and histograms that are lower than in prev were reset with
+// ResetStats, and are returned as they are. Windowed counts are those of s.
+func (s Stats) Delta(prev Stats) Stats {
+	s.Hits = counterDelta(s.Hits, prev.Hits)
+	s.Misses = counterDelta(s.Misses, prev.Misses)
+	s.Evictions = counterDelta(s.Evictions, prev.Evictions)
+	s.Rejections = counterDelta(s.Rejections, prev.Rejections)
+	s.GetLatency = s.GetLatency.delta(prev.GetLatency)
+	s.InsertLatency = s.InsertLatency.delta(prev.InsertLatency)
+	s.EvictLatency = s.EvictLatency.delta(prev.EvictLatency)
+	s.LockContentions = counterDelta(s.LockContentions, prev.LockContentions)
+	s.LockWaitTime = time.Duration(counterDelta(uint64(s.LockWaitTime), uint64(prev.LockWaitTime)))
+	return s
+}
+
+// counterDelta returns the increase of a counter since it was prev,
+// assuming that it was reset if it is now lower.
+func counterDelta(current, prev uint64) uint64 {
+	if current < prev {
+		return current
+	}
+	return current - prev
+}
+
+// statsRecorder counts lookups. Counters are updated atomically, so that
+// lookups can be recorded while only holding a read lock.
+type statsRecorder struct {
+	clock   Clock
+	hits    atomic.Uint64
+	misses  atomic.Uint64
+	buckets [statsBuckets]statsBucket
+}
+
+// statsBucket counts the lookups of a single period.
+type statsBucket struct {
+	// Index of the period since the Unix epoch
+	period atomic.Int64
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// newStatsRecorder creates a recorder using clock for windowed statistics.
+func newStatsRecorder(clock Clock) *statsRecorder {
+	return &statsRecorder{clock: clockOrDefault(clock)}
+}
+
+// record counts a lookup.
+func (r *statsRecorder) record(hit bool) {
+	period := r.clock.Now().UnixNano() / int64(statsBucketDuration)
+	bucket := r.bucket(period)
+	if old := bucket.period.Load(); old != period && bucket.period.CompareAndSwap(old, period) {
+		// Lookups recorded concurrently with the rotation may be lost
+		bucket.hits.Store(0)
+		bucket.misses.Store(0)
+	}
+	if hit {
+		r.hits.Add(1)
+		bucket.hits.Add(1)
+	} else {
+		r.misses.Add(1)
+		bucket.misses.Add(1)
+	}
+}
+
+// bucket returns the bucket for the given period.
+func (r *statsRecorder) bucket(period int64) *statsBucket {
+	return &r.buckets[(period%statsBuckets+statsBuckets)%statsBuckets]
+}
+
+// window returns the approximate number of lookups over the last n periods.
+func (r *statsRecorder) window(now time.Time, n int) HitCounts {
+	period := now.UnixNano() / int64(statsBucketDuration)
+	elapsed := float64(now.UnixNano()%int64(statsBucketDuration)) / float64(statsBucketDuration)
+
+	var hits, misses float64
+	for i := 0; i <= n; i++ {
+		bucket := r.bucket(period - int64(i))
+		if bucket.period.Load() != period-int64(i) {
+			continue
+		}
+		// The current period is partial, and only the remaining part of
+		// the oldest one is still in the window
+		weight := 1.0
+		if i == n {
+			weight = 1 - elapsed
+		}
+		hits += weight * float64(bucket.hits.Load())
+		misses += weight * float64(bucket.misses.Load())
+	}
+	return HitCounts{Hits: uint64(hits + 0.5), Misses: uint64(misses + 0.5)}
+}
+
+// fill sets the lookup counts of s.
+func (r *statsRecorder) fill(s *Stats) {
+	s.Hits = r.hits.Load()
+	s.Misses = r.misses.Load()
+	now := r.clock.Now()
+	s.LastMinute = r.window(now, 1)
+	s.Last5Minutes = r.window(now, 5)
+	s.Last15Minutes = r.window(now, 15)
+}
+
+// reset zeroes all the counters.
+func (r *statsRecorder) reset() {
+	r.hits.Store(0)
+	r.misses.Store(0)
+	for i := range r.buckets {
+		r.buckets[i].hits.Store(0)
+		r.buckets[i].misses.Store(0)
+	}
+}
+
+// clone returns an independent copy of the recorder.
+func (r *statsRecorder) clone() *statsRecorder {
+	clone := newStatsRecorder(r.clock)
+	clone.hits.Store(r.hits.Load())
+	clone.misses.Store(r.misses.Load())
+	for i := range r.buckets {
+		clone.buckets[i].period.Store(r.buckets[i].period.Load())
+		clone.buckets[i].hits.Store(r.buckets[i].hits.Load())
+		clone.buckets[i].misses.Store(r.buckets[i].misses.Load())
+	}
+	return clone
+}
+
+// recordLookup counts a lookup, if statistics are recorded.
+func (c *SieveCache[K, V]) recordLookup(hit bool) {
+	if c.stats != nil {
+		c.stats.record(hit)
+	}
+}
+
+// Stats returns the statistics of the cache.
+func (c *SieveCache[K, V]) Stats() Stats {
+	s := Stats{Evictions: c.evictions, Rejections: c.rejections}
+	if c.stats != nil {
+		c.stats.fill(&s)
+	}
+	if c.latencies != nil {
+		c.latencies.fill(&s)
+	}
+	return s
+}
+
+// ResetStats zeroes the counters reported by Stats.
+func (c *SieveCache[K, V]) ResetStats() {
+	c.evictions = 0
+	c.rejections = 0
+	if c.stats != nil {
+		c.stats.reset()
+	}
+	if c.latencies != nil {
+		c.latencies.reset()
+	}
+}
+
+// Stats returns the statistics of the cache.
+func (c *SyncSieveCache[K, V]) Stats() Stats {
+	c.rlock()
+	defer c.runlock()
+	s := c.cache.Stats()
+	s.LockContentions = c.lockContentions.Load()
+	s.LockWaitTime = time.Duration(c.lockWaitTime.Load())
+	return s
+}
+
+// ResetStats zeroes the counters reported by Stats.
+func (c *SyncSieveCache[K, V]) ResetStats() {
+	c.lock()
+	defer c.unlock()
+	c.cache.ResetStats()
+	c.lockContentions.Store(0)
+	c.lockWaitTime.Store(0)
+}
+
+// Stats returns the statistics of the cache, summed across shards.
+// Statistics of the previous shards are lost by Reshard.
+func (c *ShardedSieveCache[K, V]) Stats() Stats {
+	var s Stats
+	for _, shard := range c.table.Load().all() {
+		s = s.add(shard.Stats())
+	}
+	return s
+}
+
+// ResetStats zeroes the counters reported by Stats, shard by shard.
+func (c *ShardedSieveCache[K, V]) ResetStats() {
+	for _, shard := range c.table.Load().all() {
+		shard.ResetStats()
+	}
+}
@@ -0,0 +1,133 @@
+package sievecache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1700000000, 0).Truncate(time.Minute))
+	cache, _ := NewWithOptions(Options[string, int]{Capacity: 2, RecordStats: true, Clock: clock})
+	cache.Insert("a", 1)
+	cache.Get("a")
+	cache.Get("b")
+	cache.Insert("b", 2)
+	cache.Insert("c", 3)
+
+	stats := cache.Stats()
+	if stats.Hits != 1 || stats.Misses != 1 || stats.Evictions != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	if stats.HitRatio() != 0.5 {
+		t.Errorf("Expected a hit ratio of 0.5, got %v", stats.HitRatio())
+	}
+	if stats.LastMinute != stats.HitCounts || stats.Last15Minutes != stats.HitCounts {
+		t.Errorf("Expected all lookups in every window: %+v", stats)
+	}
+
+	// Lookups leave the windows as time passes
+	clock.Advance(2 * time.Minute)
+	for i := 0; i < 3; i++ {
+		cache.Get("c")
+	}
+	stats = cache.Stats()
+	if stats.LastMinute != (HitCounts{Hits: 3}) {
+		t.Errorf("Expected 3 hits over the last minute, got %+v", stats.LastMinute)
+	}
+	if stats.Last5Minutes != (HitCounts{Hits: 4, Misses: 1}) {
+		t.Errorf("Expected 4 hits and 1 miss over the last 5 minutes, got %+v", stats.Last5Minutes)
+	}
+
+	// Half of the oldest minute is still in the window
+	clock.Advance(time.Minute + 30*time.Second)
+	if last := cache.Stats().LastMinute; last.Hits != 2 {
+		t.Errorf("Expected about 2 hits over the last minute, got %+v", last)
+	}
+
+	clock.Advance(time.Hour)
+	stats = cache.Stats()
+	if stats.Last15Minutes != (HitCounts{}) || stats.Hits != 4 {
+		t.Errorf("Expected only lifetime counts to remain, got %+v", stats)
+	}
+}
+
+func TestShardedStats(t *testing.T) {
+	cache, _ := NewShardedWithOptions(Options[int, int]{Capacity: 100, RecordStats: true}, 4)
+	for i := 0; i < 10; i++ {
+		cache.Insert(i, i)
+	}
+	for i := 0; i < 20; i++ {
+		cache.Get(i)
+	}
+	cache.GetMut(0, func(v *int) { *v++ })
+
+	stats := cache.Stats()
+	if stats.Hits != 11 || stats.Misses != 10 {
+		t.Errorf("Expected 11 hits and 10 misses, got %+v", stats)
+	}
+	if stats.LastMinute.Hits != 11 {
+		t.Errorf("Expected 11 hits over the last minute, got %+v", stats.LastMinute)
+	}
+
+	// Lookups are not counted unless enabled
+	plain, _ := NewSync[int, int](10)
+	plain.Get(1)
+	if plain.Stats().Misses != 0 {
+		t.Error("Expected no lookups to be counted")
+	}
+}
+
+func TestStatsDeltaAndReset(t *testing.T) {
+	cache, _ := NewShardedWithOptions(Options[int, int]{Capacity: 4, RecordStats: true}, 2)
+	for i := 0; i < 10; i++ {
+		cache.Insert(i, i)
+		cache.Get(i)
+	}
+	first := cache.Stats()
+
+	cache.Get(100)
+	cache.Get(9)
+	delta := cache.Stats().Delta(first)
+	if delta.Hits != 1 || delta.Misses != 1 || delta.Evictions != 0 {
+		t.Errorf("Unexpected delta: %+v", delta)
+	}
+
+	cache.ResetStats()
+	stats := cache.Stats()
+	if stats != (Stats{}) {
+		t.Errorf("Expected zeroed stats, got %+v", stats)
+	}
+
+	// A reset counter is reported as is
+	cache.Get(9)
+	if delta := cache.Stats().Delta(first); delta.Hits != 1 {
+		t.Errorf("Expected 1 hit since the reset, got %+v", delta)
+	}
+	if err := cache.Rebalance(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLockContentionStats(t *testing.T) {
+	cache, _ := NewSync[int, int](100)
+
+	// Hold the lock so that the insert has to wait
+	cache.mutex.Lock()
+	done := make(chan struct{})
+	go func() {
+		cache.Insert(1, 1)
+		close(done)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cache.unlock()
+	<-done
+
+	stats := cache.Stats()
+	if stats.LockContentions != 1 || stats.LockWaitTime < 5*time.Millisecond {
+		t.Errorf("Expected a single wait of at least 5ms, got %d and %v", stats.LockContentions, stats.LockWaitTime)
+	}
+	cache.ResetStats()
+	if stats := cache.Stats(); stats.LockContentions != 0 || stats.LockWaitTime != 0 {
+		t.Error("Expected lock statistics to be reset")
+	}
+}
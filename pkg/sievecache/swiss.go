@@ -0,0 +1,193 @@
+package sievecache
+
+import "math/bits"
+
+// Control bytes of a Swiss table. Full slots store the low 7 bits of the
+// hash of their key, so their high bit is clear.
+const (
+	swissEmpty   uint8 = 0x80
+	swissDeleted uint8 = 0xfe
+)
+
+// swissGroupSize is the number of slots whose control bytes are matched at
+// once, as the bytes of a 64-bit word.
+const swissGroupSize = 8
+
+// Repeated bytes for SWAR operations on control words
+const (
+	swissLSB uint64 = 0x0101010101010101
+	swissMSB uint64 = 0x8080808080808080
+)
+
+// swissTable is a hash table of entry slots in the layout of a SwissTable:
+// slots are split into groups of 8, and a word of control bytes per group
+// tells which slots are free and holds 7 bits of the hash of their keys, so
+// that most mismatches are rejected without reading the slots.
+// Removals leave tombstones in groups that were full, so that lookups still
+// probe past them. Tombstones are compacted away by rehashing the table in
+// place when they would otherwise make it grow.
+type swissTable struct {
+	ctrl       []uint64
+	slots      []openEntry
+	groupMask  uint32
+	count      int
+	tombstones int
+}
+
+// newSwissTable creates a table with enough groups for n entries.
+func newSwissTable(n int) *swissTable {
+	groups := 1
+	if want := (n*8/7 + swissGroupSize - 1) / swissGroupSize; want > groups {
+		groups = 1 << bits.Len(uint(want-1))
+	}
+	return newSwissTableGroups(groups)
+}
+
+// newSwissTableGroups creates an empty table with the given number of
+// groups, which must be a power of two.
+func newSwissTableGroups(groups int) *swissTable {
+	t := &swissTable{
+		ctrl:      make([]uint64, groups),
+		slots:     make([]openEntry, groups*swissGroupSize),
+		groupMask: uint32(groups - 1),
+	}
+	for i := range t.ctrl {
+		t.ctrl[i] = swissLSB * uint64(swissEmpty)
+	}
+	return t
+}
+
+// swissMatch returns a mask with the high bit set in every byte of w equal
+// to b. It may report false positives after a true match, so candidates
+// must be checked.
+func swissMatch(w uint64, b uint8) uint64 {
+	x := w ^ (swissLSB * uint64(b))
+	return (x - swissLSB) &^ x & swissMSB
+}
+
+// swissMatchEmpty returns a mask with the high bit set in every empty byte of w.
+func swissMatchEmpty(w uint64) uint64 {
+	return w &^ (w << 6) & swissMSB
+}
+
+// swissMatchFree returns a mask with the high bit set in every empty or
+// deleted byte of w.
+func swissMatchFree(w uint64) uint64 {
+	return w & swissMSB
+}
+
+// maxLoad returns the number of used and deleted slots above which the
+// table is rehashed.
+func (t *swissTable) maxLoad() int {
+	return len(t.slots) * 7 / 8
+}
+
+// setCtrl sets the control byte of the slot at pos.
+func (t *swissTable) setCtrl(pos uint32, b uint8) {
+	shift := (pos % swissGroupSize) * 8
+	w := &t.ctrl[pos/swissGroupSize]
+	*w = *w&^(0xff<<shift) | uint64(b)<<shift
+}
+
+// find returns the position of the entry for slot, which must be present.
+func (t *swissTable) find(hash uint32, slot int) uint32 {
+	h2 := uint8(hash & 0x7f)
+	g := (hash >> 7) & t.groupMask
+	for step := uint32(1); ; step++ {
+		for m := swissMatch(t.ctrl[g], h2); m != 0; m &= m - 1 {
+			pos := g*swissGroupSize + uint32(bits.TrailingZeros64(m)/8)
+			if t.slots[pos].slot == uint32(slot)+1 {
+				return pos
+			}
+		}
+		g = (g + step) & t.groupMask
+	}
+}
+
+// add adds an entry for slot, whose key must not be present.
+func (t *swissTable) add(hash uint32, slot int) {
+	if t.count+t.tombstones+1 > t.maxLoad() {
+		if (t.count+1)*32 <= len(t.slots)*25 {
+			// Enough tombstones were left to compact them without growing
+			t.rehash(len(t.ctrl))
+		} else {
+			t.rehash(2 * len(t.ctrl))
+		}
+	}
+	t.insert(openEntry{hash: hash, slot: uint32(slot) + 1})
+	t.count++
+}
+
+// insert stores e in the first free slot of its probe sequence.
+func (t *swissTable) insert(e openEntry) {
+	g := (e.hash >> 7) & t.groupMask
+	for step := uint32(1); ; step++ {
+		if m := swissMatchFree(t.ctrl[g]); m != 0 {
+			pos := g*swissGroupSize + uint32(bits.TrailingZeros64(m)/8)
+			if uint8(t.ctrl[g]>>((pos%swissGroupSize)*8)) == swissDeleted {
+				t.tombstones--
+			}
+			t.setCtrl(pos, uint8(e.hash&0x7f))
+			t.slots[pos] = e
+			return
+		}
+		g = (g + step) & t.groupMask
+	}
+}
+
+// remove removes the entry for slot, which must be present.
+func (t *swissTable) remove(hash uint32, slot int) {
+	pos := t.find(hash, slot)
+	t.slots[pos] = openEntry{}
+	t.count--
+	// A group that still has an empty slot was never full, so no lookup
+	// probed past it, and the slot can be freed. Otherwise, lookups must
+	// keep probing past it.
+	if swissMatchEmpty(t.ctrl[pos/swissGroupSize]) != 0 {
+		t.setCtrl(pos, swissEmpty)
+	} else {
+		t.setCtrl(pos, swissDeleted)
+		t.tombstones++
+	}
+}
+
+// rehash moves the entries to a table with the given number of groups,
+// dropping the tombstones. Stored hashes avoid hashing keys again.
+func (t *swissTable) rehash(groups int) {
+	old := t.slots
+	*t = *newSwissTableGroups(groups)
+	for _, e := range old {
+		if e.slot != 0 {
+			t.insert(e)
+			t.count++
+		}
+	}
+}
+
+// clone returns an independent copy of the table.
+func (t *swissTable) clone() *swissTable {
+	clone := *t
+	clone.ctrl = append([]uint64(nil), t.ctrl...)
+	clone.slots = append([]openEntry(nil), t.slots...)
+	return &clone
+}
+
+// swissGet returns the slot of key among nodes.
+func swissGet[K comparable, V any](t *swissTable, key K, nodes []Node[K, V]) (int, bool) {
+	hash := uint32(hashKey(key))
+	h2 := uint8(hash & 0x7f)
+	g := (hash >> 7) & t.groupMask
+	for step := uint32(1); ; step++ {
+		w := t.ctrl[g]
+		for m := swissMatch(w, h2); m != 0; m &= m - 1 {
+			e := t.slots[g*swissGroupSize+uint32(bits.TrailingZeros64(m)/8)]
+			if e.hash == hash && e.slot != 0 && nodes[e.slot-1].Key == key {
+				return int(e.slot - 1), true
+			}
+		}
+		if swissMatchEmpty(w) != 0 {
+			return 0, false
+		}
+		g = (g + step) & t.groupMask
+	}
+}
@@ -1,7 +1,10 @@
 package sievecache
 
 import (
+	"context"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // SyncSieveCache is a thread-safe wrapper around SieveCache.
@@ -9,19 +12,37 @@ import (
 type SyncSieveCache[K comparable, V any] struct {
 	cache *SieveCache[K, V]
 	mutex sync.RWMutex
+	// Number of entries, published when the write lock is released, so that
+	// Len doesn't have to lock
+	length atomic.Int64
+	// Number of lock acquisitions that had to wait, and the total time spent waiting
+	lockContentions atomic.Uint64
+	lockWaitTime    atomic.Int64
 }
 
 // NewSync creates a new thread-safe cache with the given capacity.
 func NewSync[K comparable, V any](capacity int) (*SyncSieveCache[K, V], error) {
-	cache, err := New[K, V](capacity)
+	return NewSyncWithOptions(Options[K, V]{Capacity: capacity})
+}
+
+// MustNewSync is like NewSync, but panics if capacity is invalid.
+// It simplifies the initialization of package-level variables.
+func MustNewSync[K comparable, V any](capacity int) *SyncSieveCache[K, V] {
+	cache, err := NewSync[K, V](capacity)
+	if err != nil {
+		panic(err)
+	}
+	return cache
+}
+
+// NewSyncWithOptions creates a new thread-safe cache configured by opts.
+func NewSyncWithOptions[K comparable, V any](opts Options[K, V]) (*SyncSieveCache[K, V], error) {
+	cache, err := NewWithOptions(opts)
 	if err != nil {
 		return nil, err
 	}
 
-	return &SyncSieveCache[K, V]{
-		cache: cache,
-		mutex: sync.RWMutex{},
-	}, nil
+	return FromSieveCache(cache), nil
 }
 
 // DefaultSync creates a new thread-safe cache with a default capacity of 100.
@@ -36,54 +57,154 @@ func DefaultSync[K comparable, V any]() *SyncSieveCache[K, V] {
 
 // FromSieveCache creates a new thread-safe cache from an existing SieveCache.
 func FromSieveCache[K comparable, V any](cache *SieveCache[K, V]) *SyncSieveCache[K, V] {
-	return &SyncSieveCache[K, V]{
+	c := &SyncSieveCache[K, V]{
 		cache: cache,
 		mutex: sync.RWMutex{},
 	}
+	c.length.Store(int64(cache.Len()))
+	return c
+}
+
+// unlock releases the write lock, publishing the number of entries first.
+// Every method that takes the write lock must release it with unlock.
+func (c *SyncSieveCache[K, V]) unlock() {
+	c.length.Store(int64(len(c.cache.nodes)))
+	checkUnlock(c)
+	c.mutex.Unlock()
+}
+
+// runlock releases a read lock taken with rlock.
+func (c *SyncSieveCache[K, V]) runlock() {
+	checkUnlock(c)
+	c.mutex.RUnlock()
+}
+
+// lock takes the write lock, recording the time spent waiting for it.
+func (c *SyncSieveCache[K, V]) lock() {
+	checkLock(c, true)
+	if !c.mutex.TryLock() {
+		start := time.Now()
+		c.mutex.Lock()
+		c.recordLockWait(start)
+	}
+}
+
+// tryLock takes the write lock if it is available, and returns true if it did.
+func (c *SyncSieveCache[K, V]) tryLock() bool {
+	if !c.mutex.TryLock() {
+		return false
+	}
+	checkTryLock(c, true)
+	return true
+}
+
+// rlock takes a read lock, recording the time spent waiting for it.
+func (c *SyncSieveCache[K, V]) rlock() {
+	checkLock(c, false)
+	if !c.mutex.TryRLock() {
+		start := time.Now()
+		c.mutex.RLock()
+		c.recordLockWait(start)
+	}
+}
+
+// recordLockWait records a lock acquisition that started waiting at start.
+// Uncontended acquisitions are not recorded, so that they stay cheap.
+func (c *SyncSieveCache[K, V]) recordLockWait(start time.Time) {
+	c.lockContentions.Add(1)
+	c.lockWaitTime.Add(int64(time.Since(start)))
 }
 
 // Capacity returns the maximum number of entries the cache can hold.
 func (c *SyncSieveCache[K, V]) Capacity() int {
-	c.mutex.RLock()
-	defer c.mutex.RUnlock()
+	c.rlock()
+	defer c.runlock()
 	return c.cache.Capacity()
 }
 
+// SetCapacity changes the maximum number of entries the cache can hold,
+// evicting entries if needed.
+func (c *SyncSieveCache[K, V]) SetCapacity(capacity int) error {
+	c.lock()
+	defer c.unlock()
+	return c.cache.SetCapacity(capacity)
+}
+
+// Cost returns the total cost of the entries, as computed by the sizer.
+func (c *SyncSieveCache[K, V]) Cost() int64 {
+	c.rlock()
+	defer c.runlock()
+	return c.cache.Cost()
+}
+
+// MaxCost returns the bound on the total cost of the entries, or 0 if there is none.
+func (c *SyncSieveCache[K, V]) MaxCost() int64 {
+	c.rlock()
+	defer c.runlock()
+	return c.cache.MaxCost()
+}
+
+// Rejections returns the number of entries that were not stored.
+// See SieveCache.Rejections.
+func (c *SyncSieveCache[K, V]) Rejections() uint64 {
+	c.rlock()
+	defer c.runlock()
+	return c.cache.Rejections()
+}
+
+// SetMaxCost changes the bound on the total cost of the entries.
+// See SieveCache.SetMaxCost for details.
+func (c *SyncSieveCache[K, V]) SetMaxCost(maxCost int64) error {
+	c.lock()
+	defer c.unlock()
+	return c.cache.SetMaxCost(maxCost)
+}
+
 // Len returns the number of cached values.
+// It doesn't lock, and reflects all the operations that have completed.
 func (c *SyncSieveCache[K, V]) Len() int {
-	c.mutex.RLock()
-	defer c.mutex.RUnlock()
-	return c.cache.Len()
+	return int(c.length.Load())
 }
 
 // IsEmpty returns true when no values are currently cached.
+// It doesn't lock, and reflects all the operations that have completed.
 func (c *SyncSieveCache[K, V]) IsEmpty() bool {
-	c.mutex.RLock()
-	defer c.mutex.RUnlock()
-	return c.cache.IsEmpty()
+	return c.length.Load() == 0
 }
 
 // ContainsKey returns true if there is a value in the cache mapped to by key.
 func (c *SyncSieveCache[K, V]) ContainsKey(key K) bool {
-	c.mutex.RLock()
-	defer c.mutex.RUnlock()
+	c.rlock()
+	defer c.runlock()
 	return c.cache.ContainsKey(key)
 }
 
 // Get returns the value in the cache mapped to by key.
 // Unlike the unwrapped SieveCache, this returns a copy of the value
 // rather than a reference, since the mutex guard is released after this method returns.
+// Only a read lock is taken, since the visited flag is set atomically, so
+// concurrent lookups don't serialize.
+//
+// Lookups without any lock were considered and rejected. An immutable,
+// RCU-style index would have to be copied on every insertion, removal and
+// eviction, since these move entries between slots. Optimistic reads
+// validated by a sequence number would race with writers on the keys and
+// values themselves, which isn't allowed by the Go memory model for
+// arbitrary K and V. The read lock costs about 10ns when uncontended; when
+// it is contended, ShardedSieveCache, its hot key replicas and LocalCache
+// spread or avoid the lock traffic.
 func (c *SyncSieveCache[K, V]) Get(key K) (V, bool) {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
-	return c.cache.Get(key)
+	c.rlock()
+	defer c.runlock()
+	return c.cache.getShared(key)
 }
 
 // GetMut gets a mutable reference to the value in the cache mapped to by key via a callback function.
 // Returns true if the key exists and the callback was invoked, false otherwise.
+// The callback runs on a copy without holding the lock; if it panics, the value is left unchanged.
 func (c *SyncSieveCache[K, V]) GetMut(key K, f func(*V)) bool {
 	// First get a copy of the value to avoid holding the lock during callback
-	c.mutex.Lock()
+	c.lock()
 	var valueCopy V
 	var exists bool
 	ptr := c.cache.GetPointer(key)
@@ -91,7 +212,7 @@ func (c *SyncSieveCache[K, V]) GetMut(key K, f func(*V)) bool {
 		valueCopy = *ptr
 		exists = true
 	}
-	c.mutex.Unlock()
+	c.unlock()
 
 	if !exists {
 		return false
@@ -101,13 +222,13 @@ func (c *SyncSieveCache[K, V]) GetMut(key K, f func(*V)) bool {
 	f(&valueCopy)
 
 	// Update the value back in the cache
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
+	c.lock()
+	defer c.unlock()
 
-	// Check if the key still exists
-	ptr = c.cache.GetPointer(key)
-	if ptr != nil {
-		*ptr = valueCopy
+	// Check if the key still exists; it was already counted as a lookup
+	if idx, exists := c.cache.indices.get(key, c.cache.nodes); exists {
+		c.cache.nodes[idx].Value = valueCopy
+		c.cache.nodes[idx].version = c.cache.nextVersion()
 		return true
 	}
 
@@ -116,63 +237,251 @@ func (c *SyncSieveCache[K, V]) GetMut(key K, f func(*V)) bool {
 
 // Insert maps key to value in the cache, possibly evicting old entries.
 func (c *SyncSieveCache[K, V]) Insert(key K, value V) bool {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
+	c.lock()
+	defer c.unlock()
 	return c.cache.Insert(key, value)
 }
 
+// GetVersioned is like Get, but also returns the version of the value.
+// See SieveCache.GetVersioned.
+func (c *SyncSieveCache[K, V]) GetVersioned(key K) (V, uint64, bool) {
+	c.rlock()
+	defer c.runlock()
+	value, found := c.cache.getShared(key)
+	return value, c.cache.versionOf(key), found
+}
+
+// InsertIfVersion maps key to value only if the current version of its
+// value is version, as a single atomic operation.
+// See SieveCache.InsertIfVersion.
+func (c *SyncSieveCache[K, V]) InsertIfVersion(key K, value V, version uint64) bool {
+	c.lock()
+	defer c.unlock()
+	return c.cache.InsertIfVersion(key, value, version)
+}
+
+// TryInsert is like Insert, but also returns the reason a value was rejected.
+// See SieveCache.TryInsert.
+func (c *SyncSieveCache[K, V]) TryInsert(key K, value V) (bool, error) {
+	c.lock()
+	defer c.unlock()
+	return c.cache.TryInsert(key, value)
+}
+
+// Swap maps key to value, and returns the previous value of key and whether
+// it was present, as a single atomic operation.
+func (c *SyncSieveCache[K, V]) Swap(key K, value V) (V, bool) {
+	c.lock()
+	defer c.unlock()
+	return c.cache.Swap(key, value)
+}
+
 // Remove removes the cache entry mapped to by key.
 func (c *SyncSieveCache[K, V]) Remove(key K) (V, bool) {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
+	c.lock()
+	defer c.unlock()
 	return c.cache.Remove(key)
 }
 
 // Evict removes and returns a value from the cache that was not recently accessed.
 func (c *SyncSieveCache[K, V]) Evict() (V, bool) {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
+	c.lock()
+	defer c.unlock()
 	return c.cache.Evict()
 }
 
+// EvictAhead evicts entries until at least headroom slots are free, so that
+// subsequent inserts don't have to evict. The lock is released every
+// evictAheadBatchSize evictions to let other operations proceed.
+// Returns the number of evicted entries.
+func (c *SyncSieveCache[K, V]) EvictAhead(headroom int) int {
+	evicted := 0
+	for {
+		c.lock()
+		target := c.cache.capacity - min(headroom, c.cache.capacity)
+		batch := min(len(c.cache.nodes)-target, evictAheadBatchSize)
+		for i := 0; i < batch; i++ {
+			c.cache.Evict()
+		}
+		c.unlock()
+
+		if batch <= 0 {
+			return evicted
+		}
+		evicted += batch
+	}
+}
+
+// SetEnabled enables or disables the cache.
+// See SieveCache.SetEnabled for details.
+func (c *SyncSieveCache[K, V]) SetEnabled(enabled bool) {
+	c.lock()
+	defer c.unlock()
+	c.cache.SetEnabled(enabled)
+}
+
+// Enabled returns false if the cache was disabled with SetEnabled.
+func (c *SyncSieveCache[K, V]) Enabled() bool {
+	c.rlock()
+	defer c.runlock()
+	return c.cache.Enabled()
+}
+
 // Clear removes all entries from the cache.
 func (c *SyncSieveCache[K, V]) Clear() {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
+	c.lock()
+	defer c.unlock()
 	c.cache.Clear()
 }
 
+// ClearWithOptions removes all entries from the cache, optionally calling a
+// function for each removed entry and releasing the memory used by the cache.
+// The OnRemove callback is called after the lock has been released, so it can
+// safely access the cache.
+func (c *SyncSieveCache[K, V]) ClearWithOptions(opts ClearOptions[K, V]) {
+	c.lock()
+	nodes := c.cache.nodes
+	c.cache.reset(opts.ReleaseMemory)
+	c.unlock()
+
+	if opts.OnRemove != nil {
+		for _, node := range nodes {
+			opts.OnRemove(node.Key, node.Value)
+		}
+	}
+}
+
+// Drain atomically removes all entries from the cache and returns them.
+// This is useful for graceful shutdown flows that persist the remaining entries elsewhere.
+func (c *SyncSieveCache[K, V]) Drain() []Item[K, V] {
+	c.lock()
+	defer c.unlock()
+	return c.cache.Drain()
+}
+
 // Keys returns a slice of all keys in the cache.
 func (c *SyncSieveCache[K, V]) Keys() []K {
-	c.mutex.RLock()
-	defer c.mutex.RUnlock()
+	c.rlock()
+	defer c.runlock()
 	return c.cache.Keys()
 }
 
+// VisitedKeys returns up to limit keys of entries that were accessed since
+// the hand last passed them. See SieveCache.VisitedKeys for details.
+func (c *SyncSieveCache[K, V]) VisitedKeys(limit int) []K {
+	c.rlock()
+	defer c.runlock()
+	return c.cache.VisitedKeys(limit)
+}
+
 // Values returns a slice of all values in the cache.
 func (c *SyncSieveCache[K, V]) Values() []V {
-	c.mutex.RLock()
-	defer c.mutex.RUnlock()
+	c.rlock()
+	defer c.runlock()
 	return c.cache.Values()
 }
 
 // Items returns a slice of all key-value pairs in the cache.
-func (c *SyncSieveCache[K, V]) Items() []struct {
-	Key   K
-	Value V
-} {
-	c.mutex.RLock()
-	defer c.mutex.RUnlock()
+func (c *SyncSieveCache[K, V]) Items() []Item[K, V] {
+	c.rlock()
+	defer c.runlock()
 	return c.cache.Items()
 }
 
+// ItemsInEvictionOrder returns all key-value pairs in the order in which the
+// SIEVE hand considers them for eviction, starting with the current eviction candidate.
+func (c *SyncSieveCache[K, V]) ItemsInEvictionOrder() []Item[K, V] {
+	c.rlock()
+	defer c.runlock()
+	return c.cache.ItemsInEvictionOrder()
+}
+
+// Snapshot returns an independent, point-in-time copy of the cache.
+// The lock is only held while the internal structures are copied, which is much
+// faster than building slices of keys, values or items, so iterating over the
+// returned cache, even for millions of entries, doesn't stall writers.
+// The returned cache is not thread-safe and doesn't share any state with this one.
+func (c *SyncSieveCache[K, V]) Snapshot() *SieveCache[K, V] {
+	c.rlock()
+	defer c.runlock()
+	return c.cache.Clone()
+}
+
+// Clone returns an independent thread-safe copy of the cache, including
+// visited flags and hand position.
+func (c *SyncSieveCache[K, V]) Clone() *SyncSieveCache[K, V] {
+	return FromSieveCache(c.Snapshot())
+}
+
+// Merge copies all entries of other into this cache, possibly evicting entries.
+// See SieveCache.Merge for details.
+// A snapshot of other is taken first, so the two caches are never locked at the same time.
+func (c *SyncSieveCache[K, V]) Merge(other *SyncSieveCache[K, V], conflict func(key K, existing, incoming V) V) {
+	if other == c {
+		return
+	}
+	snapshot := other.Snapshot()
+
+	c.lock()
+	defer c.unlock()
+	c.cache.Merge(snapshot, conflict)
+}
+
+// ItemsChan streams all key-value pairs in the cache over the returned channel.
+// Entries are copied in batches of batchSize, and the lock is released between
+// batches so that exporting a huge cache doesn't block other operations.
+// As a consequence, iteration is weakly consistent: entries inserted or removed
+// concurrently may or may not be reported, and an entry may be missed if another
+// one is removed during iteration.
+// The channel is closed once all entries have been sent or ctx is done.
+func (c *SyncSieveCache[K, V]) ItemsChan(ctx context.Context, batchSize int) <-chan Item[K, V] {
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	ch := make(chan Item[K, V], batchSize)
+
+	go func() {
+		defer close(ch)
+		c.sendItems(ctx, ch, batchSize)
+	}()
+
+	return ch
+}
+
+// sendItems sends all items to ch in batches, releasing the lock between batches.
+// Returns false if ctx was canceled.
+func (c *SyncSieveCache[K, V]) sendItems(ctx context.Context, ch chan<- Item[K, V], batchSize int) bool {
+	batch := make([]Item[K, V], 0, batchSize)
+	for offset := 0; ; offset += batchSize {
+		// Copy the next batch under the read lock
+		batch = batch[:0]
+		c.rlock()
+		nodes := c.cache.nodes
+		for i := offset; i < len(nodes) && i < offset+batchSize; i++ {
+			batch = append(batch, Item[K, V]{Key: nodes[i].Key, Value: nodes[i].Value})
+		}
+		c.runlock()
+
+		if len(batch) == 0 {
+			return true
+		}
+		for _, item := range batch {
+			select {
+			case ch <- item:
+			case <-ctx.Done():
+				return false
+			}
+		}
+	}
+}
+
 // ForEachValue applies a function to all values in the cache.
 // The function receives and can modify a copy of each value, and changes will be saved back to the cache.
 func (c *SyncSieveCache[K, V]) ForEachValue(f func(*V)) {
 	// First collect all items under the read lock
-	c.mutex.RLock()
+	c.rlock()
 	items := c.cache.Items()
-	c.mutex.RUnlock()
+	c.runlock()
 
 	// Process each value without holding the lock
 	// Pre-allocate map with the expected size to prevent resizing
@@ -184,8 +493,8 @@ func (c *SyncSieveCache[K, V]) ForEachValue(f func(*V)) {
 	}
 
 	// Update any changed values back to the cache
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
+	c.lock()
+	defer c.unlock()
 	for k, v := range updatedItems {
 		if c.cache.ContainsKey(k) {
 			c.cache.Insert(k, v)
@@ -197,9 +506,9 @@ func (c *SyncSieveCache[K, V]) ForEachValue(f func(*V)) {
 // The function receives the key and can modify a copy of each value, and changes will be saved back to the cache.
 func (c *SyncSieveCache[K, V]) ForEachEntry(f func(K, *V)) {
 	// First collect all items under the read lock
-	c.mutex.RLock()
+	c.rlock()
 	items := c.cache.Items()
-	c.mutex.RUnlock()
+	c.runlock()
 
 	// Process each entry without holding the lock
 	// Pre-allocate map with the expected size to prevent resizing
@@ -211,8 +520,8 @@ func (c *SyncSieveCache[K, V]) ForEachEntry(f func(K, *V)) {
 	}
 
 	// Update any changed values back to the cache
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
+	c.lock()
+	defer c.unlock()
 	for k, v := range updatedItems {
 		if c.cache.ContainsKey(k) {
 			c.cache.Insert(k, v)
@@ -222,9 +531,10 @@ func (c *SyncSieveCache[K, V]) ForEachEntry(f func(K, *V)) {
 
 // WithLock gets exclusive access to the underlying cache to perform multiple operations atomically.
 // This is useful when you need to perform a series of operations that depend on each other.
+// If f panics, the lock is released and the operations it already performed are kept.
 func (c *SyncSieveCache[K, V]) WithLock(f func(*SieveCache[K, V])) {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
+	c.lock()
+	defer c.unlock()
 	f(c.cache)
 }
 
@@ -232,9 +542,9 @@ func (c *SyncSieveCache[K, V]) WithLock(f func(*SieveCache[K, V])) {
 // Removes all entries for which f returns false.
 func (c *SyncSieveCache[K, V]) Retain(f func(K, V) bool) {
 	// First collect all items under the read lock
-	c.mutex.RLock()
+	c.rlock()
 	items := c.cache.Items()
-	c.mutex.RUnlock()
+	c.runlock()
 
 	// Estimate number of elements to remove - pre-allocate with a reasonable capacity
 	estimatedRemoveCount := len(items) / 4 // Assume about 25% will be removed
@@ -254,8 +564,8 @@ func (c *SyncSieveCache[K, V]) Retain(f func(K, V) bool) {
 	}
 
 	// Remove entries that don't match the predicate
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
+	c.lock()
+	defer c.unlock()
 	for _, key := range keysToRemove {
 		c.cache.Remove(key)
 	}
@@ -265,9 +575,9 @@ func (c *SyncSieveCache[K, V]) Retain(f func(K, V) bool) {
 // then removes them in a single batch operation with a single lock acquisition.
 func (c *SyncSieveCache[K, V]) RetainBatch(f func(K, V) bool) {
 	// First collect all items under the read lock
-	c.mutex.RLock()
+	c.rlock()
 	items := c.cache.Items()
-	c.mutex.RUnlock()
+	c.runlock()
 
 	// Estimate number of elements to remove - pre-allocate with a reasonable capacity
 	estimatedRemoveCount := len(items) / 4 // Assume about 25% will be removed
@@ -288,17 +598,33 @@ func (c *SyncSieveCache[K, V]) RetainBatch(f func(K, V) bool) {
 
 	// If there are keys to remove, do it in a single batch operation
 	if len(keysToRemove) > 0 {
-		c.mutex.Lock()
-		defer c.mutex.Unlock()
+		c.lock()
+		defer c.unlock()
 		for _, key := range keysToRemove {
 			c.cache.Remove(key)
 		}
 	}
 }
 
+// FillRatio returns the number of entries divided by the capacity, or 0 for
+// a null or Unbounded cache.
+func (c *SyncSieveCache[K, V]) FillRatio() float64 {
+	c.rlock()
+	defer c.runlock()
+	return c.cache.FillRatio()
+}
+
+// VisitedRatio returns the fraction of the entries that were accessed since
+// the hand last passed them, or 0 if the cache is empty.
+func (c *SyncSieveCache[K, V]) VisitedRatio() float64 {
+	c.rlock()
+	defer c.runlock()
+	return c.cache.VisitedRatio()
+}
+
 // RecommendedCapacity analyzes the current cache utilization and recommends a new capacity.
 func (c *SyncSieveCache[K, V]) RecommendedCapacity(minFactor, maxFactor, lowThreshold, highThreshold float64) int {
-	c.mutex.RLock()
-	defer c.mutex.RUnlock()
+	c.rlock()
+	defer c.runlock()
 	return c.cache.RecommendedCapacity(minFactor, maxFactor, lowThreshold, highThreshold)
 }
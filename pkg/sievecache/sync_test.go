@@ -1,6 +1,8 @@
 package sievecache
 
 import (
+	"context"
+	"io"
 	"sync"
 	"testing"
 	"time"
@@ -248,3 +250,390 @@ func TestDeadlockPrevention(t *testing.T) {
 		t.Errorf("Expected 4, got %v", val)
 	}
 }
+
+func TestSyncSnapshotIsIndependent(t *testing.T) {
+	cache, _ := NewSync[string, int](10)
+	cache.Insert("a", 1)
+	cache.Insert("b", 2)
+	cache.Get("a")
+
+	snapshot := cache.Snapshot()
+
+	// Changes to the original are not visible in the snapshot
+	cache.Insert("c", 3)
+	cache.Remove("a")
+	if snapshot.Len() != 2 || !snapshot.ContainsKey("a") || snapshot.ContainsKey("c") {
+		t.Errorf("Snapshot was affected by changes to the original: %v", snapshot.Keys())
+	}
+
+	// Changes to the snapshot are not visible in the original
+	snapshot.Insert("d", 4)
+	if cache.ContainsKey("d") {
+		t.Error("Original was affected by changes to the snapshot")
+	}
+
+	// Visited flags are preserved: "b" is evicted before "a"
+	snapshot.Remove("d")
+	if val, ok := snapshot.Evict(); !ok || val != 2 {
+		t.Errorf("Expected unvisited value 2 to be evicted, got %v", val)
+	}
+}
+
+func TestSyncSnapshotConcurrent(t *testing.T) {
+	cache, _ := NewSync[int, int](1000)
+	for i := 0; i < 1000; i++ {
+		cache.Insert(i, i)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			cache.Insert(1000+i, i)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 10; i++ {
+			snapshot := snapshotLen(cache)
+			if snapshot > 1000 {
+				t.Errorf("Snapshot exceeds capacity: %d", snapshot)
+			}
+		}
+	}()
+	wg.Wait()
+}
+
+// snapshotLen iterates over a snapshot of the cache and returns the number of entries.
+func snapshotLen(cache *SyncSieveCache[int, int]) int {
+	n := 0
+	cache.Snapshot().ForEach(func(k, v int) {
+		n++
+	})
+	return n
+}
+
+func TestSyncItemsChan(t *testing.T) {
+	cache, _ := NewSync[int, int](100)
+	for i := 0; i < 100; i++ {
+		cache.Insert(i, i*10)
+	}
+
+	seen := make(map[int]bool)
+	for item := range cache.ItemsChan(context.Background(), 7) {
+		if item.Value != item.Key*10 {
+			t.Errorf("Unexpected value %d for key %d", item.Value, item.Key)
+		}
+		seen[item.Key] = true
+	}
+	if len(seen) != 100 {
+		t.Errorf("Expected 100 items, got %d", len(seen))
+	}
+
+	// The lock is not held between batches, so the cache can be modified while iterating
+	for item := range cache.ItemsChan(context.Background(), 10) {
+		cache.Insert(item.Key, item.Value+1)
+	}
+
+	// Cancellation closes the channel
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := cache.ItemsChan(ctx, 1)
+	<-ch
+	cancel()
+	for range ch {
+	}
+}
+
+func TestSyncCloneAndMerge(t *testing.T) {
+	a, _ := NewSync[string, int](10)
+	a.Insert("a", 1)
+
+	clone := a.Clone()
+	clone.Insert("b", 2)
+	if a.ContainsKey("b") {
+		t.Error("Original was affected by changes to the clone")
+	}
+
+	a.Merge(clone, nil)
+	if a.Len() != 2 {
+		t.Errorf("Expected 2 entries after merge, got %d", a.Len())
+	}
+
+	// Merging a cache into itself is a no-op
+	a.Merge(a, nil)
+	if a.Len() != 2 {
+		t.Errorf("Expected 2 entries after self-merge, got %d", a.Len())
+	}
+}
+
+func TestSyncDrain(t *testing.T) {
+	cache, _ := NewSync[string, int](10)
+	cache.Insert("a", 1)
+	cache.Insert("b", 2)
+
+	total := 0
+	for _, item := range cache.Drain() {
+		total += item.Value
+	}
+	if total != 3 {
+		t.Errorf("Expected drained values to sum to 3, got %d", total)
+	}
+	if !cache.IsEmpty() {
+		t.Error("Expected empty cache after drain")
+	}
+}
+
+func TestSyncClearWithOptions(t *testing.T) {
+	cache, _ := NewSync[string, int](10)
+	cache.Insert("a", 1)
+	cache.Insert("b", 2)
+
+	count := 0
+	cache.ClearWithOptions(ClearOptions[string, int]{
+		OnRemove: func(key string, value int) {
+			// The lock is not held during callbacks
+			cache.ContainsKey(key)
+			count++
+		},
+	})
+	if count != 2 {
+		t.Errorf("Expected 2 removal callbacks, got %d", count)
+	}
+	if !cache.IsEmpty() {
+		t.Error("Expected empty cache")
+	}
+}
+
+func TestSyncConcurrentGetsUnderReadLock(t *testing.T) {
+	cache, _ := NewSync[int, int](1000)
+	for i := 0; i < 1000; i++ {
+		cache.Insert(i, i)
+	}
+
+	// Lookups run concurrently with each other and with the other readers
+	// that look at visited flags; the race detector checks that the flags
+	// are accessed atomically
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				if v, ok := cache.Get((i * (g + 1)) % 1000); !ok || v != (i*(g+1))%1000 {
+					t.Errorf("Unexpected value %d (%v)", v, ok)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 10; i++ {
+			cache.Snapshot()
+			cache.RecommendedCapacity(0.5, 2.0, 0.3, 0.7)
+			cache.SaveToWriter(io.Discard, SnapshotOptions{})
+		}
+	}()
+	wg.Wait()
+
+	// Every entry was visited
+	if n := cache.Snapshot().visited.CountSetBits(); n != 1000 {
+		t.Errorf("Expected 1000 visited entries, got %d", n)
+	}
+}
+
+func TestSyncLenDoesNotLock(t *testing.T) {
+	cache, _ := NewSync[int, int](100)
+	cache.Insert(1, 1)
+
+	cache.WithLock(func(inner *SieveCache[int, int]) {
+		inner.Insert(2, 2)
+		inner.Insert(3, 3)
+
+		// Len doesn't wait for the lock, and still reports the last
+		// published length
+		done := make(chan int)
+		go func() { done <- cache.Len() }()
+		select {
+		case n := <-done:
+			if n != 1 {
+				t.Errorf("Expected 1 entry while locked, got %d", n)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Len blocked on the lock")
+		}
+	})
+
+	if cache.Len() != 3 || cache.IsEmpty() {
+		t.Errorf("Expected 3 entries once unlocked, got %d", cache.Len())
+	}
+	cache.Clear()
+	if cache.Len() != 0 || !cache.IsEmpty() {
+		t.Errorf("Expected an empty cache, got %d entries", cache.Len())
+	}
+
+	restored := FromSieveCache(cache.Snapshot())
+	if restored.Len() != 0 {
+		t.Errorf("Expected 0 entries, got %d", restored.Len())
+	}
+	inner, _ := New[int, int](10)
+	inner.Insert(1, 1)
+	if FromSieveCache(inner).Len() != 1 {
+		t.Error("Expected FromSieveCache to publish the initial length")
+	}
+}
+
+func TestSyncCallbackPanics(t *testing.T) {
+	cache, _ := NewSync[int, int](100)
+	for i := 0; i < 10; i++ {
+		cache.Insert(i, i)
+	}
+
+	mustPanic := func(name string, f func()) {
+		t.Helper()
+		defer func() {
+			if recover() == nil {
+				t.Errorf("%s: expected the panic to be propagated", name)
+			}
+		}()
+		f()
+	}
+
+	mustPanic("GetMut", func() {
+		cache.GetMut(1, func(value *int) {
+			*value = 100
+			panic("boom")
+		})
+	})
+	mustPanic("ForEachEntry", func() {
+		cache.ForEachEntry(func(key int, value *int) {
+			*value = -1
+			if key == 5 {
+				panic("boom")
+			}
+		})
+	})
+	mustPanic("Retain", func() {
+		cache.Retain(func(key, _ int) bool {
+			if key == 5 {
+				panic("boom")
+			}
+			return false
+		})
+	})
+	mustPanic("WithLock", func() {
+		cache.WithLock(func(c *SieveCache[int, int]) {
+			c.Insert(10, 10)
+			panic("boom")
+		})
+	})
+	mustPanic("ClearWithOptions", func() {
+		cache.Clone().ClearWithOptions(ClearOptions[int, int]{
+			OnRemove: func(int, int) { panic("boom") },
+		})
+	})
+
+	// Changes made by callbacks that panicked are discarded, except for
+	// those made under WithLock, and the lock was released
+	for i := 0; i <= 10; i++ {
+		if value, ok := cache.Get(i); !ok || value != i {
+			t.Errorf("Expected %d to map to itself, got %d, %v", i, value, ok)
+		}
+	}
+	if cache.Len() != 11 {
+		t.Errorf("Expected 11 entries, got %d", cache.Len())
+	}
+	cache.Insert(11, 11)
+	if !cache.ContainsKey(11) {
+		t.Error("Expected the cache to remain usable after panics")
+	}
+}
+
+func TestSyncSwap(t *testing.T) {
+	cache, _ := NewSync[string, int](10)
+	if _, loaded := cache.Swap("key", 0); loaded {
+		t.Error("Expected no previous value")
+	}
+
+	// Every swapped value is returned exactly once, or is the final value
+	const goroutines, swaps = 8, 1000
+	var wg sync.WaitGroup
+	previous := make(chan int, goroutines*swaps)
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 1; i <= swaps; i++ {
+				old, loaded := cache.Swap("key", g*swaps+i)
+				if !loaded {
+					t.Error("Expected a previous value")
+				}
+				previous <- old
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(previous)
+
+	seen := make(map[int]bool)
+	for v := range previous {
+		if seen[v] {
+			t.Fatalf("Value %d was swapped out twice", v)
+		}
+		seen[v] = true
+	}
+	final, _ := cache.Get("key")
+	if seen[final] || len(seen) != goroutines*swaps {
+		t.Errorf("Expected every value but the final one %d to be swapped out once, got %d", final, len(seen))
+	}
+}
+
+func TestInsertIfVersion(t *testing.T) {
+	cache, _ := NewSync[string, int](10)
+	if cache.InsertIfVersion("key", 1, 42) {
+		t.Error("Expected a missing key to only match version 0")
+	}
+	if !cache.InsertIfVersion("key", 1, 0) {
+		t.Fatal("Expected version 0 to match a missing key")
+	}
+
+	value, version, found := cache.GetVersioned("key")
+	if !found || value != 1 || version == 0 {
+		t.Fatalf("Unexpected versioned value %d, %d, %v", value, version, found)
+	}
+	cache.GetMut("key", func(v *int) { *v++ })
+	if cache.InsertIfVersion("key", 10, version) {
+		t.Error("Expected a modified value not to match its old version")
+	}
+
+	// Removing and inserting the key again doesn't reuse versions
+	_, version, _ = cache.GetVersioned("key")
+	cache.Remove("key")
+	cache.Insert("key", 3)
+	if _, newVersion, _ := cache.GetVersioned("key"); newVersion <= version {
+		t.Errorf("Expected version %d to be greater than %d", newVersion, version)
+	}
+
+	// Concurrent read-modify-write cycles never lose an increment
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				for {
+					value, version, _ := cache.GetVersioned("key")
+					if cache.InsertIfVersion("key", value+1, version) {
+						break
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if value, _ := cache.Get("key"); value != 803 {
+		t.Errorf("Expected 803, got %d", value)
+	}
+}
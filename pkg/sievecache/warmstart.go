@@ -0,0 +1,80 @@
+package sievecache
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// errSnapshotStale is returned internally when a snapshot exceeds WarmStartOptions.MaxAge.
+var errSnapshotStale = errors.New("SieveCache: snapshot is too old")
+
+// WarmStartOptions configures LoadOrNew.
+type WarmStartOptions struct {
+	SnapshotOptions
+	// MaxAge discards snapshots whose file is older than this duration.
+	// Zero means snapshots are used regardless of their age.
+	MaxAge time.Duration
+	// Clock is used to compute the snapshot age. Defaults to the system clock.
+	Clock Clock
+	// Logger, if set, reports snapshots that exist but can't be used, for
+	// example because they are corrupt or too old.
+	Logger *slog.Logger
+}
+
+// LoadOrNew restores a cache from the snapshot at path if it is present, valid
+// and not older than opts.MaxAge, or creates a fresh cache otherwise.
+// The returned cache always has the requested capacity: if the snapshot was
+// taken with a larger capacity, entries are evicted to fit.
+// Returns an error only if the capacity is negative.
+func LoadOrNew[K comparable, V any](path string, capacity int, opts WarmStartOptions) (*SieveCache[K, V], error) {
+	if capacity < 0 {
+		return nil, errors.New("SieveCache: capacity must not be negative")
+	}
+
+	cache, err := loadSnapshotFile[K, V](path, opts)
+	if err != nil {
+		if opts.Logger != nil && !errors.Is(err, fs.ErrNotExist) {
+			opts.Logger.Warn("SieveCache: ignoring snapshot", "path", path, "error", err)
+		}
+		return New[K, V](capacity)
+	}
+
+	// Honor the requested capacity if it changed since the snapshot was taken
+	if err := cache.SetCapacity(capacity); err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
+
+// LoadOrNewSync is like LoadOrNew, but returns a thread-safe cache.
+func LoadOrNewSync[K comparable, V any](path string, capacity int, opts WarmStartOptions) (*SyncSieveCache[K, V], error) {
+	cache, err := LoadOrNew[K, V](path, capacity, opts)
+	if err != nil {
+		return nil, err
+	}
+	return FromSieveCache(cache), nil
+}
+
+// loadSnapshotFile loads a snapshot from path, checking its age first.
+func loadSnapshotFile[K comparable, V any](path string, opts WarmStartOptions) (*SieveCache[K, V], error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if opts.MaxAge > 0 {
+		info, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+		if clockOrDefault(opts.Clock).Now().Sub(info.ModTime()) > opts.MaxAge {
+			return nil, errSnapshotStale
+		}
+	}
+
+	return LoadFromReader[K, V](f, opts.SnapshotOptions)
+}
@@ -0,0 +1,101 @@
+package sievecache
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestSnapshot saves a cache with the given number of entries to path.
+func writeTestSnapshot(t *testing.T, path string, capacity, count int) {
+	t.Helper()
+	cache, _ := New[string, int](capacity)
+	for i := 0; i < count; i++ {
+		cache.Insert(fmt.Sprintf("key%d", i), i)
+	}
+	p := NewPersister(cache, path, 0, SnapshotOptions{})
+	if err := p.Close(); err != nil {
+		t.Fatalf("Failed to write snapshot: %v", err)
+	}
+}
+
+func TestLoadOrNew(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cache.snap")
+
+	// Missing file: fresh cache
+	cache, err := LoadOrNew[string, int](path, 10, WarmStartOptions{})
+	if err != nil {
+		t.Fatalf("LoadOrNew failed: %v", err)
+	}
+	if !cache.IsEmpty() || cache.Capacity() != 10 {
+		t.Errorf("Expected fresh cache, got len=%d cap=%d", cache.Len(), cache.Capacity())
+	}
+
+	// Valid file: restored cache
+	writeTestSnapshot(t, path, 10, 5)
+	cache, _ = LoadOrNew[string, int](path, 10, WarmStartOptions{})
+	if cache.Len() != 5 {
+		t.Errorf("Expected 5 restored entries, got %d", cache.Len())
+	}
+
+	// Smaller capacity: entries are evicted to fit
+	cache, _ = LoadOrNew[string, int](path, 3, WarmStartOptions{})
+	if cache.Len() != 3 || cache.Capacity() != 3 {
+		t.Errorf("Expected 3 entries with capacity 3, got len=%d cap=%d", cache.Len(), cache.Capacity())
+	}
+
+	// Corrupted file: fresh cache
+	if err := os.WriteFile(path, []byte("garbage"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cache, err = LoadOrNew[string, int](path, 10, WarmStartOptions{})
+	if err != nil {
+		t.Fatalf("LoadOrNew failed: %v", err)
+	}
+	if !cache.IsEmpty() {
+		t.Errorf("Expected fresh cache for corrupted snapshot, got %d entries", cache.Len())
+	}
+
+	if _, err := LoadOrNew[string, int](path, -1, WarmStartOptions{}); err == nil {
+		t.Error("Expected error for invalid capacity")
+	}
+}
+
+func TestLoadOrNewMaxAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.snap")
+	writeTestSnapshot(t, path, 10, 5)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := NewFakeClock(info.ModTime().Add(time.Minute))
+
+	var log bytes.Buffer
+	opts := WarmStartOptions{MaxAge: time.Hour, Clock: clock, Logger: slog.New(slog.NewTextHandler(&log, nil))}
+	cache, _ := LoadOrNewSync[string, int](path, 10, opts)
+	if cache.Len() != 5 {
+		t.Errorf("Expected fresh snapshot to be restored, got %d entries", cache.Len())
+	}
+
+	clock.Advance(2 * time.Hour)
+	cache, _ = LoadOrNewSync[string, int](path, 10, opts)
+	if !cache.IsEmpty() {
+		t.Errorf("Expected stale snapshot to be discarded, got %d entries", cache.Len())
+	}
+	if !bytes.Contains(log.Bytes(), []byte("snapshot is too old")) {
+		t.Errorf("Expected the stale snapshot to be logged, got %q", log.String())
+	}
+
+	// A missing snapshot is expected on the first start, and isn't logged
+	log.Reset()
+	LoadOrNew[string, int](filepath.Join(t.TempDir(), "missing"), 10, opts)
+	if log.Len() != 0 {
+		t.Errorf("Expected nothing to be logged, got %q", log.String())
+	}
+}
@@ -0,0 +1,189 @@
+package sievecache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"slices"
+	"sync"
+)
+
+// warmupResult is a value loaded by Warmup.
+type warmupResult[K comparable, V any] struct {
+	key   K
+	value V
+	err   error
+}
+
+// warmup loads the values of keys with up to parallelism concurrent calls to
+// loader, and passes them to insert from the calling goroutine.
+func warmup[K comparable, V any](ctx context.Context, keys []K, loader func(ctx context.Context, key K) (V, error), parallelism int, insert func(key K, value V)) error {
+	if parallelism <= 0 {
+		parallelism = runtime.GOMAXPROCS(0)
+	}
+	parallelism = max(1, min(parallelism, len(keys)))
+
+	todo := make(chan K)
+	results := make(chan warmupResult[K, V])
+	var wg sync.WaitGroup
+	for i := 0; i < parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for key := range todo {
+				value, err := loader(ctx, key)
+				results <- warmupResult[K, V]{key: key, value: value, err: err}
+			}
+		}()
+	}
+	go func() {
+		defer close(todo)
+		for _, key := range keys {
+			select {
+			case todo <- key:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var errs []error
+	for result := range results {
+		if result.err != nil {
+			errs = append(errs, fmt.Errorf("SieveCache: failed to load %v: %w", result.key, result.err))
+			continue
+		}
+		insert(result.key, result.value)
+	}
+	if err := ctx.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// Warmup fills the cache with the values of keys returned by loader, calling
+// it from up to parallelism goroutines, or GOMAXPROCS if parallelism is not
+// positive, to prime the cache at startup. Values are inserted from the
+// calling goroutine, as they are loaded. Keys that fail to load are skipped,
+// and their errors are returned joined, along with the error of ctx if it
+// is canceled before all keys are loaded.
+func (c *SieveCache[K, V]) Warmup(ctx context.Context, keys []K, loader func(ctx context.Context, key K) (V, error), parallelism int) error {
+	return warmup(ctx, keys, loader, parallelism, func(key K, value V) { c.Insert(key, value) })
+}
+
+// Warmup fills the cache with the values of keys returned by loader, with
+// bounded parallelism. See SieveCache.Warmup for details.
+func (c *SyncSieveCache[K, V]) Warmup(ctx context.Context, keys []K, loader func(ctx context.Context, key K) (V, error), parallelism int) error {
+	return warmup(ctx, keys, loader, parallelism, func(key K, value V) { c.Insert(key, value) })
+}
+
+// Warmup fills the cache with the values of keys returned by loader, with
+// bounded parallelism. See SieveCache.Warmup for details.
+func (c *ShardedSieveCache[K, V]) Warmup(ctx context.Context, keys []K, loader func(ctx context.Context, key K) (V, error), parallelism int) error {
+	return warmup(ctx, keys, loader, parallelism, func(key K, value V) { c.Insert(key, value) })
+}
+
+// hottest returns the slots of up to limit entries, or all of them if limit
+// is not positive, that the hand would evict last: visited entries, then
+// the entries it reaches last. Slots are ordered from the hottest.
+func (c *SieveCache[K, V]) hottest(limit int) []int {
+	n := len(c.nodes)
+	if limit <= 0 || limit > n {
+		limit = n
+	}
+	// The hand evicts the entries that aren't visited in scan order, and
+	// only then the visited ones, whose flags it cleared on its way
+	slots := make([]int, 0, n)
+	var visited []int
+	c.forEachInEvictionOrder(func(idx int) {
+		if c.visited.Get(idx) {
+			visited = append(visited, idx)
+		} else {
+			slots = append(slots, idx)
+		}
+	})
+	slots = append(slots, visited...)
+	slices.Reverse(slots)
+	return slots[:limit]
+}
+
+// WarmFrom copies up to limit of the hottest entries of other into the
+// cache, or all of them if limit is not positive, for example to prime the
+// cache of a new instance from a snapshot of the previous one. Visited
+// entries are copied first, then those that other would evict last, and
+// their visited flags are carried over. Entries are only copied while the
+// cache has free capacity, so that the copies don't evict each other, and
+// keys that are already present are left unchanged, since their values are
+// likely fresher.
+func (c *SieveCache[K, V]) WarmFrom(other *SieveCache[K, V], limit int) {
+	if other == c {
+		return
+	}
+	for _, idx := range other.hottest(limit) {
+		node := other.nodes[idx]
+		if len(c.nodes) >= c.capacity {
+			return
+		}
+		if !c.ContainsKey(node.Key) {
+			c.mergeEntry(node.Key, node.Value, other.visited.Get(idx), nil)
+		}
+	}
+}
+
+// WarmFrom copies up to limit of the hottest entries of other into the
+// cache. See SieveCache.WarmFrom for details.
+// A snapshot of other is taken first, so the two caches are never locked at
+// the same time.
+func (c *SyncSieveCache[K, V]) WarmFrom(other *SyncSieveCache[K, V], limit int) {
+	if other == c {
+		return
+	}
+	snapshot := other.Snapshot()
+
+	c.lock()
+	defer c.unlock()
+	c.cache.WarmFrom(snapshot, limit)
+}
+
+// WarmFrom copies up to limit of the hottest entries of other into the
+// cache. See SieveCache.WarmFrom for details.
+// The limit is split evenly among the shards of other, and the two caches
+// may have different numbers of shards.
+func (c *ShardedSieveCache[K, V]) WarmFrom(other *ShardedSieveCache[K, V], limit int) {
+	if other == c {
+		return
+	}
+	defer c.invalidateAllHot()
+
+	c.reshardMutex.Lock()
+	defer c.reshardMutex.Unlock()
+	table := c.table.Load()
+	otherShards := other.table.Load().all()
+	perShard := limit
+	if limit > 0 {
+		perShard = (limit + len(otherShards) - 1) / len(otherShards)
+	}
+	copied := 0
+	for _, otherShard := range otherShards {
+		snapshot := otherShard.Snapshot()
+		slots := snapshot.hottest(perShard)
+		if limit > 0 && copied+len(slots) > limit {
+			slots = slots[:limit-copied]
+		}
+		copied += len(slots)
+		for _, idx := range slots {
+			node := snapshot.nodes[idx]
+			visited := snapshot.visited.Get(idx)
+			table.shardFor(hashKey(node.Key)).WithLock(func(shard *SieveCache[K, V]) {
+				if len(shard.nodes) < shard.capacity && !shard.ContainsKey(node.Key) {
+					shard.mergeEntry(node.Key, node.Value, visited, nil)
+				}
+			})
+		}
+	}
+}
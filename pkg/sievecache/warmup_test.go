@@ -0,0 +1,147 @@
+package sievecache
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWarmup(t *testing.T) {
+	cache, _ := NewSharded[int, int](1000)
+	keys := make([]int, 100)
+	for i := range keys {
+		keys[i] = i
+	}
+
+	var running, maxRunning atomic.Int32
+	errOdd := errors.New("odd key")
+	err := cache.Warmup(context.Background(), keys, func(_ context.Context, key int) (int, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		if key%2 == 1 {
+			return 0, errOdd
+		}
+		return key * 10, nil
+	}, 4)
+
+	if !errors.Is(err, errOdd) {
+		t.Errorf("Expected the loader errors to be returned, got %v", err)
+	}
+	if cache.Len() != 50 {
+		t.Errorf("Expected 50 loaded entries, got %d", cache.Len())
+	}
+	if value, found := cache.Get(42); !found || value != 420 {
+		t.Errorf("Expected 420, got %v (found: %v)", value, found)
+	}
+	if maxRunning.Load() > 4 {
+		t.Errorf("Expected at most 4 concurrent loads, got %d", maxRunning.Load())
+	}
+}
+
+func TestWarmupCanceled(t *testing.T) {
+	cache, _ := New[int, int](1000)
+	ctx, cancel := context.WithCancel(context.Background())
+	keys := make([]int, 1000)
+	for i := range keys {
+		keys[i] = i
+	}
+
+	err := cache.Warmup(ctx, keys, func(_ context.Context, key int) (int, error) {
+		if key == 10 {
+			cancel()
+		}
+		return key, nil
+	}, 1)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if cache.Len() == 0 || cache.Len() == len(keys) {
+		t.Errorf("Expected a partial warmup, got %d entries", cache.Len())
+	}
+}
+
+func TestWarmFrom(t *testing.T) {
+	old, _ := New[int, int](10)
+	for i := 0; i < 10; i++ {
+		old.Insert(i, i)
+	}
+	old.Get(2)
+	old.Get(5)
+
+	cache, _ := New[int, int](10)
+	cache.Insert(0, 900)
+	cache.WarmFrom(old, 4)
+
+	// Visited entries are copied first, then those the hand reaches last
+	if cache.Len() != 4 {
+		t.Fatalf("Expected 4 entries, got %d", cache.Len())
+	}
+	for _, key := range []int{0, 1, 2, 5} {
+		if !cache.ContainsKey(key) {
+			t.Errorf("Expected key %d to be copied", key)
+		}
+	}
+	visited := cache.VisitedKeys(0)
+	if len(visited) != 2 || !slices.Contains(visited, 2) || !slices.Contains(visited, 5) {
+		t.Errorf("Expected visited flags to be carried over, got %v", visited)
+	}
+	if value, _ := cache.Get(0); value != 900 {
+		t.Errorf("Expected the existing value to be kept, got %d", value)
+	}
+	if err := cache.CheckInvariants(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWarmFromKeepsHottest(t *testing.T) {
+	old, _ := New[int, int](10)
+	for i := 0; i < 10; i++ {
+		old.Insert(i, i)
+	}
+	old.Get(9)
+
+	// Only the hottest entries are copied when they don't all fit
+	cache, _ := New[int, int](3)
+	cache.WarmFrom(old, 0)
+	for _, key := range []int{9, 0, 1} {
+		if !cache.ContainsKey(key) {
+			t.Errorf("Expected key %d to be kept, got %v", key, cache.Keys())
+		}
+	}
+}
+
+func TestSyncAndShardedWarmFrom(t *testing.T) {
+	oldSync, _ := NewSync[int, int](100)
+	oldSharded, _ := NewShardedWithShards[int, int](400, 4)
+	for i := 0; i < 100; i++ {
+		oldSync.Insert(i, i)
+		oldSharded.Insert(i, i)
+	}
+
+	syncCache, _ := NewSync[int, int](100)
+	syncCache.WarmFrom(oldSync, 10)
+	if syncCache.Len() != 10 {
+		t.Errorf("Expected 10 entries, got %d", syncCache.Len())
+	}
+
+	sharded, _ := NewShardedWithShards[int, int](300, 3)
+	sharded.WarmFrom(oldSharded, 10)
+	if sharded.Len() != 10 {
+		t.Errorf("Expected 10 entries, got %d", sharded.Len())
+	}
+	sharded.WarmFrom(oldSharded, 0)
+	if sharded.Len() != 100 {
+		t.Errorf("Expected 100 entries, got %d", sharded.Len())
+	}
+	if err := sharded.CheckInvariants(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,24 @@
+/*
+Package sievedebug provides an HTTP handler to inspect and administer a
+sharded SIEVE cache from a running service, in the spirit of expvar and
+net/http/pprof.
+
+# Usage
+
+The handler serves paths relative to its mount point, so it is usually
+mounted with http.StripPrefix. It exposes internal state and can remove
+entries, so it should only be reachable through an authenticated route:
+
+	h := sievedebug.NewHandler(cache, sievedebug.Options[string]{})
+	http.Handle("/debug/cache/", requireAdmin(http.StripPrefix("/debug/cache", h)))
+
+GET / returns a JSON document with the capacity, the number of entries, the
+capacity and utilization of each shard, and a sample of the hot keys (the
+entries accessed since the eviction hand last passed them). Hot keys can be
+redacted with Options.RedactKey, so that sensitive keys aren't revealed.
+
+POST /invalidate?key=... (or DELETE) removes the given keys, which can be
+repeated, and responds with the number of entries removed. Invalidation is
+disabled when Options.ReadOnly is set.
+*/
+package sievedebug
@@ -0,0 +1,176 @@
+package sievedebug
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/jedisct1/go-sieve-cache/pkg/sievecache"
+)
+
+// DefaultHotKeys is the number of hot keys reported when Options.HotKeys is 0.
+const DefaultHotKeys = 100
+
+// Options configures a debug handler.
+type Options[K comparable] struct {
+	// ParseKey converts a key from a request into a cache key.
+	// It is required to invalidate entries unless K is string.
+	ParseKey func(string) (K, error)
+
+	// ReadOnly disables invalidation.
+	ReadOnly bool
+
+	// HotKeys is the maximum number of hot keys reported.
+	// If 0, DefaultHotKeys is used; if negative, none are reported.
+	HotKeys int
+
+	// RedactKey, if set, replaces the hot keys that are reported, for
+	// example with sievecache.RedactHMAC, so that sensitive keys aren't
+	// revealed.
+	RedactKey sievecache.KeyRedactor
+}
+
+// Shard describes a shard of the cache.
+type Shard struct {
+	Capacity    int     `json:"capacity"`
+	Len         int     `json:"len"`
+	Utilization float64 `json:"utilization"`
+}
+
+// Status is the document served by GET /.
+type Status struct {
+	Capacity    int      `json:"capacity"`
+	Len         int      `json:"len"`
+	Utilization float64  `json:"utilization"`
+	Shards      []Shard  `json:"shards"`
+	HotKeys     []string `json:"hot_keys"`
+}
+
+// handler implements the debug endpoints for a cache.
+type handler[K comparable, V any] struct {
+	cache *sievecache.ShardedSieveCache[K, V]
+	opts  Options[K]
+}
+
+// NewHandler returns an http.Handler serving the status of cache and, unless
+// opts.ReadOnly is set, invalidating its entries on request.
+func NewHandler[K comparable, V any](cache *sievecache.ShardedSieveCache[K, V], opts Options[K]) http.Handler {
+	if opts.ParseKey == nil {
+		opts.ParseKey = parseStringKey[K]
+	}
+	if opts.HotKeys == 0 {
+		opts.HotKeys = DefaultHotKeys
+	}
+	h := &handler[K, V]{cache: cache, opts: opts}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", h.handleStatus)
+	mux.HandleFunc("/invalidate", h.handleInvalidate)
+	return mux
+}
+
+// parseStringKey is the default key parser. It only supports string keys.
+func parseStringKey[K comparable](s string) (K, error) {
+	key, ok := any(s).(K)
+	if !ok {
+		return key, errors.New("sievedebug: no key parser configured")
+	}
+	return key, nil
+}
+
+// handleStatus serves GET /.
+func (h *handler[K, V]) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, h.status())
+}
+
+// status collects the current state of the cache.
+func (h *handler[K, V]) status() Status {
+	st := Status{
+		Shards:  []Shard{},
+		HotKeys: []string{},
+	}
+	for i := 0; i < h.cache.NumShards(); i++ {
+		shard := h.cache.GetShardByIndex(i)
+		if shard == nil {
+			// The cache was resharded meanwhile
+			break
+		}
+		s := Shard{Capacity: shard.Capacity(), Len: shard.Len()}
+		s.Utilization = utilization(s.Len, s.Capacity)
+		st.Capacity += s.Capacity
+		st.Len += s.Len
+		st.Shards = append(st.Shards, s)
+	}
+	st.Utilization = utilization(st.Len, st.Capacity)
+	if h.opts.HotKeys > 0 {
+		for _, key := range h.cache.VisitedKeys(h.opts.HotKeys) {
+			s := sievecache.ToString(key)
+			if h.opts.RedactKey != nil {
+				s = h.opts.RedactKey(s)
+			}
+			st.HotKeys = append(st.HotKeys, s)
+		}
+	}
+	return st
+}
+
+// handleInvalidate serves POST and DELETE /invalidate?key=...
+func (h *handler[K, V]) handleInvalidate(w http.ResponseWriter, r *http.Request) {
+	if h.opts.ReadOnly {
+		http.Error(w, "invalidation is disabled", http.StatusForbidden)
+		return
+	}
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		w.Header().Set("Allow", "POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	raw := r.URL.Query()["key"]
+	if len(raw) == 0 {
+		http.Error(w, "missing key", http.StatusBadRequest)
+		return
+	}
+	keys := make([]K, 0, len(raw))
+	for _, s := range raw {
+		key, err := h.opts.ParseKey(s)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid key %q: %v", s, err), http.StatusBadRequest)
+			return
+		}
+		keys = append(keys, key)
+	}
+
+	removed := 0
+	for _, key := range keys {
+		if _, found := h.cache.Remove(key); found {
+			removed++
+		}
+	}
+	writeJSON(w, struct {
+		Removed int `json:"removed"`
+	}{removed})
+}
+
+// utilization returns n/capacity, or 0 if capacity is 0.
+func utilization(n, capacity int) float64 {
+	if capacity == 0 {
+		return 0
+	}
+	return float64(n) / float64(capacity)
+}
+
+// writeJSON writes v as a JSON response.
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,121 @@
+package sievedebug
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/jedisct1/go-sieve-cache/pkg/sievecache"
+)
+
+func TestStatus(t *testing.T) {
+	cache, _ := sievecache.NewShardedWithShards[string, int](400, 4)
+	for i := 0; i < 100; i++ {
+		cache.Insert(strconv.Itoa(i), i)
+	}
+	cache.Get("7")
+	cache.Get("42")
+
+	mux := http.NewServeMux()
+	mux.Handle("/debug/cache/", http.StripPrefix("/debug/cache", NewHandler(cache, Options[string]{})))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/cache/", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+
+	var st Status
+	if err := json.Unmarshal(rec.Body.Bytes(), &st); err != nil {
+		t.Fatal(err)
+	}
+	if st.Capacity != 400 || st.Len != 100 || st.Utilization != 0.25 {
+		t.Errorf("Unexpected totals: %+v", st)
+	}
+	if len(st.Shards) != 4 {
+		t.Fatalf("Expected 4 shards, got %d", len(st.Shards))
+	}
+	sum := 0
+	for _, s := range st.Shards {
+		sum += s.Len
+		if s.Capacity != 100 {
+			t.Errorf("Expected a shard capacity of 100, got %d", s.Capacity)
+		}
+	}
+	if sum != 100 {
+		t.Errorf("Expected shard lengths to add up to 100, got %d", sum)
+	}
+	if len(st.HotKeys) != 2 {
+		t.Errorf("Expected 2 hot keys, got %v", st.HotKeys)
+	}
+
+	h := NewHandler(cache, Options[string]{RedactKey: sievecache.RedactSHA256})
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	st = Status{}
+	json.Unmarshal(rec.Body.Bytes(), &st)
+	for _, key := range st.HotKeys {
+		if key != sievecache.RedactSHA256("7") && key != sievecache.RedactSHA256("42") {
+			t.Errorf("Expected redacted hot keys, got %q", key)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/cache/nope", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown path, got %d", rec.Code)
+	}
+}
+
+func TestInvalidate(t *testing.T) {
+	cache, _ := sievecache.NewSharded[int, string](100)
+	cache.Insert(1, "one")
+	cache.Insert(2, "two")
+	cache.Insert(3, "three")
+
+	h := NewHandler(cache, Options[int]{ParseKey: strconv.Atoi})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/invalidate?key=1&key=3&key=4", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+	var res struct{ Removed int }
+	json.Unmarshal(rec.Body.Bytes(), &res)
+	if res.Removed != 2 {
+		t.Errorf("Expected 2 removed entries, got %d", res.Removed)
+	}
+	if cache.Len() != 1 || !cache.ContainsKey(2) {
+		t.Error("Expected only key 2 to remain")
+	}
+
+	for _, tc := range []struct {
+		method, target string
+		code           int
+	}{
+		{http.MethodGet, "/invalidate?key=2", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/invalidate", http.StatusBadRequest},
+		{http.MethodPost, "/invalidate?key=two", http.StatusBadRequest},
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.target, nil))
+		if rec.Code != tc.code {
+			t.Errorf("%s %s: expected %d, got %d", tc.method, tc.target, tc.code, rec.Code)
+		}
+	}
+
+	// Without a key parser, non-string keys can't be invalidated
+	h = NewHandler(cache, Options[int]{})
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/invalidate?key=2", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without a key parser, got %d", rec.Code)
+	}
+
+	h = NewHandler(cache, Options[int]{ParseKey: strconv.Atoi, ReadOnly: true})
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/invalidate?key=2", nil))
+	if rec.Code != http.StatusForbidden || !cache.ContainsKey(2) {
+		t.Errorf("Expected invalidation to be forbidden, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,37 @@
+/*
+Package sievepeer lets multiple processes share a distributed cache built on
+SIEVE, in the style of groupcache.
+
+# Overview
+
+Processes form a peer group. Every key is owned by exactly one peer, chosen by
+consistent hashing, so adding or removing a peer only moves the keys of that
+peer. When a value isn't cached locally:
+
+ 1. If another peer owns the key, the value is fetched from that peer and kept
+    in a small "hot" cache, so that popular keys don't always cross the network.
+ 2. If the local process owns the key, or the owner can't be reached, the value
+    is loaded from the source of truth with the group's Loader and cached.
+
+Concurrent requests for the same key are coalesced, so a key is loaded at most
+once at a time per process, and at most once across the group when all peers
+are reachable.
+
+# Usage
+
+	pool := sievepeer.NewHTTPPool("http://10.0.0.1:8080", sievepeer.HTTPPoolOptions{})
+	pool.Set("http://10.0.0.1:8080", "http://10.0.0.2:8080", "http://10.0.0.3:8080")
+	http.Handle(sievepeer.DefaultBasePath, pool)
+
+	thumbnails, err := pool.NewGroup("thumbnails", sievepeer.LoaderFunc(
+		func(ctx context.Context, key string) ([]byte, error) {
+			return renderThumbnail(ctx, key)
+		}), sievepeer.GroupOptions{Capacity: 10000})
+
+	data, err := thumbnails.Get(ctx, "photo-1234")
+
+Values are immutable once loaded: there is no way to update a value across the
+group, only to let it be evicted. Values returned by Get are copies, and can be
+modified by the caller.
+*/
+package sievepeer
@@ -0,0 +1,166 @@
+package sievepeer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+
+	"github.com/jedisct1/go-sieve-cache/pkg/sievecache"
+)
+
+// Loader loads values from the source of truth.
+type Loader interface {
+	// Load returns the value of key. It is called by the peer that owns key,
+	// or by any peer if the owner can't be reached.
+	Load(ctx context.Context, key string) ([]byte, error)
+}
+
+// LoaderFunc is a function that implements Loader.
+type LoaderFunc func(ctx context.Context, key string) ([]byte, error)
+
+// Load calls f(ctx, key).
+func (f LoaderFunc) Load(ctx context.Context, key string) ([]byte, error) {
+	return f(ctx, key)
+}
+
+// Peer is a remote member of a peer group.
+type Peer interface {
+	// Fetch returns the value of key in the named group, as served by the peer.
+	Fetch(ctx context.Context, group string, key string) ([]byte, error)
+}
+
+// PeerPicker chooses the peer that owns a key.
+type PeerPicker interface {
+	// PickPeer returns the peer that owns key, or false if the local
+	// process owns it.
+	PickPeer(key string) (Peer, bool)
+}
+
+// GroupOptions configures a Group.
+type GroupOptions struct {
+	// Capacity is the number of values owned by this process that are cached.
+	// If 0, values are loaded or fetched on every request.
+	Capacity int
+	// HotCapacity is the number of values owned by other peers that are cached.
+	// Defaults to Capacity/8, with a minimum of 1 unless Capacity is 0.
+	HotCapacity int
+}
+
+// Group is a named cache whose keys are distributed across a peer group.
+// It is safe for concurrent use.
+type Group struct {
+	name   string
+	loader Loader
+	peers  PeerPicker
+
+	// Values of the keys owned by this process
+	main *sievecache.ShardedSieveCache[string, []byte]
+	// Values of popular keys owned by other peers
+	hot *sievecache.ShardedSieveCache[string, []byte]
+
+	flights flightGroup
+}
+
+// NewGroup creates a group that loads missing values with loader and uses
+// peers to find the owner of a key. If peers is nil, every key is owned by
+// the local process.
+func NewGroup(name string, loader Loader, peers PeerPicker, opts GroupOptions) (*Group, error) {
+	if loader == nil {
+		return nil, errors.New("sievepeer: loader must not be nil")
+	}
+	if opts.HotCapacity <= 0 && opts.Capacity > 0 {
+		opts.HotCapacity = max(1, opts.Capacity/8)
+	}
+	main, err := sievecache.NewSharded[string, []byte](opts.Capacity)
+	if err != nil {
+		return nil, err
+	}
+	hot, err := sievecache.NewSharded[string, []byte](opts.HotCapacity)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Group{
+		name:   name,
+		loader: loader,
+		peers:  peers,
+		main:   main,
+		hot:    hot,
+	}, nil
+}
+
+// Name returns the name of the group.
+func (g *Group) Name() string {
+	return g.name
+}
+
+// Get returns the value of key, from the local caches, from the peer that
+// owns key, or from the loader.
+func (g *Group) Get(ctx context.Context, key string) ([]byte, error) {
+	if value, ok := g.lookup(key); ok {
+		return bytes.Clone(value), nil
+	}
+
+	value, err := g.flights.do(key, func() ([]byte, error) {
+		// Another call may have filled the caches while this one was waiting
+		if value, ok := g.lookup(key); ok {
+			return value, nil
+		}
+		if g.peers != nil {
+			if peer, ok := g.peers.PickPeer(key); ok {
+				value, err := peer.Fetch(ctx, g.name, key)
+				if err == nil {
+					g.hot.Insert(key, value)
+					return value, nil
+				}
+				// Load locally rather than fail if the owner is unreachable
+			}
+		}
+		return g.load(ctx, key)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return bytes.Clone(value), nil
+}
+
+// getOwned returns the value of a key owned by the local process, as
+// requested by another peer. The request is never forwarded, so that peers
+// that disagree about the owner of a key can't loop.
+func (g *Group) getOwned(ctx context.Context, key string) ([]byte, error) {
+	if value, ok := g.main.Get(key); ok {
+		return value, nil
+	}
+	return g.flights.do(key, func() ([]byte, error) {
+		if value, ok := g.main.Get(key); ok {
+			return value, nil
+		}
+		return g.load(ctx, key)
+	})
+}
+
+// lookup returns the value of key if it is cached locally.
+func (g *Group) lookup(key string) ([]byte, bool) {
+	if value, ok := g.main.Get(key); ok {
+		return value, true
+	}
+	return g.hot.Get(key)
+}
+
+// load loads the value of key with the loader and caches it.
+func (g *Group) load(ctx context.Context, key string) ([]byte, error) {
+	value, err := g.loader.Load(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	// Keep a private copy, the loader may reuse its buffer
+	value = bytes.Clone(value)
+	g.main.Insert(key, value)
+	return value, nil
+}
+
+// Remove removes key from the local caches. Other peers are not notified.
+func (g *Group) Remove(key string) {
+	g.main.Remove(key)
+	g.hot.Remove(key)
+}
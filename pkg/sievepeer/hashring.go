@@ -0,0 +1,49 @@
+package sievepeer
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+)
+
+// hashRing maps keys to peers with consistent hashing.
+// Every peer is placed on the ring at several points, so that keys are spread
+// evenly, and removing a peer only moves the keys it owned.
+type hashRing struct {
+	// Sorted points on the ring
+	hashes []uint32
+	// Peer placed at each point
+	peers map[uint32]string
+}
+
+// newHashRing creates a ring with replicas points per peer.
+func newHashRing(replicas int, peers []string) *hashRing {
+	r := &hashRing{
+		hashes: make([]uint32, 0, replicas*len(peers)),
+		peers:  make(map[uint32]string, replicas*len(peers)),
+	}
+	for _, peer := range peers {
+		for i := 0; i < replicas; i++ {
+			hash := crc32.ChecksumIEEE([]byte(strconv.Itoa(i) + peer))
+			r.hashes = append(r.hashes, hash)
+			r.peers[hash] = peer
+		}
+	}
+	sort.Slice(r.hashes, func(i, j int) bool { return r.hashes[i] < r.hashes[j] })
+	return r
+}
+
+// get returns the peer that owns key, or an empty string if the ring is empty.
+func (r *hashRing) get(key string) string {
+	if len(r.hashes) == 0 {
+		return ""
+	}
+	hash := crc32.ChecksumIEEE([]byte(key))
+
+	// The owner is the first point at or after the hash, wrapping around
+	idx := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= hash })
+	if idx == len(r.hashes) {
+		idx = 0
+	}
+	return r.peers[r.hashes[idx]]
+}
@@ -0,0 +1,190 @@
+package sievepeer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// DefaultBasePath is the path under which an HTTPPool serves peer requests by default.
+const DefaultBasePath = "/_sievepeer/"
+
+// defaultReplicas is the default number of points per peer on the hash ring.
+const defaultReplicas = 50
+
+// HTTPPoolOptions configures an HTTPPool.
+// Zero values are replaced with sensible defaults.
+type HTTPPoolOptions struct {
+	// BasePath is the path prefix of peer requests. Defaults to DefaultBasePath.
+	BasePath string
+	// Replicas is the number of points per peer on the consistent hash ring.
+	// Defaults to 50.
+	Replicas int
+	// Client is used to fetch values from other peers. Defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// HTTPPool is a peer group whose members talk to each other over HTTP.
+// It picks the owner of each key, and serves the keys owned by the local
+// process to the other peers as an http.Handler.
+type HTTPPool struct {
+	self string
+	opts HTTPPoolOptions
+
+	mutex  sync.RWMutex
+	ring   *hashRing
+	peers  map[string]*httpPeer
+	groups map[string]*Group
+}
+
+// NewHTTPPool creates a pool for the local process, reachable by the other
+// peers at the base URL self (for example "http://10.0.0.1:8080").
+// The pool must be registered with an HTTP server under its base path.
+func NewHTTPPool(self string, opts HTTPPoolOptions) *HTTPPool {
+	if opts.BasePath == "" {
+		opts.BasePath = DefaultBasePath
+	}
+	if !strings.HasSuffix(opts.BasePath, "/") {
+		opts.BasePath += "/"
+	}
+	if opts.Replicas <= 0 {
+		opts.Replicas = defaultReplicas
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+
+	return &HTTPPool{
+		self:   strings.TrimSuffix(self, "/"),
+		opts:   opts,
+		ring:   newHashRing(opts.Replicas, nil),
+		groups: make(map[string]*Group),
+	}
+}
+
+// Set replaces the members of the peer group with the given base URLs,
+// which should include the local process.
+func (p *HTTPPool) Set(peers ...string) {
+	normalized := make([]string, len(peers))
+	httpPeers := make(map[string]*httpPeer, len(peers))
+	for i, peer := range peers {
+		peer = strings.TrimSuffix(peer, "/")
+		normalized[i] = peer
+		httpPeers[peer] = &httpPeer{
+			baseURL: peer + p.opts.BasePath,
+			client:  p.opts.Client,
+		}
+	}
+	ring := newHashRing(p.opts.Replicas, normalized)
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.ring = ring
+	p.peers = httpPeers
+}
+
+// NewGroup creates a group that uses this pool to find the owner of each key,
+// and registers it so that other peers can fetch its values.
+// Group names must be unique within a pool.
+func (p *HTTPPool) NewGroup(name string, loader Loader, opts GroupOptions) (*Group, error) {
+	group, err := NewGroup(name, loader, p, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if _, exists := p.groups[name]; exists {
+		return nil, fmt.Errorf("sievepeer: duplicate group name %q", name)
+	}
+	p.groups[name] = group
+	return group, nil
+}
+
+// PickPeer returns the peer that owns key, or false if the local process owns
+// it or no peers have been set.
+func (p *HTTPPool) PickPeer(key string) (Peer, bool) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	owner := p.ring.get(key)
+	if owner == "" || owner == p.self {
+		return nil, false
+	}
+	return p.peers[owner], true
+}
+
+// ServeHTTP serves the values of the keys owned by the local process to other peers.
+// Requests are of the form GET <base path><group>/<key>, with both parts
+// path-escaped.
+func (p *HTTPPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	path := r.URL.EscapedPath()
+	if !strings.HasPrefix(path, p.opts.BasePath) {
+		http.NotFound(w, r)
+		return
+	}
+	escapedGroup, escapedKey, ok := strings.Cut(path[len(p.opts.BasePath):], "/")
+	if !ok {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	groupName, err1 := url.PathUnescape(escapedGroup)
+	key, err2 := url.PathUnescape(escapedKey)
+	if err1 != nil || err2 != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	p.mutex.RLock()
+	group := p.groups[groupName]
+	p.mutex.RUnlock()
+	if group == nil {
+		http.Error(w, "no such group: "+groupName, http.StatusNotFound)
+		return
+	}
+
+	value, err := group.getOwned(r.Context(), key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(value)
+}
+
+// httpPeer fetches values from a remote HTTPPool.
+type httpPeer struct {
+	baseURL string
+	client  *http.Client
+}
+
+// Fetch returns the value of key in the named group, as served by the peer.
+func (h *httpPeer) Fetch(ctx context.Context, group string, key string) ([]byte, error) {
+	u := h.baseURL + url.PathEscape(group) + "/" + url.PathEscape(key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("sievepeer: peer returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	value, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("sievepeer: reading response: %w", err)
+	}
+	return value, nil
+}
@@ -0,0 +1,214 @@
+package sievepeer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHashRing(t *testing.T) {
+	peers := []string{"a", "b", "c", "d"}
+	ring := newHashRing(defaultReplicas, peers)
+
+	owners := make(map[string]string)
+	counts := make(map[string]int)
+	for i := 0; i < 10000; i++ {
+		key := fmt.Sprintf("key%d", i)
+		owners[key] = ring.get(key)
+		counts[owners[key]]++
+	}
+	for _, peer := range peers {
+		if counts[peer] < 1000 {
+			t.Errorf("Peer %s owns only %d keys out of 10000", peer, counts[peer])
+		}
+	}
+
+	// Removing a peer only moves the keys it owned
+	smaller := newHashRing(defaultReplicas, []string{"a", "b", "d"})
+	for key, owner := range owners {
+		if owner != "c" && smaller.get(key) != owner {
+			t.Fatalf("Key %s moved from %s to %s", key, owner, smaller.get(key))
+		}
+	}
+
+	if newHashRing(defaultReplicas, nil).get("key") != "" {
+		t.Error("Expected no owner for an empty ring")
+	}
+}
+
+func TestFlightGroup(t *testing.T) {
+	var g flightGroup
+	var calls atomic.Int32
+	release := make(chan struct{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := g.do("key", func() ([]byte, error) {
+				calls.Add(1)
+				<-release
+				return []byte("value"), nil
+			})
+			if err != nil || string(value) != "value" {
+				t.Errorf("Unexpected result %q, %v", value, err)
+			}
+		}()
+	}
+	// Let the goroutines pile up on the first call
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n < 1 || n > 10 {
+		t.Errorf("Unexpected number of calls: %d", n)
+	}
+	if _, err := g.do("key", func() ([]byte, error) { return nil, errors.New("fail") }); err == nil {
+		t.Error("Expected the error of a new call")
+	}
+}
+
+// testPeer is a member of a test peer group.
+type testPeer struct {
+	server   *httptest.Server
+	pool     *HTTPPool
+	group    *Group
+	loads    atomic.Int32
+	requests atomic.Int32
+}
+
+// newTestCluster starts n peers serving a group named "test", whose loader
+// returns "value:<key>" and fails for keys starting with "error".
+func newTestCluster(t *testing.T, n int) []*testPeer {
+	peers := make([]*testPeer, n)
+	urls := make([]string, n)
+	for i := range peers {
+		peer := &testPeer{}
+		peer.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			peer.requests.Add(1)
+			peer.pool.ServeHTTP(w, r)
+		}))
+		t.Cleanup(peer.server.Close)
+		peer.pool = NewHTTPPool(peer.server.URL, HTTPPoolOptions{})
+		group, err := peer.pool.NewGroup("test", LoaderFunc(func(ctx context.Context, key string) ([]byte, error) {
+			peer.loads.Add(1)
+			if strings.HasPrefix(key, "error") {
+				return nil, errors.New("load failed")
+			}
+			return []byte("value:" + key), nil
+		}), GroupOptions{Capacity: 1000})
+		if err != nil {
+			t.Fatalf("NewGroup failed: %v", err)
+		}
+		peer.group = group
+		peers[i] = peer
+		urls[i] = peer.server.URL
+	}
+	for _, peer := range peers {
+		peer.pool.Set(urls...)
+	}
+	return peers
+}
+
+func TestHTTPPool(t *testing.T) {
+	peers := newTestCluster(t, 3)
+	ctx := context.Background()
+
+	// Every peer gets every key, but each key is loaded once, by its owner
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key/%d ?", i)
+		for _, peer := range peers {
+			value, err := peer.group.Get(ctx, key)
+			if err != nil || string(value) != "value:"+key {
+				t.Fatalf("Get(%q): unexpected result %q, %v", key, value, err)
+			}
+		}
+	}
+	total := 0
+	for i, peer := range peers {
+		loads := int(peer.loads.Load())
+		if loads == 0 {
+			t.Errorf("Peer %d didn't own any key", i)
+		}
+		total += loads
+	}
+	if total != 100 {
+		t.Errorf("Expected 100 loads across the group, got %d", total)
+	}
+
+	// Values fetched from other peers are kept in the hot cache
+	requests := 0
+	for _, peer := range peers {
+		requests += int(peer.requests.Load())
+	}
+	for i := 0; i < 100; i++ {
+		for _, peer := range peers {
+			peer.group.Get(ctx, fmt.Sprintf("key/%d ?", i))
+		}
+	}
+	after := 0
+	for _, peer := range peers {
+		after += int(peer.requests.Load())
+	}
+	if after != requests {
+		t.Errorf("Expected cached values to be served locally, got %d more requests", after-requests)
+	}
+
+	// Loader errors are reported, even through a peer
+	for _, peer := range peers {
+		if _, err := peer.group.Get(ctx, "error-key"); err == nil {
+			t.Error("Expected the loader error")
+		}
+	}
+
+	if _, err := peers[0].pool.NewGroup("test", LoaderFunc(nil), GroupOptions{Capacity: 10}); err == nil {
+		t.Error("Expected an error for a duplicate group")
+	}
+}
+
+func TestHTTPPoolUnreachablePeer(t *testing.T) {
+	peers := newTestCluster(t, 2)
+	peers[1].server.Close()
+
+	// Keys owned by the unreachable peer are loaded locally
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprintf("key%d", i)
+		value, err := peers[0].group.Get(context.Background(), key)
+		if err != nil || string(value) != "value:"+key {
+			t.Fatalf("Get(%q): unexpected result %q, %v", key, value, err)
+		}
+	}
+	if n := peers[0].loads.Load(); n != 20 {
+		t.Errorf("Expected 20 local loads, got %d", n)
+	}
+}
+
+func TestGroupReturnsCopies(t *testing.T) {
+	group, err := NewGroup("copies", LoaderFunc(func(ctx context.Context, key string) ([]byte, error) {
+		return []byte("value"), nil
+	}), nil, GroupOptions{Capacity: 10})
+	if err != nil {
+		t.Fatalf("NewGroup failed: %v", err)
+	}
+
+	value, _ := group.Get(context.Background(), "key")
+	value[0] = 'X'
+	if again, _ := group.Get(context.Background(), "key"); string(again) != "value" {
+		t.Errorf("Expected the cached value to be unaffected, got %q", again)
+	}
+
+	if _, err := NewGroup("nil", nil, nil, GroupOptions{Capacity: 10}); err == nil {
+		t.Error("Expected an error for a nil loader")
+	}
+	if _, err := NewGroup("negative", LoaderFunc(nil), nil, GroupOptions{Capacity: -1}); err == nil {
+		t.Error("Expected an error for a negative capacity")
+	}
+}
@@ -0,0 +1,51 @@
+package sievepeer
+
+import (
+	"errors"
+	"sync"
+)
+
+// errLoadPanicked is returned to the callers waiting for a load that panicked.
+var errLoadPanicked = errors.New("sievepeer: load panicked")
+
+// call is an in-flight or completed load.
+type call struct {
+	wg    sync.WaitGroup
+	value []byte
+	err   error
+}
+
+// flightGroup coalesces concurrent loads of the same key.
+type flightGroup struct {
+	mutex sync.Mutex
+	calls map[string]*call
+}
+
+// do calls fn and returns its results, unless a call for the same key is
+// already in flight, in which case it waits for it and returns its results.
+func (g *flightGroup) do(key string, fn func() ([]byte, error)) ([]byte, error) {
+	g.mutex.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*call)
+	}
+	if c, ok := g.calls[key]; ok {
+		g.mutex.Unlock()
+		c.wg.Wait()
+		return c.value, c.err
+	}
+	c := &call{}
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.mutex.Unlock()
+
+	// Waiters must be released even if fn panics
+	c.err = errLoadPanicked
+	defer func() {
+		c.wg.Done()
+		g.mutex.Lock()
+		delete(g.calls, key)
+		g.mutex.Unlock()
+	}()
+	c.value, c.err = fn()
+	return c.value, c.err
+}